@@ -1,69 +1,227 @@
 package app
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"todo/internal/auth"
 	"todo/internal/db"
+	"todo/internal/events"
 	"todo/internal/handlers"
+	"todo/internal/logging"
+	"todo/internal/metrics"
+	"todo/internal/notify"
+	"todo/internal/ratelimit"
+	"todo/internal/storage"
+	"todo/internal/tenant"
+	"todo/internal/tracing"
+	"todo/internal/webhook"
 	"todo/pkg/config"
 )
 
+const (
+	eventsReplaySize = 1000
+	eventsHeartbeat  = 15 * time.Second
+)
+
 type App struct {
-	handler *handlers.Handler
+	handler   *handlers.Handler
+	auth      *handlers.AuthHandler
+	reminders *notify.ReminderDispatcher
+	digest    *notify.OverdueDigest
+	mentions  *notify.MentionNotifier
+	webhooks  *webhook.Dispatcher
+	events    *events.Bus
+	hub       *events.Hub
+	ws        *handlers.WSHandler
+	tenants   *tenant.Manager
+	admin     *handlers.WorkspaceHandler
+	files     *handlers.AttachmentHandler
+	transfer  *handlers.TransferHandler
+	storage   *storage.Store
+	wg        sync.WaitGroup
+	limiter   *ratelimit.Limiter
+
+	// config - действующие настройки. Reload заменяет их целиком, поэтому читать их нужно
+	// через Load, а не сохранять ссылки на отдельные секции.
+	config         atomic.Pointer[config.Config]
+	reloadMu       sync.Mutex
+	configVersion  int64
+	pendingRestart []string
+	certificate    atomic.Pointer[tls.Certificate]
+	jobsTicker     atomic.Pointer[time.Ticker]
+
+	// metricsToken, если задан, требуется в заголовке Authorization: Bearer для GET /metrics.
+	metricsToken string
+
+	repository        *db.TaskRepository
+	startedAt         time.Time
+	shuttingDown      atomic.Bool
+	backgroundStarted atomic.Int64
+	overdueChecked    atomic.Int64
 }
 
-func NewApp() (*App, error) {
-	a := &App{}
+// NewApp собирает сервис по настройкам cfg (см. config.Loader).
+func NewApp(cfg *config.Config) (*App, error) {
+	a := &App{startedAt: time.Now(), configVersion: 1}
+	a.config.Store(cfg)
 	err := a.initConfig()
 
 	if err != nil {
 		return nil, err
 	}
 
-	repository, err := db.TaskRepositoryInit()
+	repository, err := db.TaskRepositoryInit(cfg.Database, cfg.Workflow)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Server.TLSCert != "" {
+		if err := a.loadCertificate(cfg.Server); err != nil {
+			return nil, err
+		}
+	}
+	a.limiter = ratelimit.New(cfg.RateLimit.RPS, cfg.RateLimit.Burst)
+
+	bus := events.NewBus()
+	handler := handlers.NewHandler(repository, bus)
+	handler.AutoCompleteChecklist = cfg.Tasks.ChecklistAutoComplete
+	handler.TimerAutoStop = time.Duration(cfg.Tasks.TimerAutoStopHours) * time.Hour
 
+	authHandler, err := handlers.AuthHandlerFromConfig(repository, cfg.Auth, cfg.JWT)
 	if err != nil {
 		return nil, err
 	}
 
-	handler := handlers.NewHandler(repository)
+	if username := cfg.Auth.AdminUsername; username != "" {
+		if err := authHandler.EnsureAdmin(username, cfg.Auth.AdminPassword); err != nil {
+			return nil, err
+		}
+	}
+
+	webhooks := webhook.DispatcherFromConfig(repository, cfg.Webhooks)
+	bus.Subscribe(webhooks.Enqueue)
+
+	hub := events.NewHub(eventsReplaySize)
+	bus.Subscribe(hub.Publish)
+
+	// Напоминания, сводки и упоминания доставляются в одни и те же каналы
+	notifiers, err := notify.NotifiersFromConfig(cfg.Notify)
+	if err != nil {
+		return nil, err
+	}
+	reminders := notify.ReminderDispatcherFromConfig(repository, notifiers, cfg.Reminders)
+	a.digest = notify.NewOverdueDigest(repository, notifiers)
+
+	mentions := notify.NewMentionNotifier(repository, notifiers)
+	bus.Subscribe(mentions.Enqueue)
+	a.mentions = mentions
+
+	store, err := storage.StoreFromConfig(repository, cfg.Attachments)
+	if err != nil {
+		return nil, err
+	}
+	a.storage = store
+	a.files = handlers.AttachmentHandlerFromConfig(handler, store, cfg.Attachments)
+	if a.transfer, err = handlers.TransferHandlerFromConfig(handler, cfg.Transfer); err != nil {
+		return nil, err
+	}
+
+	tenants := tenant.NewManager(repository, cfg.Workspaces)
+	a.tenants = tenants
+	a.reminders = reminders
+
+	err = a.eachReminders(context.Background(), func(reminders *notify.ReminderDispatcher) error {
+		return reminders.CatchUp(time.Now())
+	})
+	if err != nil {
+		tenants.Close()
+		return nil, err
+	}
 
 	a.handler = handler
+	a.auth = authHandler
+	a.webhooks = webhooks
+	a.events = bus
+	a.hub = hub
+	a.ws = handlers.NewWSHandler(handler, hub)
+	a.admin = handlers.NewWorkspaceHandler(tenants, repository)
+	a.metricsToken = cfg.Metrics.Token
+	a.repository = repository
+	a.registerMetrics(repository)
 	return a, nil
 }
 
+// initConfig настраивает журнал и трассировку по секциям log и tracing.
 func (a *App) initConfig() error {
-	nodeEnv := os.Getenv("NODE_ENV")
-	if nodeEnv != "DOCKER" {
-		err := config.Load(".env")
-		if err != nil {
-			return err
-		}
-		log.Println("Configs are inited")
+	cfg := a.config.Load()
+	logger, err := logging.FromConfig(cfg.Log)
+	if err != nil {
+		return err
 	}
+	slog.SetDefault(logger)
+
+	tracer, err := tracing.FromConfig(cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	tracing.SetDefault(tracer)
+	slog.Info("configs are inited", "log_level", logging.Level(), "tracing", tracer.Enabled())
 	return nil
 }
 
-func (a *App) StartBackgroundTask(stopChan <-chan os.Signal) {
-	ticker := time.NewTicker(60 * time.Second)
+// StartBackgroundTask запускает фоновые задачи. Они останавливаются при отмене ctx,
+// дождаться их завершения можно через Wait.
+func (a *App) StartBackgroundTask(ctx context.Context) {
+	ctx = auth.SystemContext(ctx)
+	ticker := time.NewTicker(a.config.Load().Jobs.Interval)
+	a.jobsTicker.Store(ticker)
+	a.backgroundStarted.Store(time.Now().UnixNano())
+
+	a.wg.Add(5)
+	go func() {
+		defer a.wg.Done()
+		a.webhooks.Run(logging.With(ctx, "job", "webhooks"))
+	}()
+
+	go func() {
+		defer a.wg.Done()
+		a.mentions.Run(logging.With(ctx, "job", "mentions"))
+	}()
+
+	go func() {
+		defer a.wg.Done()
+		a.storage.Run(logging.With(ctx, "job", "attachments"))
+	}()
+
+	go func() {
+		defer a.wg.Done()
+		a.auth.WatchKeys(logging.With(ctx, "job", "jwt-keys"))
+	}()
 
 	go func() {
+		defer a.wg.Done()
 		defer ticker.Stop()
 
+		// Пропущенные за время простоя напоминания отправляем сразу при старте
+		a.dispatchReminders(logging.With(ctx, "job", "reminders"))
+
 		for {
 			select {
 			case <-ticker.C:
-				log.Println("Checking for overdue tasks...")
-				count, err := a.handler.UpdateOverdueTasks()
-				if err != nil {
-					log.Println("Error checking overdue tasks:", err)
-				}
-
-				log.Println("Count of overdue tasks: ", count)
-			case sig := <-stopChan:
-				log.Printf("Received signal: %v. Stopping background task.", sig)
+				a.checkOverdue(logging.With(ctx, "job", "overdue"))
+				a.dispatchReminders(logging.With(ctx, "job", "reminders"))
+				a.stopStaleTimers(logging.With(ctx, "job", "timers"))
+			case <-ctx.Done():
+				logging.FromContext(ctx).Info("stopping background task")
 				return
 			}
 		}
@@ -71,35 +229,310 @@ func (a *App) StartBackgroundTask(stopChan <-chan os.Signal) {
 
 }
 
-func (a *App) StartServer() *http.Server {
+// checkOverdue отмечает просроченные задачи во всех пространствах и рассылает
+// исполнителям сводки по задачам, ставшим просроченными.
+func (a *App) checkOverdue(ctx context.Context) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "job overdue", tracing.KindInternal)
+	defer span.End()
+	logger := logging.FromContext(ctx)
+	logger.Debug("checking for overdue tasks")
+	var overdue []*db.Task
+	checkErr := a.tenants.Each(ctx, func(ctx context.Context, _ *db.TaskRepository) error {
+		tasks, err := a.handler.UpdateOverdueTasks(ctx)
+		overdue = append(overdue, tasks...)
+		return err
+	})
+	if checkErr != nil {
+		logger.Error("failed to check overdue tasks", "error", checkErr)
+	}
+
+	logger.Info("overdue tasks checked", "count", len(overdue))
+
+	sent, err := a.digest.Send(overdue)
+	metrics.ObserveJob("overdue", start, errors.Join(checkErr, err))
+	span.SetAttributes("overdue", len(overdue), "digests", sent)
+	span.SetError(errors.Join(checkErr, err))
+	a.overdueChecked.Store(time.Now().UnixNano())
+	if err != nil {
+		logger.Error("failed to send overdue digests", "error", err)
+	}
+	if sent > 0 {
+		logger.Info("overdue digests sent", "count", sent)
+	}
+}
+
+// stopStaleTimers останавливает забытые таймеры во всех пространствах.
+func (a *App) stopStaleTimers(ctx context.Context) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "job timers", tracing.KindInternal)
+	defer span.End()
+	stopped := 0
+	err := a.tenants.Each(ctx, func(ctx context.Context, _ *db.TaskRepository) error {
+		worklogs, err := a.handler.StopStaleTimers(ctx)
+		stopped += len(worklogs)
+		return err
+	})
+	metrics.ObserveJob("timers", start, err)
+	span.SetAttributes("stopped", stopped)
+	span.SetError(err)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to stop stale timers", "error", err)
+	}
+	if stopped > 0 {
+		logging.FromContext(ctx).Info("stale timers stopped", "count", stopped)
+	}
+}
+
+func (a *App) Wait() {
+	a.wg.Wait()
+}
+
+// Close закрывает базы пространств. Вызывается после Shutdown сервера и Wait: до этого
+// базы еще нужны активным запросам и фоновым задачам.
+func (a *App) Close() {
+	a.tenants.Close()
+}
+
+// eachReminders вызывает fn для диспетчера каждой базы: в режиме column база одна,
+// в режиме file у каждого пространства своя.
+func (a *App) eachReminders(ctx context.Context, fn func(reminders *notify.ReminderDispatcher) error) error {
+	if !a.tenants.Separate() {
+		return fn(a.reminders)
+	}
+	return a.tenants.Each(ctx, func(_ context.Context, repo *db.TaskRepository) error {
+		return fn(a.reminders.ForRepo(repo))
+	})
+}
+
+func (a *App) dispatchReminders(ctx context.Context) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "job reminders", tracing.KindInternal)
+	defer span.End()
+	sent := 0
+	err := a.eachReminders(ctx, func(reminders *notify.ReminderDispatcher) error {
+		n, err := reminders.Dispatch(time.Now())
+		sent += n
+		return err
+	})
+	metrics.ObserveJob("reminders", start, err)
+	span.SetAttributes("sent", sent)
+	span.SetError(err)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to dispatch reminders", "error", err)
+	}
+	if sent > 0 {
+		logging.FromContext(ctx).Info("reminders sent", "count", sent)
+	}
+}
+
+// Routes собирает обработчики API вместе с аутентификацией.
+func (a *App) Routes() http.Handler {
 	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/register", a.auth.HandleRegister)
+	mux.HandleFunc("/auth/login", a.auth.HandleLogin)
+	mux.HandleFunc("/auth/logout", a.auth.HandleLogout)
+	mux.HandleFunc("/auth/me", a.auth.HandleMe)
+	mux.HandleFunc("/auth/token", a.auth.HandleToken)
+	mux.HandleFunc("/.well-known/jwks.json", a.auth.HandleJWKS)
+	mux.HandleFunc("/auth/api-keys", a.auth.HandleAPIKeys)
+	mux.HandleFunc("/auth/api-keys/{id}", a.auth.HandleAPIKeyByID)
+	mux.HandleFunc("/users", a.auth.HandleUsers)
+	mux.HandleFunc("/users/{id}", a.auth.HandleUserByID)
 	mux.HandleFunc("/tasks", a.handler.HandleTasks)
-	mux.HandleFunc("/tasks/", a.handler.HandleTaskByID)
-	mux.HandleFunc("/tasks/complete/", a.handler.HandleCompleteTask)
+	mux.HandleFunc("/tasks/{id}", a.handler.HandleTaskByID)
+	mux.HandleFunc("/tasks/{id}/complete", a.handler.HandleCompleteTask)
+	mux.HandleFunc("/tasks/{id}/status", a.handler.HandleTaskStatus)
+	mux.HandleFunc("/tasks/{id}/reminders", a.handler.HandleTaskReminders)
+	mux.HandleFunc("/tasks/{id}/reminders/{reminderID}", a.handler.HandleTaskReminder)
+	mux.HandleFunc("/tasks/{id}/shares", a.handler.HandleTaskShares)
+	mux.HandleFunc("/tasks/{id}/shares/{userID}", a.handler.HandleTaskShare)
+	mux.HandleFunc("/tasks/{id}/comments", a.handler.HandleTaskComments)
+	mux.HandleFunc("/tasks/{id}/attachments", a.files.HandleTaskAttachments)
+	mux.HandleFunc("/tasks/{id}/attachments/{attachmentID}", a.files.HandleTaskAttachment)
+	mux.HandleFunc("/tasks/{id}/comments/{commentID}", a.handler.HandleTaskComment)
+	mux.HandleFunc("/tasks/{id}/comments/{commentID}/history", a.handler.HandleTaskCommentHistory)
+	mux.HandleFunc("/tasks/{id}/checklist", a.handler.HandleTaskChecklist)
+	mux.HandleFunc("/tasks/{id}/checklist/order", a.handler.HandleTaskChecklistOrder)
+	mux.HandleFunc("/tasks/{id}/checklist/{itemID}", a.handler.HandleTaskChecklistItem)
+	mux.HandleFunc("/tasks/{id}/timer/{action}", a.handler.HandleTaskTimer)
+	mux.HandleFunc("/tasks/{id}/worklogs", a.handler.HandleTaskWorklogs)
+	mux.HandleFunc("/tasks/{id}/worklogs/{worklogID}", a.handler.HandleTaskWorklog)
+	mux.HandleFunc("/me/tasks", a.handler.HandleMyTasks)
+	mux.HandleFunc("/me/timer", a.handler.HandleMyTimer)
+	mux.HandleFunc("/reports/time", a.handler.HandleTimeReport)
+	mux.HandleFunc("/projects", a.handler.HandleProjects)
+	mux.HandleFunc("/projects/{id}", a.handler.HandleProjectByID)
+	mux.HandleFunc("/projects/{id}/shares", a.handler.HandleProjectShares)
+	mux.HandleFunc("/projects/{id}/shares/{userID}", a.handler.HandleProjectShare)
+	mux.HandleFunc("/projects/{id}/fields", a.handler.HandleProjectFields)
+	mux.HandleFunc("/projects/{id}/fields/{key}", a.handler.HandleProjectField)
+	mux.HandleFunc("/export", a.transfer.HandleExport)
+	mux.HandleFunc("/import", a.transfer.HandleImport)
+	mux.Handle("/events", handlers.NewEventsHandler(a.hub, a.handler, eventsHeartbeat))
+	mux.Handle("/ws", a.ws)
+	mux.HandleFunc("/webhooks", a.handler.HandleWebhooks)
+	mux.HandleFunc("/webhooks/{id}", a.handler.HandleWebhookByID)
+	mux.HandleFunc("/webhooks/{id}/deliveries", a.handler.HandleWebhookDeliveries)
+	mux.HandleFunc("/admin/workspaces", a.admin.HandleWorkspaces)
+	mux.HandleFunc("/admin/workspaces/{id}", a.admin.HandleWorkspaceByID)
+	mux.HandleFunc("/admin/workspaces/{id}/members", a.admin.HandleWorkspaceMembers)
+	mux.HandleFunc("/admin/workspaces/{id}/members/{userID}", a.admin.HandleWorkspaceMember)
+	mux.HandleFunc("/admin/log-level", handlers.HandleLogLevel)
+	mux.Handle("/metrics", a.metricsHandler())
+	mux.HandleFunc("/healthz", a.handleHealthz)
+	mux.HandleFunc("/readyz", a.handleReadyz)
+	mux.HandleFunc("/admin/diagnostics", a.handleDiagnostics)
 
-	address := os.Getenv("SERVER_ADDRESS")
+	return DeprecatedRoutesMiddleware(routeMiddleware(mux, ProblemMiddleware(a.CORSMiddleware(a.RateLimitMiddleware(a.AuthMiddleware(a.WorkspaceMiddleware(mux)))))))
+}
 
+// routeMiddleware определяет шаблон маршрута до аутентификации, чтобы журнал доступа,
+// метрики HTTP и спан запроса были и у отклоненных запросов.
+func routeMiddleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		logging.SetRoute(r.Context(), pattern)
+		span := tracing.SpanFromContext(r.Context())
+		if pattern != "" {
+			span.SetName(r.Method + " " + pattern)
+		}
+
+		status := metrics.ServeHTTP(w, r, pattern, next)
+		span.SetAttributes("http.route", pattern, "http.response.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetError(errors.New(http.StatusText(status)))
+		}
+	})
+}
+
+func (a *App) StartServer() *http.Server {
+	settings := a.config.Load().Server
 	server := &http.Server{
-		Addr:    address,
-		Handler: LoggerMiddleware(mux),
+		Addr:              settings.Address,
+		Handler:           tracing.Middleware(logging.Middleware(a.Routes())),
+		ReadTimeout:       settings.ReadTimeout,
+		ReadHeaderTimeout: settings.ReadHeaderTimeout,
+		WriteTimeout:      settings.WriteTimeout,
+		IdleTimeout:       settings.IdleTimeout,
 	}
+	if settings.TLSCert != "" {
+		// Сертификат берется при каждом рукопожатии, поэтому Reload меняет его без перезапуска
+		server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: a.getCertificate}
+	}
+
+	// Shutdown не прерывает активные запросы и не видит WebSocket-соединения,
+	// поэтому потоки событий закрываем сами
+	server.RegisterOnShutdown(a.hub.Close)
+	server.RegisterOnShutdown(a.ws.Shutdown)
 
-	log.Printf("Starting server on: %v ", server.Addr)
+	slog.Info("starting server", "address", server.Addr)
 
 	return server
 }
 
-func LoggerMiddleware(next http.Handler) http.Handler {
+// AuthMiddleware определяет пользователя по токену сессии, API-ключу или JWT и кладет его в контекст
+// запроса. Вход, регистрация, JWKS, проверки здоровья и метрики (см. METRICS_TOKEN) доступны без токена, вебхуки и пользователи - только администраторам.
+// Права на конкретные задачи и проекты проверяет authz.Repo.
+func (a *App) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := a.auth.Authenticate(r)
+		if errors.Is(err, auth.ErrUnauthenticated) || errors.Is(err, auth.ErrInvalidCredentials) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="lwo-go"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to authenticate: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if !allowed(principal, r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		ctx := logging.With(auth.WithPrincipal(r.Context(), principal), "user_id", principal.UserID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WorkspaceMiddleware привязывает запросы к задачам, проектам и потокам событий к рабочему
+// пространству (см. tenant.Manager.Slug). Пространство, в котором пользователь не участвует,
+// выглядит несуществующим.
+func (a *App) WorkspaceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !workspaceScoped(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-		// Логируем метод и путь запроса
-		log.Printf("Started %s %s", r.Method, r.URL.Path)
+		principal, _ := auth.FromContext(r.Context())
+		slug, err := a.tenants.Slug(r, principal)
+		if err != nil {
+			http.Error(w, "Forbidden: workspace does not match token", http.StatusForbidden)
+			return
+		}
+
+		workspace, err := a.tenants.Resolve(slug, principal)
+		switch {
+		case errors.Is(err, db.ErrWorkspaceNotFound):
+			http.Error(w, "Workspace not found", http.StatusNotFound)
+			return
+		case errors.Is(err, tenant.ErrSuspended):
+			http.Error(w, "Workspace is suspended", http.StatusForbidden)
+			return
+		case err != nil:
+			http.Error(w, fmt.Sprintf("Failed to resolve workspace: %v", err), http.StatusInternalServerError)
+			return
+		}
 
-		// Передаем управление следующему обработчику
-		next.ServeHTTP(w, r)
+		ctx, release, err := a.tenants.Bind(r.Context(), workspace)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to open workspace: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer release()
 
-		// Логируем время выполнения
-		log.Printf("Completed %s in %v", r.URL.Path, time.Since(start))
+		w.Header().Set(tenant.Header, workspace.Slug)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// publicPaths доступны без аутентификации.
+var publicPaths = map[string]bool{
+	"/auth/login":            true,
+	"/auth/register":         true,
+	"/.well-known/jwks.json": true,
+	"/metrics":               true,
+	"/healthz":               true,
+	"/readyz":                true,
+}
+
+func workspaceScoped(path string) bool {
+	for _, prefix := range []string{"/tasks", "/me", "/projects", "/reports", "/events", "/ws", "/export", "/import"} {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed проверяет роль и права API-ключа для маршрута.
+func allowed(principal *auth.Principal, r *http.Request) bool {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/auth/"):
+		return true
+	case strings.HasPrefix(r.URL.Path, "/webhooks"), strings.HasPrefix(r.URL.Path, "/users"), strings.HasPrefix(r.URL.Path, "/admin/"):
+		return principal.IsAdmin() && principal.HasScope(auth.ScopeAdmin)
+	case r.Method == "GET" || r.Method == "HEAD":
+		return principal.HasScope(auth.ScopeTasksRead)
+	default:
+		return principal.HasScope(auth.ScopeTasksWrite)
+	}
+}
@@ -0,0 +1,1578 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"todo/internal/auth"
+	"todo/internal/db"
+	"todo/internal/events"
+	"todo/internal/handlers"
+	"todo/internal/logging"
+	"todo/internal/metrics"
+	"todo/internal/tracing"
+	"todo/pkg/config"
+)
+
+type testClient struct {
+	t       *testing.T
+	app     *App
+	handler http.Handler
+}
+
+func (c *testClient) do(token string, method string, path string, body string) *httptest.ResponseRecorder {
+	return c.doIn("", token, method, path, body)
+}
+
+// doIn выполняет запрос в рабочем пространстве workspace, переданном заголовком X-Workspace.
+func (c *testClient) doIn(workspace string, token string, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if workspace != "" {
+		req.Header.Set("X-Workspace", workspace)
+	}
+	rr := httptest.NewRecorder()
+	c.handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// decode выполняет запрос, ожидая status, и разбирает ответ в value.
+func (c *testClient) decode(token string, method string, path string, body string, status int, value any) {
+	c.t.Helper()
+	rr := c.do(token, method, path, body)
+	if rr.Code != status {
+		c.t.Fatalf("%s %s: got %d, want %d: %s", method, path, rr.Code, status, rr.Body.String())
+	}
+	if value != nil {
+		if err := json.NewDecoder(rr.Body).Decode(value); err != nil {
+			c.t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+}
+
+func (c *testClient) login(username string) string {
+	c.t.Helper()
+	credentials := fmt.Sprintf(`{"username":%q,"password":"password-%s"}`, username, username)
+	var login handlers.LoginResponse
+	c.decode("", "POST", "/auth/login", credentials, http.StatusOK, &login)
+	return login.Token
+}
+
+func newTestApp(t *testing.T) *testClient {
+	t.Setenv("FILEPATH", filepath.Join(t.TempDir(), "tasks.db"))
+	t.Setenv("AUTH_ADMIN_USERNAME", "admin")
+	t.Setenv("AUTH_ADMIN_PASSWORD", "password-admin")
+	t.Setenv("AUTH_ALLOW_SIGNUP", "true")
+	t.Setenv("NOTIFIERS", "")
+	t.Setenv("ATTACHMENTS_DIR", filepath.Join(t.TempDir(), "attachments"))
+
+	cfg, err := config.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(a.hub.Close)
+	t.Cleanup(a.tenants.Close)
+	return &testClient{t: t, app: a, handler: a.Routes()}
+}
+
+// TestAccessMatrix проходит по всем эндпоинтам задач, проектов, их вложенных ресурсов и
+// администрирования от имени каждой роли: admin, владелец (alice), доступ на редактирование (bob),
+// на просмотр (carol), роль viewer с доступом на редактирование (vera) и посторонний (dave).
+func TestAccessMatrix(t *testing.T) {
+	c := newTestApp(t)
+	actors := []string{"admin", "alice", "bob", "carol", "vera", "dave"}
+
+	tokens := map[string]string{"admin": c.login("admin")}
+	ids := map[string]int{}
+	for _, name := range actors[1:] {
+		var user db.User
+		c.decode("", "POST", "/auth/register", fmt.Sprintf(`{"username":%q,"password":"password-%s"}`, name, name), http.StatusCreated, &user)
+		ids[name] = user.ID
+		tokens[name] = c.login(name)
+	}
+	c.decode(tokens["admin"], "PATCH", fmt.Sprintf("/users/%d", ids["vera"]), `{"role":"viewer"}`, http.StatusOK, nil)
+
+	share := func(kind string, id int) {
+		for name, permission := range map[string]string{"bob": "edit", "carol": "view", "vera": "edit"} {
+			c.decode(tokens["alice"], "POST", fmt.Sprintf("/%s/%d/shares", kind, id),
+				fmt.Sprintf(`{"username":%q,"permission":%q}`, name, permission), http.StatusCreated, nil)
+		}
+	}
+	// Для каждого запроса создается свежая задача или проект: удаление не должно влиять на соседей
+	newTask := func() int {
+		var task db.Task
+		c.decode(tokens["alice"], "POST", "/tasks", `{"title":"shared","due_date":"2099-01-01"}`, http.StatusCreated, &task)
+		share("tasks", task.ID)
+		return task.ID
+	}
+	newProject := func() int {
+		var project db.Project
+		c.decode(tokens["alice"], "POST", "/projects", `{"name":"shared"}`, http.StatusCreated, &project)
+		share("projects", project.ID)
+		return project.ID
+	}
+	// child создает от имени alice вложенный объект задачи или проекта и возвращает его ID
+	child := func(path string, body string) func(int) int {
+		return func(id int) int {
+			var created struct {
+				ID int `json:"id"`
+			}
+			c.decode(tokens["alice"], "POST", fmt.Sprintf(path, id), body, http.StatusCreated, &created)
+			return created.ID
+		}
+	}
+	attachment := func(id int) int {
+		rr := c.upload(tokens["alice"], fmt.Sprintf("/tasks/%d/attachments", id), "note.txt", []byte("note"))
+		var created db.Attachment
+		if err := json.NewDecoder(rr.Body).Decode(&created); err != nil || rr.Code != http.StatusCreated {
+			t.Fatalf("upload attachment: %d %v", rr.Code, err)
+		}
+		return created.ID
+	}
+	checklistItem := func(id int) int {
+		var checklist handlers.ChecklistResponse
+		c.decode(tokens["alice"], "POST", fmt.Sprintf("/tasks/%d/checklist", id), `{"text":"step"}`, http.StatusCreated, &checklist)
+		return checklist.Items[0].ID
+	}
+	newWebhook := func() int {
+		var webhook db.Webhook
+		c.decode(tokens["admin"], "POST", "/webhooks", `{"url":"https://example.com/hook"}`, http.StatusCreated, &webhook)
+		return webhook.ID
+	}
+	workspaces := 0
+	newWorkspace := func() int {
+		var workspace db.Workspace
+		workspaces++
+		c.decode(tokens["admin"], "POST", "/admin/workspaces", fmt.Sprintf(`{"slug":"team-%d"}`, workspaces), http.StatusCreated, &workspace)
+		return workspace.ID
+	}
+	member := func(id int) int {
+		c.decode(tokens["admin"], "POST", fmt.Sprintf("/admin/workspaces/%d/members", id), `{"username":"alice"}`, http.StatusCreated, nil)
+		return ids["alice"]
+	}
+	slug := func() int {
+		workspaces++
+		return workspaces
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		create func() int
+		sub    func(id int) int // второй ID в пути: вложенный объект, созданный в create
+		upload bool             // тело отправляется файлом формы multipart/form-data
+		want   []int            // в порядке actors
+	}{
+		{method: "GET", path: "/tasks/%d", create: newTask, want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "PUT", path: "/tasks/%d", body: `{"title":"renamed"}`, create: newTask, want: []int{200, 200, 200, 403, 403, 404}},
+		{method: "PATCH", path: "/tasks/%d/complete", create: newTask, want: []int{200, 200, 200, 403, 403, 404}},
+		{method: "PATCH", path: "/tasks/%d/status", body: `{"status":"in_progress"}`, create: newTask, want: []int{200, 200, 200, 403, 403, 404}},
+		{method: "DELETE", path: "/tasks/%d", create: newTask, want: []int{200, 200, 403, 403, 403, 404}},
+		{method: "GET", path: "/tasks/%d/reminders", create: newTask, want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "POST", path: "/tasks/%d/reminders", body: `{"before":"1h"}`, create: newTask, want: []int{201, 201, 201, 403, 403, 404}},
+		{method: "GET", path: "/tasks/%d/shares", create: newTask, want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "POST", path: "/tasks/%d/shares", body: `{"username":"dave"}`, create: newTask, want: []int{201, 201, 403, 403, 403, 404}},
+		{method: "DELETE", path: "/tasks/%d/shares/" + fmt.Sprint(ids["bob"]), create: newTask, want: []int{200, 200, 200, 403, 403, 404}},
+		{method: "GET", path: "/projects/%d", create: newProject, want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "PUT", path: "/projects/%d", body: `{"name":"renamed"}`, create: newProject, want: []int{200, 200, 200, 403, 403, 404}},
+		{method: "DELETE", path: "/projects/%d", create: newProject, want: []int{200, 200, 403, 403, 403, 404}},
+		{method: "GET", path: "/projects/%d/shares", create: newProject, want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "POST", path: "/projects/%d/shares", body: `{"username":"dave"}`, create: newProject, want: []int{201, 201, 403, 403, 403, 404}},
+		{method: "DELETE", path: "/projects/%d/shares/" + fmt.Sprint(ids["bob"]), create: newProject, want: []int{200, 200, 200, 403, 403, 404}},
+		{method: "POST", path: "/tasks", body: `{"title":"in project","due_date":"2099-01-01","project_id":%d}`, create: newProject, want: []int{201, 201, 201, 403, 403, 404}},
+		{method: "GET", path: "/tasks", want: []int{200, 200, 200, 200, 200, 200}},
+		{method: "POST", path: "/tasks", body: `{"title":"own","due_date":"2099-01-01"}`, want: []int{201, 201, 201, 201, 403, 201}},
+		{method: "GET", path: "/projects", want: []int{200, 200, 200, 200, 200, 200}},
+		{method: "POST", path: "/projects", body: `{"name":"own"}`, want: []int{201, 201, 201, 201, 403, 201}},
+		{method: "GET", path: "/users", want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "PATCH", path: fmt.Sprintf("/users/%d", ids["dave"]), body: `{"role":"editor"}`, want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "DELETE", path: "/tasks/%d/reminders/%d", create: newTask, sub: child("/tasks/%d/reminders", `{"before":"1h"}`), want: []int{200, 200, 200, 403, 403, 404}},
+		{method: "GET", path: "/tasks/%d/comments", create: newTask, want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "POST", path: "/tasks/%d/comments", body: `{"body":"hi"}`, create: newTask, want: []int{201, 201, 201, 201, 403, 404}},
+		{method: "GET", path: "/tasks/%d/comments/%d", create: newTask, sub: child("/tasks/%d/comments", `{"body":"hi"}`), want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "PUT", path: "/tasks/%d/comments/%d", body: `{"body":"edited"}`, create: newTask, sub: child("/tasks/%d/comments", `{"body":"hi"}`), want: []int{200, 200, 403, 403, 403, 404}},
+		{method: "DELETE", path: "/tasks/%d/comments/%d", create: newTask, sub: child("/tasks/%d/comments", `{"body":"hi"}`), want: []int{200, 200, 403, 403, 403, 404}},
+		{method: "GET", path: "/tasks/%d/comments/%d/history", create: newTask, sub: child("/tasks/%d/comments", `{"body":"hi"}`), want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "GET", path: "/tasks/%d/attachments", create: newTask, want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "POST", path: "/tasks/%d/attachments", body: "note", create: newTask, upload: true, want: []int{201, 201, 201, 403, 403, 404}},
+		{method: "GET", path: "/tasks/%d/attachments/%d", create: newTask, sub: attachment, want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "DELETE", path: "/tasks/%d/attachments/%d", create: newTask, sub: attachment, want: []int{200, 200, 403, 403, 403, 404}},
+		{method: "GET", path: "/tasks/%d/checklist", create: newTask, want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "POST", path: "/tasks/%d/checklist", body: `{"text":"step"}`, create: newTask, want: []int{201, 201, 201, 403, 403, 404}},
+		{method: "PATCH", path: "/tasks/%d/checklist/%d", body: `{"done":true}`, create: newTask, sub: checklistItem, want: []int{200, 200, 200, 403, 403, 404}},
+		{method: "DELETE", path: "/tasks/%d/checklist/%d", create: newTask, sub: checklistItem, want: []int{200, 200, 200, 403, 403, 404}},
+		{method: "GET", path: "/tasks/%d/worklogs", create: newTask, want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "POST", path: "/tasks/%d/worklogs", body: `{"minutes":30}`, create: newTask, want: []int{201, 201, 201, 403, 403, 404}},
+		{method: "DELETE", path: "/tasks/%d/worklogs/%d", create: newTask, sub: child("/tasks/%d/worklogs", `{"minutes":30}`), want: []int{200, 200, 403, 403, 403, 404}},
+		{method: "POST", path: "/tasks/%d/timer/start", create: newTask, want: []int{201, 201, 201, 403, 403, 404}},
+		{method: "GET", path: "/projects/%d/fields", create: newProject, want: []int{200, 200, 200, 200, 200, 404}},
+		{method: "POST", path: "/projects/%d/fields", body: `{"key":"points","name":"Points","type":"number"}`, create: newProject, want: []int{201, 201, 403, 403, 403, 404}},
+		{method: "GET", path: "/me/tasks", want: []int{200, 200, 200, 200, 200, 200}},
+		{method: "GET", path: "/reports/time", want: []int{200, 200, 200, 200, 200, 200}},
+		{method: "GET", path: "/export", want: []int{200, 200, 200, 200, 200, 200}},
+		{method: "POST", path: "/import", body: `[{"title":"imported","due_date":"2099-01-01"}]`, want: []int{200, 200, 200, 200, 422, 200}},
+		{method: "GET", path: "/webhooks", want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "POST", path: "/webhooks", body: `{"url":"https://example.com/hook"}`, want: []int{201, 403, 403, 403, 403, 403}},
+		{method: "GET", path: "/webhooks/%d", create: newWebhook, want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "PUT", path: "/webhooks/%d", body: `{"active":false}`, create: newWebhook, want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "DELETE", path: "/webhooks/%d", create: newWebhook, want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "GET", path: "/webhooks/%d/deliveries", create: newWebhook, want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "GET", path: "/admin/workspaces", want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "POST", path: "/admin/workspaces", body: `{"slug":"new-%d"}`, create: slug, want: []int{201, 403, 403, 403, 403, 403}},
+		{method: "GET", path: "/admin/workspaces/%d", create: newWorkspace, want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "PATCH", path: "/admin/workspaces/%d", body: `{"status":"suspended"}`, create: newWorkspace, want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "DELETE", path: "/admin/workspaces/%d", create: newWorkspace, want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "GET", path: "/admin/workspaces/%d/members", create: newWorkspace, want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "POST", path: "/admin/workspaces/%d/members", body: `{"username":"bob"}`, create: newWorkspace, want: []int{201, 403, 403, 403, 403, 403}},
+		{method: "DELETE", path: "/admin/workspaces/%d/members/%d", create: newWorkspace, sub: member, want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "GET", path: "/admin/log-level", want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "PUT", path: "/admin/log-level", body: `{"level":"info"}`, want: []int{200, 403, 403, 403, 403, 403}},
+		{method: "GET", path: "/admin/diagnostics", want: []int{200, 403, 403, 403, 403, 403}},
+	}
+
+	for _, test := range tests {
+		for i, actor := range actors {
+			path, body := test.path, test.body
+			if test.create != nil {
+				id := test.create()
+				switch {
+				case test.sub != nil:
+					path = fmt.Sprintf(path, id, test.sub(id))
+				case strings.Contains(path, "%d"):
+					path = fmt.Sprintf(path, id)
+				default:
+					body = fmt.Sprintf(body, id)
+				}
+			}
+
+			var rr *httptest.ResponseRecorder
+			if test.upload {
+				rr = c.upload(tokens[actor], path, "note.txt", []byte(body))
+			} else {
+				rr = c.do(tokens[actor], test.method, path, body)
+			}
+			if rr.Code != test.want[i] {
+				t.Errorf("%s %s as %s: got %d, want %d: %s", test.method, test.path, actor, rr.Code, test.want[i], strings.TrimSpace(rr.Body.String()))
+			}
+		}
+	}
+
+	if rr := c.do("", "GET", "/tasks", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous GET /tasks: got %d", rr.Code)
+	}
+}
+
+// TestTaskListFiltered проверяет, что список задач фильтруется по доступу, в том числе через проект.
+func TestTaskListFiltered(t *testing.T) {
+	c := newTestApp(t)
+	tokens := map[string]string{}
+	for _, name := range []string{"alice", "bob", "dave"} {
+		c.decode("", "POST", "/auth/register", fmt.Sprintf(`{"username":%q,"password":"password-%s"}`, name, name), http.StatusCreated, nil)
+		tokens[name] = c.login(name)
+	}
+
+	var project db.Project
+	c.decode(tokens["alice"], "POST", "/projects", `{"name":"work"}`, http.StatusCreated, &project)
+	c.decode(tokens["alice"], "POST", "/tasks", fmt.Sprintf(`{"title":"in project","due_date":"2099-01-01","project_id":%d}`, project.ID), http.StatusCreated, nil)
+	c.decode(tokens["alice"], "POST", "/tasks", `{"title":"private","due_date":"2099-01-01"}`, http.StatusCreated, nil)
+	c.decode(tokens["alice"], "POST", fmt.Sprintf("/projects/%d/shares", project.ID), `{"username":"bob"}`, http.StatusCreated, nil)
+
+	count := func(name string) int {
+		var tasks []db.Task
+		c.decode(tokens[name], "GET", "/tasks", "", http.StatusOK, &tasks)
+		return len(tasks)
+	}
+	for name, want := range map[string]int{"alice": 2, "bob": 1, "dave": 0} {
+		if got := count(name); got != want {
+			t.Errorf("%s sees %d tasks, want %d", name, got, want)
+		}
+	}
+
+	var projects []db.Project
+	c.decode(tokens["dave"], "GET", "/projects", "", http.StatusOK, &projects)
+	if len(projects) != 0 {
+		t.Errorf("dave sees foreign projects: %+v", projects)
+	}
+
+	body, _ := json.Marshal(map[string]any{"title": "moved", "project_id": project.ID})
+	if rr := c.do(tokens["dave"], "POST", "/tasks", string(body)); rr.Code != http.StatusNotFound {
+		t.Errorf("dave created task in foreign project: %d", rr.Code)
+	}
+}
+
+// TestDeprecatedCompleteRoute проверяет, что старый адрес завершения задачи работает и помечен устаревшим.
+func TestDeprecatedCompleteRoute(t *testing.T) {
+	c := newTestApp(t)
+	token := c.login("admin")
+	var task db.Task
+	c.decode(token, "POST", "/tasks", `{"title":"legacy","due_date":"2099-01-01"}`, http.StatusCreated, &task)
+
+	rr := c.do(token, "PATCH", fmt.Sprintf("/tasks/complete/%d", task.ID), "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Deprecation") != "true" ||
+		rr.Header().Get("Link") != fmt.Sprintf(`</tasks/%d/complete>; rel="successor-version"`, task.ID) {
+		t.Fatalf("deprecated route: %d %v %s", rr.Code, rr.Header(), rr.Body.String())
+	}
+	c.decode(token, "GET", fmt.Sprintf("/tasks/%d", task.ID), "", http.StatusOK, &task)
+	if task.Status != "done" {
+		t.Errorf("task is not completed: %+v", task)
+	}
+	if rr := c.do(token, "GET", fmt.Sprintf("/tasks/complete/%d", task.ID), ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET on the deprecated route: %d", rr.Code)
+	}
+}
+
+// TestEventsProjectFilter проверяет фильтр потока событий по проекту при повторе пропущенных событий.
+func TestEventsProjectFilter(t *testing.T) {
+	c := newTestApp(t)
+	c.decode("", "POST", "/auth/register", `{"username":"alice","password":"password-alice"}`, http.StatusCreated, nil)
+	token := c.login("alice")
+
+	sub, _, _ := c.app.hub.Subscribe("", events.Filter{})
+	defer c.app.hub.Unsubscribe(sub)
+	c.decode(token, "POST", "/tasks", `{"title":"before","due_date":"2099-01-01"}`, http.StatusCreated, nil)
+	lastEventID := (<-sub.C).ID
+
+	var work, home db.Project
+	c.decode(token, "POST", "/projects", `{"name":"work"}`, http.StatusCreated, &work)
+	c.decode(token, "POST", "/projects", `{"name":"home"}`, http.StatusCreated, &home)
+	create := func(title string, projectID int) int {
+		var task db.Task
+		body := fmt.Sprintf(`{"title":%q,"due_date":"2099-01-01","project_id":%d}`, title, projectID)
+		c.decode(token, "POST", "/tasks", body, http.StatusCreated, &task)
+		return task.ID
+	}
+	report := create("report", work.ID)
+	groceries := create("groceries", home.ID)
+	c.decode(token, "POST", "/tasks", `{"title":"no project","due_date":"2099-01-01"}`, http.StatusCreated, nil)
+	c.decode(token, "PUT", fmt.Sprintf("/tasks/%d", groceries), fmt.Sprintf(`{"project_id":%d}`, work.ID), http.StatusOK, nil)
+
+	// Отмененный контекст: обработчик отдает повтор и сразу завершается
+	replay := func(query string) []events.Event {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := httptest.NewRequest("GET", "/events?"+query, nil).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Last-Event-ID", lastEventID)
+		rr := httptest.NewRecorder()
+		c.handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET /events?%s: %d %s", query, rr.Code, rr.Body.String())
+		}
+		var replayed []events.Event
+		for _, line := range strings.Split(rr.Body.String(), "\n") {
+			if data, ok := strings.CutPrefix(line, "data: "); ok && data != "{}" {
+				var event events.Event
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					t.Fatal(err)
+				}
+				replayed = append(replayed, event)
+			}
+		}
+		return replayed
+	}
+
+	// Перенесенная задача попадает в поток нового проекта
+	got := replay(fmt.Sprintf("project_id=%d", work.ID))
+	if len(got) != 2 || got[0].Type != events.TaskCreated || got[0].TaskID != report ||
+		got[1].Type != events.TaskUpdated || got[1].TaskID != groceries {
+		t.Errorf("work project events: %+v", got)
+	}
+	got = replay(fmt.Sprintf("project_id=%d&type=task.created", home.ID))
+	if len(got) != 1 || got[0].TaskID != groceries {
+		t.Errorf("home project events: %+v", got)
+	}
+	if got = replay(fmt.Sprintf("project_id=%d,%d", work.ID, home.ID)); len(got) != 3 {
+		t.Errorf("events of both projects: %+v", got)
+	}
+
+	if rr := c.do(token, "GET", "/events?project_id=work", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid project_id: %d", rr.Code)
+	}
+}
+
+// TestWorkspaces проверяет изоляцию пространств и API администратора в обоих режимах хранения.
+func TestWorkspaces(t *testing.T) {
+	for _, mode := range []string{"column", "file"} {
+		t.Run(mode, func(t *testing.T) {
+			t.Setenv("WORKSPACE_MODE", mode)
+			t.Setenv("WORKSPACE_DIR", t.TempDir())
+			t.Setenv("WORKSPACE_DOMAIN", "todo.test")
+			c := newTestApp(t)
+
+			admin := c.login("admin")
+			tokens := map[string]string{}
+			for _, name := range []string{"alice", "bob"} {
+				c.decode("", "POST", "/auth/register", fmt.Sprintf(`{"username":%q,"password":"password-%s"}`, name, name), http.StatusCreated, nil)
+				tokens[name] = c.login(name)
+			}
+
+			var acme db.Workspace
+			c.decode(admin, "POST", "/admin/workspaces", `{"slug":"acme","name":"ACME"}`, http.StatusCreated, &acme)
+			c.decode(admin, "POST", "/admin/workspaces", `{"slug":"acme"}`, http.StatusConflict, nil)
+			c.decode(admin, "POST", "/admin/workspaces", `{"slug":"Bad Slug"}`, http.StatusBadRequest, nil)
+			c.decode(tokens["alice"], "GET", "/admin/workspaces", "", http.StatusForbidden, nil)
+			c.decode(admin, "POST", fmt.Sprintf("/admin/workspaces/%d/members", acme.ID), `{"username":"alice"}`, http.StatusCreated, nil)
+
+			titles := func(workspace string, token string, path string) []string {
+				t.Helper()
+				rr := c.doIn(workspace, token, "GET", path, "")
+				if rr.Code != http.StatusOK {
+					t.Fatalf("GET %s in %q: got %d: %s", path, workspace, rr.Code, rr.Body.String())
+				}
+				var tasks []db.Task
+				if err := json.NewDecoder(rr.Body).Decode(&tasks); err != nil {
+					t.Fatal(err)
+				}
+				var titles []string
+				for _, task := range tasks {
+					titles = append(titles, task.Title)
+				}
+				return titles
+			}
+
+			if rr := c.doIn("", tokens["alice"], "POST", "/tasks", `{"title":"default task","due_date":"2099-01-01"}`); rr.Code != http.StatusCreated {
+				t.Fatalf("create in default: %d %s", rr.Code, rr.Body.String())
+			}
+			rr := c.doIn("acme", tokens["alice"], "POST", "/tasks", `{"title":"acme task","due_date":"2099-01-01"}`)
+			if rr.Code != http.StatusCreated || rr.Header().Get("X-Workspace") != "acme" {
+				t.Fatalf("create in acme: %d %q %s", rr.Code, rr.Header().Get("X-Workspace"), rr.Body.String())
+			}
+
+			if got := titles("", tokens["alice"], "/tasks"); len(got) != 1 || got[0] != "default task" {
+				t.Errorf("default workspace tasks: %v", got)
+			}
+			if got := titles("acme", tokens["alice"], "/tasks"); len(got) != 1 || got[0] != "acme task" {
+				t.Errorf("acme workspace tasks: %v", got)
+			}
+			if got := titles("", admin, "http://acme.todo.test/tasks"); len(got) != 1 || got[0] != "acme task" {
+				t.Errorf("acme tasks by subdomain: %v", got)
+			}
+
+			if rr := c.doIn("acme", tokens["bob"], "GET", "/tasks", ""); rr.Code != http.StatusNotFound {
+				t.Errorf("non-member in acme: got %d", rr.Code)
+			}
+			if rr := c.doIn("missing", tokens["alice"], "GET", "/tasks", ""); rr.Code != http.StatusNotFound {
+				t.Errorf("unknown workspace: got %d", rr.Code)
+			}
+
+			// Выгрузка и загрузка работают в пространстве запроса
+			rr = c.doIn("acme", tokens["alice"], "POST", "/import", `[{"title":"imported","due_date":"2099-01-01"}]`)
+			if rr.Code != http.StatusOK || rr.Header().Get("X-Workspace") != "acme" {
+				t.Fatalf("import in acme: %d %q %s", rr.Code, rr.Header().Get("X-Workspace"), rr.Body.String())
+			}
+			if got := titles("acme", tokens["alice"], "/export"); !slices.Equal(got, []string{"acme task", "imported"}) {
+				t.Errorf("acme export: %v", got)
+			}
+			if got := titles("", tokens["alice"], "/export"); !slices.Equal(got, []string{"default task"}) {
+				t.Errorf("default export: %v", got)
+			}
+			if rr := c.doIn("acme", tokens["bob"], "GET", "/export", ""); rr.Code != http.StatusNotFound {
+				t.Errorf("non-member export in acme: got %d", rr.Code)
+			}
+
+			// Таймеры независимы в каждом пространстве в обоих режимах хранения
+			timers := map[string]db.Worklog{}
+			for _, workspace := range []string{"", "acme"} {
+				var tasks []db.Task
+				if err := json.NewDecoder(c.doIn(workspace, tokens["alice"], "GET", "/tasks", "").Body).Decode(&tasks); err != nil || len(tasks) == 0 {
+					t.Fatalf("tasks in %q: %v", workspace, err)
+				}
+				rr := c.doIn(workspace, tokens["alice"], "POST", fmt.Sprintf("/tasks/%d/timer/start", tasks[0].ID), "")
+				if rr.Code != http.StatusCreated {
+					t.Fatalf("start timer in %q: %d %s", workspace, rr.Code, rr.Body.String())
+				}
+				var timer db.Worklog
+				json.NewDecoder(rr.Body).Decode(&timer)
+				timers[workspace] = timer
+			}
+			for _, workspace := range []string{"", "acme"} {
+				var running db.Worklog
+				if err := json.NewDecoder(c.doIn(workspace, tokens["alice"], "GET", "/me/timer", "").Body).Decode(&running); err != nil || running.ID != timers[workspace].ID || running.TaskID != timers[workspace].TaskID {
+					t.Errorf("running timer in %q: %+v, want %+v", workspace, running, timers[workspace])
+				}
+				rr := c.doIn(workspace, tokens["alice"], "POST", fmt.Sprintf("/tasks/%d/timer/stop", timers[workspace].TaskID), "")
+				if rr.Code != http.StatusOK {
+					t.Errorf("stop timer in %q: %d %s", workspace, rr.Code, rr.Body.String())
+				}
+			}
+
+			c.decode(admin, "PATCH", fmt.Sprintf("/admin/workspaces/%d", acme.ID), `{"status":"suspended"}`, http.StatusOK, nil)
+			if rr := c.doIn("acme", tokens["alice"], "GET", "/tasks", ""); rr.Code != http.StatusForbidden {
+				t.Errorf("suspended workspace: got %d", rr.Code)
+			}
+
+			c.decode(admin, "DELETE", fmt.Sprintf("/admin/workspaces/%d", db.DefaultWorkspaceID), "", http.StatusConflict, nil)
+			c.decode(admin, "DELETE", fmt.Sprintf("/admin/workspaces/%d", acme.ID), "", http.StatusOK, nil)
+			if rr := c.doIn("acme", admin, "GET", "/tasks", ""); rr.Code != http.StatusNotFound {
+				t.Errorf("deleted workspace: got %d", rr.Code)
+			}
+			if got := titles("", tokens["alice"], "/tasks"); len(got) != 1 {
+				t.Errorf("default workspace tasks after delete: %v", got)
+			}
+		})
+	}
+}
+
+// TestAssigneesAndWatchers проверяет доступ исполнителей и наблюдателей, смену исполнителей
+// и списки GET /me/tasks.
+func TestAssigneesAndWatchers(t *testing.T) {
+	c := newTestApp(t)
+	tokens := map[string]string{}
+	ids := map[string]int{}
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		var user db.User
+		c.decode("", "POST", "/auth/register", fmt.Sprintf(`{"username":%q,"password":"password-%s"}`, name, name), http.StatusCreated, &user)
+		ids[name] = user.ID
+		tokens[name] = c.login(name)
+	}
+
+	var reassigned []events.Event
+	c.app.events.Subscribe(func(event events.Event) {
+		if event.Type == events.TaskReassigned {
+			reassigned = append(reassigned, event)
+		}
+	})
+
+	c.decode(tokens["alice"], "POST", "/tasks", `{"title":"ghost","due_date":"2099-01-01","assignees":[999]}`, http.StatusBadRequest, nil)
+
+	var task db.Task
+	body := fmt.Sprintf(`{"title":"review","due_date":"2099-01-01","assignees":[%d,%d],"watchers":[%d]}`, ids["bob"], ids["bob"], ids["carol"])
+	c.decode(tokens["alice"], "POST", "/tasks", body, http.StatusCreated, &task)
+	if len(task.Assignees) != 1 || task.Assignees[0] != ids["bob"] || len(task.Watchers) != 1 {
+		t.Fatalf("unexpected people: %+v", task)
+	}
+	path := fmt.Sprintf("/tasks/%d", task.ID)
+
+	// Исполнитель редактирует, наблюдатель только читает, посторонний задачу не видит
+	c.decode(tokens["bob"], "PUT", path, `{"title":"reviewed"}`, http.StatusOK, nil)
+	c.decode(tokens["carol"], "GET", path, "", http.StatusOK, nil)
+	c.decode(tokens["carol"], "PUT", path, `{"title":"watched"}`, http.StatusForbidden, nil)
+	c.decode(tokens["dave"], "GET", path, "", http.StatusNotFound, nil)
+
+	// Менять исполнителей может только владелец
+	c.decode(tokens["bob"], "PUT", path, fmt.Sprintf(`{"assignees":[%d]}`, ids["dave"]), http.StatusForbidden, nil)
+	c.decode(tokens["alice"], "PUT", path, `{"assignees":[999]}`, http.StatusBadRequest, nil)
+	c.decode(tokens["alice"], "PUT", path, fmt.Sprintf(`{"assignees":[%d]}`, ids["dave"]), http.StatusOK, nil)
+
+	if len(reassigned) != 1 || reassigned[0].PreviousAssignees[0] != ids["bob"] || reassigned[0].Task.Assignees[0] != ids["dave"] {
+		t.Errorf("unexpected reassignment events: %+v", reassigned)
+	}
+
+	count := func(name string, query string) int {
+		t.Helper()
+		var tasks []db.Task
+		c.decode(tokens[name], "GET", "/me/tasks"+query, "", http.StatusOK, &tasks)
+		return len(tasks)
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"bob", "?filter=assigned", 0},
+		{"dave", "?filter=assigned", 1},
+		{"dave", "?filter=watching,created", 0},
+		{"carol", "?filter=watching", 1},
+		{"alice", "?filter=created", 1},
+		{"alice", "?filter=assigned", 0},
+		{"dave", "", 1},
+	}
+	for _, test := range tests {
+		if got := count(test.name, test.query); got != test.want {
+			t.Errorf("%s /me/tasks%s: got %d, want %d", test.name, test.query, got, test.want)
+		}
+	}
+	c.decode(tokens["alice"], "GET", "/me/tasks?filter=bogus", "", http.StatusBadRequest, nil)
+}
+
+func TestComments(t *testing.T) {
+	c := newTestApp(t)
+	tokens := map[string]string{}
+	ids := map[string]int{}
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		var user db.User
+		c.decode("", "POST", "/auth/register", fmt.Sprintf(`{"username":%q,"password":"password-%s"}`, name, name), http.StatusCreated, &user)
+		ids[name] = user.ID
+		tokens[name] = c.login(name)
+	}
+	tokens["admin"] = c.login("admin")
+
+	var published []events.Event
+	c.app.events.Subscribe(func(event events.Event) {
+		if event.Comment != nil {
+			published = append(published, event)
+		}
+	})
+
+	var task db.Task
+	body := fmt.Sprintf(`{"title":"review","due_date":"2099-01-01","assignees":[%d],"watchers":[%d]}`, ids["bob"], ids["carol"])
+	c.decode(tokens["alice"], "POST", "/tasks", body, http.StatusCreated, &task)
+	path := fmt.Sprintf("/tasks/%d/comments", task.ID)
+
+	// dave задачу не видит: его упоминание отбрасывается, а комментировать он не может
+	var first db.Comment
+	c.decode(tokens["alice"], "POST", path, `{"body":"Hi @bob and @dave, see **this**"}`, http.StatusCreated, &first)
+	if first.Author != "alice" || len(first.Mentions) != 1 || first.Mentions[0] != ids["bob"] || !strings.Contains(first.BodyHTML, "<strong>this</strong>") {
+		t.Fatalf("unexpected comment: %+v", first)
+	}
+	c.decode(tokens["dave"], "POST", path, `{"body":"let me in"}`, http.StatusNotFound, nil)
+	c.decode(tokens["carol"], "POST", path, `{"body":"  "}`, http.StatusBadRequest, nil)
+
+	var second db.Comment
+	c.decode(tokens["carol"], "POST", path, `{"body":"looks good"}`, http.StatusCreated, &second)
+
+	// Править может только автор; уведомление получают только впервые упомянутые
+	firstPath := fmt.Sprintf("%s/%d", path, first.ID)
+	c.decode(tokens["bob"], "PUT", firstPath, `{"body":"hijacked"}`, http.StatusForbidden, nil)
+	c.decode(tokens["alice"], "PUT", firstPath, `{"body":"Hi @bob and @carol"}`, http.StatusOK, nil)
+
+	var history []db.CommentRevision
+	c.decode(tokens["carol"], "GET", firstPath+"/history", "", http.StatusOK, &history)
+	if len(history) != 1 || history[0].Body != "Hi @bob and @dave, see **this**" {
+		t.Errorf("unexpected history: %+v", history)
+	}
+
+	if len(published) != 3 || published[0].Type != events.CommentCreated || published[0].Mentioned[0] != ids["bob"] ||
+		len(published[1].Mentioned) != 0 || published[2].Type != events.CommentUpdated ||
+		len(published[2].Mentioned) != 1 || published[2].Mentioned[0] != ids["carol"] {
+		t.Errorf("unexpected comment events: %+v", published)
+	}
+
+	// Постраничный вывод: ссылка на следующую страницу в заголовке Link
+	for i := 0; i < 3; i++ {
+		c.decode(tokens["bob"], "POST", path, fmt.Sprintf(`{"body":"note %d"}`, i), http.StatusCreated, nil)
+	}
+	rr := c.do(tokens["carol"], "GET", path+"?limit=2", "")
+	var page []db.Comment
+	json.NewDecoder(rr.Body).Decode(&page)
+	link := rr.Header().Get("Link")
+	if len(page) != 2 || page[0].ID != first.ID || !strings.Contains(link, fmt.Sprintf("after=%d", second.ID)) {
+		t.Fatalf("unexpected first page %+v, link %q", page, link)
+	}
+	next := strings.TrimPrefix(strings.Split(link, ">")[0], "<")
+	rr = c.do(tokens["carol"], "GET", next, "")
+	page = nil
+	json.NewDecoder(rr.Body).Decode(&page)
+	if len(page) != 2 || page[0].Body != "note 0" || rr.Header().Get("Link") == "" {
+		t.Errorf("unexpected second page: %+v", page)
+	}
+	c.decode(tokens["carol"], "GET", path+"?limit=0", "", http.StatusBadRequest, nil)
+
+	var withComments handlers.TaskWithComments
+	c.decode(tokens["carol"], "GET", fmt.Sprintf("/tasks/%d?include=comments&comments_limit=2", task.ID), "", http.StatusOK, &withComments)
+	if withComments.Task == nil || withComments.Title != "review" || len(withComments.Comments) != 2 || withComments.Comments[1].Body != "note 2" {
+		t.Errorf("unexpected task with comments: %+v", withComments)
+	}
+	c.decode(tokens["carol"], "GET", fmt.Sprintf("/tasks/%d?include=history", task.ID), "", http.StatusBadRequest, nil)
+
+	// Чужой комментарий удаляет только владелец задачи; удаленный остается в ленте без текста
+	secondPath := fmt.Sprintf("%s/%d", path, second.ID)
+	c.decode(tokens["bob"], "DELETE", secondPath, "", http.StatusForbidden, nil)
+	c.decode(tokens["alice"], "DELETE", secondPath, "", http.StatusOK, nil)
+	c.decode(tokens["alice"], "DELETE", secondPath, "", http.StatusNotFound, nil)
+	var deleted db.Comment
+	c.decode(tokens["carol"], "GET", secondPath, "", http.StatusOK, &deleted)
+	if !deleted.Deleted || deleted.Body != "" {
+		t.Errorf("comment is not soft-deleted: %+v", deleted)
+	}
+	c.decode(tokens["carol"], "PUT", secondPath, `{"body":"back"}`, http.StatusNotFound, nil)
+
+	// Вместе с задачей удаляются и комментарии: администратор видит пустую ленту
+	c.decode(tokens["alice"], "DELETE", fmt.Sprintf("/tasks/%d", task.ID), "", http.StatusOK, nil)
+	page = nil
+	c.decode(tokens["admin"], "GET", path, "", http.StatusOK, &page)
+	if len(page) != 0 {
+		t.Errorf("comments survived task deletion: %+v", page)
+	}
+}
+
+// upload отправляет content частью file формы multipart/form-data.
+func (c *testClient) upload(token string, path string, filename string, content []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("comment", "ignored")
+	part, _ := form.CreateFormFile("file", filename)
+	part.Write(content)
+	form.Close()
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rr := httptest.NewRecorder()
+	c.handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAttachments(t *testing.T) {
+	t.Setenv("ATTACHMENTS_MAX_SIZE", "1K")
+	c := newTestApp(t)
+	tokens := map[string]string{"admin": c.login("admin")}
+	ids := map[string]int{}
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		var user db.User
+		c.decode("", "POST", "/auth/register", fmt.Sprintf(`{"username":%q,"password":"password-%s"}`, name, name), http.StatusCreated, &user)
+		ids[name] = user.ID
+		tokens[name] = c.login(name)
+	}
+
+	var task db.Task
+	body := fmt.Sprintf(`{"title":"crash","due_date":"2099-01-01","assignees":[%d],"watchers":[%d]}`, ids["bob"], ids["carol"])
+	c.decode(tokens["alice"], "POST", "/tasks", body, http.StatusCreated, &task)
+	path := fmt.Sprintf("/tasks/%d/attachments", task.ID)
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 592)...)
+	sum := sha256.Sum256(png)
+
+	upload := func(name string, filename string, content []byte, status int) db.Attachment {
+		t.Helper()
+		rr := c.upload(tokens[name], path, filename, content)
+		if rr.Code != status {
+			t.Fatalf("%s upload %s: got %d, want %d: %s", name, filename, rr.Code, status, rr.Body.String())
+		}
+		var attachment db.Attachment
+		json.NewDecoder(rr.Body).Decode(&attachment)
+		return attachment
+	}
+
+	// Тип определяется по содержимому, путь из имени файла отбрасывается
+	first := upload("alice", "../../screenshot.png", png, http.StatusCreated)
+	if first.Hash != hex.EncodeToString(sum[:]) || first.ContentType != "image/png" || first.Filename != "screenshot.png" || first.Size != 600 {
+		t.Fatalf("unexpected attachment: %+v", first)
+	}
+	second := upload("bob", "copy.png", png, http.StatusCreated)
+	upload("carol", "watcher.png", png, http.StatusForbidden)
+	upload("dave", "stranger.png", png, http.StatusNotFound)
+	upload("alice", "huge.log", bytes.Repeat([]byte("x"), 2048), http.StatusRequestEntityTooLarge)
+
+	// Одинаковое содержимое хранится один раз
+	files := 0
+	filepath.WalkDir(os.Getenv("ATTACHMENTS_DIR"), func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files++
+		}
+		return err
+	})
+	if files != 1 {
+		t.Errorf("stored files = %d, want 1", files)
+	}
+
+	var list []db.Attachment
+	c.decode(tokens["carol"], "GET", path, "", http.StatusOK, &list)
+	if len(list) != 2 {
+		t.Fatalf("attachments = %d, want 2", len(list))
+	}
+
+	firstPath := fmt.Sprintf("%s/%d", path, first.ID)
+	rr := c.do(tokens["carol"], "GET", firstPath, "")
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), png) || !strings.Contains(rr.Header().Get("Content-Disposition"), "screenshot.png") {
+		t.Errorf("download: %d %q", rr.Code, rr.Header().Get("Content-Disposition"))
+	}
+	req := httptest.NewRequest("GET", firstPath, nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["carol"])
+	req.Header.Set("Range", "bytes=1-3")
+	rr = httptest.NewRecorder()
+	c.handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "PNG" {
+		t.Errorf("range download: %d %q", rr.Code, rr.Body.String())
+	}
+
+	// Квота пространства учитывает каждое вложение, даже если содержимое совпадает
+	c.decode(tokens["admin"], "PATCH", "/admin/workspaces/1", `{"storage_quota":1500}`, http.StatusOK, nil)
+	upload("alice", "third.png", png, http.StatusInsufficientStorage)
+	c.decode(tokens["admin"], "PATCH", "/admin/workspaces/1", `{"storage_quota":-1}`, http.StatusBadRequest, nil)
+
+	// Чужое вложение удаляет только владелец задачи
+	secondPath := fmt.Sprintf("%s/%d", path, second.ID)
+	c.decode(tokens["carol"], "DELETE", secondPath, "", http.StatusForbidden, nil)
+	c.decode(tokens["bob"], "DELETE", firstPath, "", http.StatusForbidden, nil)
+	c.decode(tokens["bob"], "DELETE", secondPath, "", http.StatusOK, nil)
+	c.decode(tokens["bob"], "DELETE", secondPath, "", http.StatusNotFound, nil)
+	upload("alice", "third.png", png, http.StatusCreated)
+
+	c.decode(tokens["alice"], "DELETE", fmt.Sprintf("/tasks/%d", task.ID), "", http.StatusOK, nil)
+	list = nil
+	c.decode(tokens["admin"], "GET", path, "", http.StatusOK, &list)
+	if len(list) != 0 {
+		t.Errorf("attachments survived task deletion: %+v", list)
+	}
+}
+
+func TestChecklist(t *testing.T) {
+	t.Setenv("CHECKLIST_AUTO_COMPLETE", "true")
+	c := newTestApp(t)
+	tokens := map[string]string{}
+	ids := map[string]int{}
+	for _, name := range []string{"alice", "carol", "dave"} {
+		var user db.User
+		c.decode("", "POST", "/auth/register", fmt.Sprintf(`{"username":%q,"password":"password-%s"}`, name, name), http.StatusCreated, &user)
+		ids[name] = user.ID
+		tokens[name] = c.login(name)
+	}
+
+	var task db.Task
+	c.decode(tokens["alice"], "POST", "/tasks", fmt.Sprintf(`{"title":"release","due_date":"2099-01-01","watchers":[%d]}`, ids["carol"]), http.StatusCreated, &task)
+	path := fmt.Sprintf("/tasks/%d/checklist", task.ID)
+
+	var list handlers.ChecklistResponse
+	c.decode(tokens["alice"], "GET", path, "", http.StatusOK, &list)
+	if len(list.Items) != 0 || list.Progress == nil || list.Progress.Total != 0 {
+		t.Fatalf("unexpected empty checklist: %+v", list)
+	}
+
+	for _, text := range []string{"tag", "build", "publish"} {
+		c.decode(tokens["alice"], "POST", path, fmt.Sprintf(`{"text":%q}`, text), http.StatusCreated, &list)
+	}
+	c.decode(tokens["alice"], "POST", path, `{"text":"changelog","position":0}`, http.StatusCreated, &list)
+	c.decode(tokens["alice"], "POST", path, `{"text":"  "}`, http.StatusBadRequest, nil)
+	c.decode(tokens["carol"], "POST", path, `{"text":"sneak"}`, http.StatusForbidden, nil)
+	c.decode(tokens["dave"], "GET", path, "", http.StatusNotFound, nil)
+
+	texts := func() []string {
+		var texts []string
+		for _, item := range list.Items {
+			texts = append(texts, item.Text)
+		}
+		return texts
+	}
+	if got := strings.Join(texts(), ","); got != "changelog,tag,build,publish" || list.Progress.Total != 4 {
+		t.Fatalf("unexpected checklist %s: %+v", got, list.Progress)
+	}
+	item := func(text string) int {
+		for _, item := range list.Items {
+			if item.Text == text {
+				return item.ID
+			}
+		}
+		t.Fatalf("no checklist item %q", text)
+		return 0
+	}
+
+	// Порядок: целиком через order или перемещением одного пункта
+	order := fmt.Sprintf(`{"ids":[%d,%d,%d,%d]}`, item("tag"), item("build"), item("publish"), item("changelog"))
+	c.decode(tokens["alice"], "PUT", path+"/order", order, http.StatusOK, &list)
+	c.decode(tokens["alice"], "PUT", path+"/order", fmt.Sprintf(`{"ids":[%d]}`, item("tag")), http.StatusBadRequest, nil)
+	c.decode(tokens["alice"], "PATCH", fmt.Sprintf("%s/%d", path, item("changelog")), `{"position":2}`, http.StatusOK, &list)
+	if got := strings.Join(texts(), ","); got != "tag,build,changelog,publish" {
+		t.Fatalf("unexpected order %s", got)
+	}
+
+	c.decode(tokens["alice"], "DELETE", fmt.Sprintf("%s/%d", path, item("build")), "", http.StatusOK, &list)
+	c.decode(tokens["alice"], "DELETE", fmt.Sprintf("%s/%d", path, 9999), "", http.StatusNotFound, nil)
+	c.decode(tokens["alice"], "PATCH", fmt.Sprintf("%s/%d", path, 9999), `{"done":true}`, http.StatusNotFound, nil)
+
+	// Прогресс виден в самой задаче; последний отмеченный пункт завершает ее
+	for _, text := range []string{"tag", "changelog"} {
+		c.decode(tokens["alice"], "PATCH", fmt.Sprintf("%s/%d", path, item(text)), `{"done":true}`, http.StatusOK, &list)
+	}
+	var current db.Task
+	c.decode(tokens["carol"], "GET", fmt.Sprintf("/tasks/%d", task.ID), "", http.StatusOK, &current)
+	if current.ChecklistProgress == nil || current.ChecklistProgress.Done != 2 || current.ChecklistProgress.Total != 3 || current.IsCompleted {
+		t.Fatalf("unexpected task progress: %+v", current)
+	}
+
+	c.decode(tokens["alice"], "PATCH", fmt.Sprintf("%s/%d", path, item("publish")), `{"done":true}`, http.StatusOK, &list)
+	c.decode(tokens["carol"], "GET", fmt.Sprintf("/tasks/%d", task.ID), "", http.StatusOK, &current)
+	if !current.IsCompleted || list.TaskStatus != current.Status || list.Progress.Done != 3 {
+		t.Errorf("task is not auto-completed: %+v, %+v", current, list)
+	}
+}
+
+func TestTimeTracking(t *testing.T) {
+	c := newTestApp(t)
+	tokens := map[string]string{}
+	ids := map[string]int{}
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		var user db.User
+		c.decode("", "POST", "/auth/register", fmt.Sprintf(`{"username":%q,"password":"password-%s"}`, name, name), http.StatusCreated, &user)
+		ids[name] = user.ID
+		tokens[name] = c.login(name)
+	}
+
+	var task, other db.Task
+	body := fmt.Sprintf(`{"title":"invoice","due_date":"2099-01-01","estimate_minutes":90,"assignees":[%d],"watchers":[%d]}`, ids["bob"], ids["carol"])
+	c.decode(tokens["alice"], "POST", "/tasks", body, http.StatusCreated, &task)
+	c.decode(tokens["bob"], "POST", "/tasks", `{"title":"other","due_date":"2099-01-01"}`, http.StatusCreated, &other)
+	if task.EstimateMinutes != 90 {
+		t.Fatalf("unexpected estimate: %+v", task)
+	}
+	c.decode(tokens["alice"], "PUT", fmt.Sprintf("/tasks/%d", task.ID), `{"estimate_minutes":-5}`, http.StatusBadRequest, nil)
+	c.decode(tokens["alice"], "PUT", fmt.Sprintf("/tasks/%d", task.ID), `{"estimate_minutes":120}`, http.StatusOK, &task)
+	if task.EstimateMinutes != 120 {
+		t.Fatalf("estimate is not updated: %+v", task)
+	}
+	path := fmt.Sprintf("/tasks/%d", task.ID)
+
+	// Один таймер на пользователя
+	var timer db.Worklog
+	c.decode(tokens["bob"], "POST", path+"/timer/start", "", http.StatusCreated, &timer)
+	c.decode(tokens["bob"], "POST", fmt.Sprintf("/tasks/%d/timer/start", other.ID), "", http.StatusConflict, nil)
+	c.decode(tokens["carol"], "POST", path+"/timer/start", "", http.StatusForbidden, nil)
+	c.decode(tokens["dave"], "POST", path+"/timer/start", "", http.StatusNotFound, nil)
+
+	var running db.Worklog
+	c.decode(tokens["bob"], "GET", "/me/timer", "", http.StatusOK, &running)
+	if running.ID != timer.ID || running.TaskID != task.ID || running.EndedAt != "" {
+		t.Fatalf("unexpected running timer: %+v", running)
+	}
+	c.decode(tokens["bob"], "POST", fmt.Sprintf("/tasks/%d/timer/stop", other.ID), "", http.StatusConflict, nil)
+	c.decode(tokens["bob"], "POST", path+"/timer/stop", "", http.StatusOK, &timer)
+	if timer.EndedAt == "" || timer.Source != db.WorklogTimer {
+		t.Fatalf("timer is not stopped: %+v", timer)
+	}
+	c.decode(tokens["bob"], "GET", "/me/timer", "", http.StatusNoContent, nil)
+
+	// Записи вручную попадают в отчет за период
+	var manual db.Worklog
+	c.decode(tokens["alice"], "POST", path+"/worklogs", `{"minutes":60,"started_at":"2024-03-10 09:00:00","note":"draft"}`, http.StatusCreated, &manual)
+	c.decode(tokens["bob"], "POST", path+"/worklogs", `{"minutes":30,"started_at":"2024-03-31"}`, http.StatusCreated, nil)
+	c.decode(tokens["bob"], "POST", fmt.Sprintf("/tasks/%d/worklogs", other.ID), `{"minutes":15,"started_at":"2024-03-12"}`, http.StatusCreated, nil)
+	c.decode(tokens["bob"], "POST", path+"/worklogs", `{"minutes":0}`, http.StatusBadRequest, nil)
+	c.decode(tokens["bob"], "POST", path+"/worklogs", `{"minutes":5,"started_at":"yesterday"}`, http.StatusBadRequest, nil)
+	if manual.EndedAt != "2024-03-10 10:00:00" || manual.Minutes != 60 || manual.UserID != ids["alice"] {
+		t.Fatalf("unexpected manual worklog: %+v", manual)
+	}
+
+	var report handlers.TimeReport
+	c.decode(tokens["bob"], "GET", "/reports/time?from=2024-03-01&to=2024-03-31&group_by=user", "", http.StatusOK, &report)
+	if report.TotalMinutes != 105 || len(report.Rows) != 2 || report.Rows[0].Name != "alice" || report.Rows[0].Minutes != 60 || report.Rows[1].Minutes != 45 {
+		t.Fatalf("unexpected user report: %+v", report.Rows)
+	}
+	c.decode(tokens["alice"], "GET", "/reports/time?from=2024-03-01&to=2024-03-30", "", http.StatusOK, &report)
+	if report.GroupBy != db.GroupByTask || len(report.Rows) != 1 || report.Rows[0].Name != "invoice" || report.Rows[0].Minutes != 60 {
+		t.Errorf("unexpected task report for alice: %+v", report.Rows)
+	}
+	c.decode(tokens["dave"], "GET", "/reports/time?group_by=project", "", http.StatusOK, &report)
+	if len(report.Rows) != 0 {
+		t.Errorf("dave sees foreign time: %+v", report.Rows)
+	}
+	c.decode(tokens["alice"], "GET", "/reports/time?group_by=week", "", http.StatusBadRequest, nil)
+	c.decode(tokens["alice"], "GET", "/reports/time?from=2024-03-10&to=2024-03-01", "", http.StatusBadRequest, nil)
+
+	// Чужую запись удаляет только владелец задачи
+	var worklogs []db.Worklog
+	c.decode(tokens["carol"], "GET", path+"/worklogs", "", http.StatusOK, &worklogs)
+	if len(worklogs) != 3 {
+		t.Fatalf("unexpected worklogs: %+v", worklogs)
+	}
+	c.decode(tokens["bob"], "DELETE", fmt.Sprintf("%s/worklogs/%d", path, manual.ID), "", http.StatusForbidden, nil)
+	c.decode(tokens["alice"], "DELETE", fmt.Sprintf("%s/worklogs/%d", path, timer.ID), "", http.StatusOK, nil)
+	c.decode(tokens["alice"], "DELETE", fmt.Sprintf("%s/worklogs/%d", path, timer.ID), "", http.StatusNotFound, nil)
+
+	// Забытый таймер останавливает фоновая задача
+	c.decode(tokens["alice"], "POST", path+"/timer/start", "", http.StatusCreated, &timer)
+	c.app.handler.TimerAutoStop = time.Second
+	time.Sleep(2 * time.Second)
+	c.app.stopStaleTimers(auth.SystemContext(context.Background()))
+	c.decode(tokens["alice"], "GET", "/me/timer", "", http.StatusNoContent, nil)
+	c.decode(tokens["alice"], "GET", path+"/worklogs", "", http.StatusOK, &worklogs)
+	last := worklogs[len(worklogs)-1]
+	if last.ID != timer.ID || !last.AutoStopped || last.Seconds != 1 {
+		t.Errorf("timer is not auto-stopped: %+v", last)
+	}
+}
+
+func TestCustomFields(t *testing.T) {
+	c := newTestApp(t)
+	tokens := map[string]string{}
+	for _, name := range []string{"alice", "bob"} {
+		c.decode("", "POST", "/auth/register", fmt.Sprintf(`{"username":%q,"password":"password-%s"}`, name, name), http.StatusCreated, nil)
+		tokens[name] = c.login(name)
+	}
+
+	var project db.Project
+	c.decode(tokens["alice"], "POST", "/projects", `{"name":"sales"}`, http.StatusCreated, &project)
+	c.decode(tokens["alice"], "POST", fmt.Sprintf("/projects/%d/shares", project.ID), `{"username":"bob","permission":"edit"}`, http.StatusCreated, nil)
+	fields := fmt.Sprintf("/projects/%d/fields", project.ID)
+
+	// Определения полей меняет только владелец проекта
+	for _, body := range []string{
+		`{"key":"customer","name":"Customer","type":"text","required":true}`,
+		`{"key":"points","name":"Story points","type":"number"}`,
+		`{"key":"env","name":"Environment","type":"enum","options":["dev","prod"]}`,
+		`{"key":"release","name":"Release","type":"date"}`,
+		`{"key":"urgent","name":"Urgent","type":"bool"}`,
+	} {
+		c.decode(tokens["alice"], "POST", fields, body, http.StatusCreated, nil)
+	}
+	c.decode(tokens["alice"], "POST", fields, `{"key":"points","name":"Again","type":"number"}`, http.StatusConflict, nil)
+	c.decode(tokens["alice"], "POST", fields, `{"key":"Bad Key","name":"x","type":"text"}`, http.StatusBadRequest, nil)
+	c.decode(tokens["alice"], "POST", fields, `{"key":"kind","name":"Kind","type":"enum"}`, http.StatusBadRequest, nil)
+	c.decode(tokens["alice"], "POST", fields, `{"key":"size","name":"Size","type":"number","options":["s"]}`, http.StatusBadRequest, nil)
+	c.decode(tokens["bob"], "POST", fields, `{"key":"owner","name":"Owner","type":"text"}`, http.StatusForbidden, nil)
+	c.decode(tokens["alice"], "PUT", fields+"/env", `{"options":["dev","stage","prod"]}`, http.StatusOK, nil)
+	c.decode(tokens["alice"], "PUT", fields+"/env", `{"type":"text"}`, http.StatusBadRequest, nil)
+	c.decode(tokens["alice"], "PUT", fields+"/missing", `{"name":"Missing"}`, http.StatusNotFound, nil)
+
+	var definitions []db.CustomField
+	c.decode(tokens["bob"], "GET", fields, "", http.StatusOK, &definitions)
+	if len(definitions) != 5 || definitions[2].Key != "env" || len(definitions[2].Options) != 3 {
+		t.Fatalf("unexpected definitions: %+v", definitions)
+	}
+
+	// Значения проверяются по типу поля
+	create := func(body string, status int) db.Task {
+		var task db.Task
+		var value any
+		if status == http.StatusCreated {
+			value = &task
+		}
+		c.decode(tokens["bob"], "POST", "/tasks",
+			fmt.Sprintf(`{"title":"deal","due_date":"2099-01-01","project_id":%d,"custom_fields":%s}`, project.ID, body), status, value)
+		return task
+	}
+	create(`{"points":3}`, http.StatusBadRequest)
+	create(`{"customer":"acme","points":"three"}`, http.StatusBadRequest)
+	create(`{"customer":"acme","env":"qa"}`, http.StatusBadRequest)
+	create(`{"customer":"acme","release":"next week"}`, http.StatusBadRequest)
+	create(`{"customer":"acme","unknown":1}`, http.StatusBadRequest)
+	c.decode(tokens["bob"], "POST", "/tasks", `{"title":"loose","due_date":"2099-01-01","custom_fields":{"customer":"acme"}}`, http.StatusBadRequest, nil)
+
+	small := create(`{"customer":"acme","points":2,"env":"prod","urgent":true}`, http.StatusCreated)
+	large := create(`{"customer":"globex","points":8,"env":"dev","release":"2099-02-01"}`, http.StatusCreated)
+	create(`{"customer":"initech","env":"prod"}`, http.StatusCreated)
+	if small.CustomFields["customer"] != "acme" || small.CustomFields["points"] != 2.0 || small.CustomFields["urgent"] != true {
+		t.Fatalf("unexpected custom fields: %+v", small.CustomFields)
+	}
+
+	// null удаляет значение, обязательное поле удалить нельзя
+	path := fmt.Sprintf("/tasks/%d", small.ID)
+	c.decode(tokens["bob"], "PUT", path, `{"custom_fields":{"customer":null}}`, http.StatusBadRequest, nil)
+	var updated db.Task
+	c.decode(tokens["bob"], "PUT", path, `{"custom_fields":{"urgent":null,"points":3}}`, http.StatusOK, &updated)
+	if _, ok := updated.CustomFields["urgent"]; ok || updated.CustomFields["points"] != 3.0 || updated.CustomFields["env"] != "prod" {
+		t.Fatalf("custom fields are not merged: %+v", updated.CustomFields)
+	}
+
+	customers := func(query string) []string {
+		var tasks []db.Task
+		c.decode(tokens["bob"], "GET", "/tasks?"+query, "", http.StatusOK, &tasks)
+		var result []string
+		for _, task := range tasks {
+			result = append(result, task.CustomFields["customer"].(string))
+		}
+		return result
+	}
+	projectQuery := fmt.Sprintf("project_id=%d", project.ID)
+	if got := customers(projectQuery + "&cf.env=prod&sort=-cf.points"); !slices.Equal(got, []string{"acme", "initech"}) {
+		t.Errorf("unexpected filtered tasks: %v", got)
+	}
+	if got := customers(projectQuery + "&sort=-cf.points"); !slices.Equal(got, []string{"globex", "acme", "initech"}) {
+		t.Errorf("unexpected sorted tasks: %v", got)
+	}
+	if got := customers(projectQuery + "&cf.points=8"); !slices.Equal(got, []string{"globex"}) {
+		t.Errorf("unexpected number filter: %v", got)
+	}
+	c.decode(tokens["bob"], "GET", "/tasks?cf.env=prod", "", http.StatusBadRequest, nil)
+	c.decode(tokens["bob"], "GET", "/tasks?"+projectQuery+"&cf.points=many", "", http.StatusBadRequest, nil)
+	c.decode(tokens["bob"], "GET", "/tasks?"+projectQuery+"&sort=-cf.unknown", "", http.StatusBadRequest, nil)
+
+	// Удаление поля убирает его значения из задач
+	c.decode(tokens["alice"], "DELETE", fields+"/points", "", http.StatusOK, nil)
+	c.decode(tokens["alice"], "DELETE", fields+"/points", "", http.StatusNotFound, nil)
+	var reloaded db.Task
+	c.decode(tokens["bob"], "GET", fmt.Sprintf("/tasks/%d", large.ID), "", http.StatusOK, &reloaded)
+	if _, ok := reloaded.CustomFields["points"]; ok || reloaded.CustomFields["release"] != "2099-02-01" {
+		t.Errorf("field values are not removed: %+v", reloaded.CustomFields)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	c := newTestApp(t)
+	c.decode("", "POST", "/auth/register", `{"username":"alice","password":"password-alice"}`, http.StatusCreated, nil)
+	admin, alice := c.login("admin"), c.login("alice")
+	t.Cleanup(func() { logging.SetLevel("info") })
+
+	var level handlers.LogLevel
+	c.decode(admin, "PUT", "/admin/log-level", `{"level":"debug"}`, http.StatusOK, &level)
+	if level.Level != "debug" || logging.Level() != "debug" {
+		t.Fatalf("level is not changed: %+v", level)
+	}
+	c.decode(admin, "PUT", "/admin/log-level", `{"level":"verbose"}`, http.StatusBadRequest, nil)
+	c.decode(alice, "PUT", "/admin/log-level", `{"level":"error"}`, http.StatusForbidden, nil)
+	c.decode(admin, "GET", "/admin/log-level", "", http.StatusOK, &level)
+	if level.Level != "debug" {
+		t.Errorf("unexpected level: %+v", level)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	t.Setenv("METRICS_TOKEN", "scrape-secret")
+	c := newTestApp(t)
+	c.decode("", "POST", "/auth/register", `{"username":"alice","password":"password-alice"}`, http.StatusCreated, nil)
+	alice := c.login("alice")
+
+	var done db.Task
+	c.decode(alice, "POST", "/tasks", `{"title":"open","due_date":"2099-01-01"}`, http.StatusCreated, nil)
+	c.decode(alice, "POST", "/tasks", `{"title":"done","due_date":"2099-01-01"}`, http.StatusCreated, &done)
+	c.decode(alice, "PATCH", fmt.Sprintf("/tasks/%d/complete", done.ID), "", http.StatusOK, nil)
+	c.decode(alice, "GET", "/tasks", "", http.StatusOK, nil)
+	c.decode(alice, "GET", "/tasks/999999", "", http.StatusNotFound, nil)
+
+	if rr := c.do("", "GET", "/metrics", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("metrics without token: %d", rr.Code)
+	}
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-secret")
+	rr := httptest.NewRecorder()
+	c.handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != metrics.ContentType {
+		t.Fatalf("metrics: %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	body := rr.Body.String()
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/tasks",status="200"} `,
+		`http_requests_total{method="GET",route="/tasks/{id}",status="404"} `,
+		`http_request_duration_seconds_bucket{method="POST",route="/tasks",status="201",le="+Inf"} `,
+		`db_query_duration_seconds_count{method="GetVisibleTasks"} `,
+		`db_open_connections `,
+		"tasks{state=\"open\"} 1\n",
+		"tasks{state=\"completed\"} 1\n",
+		"tasks{state=\"overdue\"} 0\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("metrics do not contain %q", line)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	c := newTestApp(t)
+	c.decode("", "POST", "/auth/register", `{"username":"alice","password":"password-alice"}`, http.StatusCreated, nil)
+	admin, alice := c.login("admin"), c.login("alice")
+
+	if rr := c.do("", "GET", "/healthz", ""); rr.Code != http.StatusOK {
+		t.Fatalf("healthz: %d", rr.Code)
+	}
+
+	// Пока фоновые задачи не запущены, сервис не готов
+	var readiness Readiness
+	c.decode("", "GET", "/readyz", "", http.StatusServiceUnavailable, &readiness)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.app.StartBackgroundTask(ctx)
+	t.Cleanup(func() {
+		cancel()
+		c.app.Wait()
+	})
+
+	c.decode("", "GET", "/readyz", "", http.StatusOK, &readiness)
+	if !readiness.Ready || len(readiness.Checks) != 4 {
+		t.Fatalf("unexpected readiness: %+v", readiness)
+	}
+	if err := c.app.checkOverdueJob(time.Now().Add(3 * c.app.config.Load().Jobs.Interval)); err == nil {
+		t.Error("stale overdue job is reported as healthy")
+	}
+
+	var diagnostics Diagnostics
+	c.decode(alice, "GET", "/admin/diagnostics", "", http.StatusForbidden, nil)
+	c.decode(admin, "GET", "/admin/diagnostics", "", http.StatusOK, &diagnostics)
+	if diagnostics.Config["AUTH_ADMIN_PASSWORD"] != "[redacted]" || diagnostics.Config["AUTH_ADMIN_USERNAME"] != "admin" {
+		t.Errorf("unexpected config: %v", diagnostics.Config)
+	}
+	if diagnostics.Database.SizeBytes == 0 || diagnostics.Database.SchemaVersion == 0 || diagnostics.Build.GoVersion == "" {
+		t.Errorf("unexpected diagnostics: %+v", diagnostics)
+	}
+
+	c.app.BeginShutdown()
+	c.decode("", "GET", "/readyz", "", http.StatusServiceUnavailable, &readiness)
+	if readiness.Ready || readiness.Checks[0].Name != "shutdown" {
+		t.Errorf("readiness does not report shutdown: %+v", readiness)
+	}
+	if rr := c.do("", "GET", "/healthz", ""); rr.Code != http.StatusOK {
+		t.Errorf("healthz during shutdown: %d", rr.Code)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	c := newTestApp(t)
+	c.decode("", "POST", "/auth/register", `{"username":"alice","password":"password-alice"}`, http.StatusCreated, nil)
+	alice := c.login("alice")
+	c.decode(alice, "POST", "/tasks", `{"title":"traced","due_date":"2099-01-01"}`, http.StatusCreated, nil)
+
+	var out bytes.Buffer
+	tracer := tracing.NewTracer(tracing.NewWriterExporter(&out), tracing.Sampler{Ratio: 1})
+	previous := tracing.Default()
+	tracing.SetDefault(tracer)
+	t.Cleanup(func() { tracing.SetDefault(previous) })
+	c.handler = tracing.Middleware(logging.Middleware(c.handler))
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req := httptest.NewRequest("GET", "/tasks", nil)
+	req.Header.Set("Authorization", "Bearer "+alice)
+	req.Header.Set(tracing.TraceparentHeader, "00-"+traceID+"-"+parentID+"-01")
+	rr := httptest.NewRecorder()
+	c.handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /tasks: %d", rr.Code)
+	}
+	c.app.checkOverdue(auth.SystemContext(context.Background()))
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := map[string][]tracing.SpanData{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var span tracing.SpanData
+		if err := decoder.Decode(&span); err != nil {
+			t.Fatal(err)
+		}
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+
+	server := byName["GET /tasks"]
+	if len(server) != 1 || server[0].TraceID != traceID || server[0].ParentSpanID != parentID ||
+		server[0].Attributes["http.response.status_code"] != 200.0 {
+		t.Fatalf("unexpected server span: %+v", server)
+	}
+	// Каждый запрос метода - отдельный спан: сами задачи, затем люди и чек-листы
+	query := byName["db GetVisibleTasks"]
+	if len(query) != 3 || query[0].TraceID != traceID || query[0].ParentSpanID != server[0].SpanID ||
+		!strings.Contains(query[0].Attributes["db.statement"].(string), "FROM tasks") {
+		t.Errorf("unexpected query span: %+v", query)
+	}
+
+	job := byName["job overdue"]
+	if len(job) != 1 || job[0].ParentSpanID != "" || job[0].Attributes["overdue"] != 0.0 {
+		t.Fatalf("unexpected job span: %+v", job)
+	}
+	update := byName["db UpdateOverdueTasks"]
+	if len(update) == 0 || update[0].TraceID != job[0].TraceID {
+		t.Errorf("job queries are not traced: %+v", update)
+	}
+}
+
+func TestReload(t *testing.T) {
+	c := newTestApp(t)
+	admin := c.login("admin")
+	t.Cleanup(func() { logging.SetLevel("info") })
+	sub, _, _ := c.app.hub.Subscribe("", events.Filter{Types: []string{events.ConfigChanged}})
+	defer c.app.hub.Unsubscribe(sub)
+
+	next := c.app.config.Load().Clone()
+	next.Log.Level = "debug"
+	next.CORS.Origins = []string{"https://app.example.com"}
+	next.RateLimit = config.RateLimit{RPS: 1, Burst: 2}
+	next.Webhooks.Timeout = 3 * time.Second
+	next.Database.Path = filepath.Join(t.TempDir(), "other.db")
+
+	change, err := c.app.Reload(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantApplied := []string{"cors.origins", "rate_limit.rps", "rate_limit.burst", "log.level", "webhooks.timeout"}
+	if change.Version != 2 || !slices.Equal(change.Applied, wantApplied) || !slices.Equal(change.RestartRequired, []string{"database.path"}) {
+		t.Fatalf("unexpected change: %+v", change)
+	}
+	if logging.Level() != "debug" {
+		t.Errorf("log level is not applied: %s", logging.Level())
+	}
+	select {
+	case envelope := <-sub.C:
+		if envelope.Event.Config == nil || envelope.Event.Config.Version != 2 {
+			t.Errorf("unexpected event: %+v", envelope.Event)
+		}
+	default:
+		t.Error("config.changed is not published")
+	}
+
+	diagnostics := c.app.Diagnostics()
+	if diagnostics.ConfigVersion != 2 || diagnostics.Config["LOG_LEVEL"] != "debug" ||
+		diagnostics.Config["FILEPATH"] == next.Database.Path || !slices.Equal(diagnostics.RestartRequired, []string{"database.path"}) {
+		t.Errorf("unexpected diagnostics: %d %v %v", diagnostics.ConfigVersion, diagnostics.RestartRequired, diagnostics.Config)
+	}
+
+	// Предварительный запрос браузера проходит без токена и без учета в ограничении
+	preflight := httptest.NewRequest("OPTIONS", "/tasks", nil)
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	preflight.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+	rr := httptest.NewRecorder()
+	c.handler.ServeHTTP(rr, preflight)
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		rr.Header().Get("Access-Control-Allow-Headers") != "authorization, content-type" {
+		t.Errorf("unexpected preflight response: %d %v", rr.Code, rr.Header())
+	}
+
+	request := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+admin)
+		req.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+		c.handler.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := request("https://evil.example.com"); rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("unknown origin is allowed: %d %v", rr.Code, rr.Header())
+	}
+	if rr := request("https://app.example.com"); rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Errorf("allowed origin: %d %v", rr.Code, rr.Header())
+	}
+	if rr := request("https://app.example.com"); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("rate limit is not applied: %d %v", rr.Code, rr.Header())
+	}
+
+	// Повторное чтение тех же настроек ничего не применяет, но напоминает о перезапуске
+	change, err = c.app.Reload(next)
+	if err != nil || change.Version != 2 || len(change.Applied) != 0 || len(change.RestartRequired) != 1 {
+		t.Errorf("unexpected repeated reload: %+v %v", change, err)
+	}
+}
+
+// writeCertificate записывает самоподписанный сертификат для name и его ключ в dir.
+func writeCertificate(t *testing.T, dir string, name string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func TestReloadCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir, "old.example.com")
+	t.Setenv("TLS_CERT_FILE", certFile)
+	t.Setenv("TLS_KEY_FILE", keyFile)
+	c := newTestApp(t)
+	t.Cleanup(func() { logging.SetLevel("info") })
+
+	server := c.app.StartServer()
+	commonName := func() string {
+		certificate, err := server.TLSConfig.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := x509.ParseCertificate(certificate.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed.Subject.CommonName
+	}
+	if commonName() != "old.example.com" {
+		t.Fatalf("unexpected certificate %s", commonName())
+	}
+
+	// Файлы обновлены на месте: пути те же, но сертификат перечитывается
+	writeCertificate(t, dir, "new.example.com")
+	if _, err := c.app.Reload(c.app.config.Load().Clone()); err != nil {
+		t.Fatal(err)
+	}
+	if commonName() != "new.example.com" {
+		t.Errorf("certificate is not reloaded: %s", commonName())
+	}
+
+	os.WriteFile(certFile, []byte("broken"), 0o600)
+	next := c.app.config.Load().Clone()
+	next.Log.Level = "debug"
+	if _, err := c.app.Reload(next); err == nil {
+		t.Fatal("broken certificate is accepted")
+	}
+	if commonName() != "new.example.com" || c.app.config.Load().Log.Level != "info" {
+		t.Error("failed reload changes the active configuration")
+	}
+
+	next.Server.TLSCert, next.Server.TLSKey = "", ""
+	writeCertificate(t, dir, "new.example.com")
+	if change, err := c.app.Reload(next); err != nil || !slices.Equal(change.RestartRequired, []string{"server.tls_cert", "server.tls_key"}) {
+		t.Errorf("disabling TLS does not require restart: %+v %v", change, err)
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	c := newTestApp(t)
+	admin := c.login("admin")
+
+	request := func(method string, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+admin)
+		req.Header.Set("Accept", "application/problem+json, application/json")
+		rr := httptest.NewRecorder()
+		c.handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request("GET", "/tasks/42")
+	var body problem
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil || rr.Header().Get("Content-Type") != ProblemContentType {
+		t.Fatalf("not a problem: %v %v", err, rr.Header())
+	}
+	if rr.Code != http.StatusNotFound || body.Status != http.StatusNotFound || body.Title != "Not Found" ||
+		!strings.Contains(body.Detail, "not found") || body.Instance != "/tasks/42" {
+		t.Errorf("unexpected problem: %d %+v", rr.Code, body)
+	}
+
+	// Ответ без тела тоже получает описание ошибки
+	if rr := request("DELETE", "/tasks/42"); rr.Code != http.StatusNotFound || rr.Header().Get("Content-Type") != ProblemContentType {
+		t.Errorf("empty error: %d %v", rr.Code, rr.Header())
+	}
+	// Успешные ответы и клиенты без problem+json в Accept не затрагиваются
+	if rr := request("GET", "/tasks"); rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("success response: %d %v", rr.Code, rr.Header())
+	}
+	if rr := c.do(admin, "GET", "/tasks/42", ""); !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("plain client: %v", rr.Header())
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	c := newTestApp(t)
+	tokens := map[string]string{}
+	for _, name := range []string{"alice", "bob"} {
+		c.decode("", "POST", "/auth/register", fmt.Sprintf(`{"username":%q,"password":"password-%s"}`, name, name), http.StatusCreated, nil)
+		tokens[name] = c.login(name)
+	}
+	alice := tokens["alice"]
+
+	var project db.Project
+	c.decode(alice, "POST", "/projects", `{"name":"sales"}`, http.StatusCreated, &project)
+	c.decode(alice, "POST", fmt.Sprintf("/projects/%d/fields", project.ID), `{"key":"points","name":"Points","type":"number"}`, http.StatusCreated, nil)
+
+	byExternalID := func() map[string]db.Task {
+		var tasks []db.Task
+		c.decode(alice, "GET", "/tasks", "", http.StatusOK, &tasks)
+		result := map[string]db.Task{}
+		for _, task := range tasks {
+			result[task.ExternalID] = task
+		}
+		return result
+	}
+
+	// Загрузка создает задачи, проект находится по названию, статус меняется переходом
+	var report handlers.ImportReport
+	c.decode(alice, "POST", "/import", `[
+		{"external_id":"A-1","title":"first","due_date":"2099-01-01","project":"sales","custom_fields":{"points":3}},
+		{"external_id":"A-2","title":"second","due_date":"2099-01-02 10:00:00","status":"in_progress"}
+	]`, http.StatusOK, &report)
+	if !report.Committed || report.Created != 2 || report.Failed != 0 || report.Rows[1].TaskID == 0 || report.Rows[1].ExternalID != "A-2" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	tasks := byExternalID()
+	if first := tasks["A-1"]; first.ProjectID != project.ID || first.CustomFields["points"] != 3.0 || first.DueDate != "2099-01-01 23:59:59" {
+		t.Errorf("unexpected first task: %+v", first)
+	}
+	if second := tasks["A-2"]; second.Status != "in_progress" || second.DueDate != "2099-01-02 10:00:00" {
+		t.Errorf("unexpected second task: %+v", second)
+	}
+
+	// Выгрузка в JSON - массив задач с названием проекта
+	rr := c.do(alice, "GET", "/export", "")
+	var exported []map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &exported); err != nil || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("JSON export: %v %v %s", err, rr.Header(), rr.Body.String())
+	}
+	if len(exported) != 2 || exported[0]["project"] != "sales" || exported[0]["external_id"] != "A-1" {
+		t.Errorf("unexpected JSON export: %v", exported)
+	}
+	if rr := c.do(tokens["bob"], "GET", "/export", ""); rr.Code != http.StatusOK || rr.Body.String() != "[]\n" {
+		t.Errorf("bob sees tasks of alice: %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = c.do(alice, "GET", "/export?format=csv&columns=Key=external_id,Name=title,Project=project,Points=cf.points", "")
+	if want := "Key,Name,Project,Points\r\nA-1,first,sales,3\r\nA-2,second,,\r\n"; rr.Body.String() != want || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("CSV export: %q, want %q", rr.Body.String(), want)
+	}
+	rr = c.do(alice, "GET", "/export?format=csv", "")
+	if header, _, _ := strings.Cut(rr.Body.String(), "\r\n"); !strings.HasPrefix(header, "id,external_id,title,") || !strings.Contains(rr.Body.String(), `"{""points"":3}"`) {
+		t.Errorf("default CSV columns: %q", rr.Body.String())
+	}
+
+	// CSV со своими заголовками: задача с известным external_id меняется, пустые ячейки ее не трогают
+	csvBody := "Key,Name,Due,Points,Notes\nA-1,renamed,2099-02-01,5,ignored\nA-3,third,,,\n"
+	c.decode(alice, "POST", "/import?format=csv&columns=Key=external_id,Name=title,Due=due_date,Points=cf.points,Notes=-", csvBody, http.StatusOK, &report)
+	if report.Created != 1 || report.Updated != 1 || report.Rows[0].Action != "updated" {
+		t.Fatalf("unexpected CSV report: %+v", report)
+	}
+	if first := byExternalID()["A-1"]; first.Title != "renamed" || first.DueDate != "2099-02-01 23:59:59" ||
+		first.CustomFields["points"] != 5.0 || first.ProjectID != project.ID {
+		t.Errorf("CSV update: %+v", first)
+	}
+
+	// Проверка без сохранения
+	report = handlers.ImportReport{}
+	c.decode(alice, "POST", "/import?dry_run=true&format=ndjson", `{"external_id":"A-2","title":"changed"}`+"\n"+`{"title":"new","due_date":"2099-01-01"}`, http.StatusOK, &report)
+	if !report.DryRun || report.Committed || report.Updated != 1 || report.Created != 1 || report.Rows[1].TaskID != 0 {
+		t.Errorf("unexpected dry run report: %+v", report)
+	}
+	if tasks := byExternalID(); len(tasks) != 3 || tasks["A-2"].Title != "second" {
+		t.Errorf("dry run changed tasks: %+v", tasks)
+	}
+
+	// Ошибка в одной записи откатывает всю загрузку
+	rr = c.do(alice, "POST", "/import", `[{"external_id":"A-1","title":"again"},{"title":"late","due_date":"yesterday"},{"title":5},{"project":"missing"}]`)
+	report = handlers.ImportReport{}
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	if rr.Code != http.StatusUnprocessableEntity || report.Committed || report.Failed != 3 || report.Rows[0].Action != "updated" ||
+		!strings.Contains(report.Rows[1].Error, "due_date") || !strings.Contains(report.Rows[3].Error, "unknown project") {
+		t.Errorf("failed import: %d %+v", rr.Code, report)
+	}
+	if first := byExternalID()["A-1"]; first.Title != "renamed" {
+		t.Errorf("failed import is not rolled back: %+v", first)
+	}
+	c.decode(alice, "POST", "/import", `[{"title":"a"},{"title":`, http.StatusBadRequest, nil)
+	c.decode(alice, "POST", "/import?format=csv", "Key,Title\nA-1,x\n", http.StatusBadRequest, nil)
+	c.decode(alice, "POST", "/import?format=xml", "<tasks/>", http.StatusBadRequest, nil)
+	c.decode(alice, "GET", "/export?format=xml", "", http.StatusBadRequest, nil)
+	c.decode(alice, "GET", "/export?project_id=1&sort=-cf.points", "", http.StatusBadRequest, nil)
+	c.decode("", "POST", "/import", "[]", http.StatusUnauthorized, nil)
+
+	// Выгрузка читает задачи страницами
+	var bulk strings.Builder
+	for i := range 600 {
+		fmt.Fprintf(&bulk, `{"title":"bulk %d","due_date":"2099-03-01"}`+"\n", i)
+	}
+	c.decode(alice, "POST", "/import?format=ndjson", bulk.String(), http.StatusOK, &report)
+	rr = c.do(alice, "GET", "/export?format=ndjson", "")
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 603 || !strings.Contains(lines[602], `"bulk 599"`) || rr.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("NDJSON export has %d lines, last %q", len(lines), lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,177 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// ReadinessCheck - результат одной проверки GET /readyz.
+type ReadinessCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type Readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+type BuildInfo struct {
+	GoVersion string            `json:"go_version"`
+	Path      string            `json:"path,omitempty"`
+	Version   string            `json:"version,omitempty"`
+	Settings  map[string]string `json:"settings,omitempty"`
+}
+
+type DatabaseInfo struct {
+	Path          string `json:"path,omitempty"`
+	SizeBytes     int64  `json:"size_bytes"`
+	SchemaVersion int    `json:"schema_version"`
+}
+
+// Diagnostics - ответ GET /admin/diagnostics. Config содержит действующие настройки по именам
+// переменных окружения со скрытыми секретами, ConfigVersion растет с каждым примененным
+// перечитыванием, RestartRequired - измененные настройки, которым нужен перезапуск.
+type Diagnostics struct {
+	Build           BuildInfo         `json:"build"`
+	Config          map[string]string `json:"config"`
+	ConfigVersion   int64             `json:"config_version"`
+	RestartRequired []string          `json:"restart_required,omitempty"`
+	StartedAt       string            `json:"started_at"`
+	UptimeSeconds   int64             `json:"uptime_seconds"`
+	ShuttingDown    bool              `json:"shutting_down"`
+	Database        DatabaseInfo      `json:"database"`
+}
+
+// BeginShutdown переводит /readyz в состояние отказа, чтобы балансировщик перестал
+// направлять запросы, пока сервер завершает текущие.
+func (a *App) BeginShutdown() {
+	a.shuttingDown.Store(true)
+}
+
+// Readiness проверяет, что сервис может обслуживать запросы: база отвечает и доступна на
+// запись, схема соответствует коду, а проверка просроченных задач запускалась не позже
+// двух интервалов назад.
+func (a *App) Readiness() *Readiness {
+	readiness := &Readiness{Ready: true}
+	check := func(name string, err error) {
+		result := ReadinessCheck{Name: name, OK: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+			readiness.Ready = false
+		}
+		readiness.Checks = append(readiness.Checks, result)
+	}
+
+	if a.shuttingDown.Load() {
+		check("shutdown", fmt.Errorf("server is shutting down"))
+	}
+	check("database", a.repository.Ping())
+	check("migrations", a.checkSchema())
+	check("writable", a.repository.CheckWritable())
+	check("overdue_job", a.checkOverdueJob(time.Now()))
+	return readiness
+}
+
+func (a *App) checkSchema() error {
+	current, expected, err := a.repository.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current != expected {
+		return fmt.Errorf("schema version %d, expected %d", current, expected)
+	}
+	return nil
+}
+
+// checkOverdueJob отсчитывает два интервала от последнего запуска проверки просроченных
+// задач, а до первого запуска - от старта фоновых задач.
+func (a *App) checkOverdueJob(now time.Time) error {
+	started := a.backgroundStarted.Load()
+	if started == 0 {
+		return fmt.Errorf("background jobs are not running")
+	}
+	last := max(started, a.overdueChecked.Load())
+	if since := now.Sub(time.Unix(0, last)); since > 2*a.config.Load().Jobs.Interval {
+		return fmt.Errorf("overdue job has not run for %s", since.Round(time.Second))
+	}
+	return nil
+}
+
+// Diagnostics собирает сведения о сборке, настройках, времени работы и базе.
+func (a *App) Diagnostics() *Diagnostics {
+	a.reloadMu.Lock()
+	version, pending := a.configVersion, a.pendingRestart
+	a.reloadMu.Unlock()
+
+	diagnostics := &Diagnostics{
+		Build:           buildInfo(),
+		Config:          a.config.Load().Redacted(),
+		ConfigVersion:   version,
+		RestartRequired: pending,
+		StartedAt:       a.startedAt.Format(time.RFC3339),
+		UptimeSeconds:   int64(time.Since(a.startedAt).Seconds()),
+		ShuttingDown:    a.shuttingDown.Load(),
+		Database:        DatabaseInfo{Path: a.repository.Path()},
+	}
+	diagnostics.Database.SizeBytes, _ = a.repository.FileSize()
+	diagnostics.Database.SchemaVersion, _, _ = a.repository.SchemaVersion()
+	return diagnostics
+}
+
+// buildInfo берет из debug.ReadBuildInfo версию и сведения VCS. Остальные настройки
+// сборки (например, -ldflags) не показываются: в них могут быть секреты.
+func buildInfo() BuildInfo {
+	info := BuildInfo{GoVersion: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.Path = build.Main.Path
+	info.Version = build.Main.Version
+	info.Settings = map[string]string{}
+	for _, setting := range build.Settings {
+		if strings.HasPrefix(setting.Key, "vcs.") || setting.Key == "GOOS" || setting.Key == "GOARCH" || setting.Key == "CGO_ENABLED" {
+			info.Settings[setting.Key] = setting.Value
+		}
+	}
+	return info
+}
+
+// GET /healthz - Процесс жив и обрабатывает запросы
+func (a *App) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// GET /readyz - Готовность принимать запросы; 503, если какая-то проверка не прошла
+func (a *App) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	readiness := a.Readiness()
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, readiness)
+}
+
+// GET /admin/diagnostics - Сборка, настройки без секретов, время работы и размер базы
+func (a *App) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, a.Diagnostics())
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
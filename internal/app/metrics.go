@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"todo/internal/auth"
+	"todo/internal/db"
+	"todo/internal/logging"
+	"todo/internal/metrics"
+)
+
+// registerMetrics добавляет к метрикам пакета metrics статистику пула соединений основной
+// базы и число задач во всех пространствах. Значения вычисляются при каждом чтении /metrics.
+func (a *App) registerMetrics(repository *db.TaskRepository) {
+	pool := func(value func(stats sql.DBStats) float64) func() []metrics.Sample {
+		return func() []metrics.Sample {
+			stats, ok := repository.DBStats()
+			if !ok {
+				return nil
+			}
+			return []metrics.Sample{{Value: value(stats)}}
+		}
+	}
+
+	metrics.Default.Register(
+		metrics.NewGaugeFunc("db_open_connections", "Open connections to the main database.",
+			pool(func(s sql.DBStats) float64 { return float64(s.OpenConnections) })),
+		metrics.NewGaugeFunc("db_in_use_connections", "Connections to the main database currently in use.",
+			pool(func(s sql.DBStats) float64 { return float64(s.InUse) })),
+		metrics.NewGaugeFunc("db_idle_connections", "Idle connections to the main database.",
+			pool(func(s sql.DBStats) float64 { return float64(s.Idle) })),
+		metrics.NewGaugeFunc("db_max_open_connections", "Maximum open connections to the main database (0 - unlimited).",
+			pool(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })),
+		metrics.NewCounterFunc("db_wait_count_total", "Connections waited for in the main database pool.",
+			pool(func(s sql.DBStats) float64 { return float64(s.WaitCount) })),
+		metrics.NewCounterFunc("db_wait_duration_seconds_total", "Time spent waiting for a connection to the main database.",
+			pool(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })),
+		metrics.NewGaugeFunc("tasks", "Tasks in all workspaces by state: open, overdue or completed.", a.taskSamples, "state"),
+	)
+}
+
+// taskSamples считает задачи во всех пространствах для метрики tasks.
+func (a *App) taskSamples() []metrics.Sample {
+	var total db.TaskCounts
+	ctx := auth.SystemContext(context.Background())
+	err := a.tenants.Each(ctx, func(ctx context.Context, repo *db.TaskRepository) error {
+		counts, err := repo.CountTasks(ctx)
+		if err != nil {
+			return err
+		}
+		total.Open += counts.Open
+		total.Overdue += counts.Overdue
+		total.Completed += counts.Completed
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to count tasks for metrics", "error", err)
+	}
+
+	return []metrics.Sample{
+		{Labels: []string{"open"}, Value: float64(total.Open)},
+		{Labels: []string{"overdue"}, Value: float64(total.Overdue)},
+		{Labels: []string{"completed"}, Value: float64(total.Completed)},
+	}
+}
+
+// metricsHandler отдает метрики; при заданном METRICS_TOKEN требует его в Authorization: Bearer.
+func (a *App) metricsHandler() http.Handler {
+	handler := metrics.Default.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.metricsToken != "" {
+			token := []byte("Bearer " + a.metricsToken)
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), token) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
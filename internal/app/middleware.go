@@ -0,0 +1,168 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"todo/internal/logging"
+	"todo/internal/tenant"
+)
+
+// corsExposedHeaders - заголовки ответа, которые нужны браузерному клиенту: пагинация,
+// пространство и идентификатор запроса.
+var corsExposedHeaders = strings.Join([]string{"Link", tenant.Header, logging.RequestIDHeader, "Retry-After"}, ", ")
+
+// CORSMiddleware разрешает запросы из браузера с источников cors.origins и отвечает на
+// предварительные запросы OPTIONS до аутентификации: браузер не передает в них токен.
+func (a *App) CORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		origins := a.config.Load().CORS.Origins
+		if origin == "" || !(slices.Contains(origins, "*") || slices.Contains(origins, origin)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+			header.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimitMiddleware ограничивает частоту запросов с одного адреса (rate_limit.rps).
+// Проверки здоровья и метрики не ограничиваются, чтобы мониторинг не получал отказов.
+func (a *App) RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz", "/readyz", "/metrics":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if ok, wait := a.limiter.Allow(client); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ProblemContentType - тип ответа с ошибкой по RFC 9457.
+const ProblemContentType = "application/problem+json"
+
+// problem - тело ошибки по RFC 9457.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// DeprecatedRoutesMiddleware переводит устаревший адрес PATCH /tasks/complete/{id} на
+// PATCH /tasks/{id}/complete. В ServeMux оба шаблона не зарегистрировать: они пересекаются
+// на /tasks/complete/complete. Ответ помечается заголовками Deprecation и Link на новый адрес.
+func DeprecatedRoutesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := strings.CutPrefix(r.URL.Path, "/tasks/complete/")
+		if !ok || id == "" || strings.Contains(id, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		path := "/tasks/" + id + "/complete"
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+path+`>; rel="successor-version"`)
+
+		r = r.Clone(r.Context())
+		r.URL.Path, r.URL.RawPath = path, ""
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ProblemMiddleware отдает ошибки в формате application/problem+json клиентам, которые
+// просят его в Accept. Обработчики по-прежнему пишут текст через http.Error, а
+// остальные клиенты получают ответы без изменений.
+func ProblemMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || !strings.Contains(r.Header.Get("Accept"), ProblemContentType) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		recorder := &problemRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			return
+		}
+
+		body := problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(recorder.status),
+			Status:   recorder.status,
+			Detail:   strings.TrimSpace(recorder.body.String()),
+			Instance: r.URL.Path,
+		}
+		header := w.Header()
+		header.Del("Content-Length")
+		header.Set("Content-Type", ProblemContentType)
+		w.WriteHeader(recorder.status)
+		json.NewEncoder(w).Encode(body)
+	})
+}
+
+// problemRecorder задерживает текстовые ответы с ошибкой, чтобы переписать их в problem+json.
+type problemRecorder struct {
+	http.ResponseWriter
+	// status - код задержанной ошибки, 0 - ответ идет клиенту как есть.
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (r *problemRecorder) WriteHeader(status int) {
+	if r.written || r.status != 0 {
+		return
+	}
+	contentType := r.Header().Get("Content-Type")
+	if status >= http.StatusBadRequest && (contentType == "" || strings.HasPrefix(contentType, "text/plain")) {
+		r.status = status
+		return
+	}
+	r.written = true
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *problemRecorder) Write(data []byte) (int, error) {
+	if !r.written && r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.status != 0 {
+		return r.body.Write(data)
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *problemRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+	"todo/internal/events"
+	"todo/internal/logging"
+	"todo/pkg/config"
+)
+
+// Reload применяет заново прочитанные настройки next без перезапуска: уровень журнала, период
+// фоновых задач, ограничение частоты запросов, CORS, доставку вебхуков и сертификат TLS.
+// Остальные изменения остаются в силе до перезапуска и перечисляются в RestartRequired.
+// Если применить настройки нельзя (например, сертификат не читается), действующие не меняются.
+func (a *App) Reload(next *config.Config) (events.ConfigChange, error) {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	current := a.config.Load()
+	updated, applied, restart := current.Update(next)
+
+	// HTTPS включается и выключается только при запуске сервера
+	if (current.Server.TLSCert == "") != (next.Server.TLSCert == "") {
+		updated.Server.TLSCert, updated.Server.TLSKey = current.Server.TLSCert, current.Server.TLSKey
+		for _, key := range []string{"server.tls_cert", "server.tls_key"} {
+			if i := slices.Index(applied, key); i >= 0 {
+				applied = slices.Delete(applied, i, i+1)
+				restart = append(restart, key)
+			}
+		}
+	}
+	// Сертификат перечитывается всегда: файлы обычно обновляют на месте
+	if updated.Server.TLSCert != "" {
+		if err := a.loadCertificate(updated.Server); err != nil {
+			return events.ConfigChange{}, err
+		}
+	}
+
+	// Уровень, заданный через /admin/log-level, сохраняется, пока log.level не изменится в настройках
+	if updated.Log.Level != current.Log.Level {
+		if err := logging.SetLevel(updated.Log.Level); err != nil {
+			return events.ConfigChange{}, err
+		}
+	}
+	a.limiter.SetLimit(updated.RateLimit.RPS, updated.RateLimit.Burst)
+	a.webhooks.Configure(updated.Webhooks)
+	if ticker := a.jobsTicker.Load(); ticker != nil && updated.Jobs.Interval != current.Jobs.Interval {
+		ticker.Reset(updated.Jobs.Interval)
+	}
+	a.config.Store(updated)
+
+	if len(applied) > 0 {
+		a.configVersion++
+	}
+	a.pendingRestart = restart
+	change := events.ConfigChange{Version: a.configVersion, Applied: applied, RestartRequired: restart}
+
+	slog.Info("configuration reloaded", "version", change.Version, "applied", applied)
+	if len(restart) > 0 {
+		slog.Warn("changed settings require restart", "settings", restart)
+	}
+	if len(applied) > 0 || len(restart) > 0 {
+		a.events.Publish(events.Event{Type: events.ConfigChanged, Config: &change, OccurredAt: time.Now().UTC()})
+	}
+	return change, nil
+}
+
+// loadCertificate читает сертификат и ключ из файлов настроек server.
+func (a *App) loadCertificate(settings config.Server) error {
+	certificate, err := tls.LoadX509KeyPair(settings.TLSCert, settings.TLSKey)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	a.certificate.Store(&certificate)
+	return nil
+}
+
+func (a *App) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return a.certificate.Load(), nil
+}
@@ -0,0 +1,136 @@
+// Package auth содержит принципала запроса, хеширование паролей и токенов и проверку прав.
+// Хранение пользователей, сессий и ключей - в db.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Роли уровня рабочего пространства: viewer только читает доступные ему задачи,
+// editor создает свои задачи и меняет доступные, admin имеет доступ ко всему.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+
+	ScopeTasksRead  = "tasks:read"
+	ScopeTasksWrite = "tasks:write"
+	ScopeAdmin      = "admin"
+
+	APIKeyPrefix = "lwo_"
+)
+
+var (
+	Roles  = []string{RoleViewer, RoleEditor, RoleAdmin}
+	Scopes = []string{ScopeTasksRead, ScopeTasksWrite, ScopeAdmin}
+
+	ErrUnauthenticated    = errors.New("authentication required")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrForbidden          = errors.New("forbidden")
+)
+
+// Principal - тот, от чьего имени выполняется запрос. Scopes пусты у сессий
+// (сессия имеет все права пользователя) и заполнены у API-ключей. Workspace задан,
+// если токен выпущен для одного рабочего пространства.
+type Principal struct {
+	UserID    int      `json:"user_id"`
+	Username  string   `json:"username"`
+	Role      string   `json:"role"`
+	Scopes    []string `json:"scopes,omitempty"`
+	Workspace string   `json:"workspace,omitempty"`
+	Via       string   `json:"via"`
+	system    bool
+}
+
+// System - принципал фоновых задач, которому доступны данные всех пользователей.
+var System = &Principal{Username: "system", Role: RoleAdmin, Via: "system", system: true}
+
+func (p *Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+func (p *Principal) IsSystem() bool {
+	return p.system
+}
+
+// HasScope проверяет право API-ключа. Для сессий и system всегда true.
+func (p *Principal) HasScope(scope string) bool {
+	if len(p.Scopes) == 0 {
+		return true
+	}
+	return slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// SystemContext используется фоновыми задачами, которые работают со всеми задачами сразу.
+func SystemContext(ctx context.Context) context.Context {
+	return WithPrincipal(ctx, System)
+}
+
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+func CheckPassword(hash string, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// HashToken хеширует токен сессии или API-ключ для хранения. У токенов высокая энтропия,
+// поэтому медленный хеш вроде bcrypt не нужен и поиск по хешу остается индексным.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// NewSessionToken возвращает токен сессии для клиента.
+func NewSessionToken() (string, error) {
+	return randomString(32)
+}
+
+// NewAPIKey возвращает ключ вида lwo_<prefix>_<secret>. Prefix хранится открыто,
+// чтобы пользователь мог отличить ключи в списке.
+func NewAPIKey() (key string, prefix string, err error) {
+	prefix, err = randomString(6)
+	if err != nil {
+		return "", "", err
+	}
+	prefix = strings.NewReplacer("-", "a", "_", "b").Replace(prefix)
+
+	secret, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return APIKeyPrefix + prefix + "_" + secret, prefix, nil
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
@@ -0,0 +1,86 @@
+// Package authz проверяет права доступа к задачам и проектам. Хранилище (db.TaskRepository)
+// права не знает; Repo оборачивает его и пропускает каждый вызов через Decide.
+package authz
+
+import (
+	"todo/internal/auth"
+	"todo/internal/db"
+)
+
+// Action - действие над задачей или проектом.
+type Action string
+
+const (
+	ActionView   Action = "view"
+	ActionEdit   Action = "edit"
+	ActionDelete Action = "delete"
+	ActionShare  Action = "share"
+	// ActionComment - комментирование: достаточно доступа на просмотр, но нужны права записи.
+	ActionComment Action = "comment"
+)
+
+var Actions = []Action{ActionView, ActionEdit, ActionDelete, ActionShare, ActionComment}
+
+// Decision - результат проверки. Hide отличается от Forbid тем, что пользователь
+// вообще не видит объект и получает 404, а не 403.
+type Decision int
+
+const (
+	Allow Decision = iota
+	Forbid
+	Hide
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Allow:
+		return "allow"
+	case Forbid:
+		return "forbid"
+	}
+	return "hide"
+}
+
+// required - минимальный уровень доступа для действия. Удалять и раздавать доступ
+// может только владелец.
+func required(action Action) db.Access {
+	switch action {
+	case ActionView, ActionComment:
+		return db.AccessView
+	case ActionEdit:
+		return db.AccessEdit
+	}
+	return db.AccessOwner
+}
+
+func scope(action Action) string {
+	if action == ActionView {
+		return auth.ScopeTasksRead
+	}
+	return auth.ScopeTasksWrite
+}
+
+// Decide решает, может ли principal с уровнем доступа access выполнить action.
+// Администратор может все, что позволяют области ключа. Роль viewer только читает,
+// даже если ей выдан доступ на редактирование.
+func Decide(principal *auth.Principal, access db.Access, action Action) Decision {
+	if principal.IsAdmin() {
+		if !principal.HasScope(scope(action)) {
+			return Forbid
+		}
+		return Allow
+	}
+	if access == db.AccessNone {
+		return Hide
+	}
+	if !principal.HasScope(scope(action)) {
+		return Forbid
+	}
+	if principal.Role == auth.RoleViewer && action != ActionView {
+		return Forbid
+	}
+	if access < required(action) {
+		return Forbid
+	}
+	return Allow
+}
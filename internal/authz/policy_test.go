@@ -0,0 +1,55 @@
+package authz
+
+import (
+	"testing"
+	"todo/internal/auth"
+	"todo/internal/db"
+)
+
+// Решения в строке matrix идут в порядке Actions: view, edit, delete, share, comment.
+// A - allow, F - forbid, H - hide.
+func TestDecideMatrix(t *testing.T) {
+	readOnly := []string{auth.ScopeTasksRead}
+	tests := []struct {
+		role   string
+		scopes []string
+		access db.Access
+		matrix string
+	}{
+		{auth.RoleAdmin, nil, db.AccessNone, "AAAAA"},
+		{auth.RoleAdmin, nil, db.AccessView, "AAAAA"},
+		{auth.RoleAdmin, readOnly, db.AccessNone, "AFFFF"},
+
+		{auth.RoleEditor, nil, db.AccessNone, "HHHHH"},
+		{auth.RoleEditor, nil, db.AccessView, "AFFFA"},
+		{auth.RoleEditor, nil, db.AccessEdit, "AAFFA"},
+		{auth.RoleEditor, nil, db.AccessOwner, "AAAAA"},
+		{auth.RoleEditor, readOnly, db.AccessNone, "HHHHH"},
+		{auth.RoleEditor, readOnly, db.AccessOwner, "AFFFF"},
+
+		{auth.RoleViewer, nil, db.AccessNone, "HHHHH"},
+		{auth.RoleViewer, nil, db.AccessView, "AFFFF"},
+		{auth.RoleViewer, nil, db.AccessEdit, "AFFFF"},
+		{auth.RoleViewer, nil, db.AccessOwner, "AFFFF"},
+	}
+
+	codes := map[byte]Decision{'A': Allow, 'F': Forbid, 'H': Hide}
+	for _, test := range tests {
+		principal := &auth.Principal{UserID: 1, Role: test.role, Scopes: test.scopes}
+		for i, action := range Actions {
+			want := codes[test.matrix[i]]
+			if got := Decide(principal, test.access, action); got != want {
+				t.Errorf("role=%s scopes=%v access=%d action=%s: got %s, want %s",
+					test.role, test.scopes, test.access, action, got, want)
+			}
+		}
+	}
+}
+
+func TestDecideSystem(t *testing.T) {
+	for _, action := range Actions {
+		if got := Decide(auth.System, db.AccessNone, action); got != Allow {
+			t.Errorf("system %s: got %s", action, got)
+		}
+	}
+}
@@ -0,0 +1,733 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"todo/internal/auth"
+	"todo/internal/db"
+)
+
+// Store - хранилище, которое оборачивает Repo.
+type Store interface {
+	db.Repo
+	db.ReminderRepo
+	db.ProjectRepo
+	db.ShareRepo
+	db.PeopleRepo
+	db.CommentRepo
+	db.AttachmentRepo
+	db.ChecklistRepo
+	db.WorklogRepo
+	db.CustomFieldRepo
+	GetUserById(id int) (*db.User, error)
+	GetVisibleTasks(ctx context.Context, userID int, filter *db.TaskFilter) ([]*db.Task, error)
+	GetVisibleProjects(ctx context.Context, userID int) ([]*db.Project, error)
+	TaskAccess(ctx context.Context, taskID int, userID int) (db.Access, error)
+	ProjectAccess(ctx context.Context, projectID int, userID int) (db.Access, error)
+}
+
+// Repo реализует db.Repo, db.ProjectRepo и db.ShareRepo с проверкой прав принципала
+// из контекста. Недоступные объекты возвращают db.ErrTaskNotFound/db.ErrProjectNotFound,
+// недостаточные права - auth.ErrForbidden.
+type Repo struct {
+	store Store
+}
+
+func New(store Store) *Repo {
+	return &Repo{store: store}
+}
+
+func principal(ctx context.Context) (*auth.Principal, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, auth.ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+func decisionError(decision Decision, notFound error) error {
+	switch decision {
+	case Forbid:
+		return auth.ErrForbidden
+	case Hide:
+		return notFound
+	}
+	return nil
+}
+
+func (r *Repo) authorizeTask(ctx context.Context, id int, action Action) (*auth.Principal, error) {
+	principal, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	access := db.AccessOwner
+	if !principal.IsAdmin() {
+		if access, err = r.store.TaskAccess(ctx, id, principal.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	return principal, decisionError(Decide(principal, access, action), db.ErrTaskNotFound)
+}
+
+func (r *Repo) authorizeProject(ctx context.Context, id int, action Action) (*auth.Principal, error) {
+	principal, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	access := db.AccessOwner
+	if !principal.IsAdmin() {
+		if access, err = r.store.ProjectAccess(ctx, id, principal.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := decisionError(Decide(principal, access, action), db.ErrProjectNotFound); err != nil {
+		return principal, err
+	}
+
+	// Администратор проходит проверку и для несуществующего проекта
+	if _, err := r.store.GetProjectById(ctx, id); err != nil {
+		return nil, err
+	}
+	return principal, nil
+}
+
+// authorizeCreate проверяет право создавать собственные задачи и проекты.
+func authorizeCreate(ctx context.Context) (*auth.Principal, error) {
+	principal, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if principal.Role == auth.RoleViewer || !principal.HasScope(auth.ScopeTasksWrite) {
+		return nil, auth.ErrForbidden
+	}
+	return principal, nil
+}
+
+// GetAllTasks возвращает задачи, видимые принципалу. Фильтрация выполняется в SQL.
+func (r *Repo) GetAllTasks(ctx context.Context, filter *db.TaskFilter) ([]*db.Task, error) {
+	principal, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.HasScope(auth.ScopeTasksRead) {
+		return nil, auth.ErrForbidden
+	}
+	if principal.IsAdmin() {
+		return r.store.GetAllTasks(ctx, filter)
+	}
+	return r.store.GetVisibleTasks(ctx, principal.UserID, filter)
+}
+
+func (r *Repo) CreateTask(ctx context.Context, input *db.TaskInput) (*db.Task, error) {
+	principal, err := authorizeCreate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if input.ProjectID != nil && *input.ProjectID != 0 {
+		if _, err := r.authorizeProject(ctx, *input.ProjectID, ActionEdit); err != nil {
+			return nil, err
+		}
+	}
+
+	input.OwnerID = principal.UserID
+	return r.store.CreateTask(ctx, input)
+}
+
+func (r *Repo) GetTaskById(ctx context.Context, id int) (*db.Task, error) {
+	if _, err := r.authorizeTask(ctx, id, ActionView); err != nil {
+		return nil, err
+	}
+	return r.store.GetTaskById(ctx, id)
+}
+
+// UpdateTask требует права на редактирование задачи, а при переносе в другой проект -
+// и на редактирование этого проекта. Исполнители и наблюдатели получают доступ к задаче,
+// поэтому менять их может только тот, кто может делиться задачей.
+func (r *Repo) UpdateTask(ctx context.Context, task *db.Task) error {
+	if _, err := r.authorizeTask(ctx, task.ID, ActionEdit); err != nil {
+		return err
+	}
+
+	current, err := r.store.GetTaskById(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+	if task.ProjectID != 0 && task.ProjectID != current.ProjectID {
+		if _, err := r.authorizeProject(ctx, task.ProjectID, ActionEdit); err != nil {
+			return err
+		}
+	}
+	if !slices.Equal(db.NormalizeUserIDs(task.Assignees), current.Assignees) || !slices.Equal(db.NormalizeUserIDs(task.Watchers), current.Watchers) {
+		if _, err := r.authorizeTask(ctx, task.ID, ActionShare); err != nil {
+			return err
+		}
+	}
+
+	return r.store.UpdateTask(ctx, task)
+}
+
+// GetUserTasks возвращает задачи пользователя. Чужие списки доступны только администратору.
+func (r *Repo) GetUserTasks(ctx context.Context, userID int, relations []string) ([]*db.Task, error) {
+	principal, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.HasScope(auth.ScopeTasksRead) || (!principal.IsAdmin() && principal.UserID != userID) {
+		return nil, auth.ErrForbidden
+	}
+	return r.store.GetUserTasks(ctx, userID, relations)
+}
+
+func (r *Repo) DeleteTask(ctx context.Context, id int) (int64, error) {
+	if _, err := r.authorizeTask(ctx, id, ActionDelete); err != nil {
+		return 0, err
+	}
+	return r.store.DeleteTask(ctx, id)
+}
+
+func (r *Repo) CompleteTask(ctx context.Context, id int) error {
+	if _, err := r.authorizeTask(ctx, id, ActionEdit); err != nil {
+		return err
+	}
+	return r.store.CompleteTask(ctx, id)
+}
+
+func (r *Repo) TransitionTask(ctx context.Context, id int, status string) error {
+	if _, err := r.authorizeTask(ctx, id, ActionEdit); err != nil {
+		return err
+	}
+	return r.store.TransitionTask(ctx, id, status)
+}
+
+// UpdateOverdueTasks затрагивает задачи всех пользователей, поэтому доступен только
+// администратору и фоновым задачам.
+func (r *Repo) UpdateOverdueTasks(ctx context.Context, currentTime string) ([]*db.Task, error) {
+	principal, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.IsAdmin() {
+		return nil, auth.ErrForbidden
+	}
+	return r.store.UpdateOverdueTasks(ctx, currentTime)
+}
+
+func (r *Repo) CreateProject(ctx context.Context, project *db.Project) (*db.Project, error) {
+	principal, err := authorizeCreate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	project.OwnerID = principal.UserID
+	return r.store.CreateProject(ctx, project)
+}
+
+func (r *Repo) GetAllProjects(ctx context.Context) ([]*db.Project, error) {
+	principal, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.HasScope(auth.ScopeTasksRead) {
+		return nil, auth.ErrForbidden
+	}
+	if principal.IsAdmin() {
+		return r.store.GetAllProjects(ctx)
+	}
+	return r.store.GetVisibleProjects(ctx, principal.UserID)
+}
+
+func (r *Repo) GetProjectById(ctx context.Context, id int) (*db.Project, error) {
+	if _, err := r.authorizeProject(ctx, id, ActionView); err != nil {
+		return nil, err
+	}
+	return r.store.GetProjectById(ctx, id)
+}
+
+func (r *Repo) UpdateProject(ctx context.Context, project *db.Project) error {
+	if _, err := r.authorizeProject(ctx, project.ID, ActionEdit); err != nil {
+		return err
+	}
+	return r.store.UpdateProject(ctx, project)
+}
+
+func (r *Repo) DeleteProject(ctx context.Context, id int) (int64, error) {
+	if _, err := r.authorizeProject(ctx, id, ActionDelete); err != nil {
+		return 0, err
+	}
+	return r.store.DeleteProject(ctx, id)
+}
+
+func (r *Repo) GetTaskShares(ctx context.Context, taskID int) ([]*db.Share, error) {
+	if _, err := r.authorizeTask(ctx, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	return r.store.GetTaskShares(ctx, taskID)
+}
+
+func (r *Repo) ShareTask(ctx context.Context, taskID int, userID int, permission string) error {
+	if _, err := r.authorizeTask(ctx, taskID, ActionShare); err != nil {
+		return err
+	}
+	if _, err := r.store.GetTaskById(ctx, taskID); err != nil {
+		return err
+	}
+	return r.store.ShareTask(ctx, taskID, userID, permission)
+}
+
+// DeleteTaskShare доступен владельцу, а также самому получателю, чтобы отказаться от доступа.
+func (r *Repo) DeleteTaskShare(ctx context.Context, taskID int, userID int) (int64, error) {
+	principal, err := r.authorizeTask(ctx, taskID, ActionShare)
+	if err != nil && !(errors.Is(err, auth.ErrForbidden) && principal.UserID == userID) {
+		return 0, err
+	}
+	return r.store.DeleteTaskShare(ctx, taskID, userID)
+}
+
+func (r *Repo) GetProjectShares(ctx context.Context, projectID int) ([]*db.Share, error) {
+	if _, err := r.authorizeProject(ctx, projectID, ActionView); err != nil {
+		return nil, err
+	}
+	return r.store.GetProjectShares(ctx, projectID)
+}
+
+func (r *Repo) ShareProject(ctx context.Context, projectID int, userID int, permission string) error {
+	if _, err := r.authorizeProject(ctx, projectID, ActionShare); err != nil {
+		return err
+	}
+	return r.store.ShareProject(ctx, projectID, userID, permission)
+}
+
+func (r *Repo) DeleteProjectShare(ctx context.Context, projectID int, userID int) (int64, error) {
+	principal, err := r.authorizeProject(ctx, projectID, ActionShare)
+	if err != nil && !(errors.Is(err, auth.ErrForbidden) && principal.UserID == userID) {
+		return 0, err
+	}
+	return r.store.DeleteProjectShare(ctx, projectID, userID)
+}
+
+// Reminders возвращает напоминания с проверкой прав на задачу. Методы планировщика
+// (GetDueReminders и т.п.) вызываются фоновой задачей и проходят без проверки.
+func (r *Repo) Reminders() db.ReminderRepo {
+	return &reminders{ReminderRepo: r.store, repo: r}
+}
+
+type reminders struct {
+	db.ReminderRepo
+	repo *Repo
+}
+
+func (r *reminders) CreateReminder(ctx context.Context, taskID int, input *db.ReminderInput) (*db.Reminder, error) {
+	if _, err := r.repo.authorizeTask(ctx, taskID, ActionEdit); err != nil {
+		return nil, err
+	}
+	return r.ReminderRepo.CreateReminder(ctx, taskID, input)
+}
+
+func (r *reminders) GetReminders(ctx context.Context, taskID int) ([]*db.Reminder, error) {
+	if _, err := r.repo.authorizeTask(ctx, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	return r.ReminderRepo.GetReminders(ctx, taskID)
+}
+
+func (r *reminders) DeleteReminder(ctx context.Context, taskID int, id int) (int64, error) {
+	if _, err := r.repo.authorizeTask(ctx, taskID, ActionEdit); err != nil {
+		return 0, err
+	}
+	return r.ReminderRepo.DeleteReminder(ctx, taskID, id)
+}
+
+// Comments возвращает комментарии с проверкой прав на задачу. Писать может каждый, кто видит
+// задачу и не ограничен чтением; править - только автор, удалять - автор или владелец задачи.
+func (r *Repo) Comments() db.CommentRepo {
+	return &comments{CommentRepo: r.store, repo: r}
+}
+
+type comments struct {
+	db.CommentRepo
+	db.AttachmentRepo
+	repo *Repo
+}
+
+func (c *comments) CreateComment(ctx context.Context, comment *db.Comment) (*db.Comment, error) {
+	principal, err := c.repo.authorizeTask(ctx, comment.TaskID, ActionComment)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.repo.store.GetTaskById(ctx, comment.TaskID); err != nil {
+		return nil, err
+	}
+
+	comment.AuthorID = principal.UserID
+	if comment.Mentions, err = c.mentionable(ctx, comment.TaskID, comment.Mentions); err != nil {
+		return nil, err
+	}
+	return c.CommentRepo.CreateComment(ctx, comment)
+}
+
+func (c *comments) GetComments(ctx context.Context, taskID int, after int, limit int) ([]*db.Comment, error) {
+	if _, err := c.repo.authorizeTask(ctx, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	return c.CommentRepo.GetComments(ctx, taskID, after, limit)
+}
+
+func (c *comments) GetLatestComments(ctx context.Context, taskID int, limit int) ([]*db.Comment, error) {
+	if _, err := c.repo.authorizeTask(ctx, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	return c.CommentRepo.GetLatestComments(ctx, taskID, limit)
+}
+
+func (c *comments) GetComment(ctx context.Context, taskID int, id int) (*db.Comment, error) {
+	if _, err := c.repo.authorizeTask(ctx, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	return c.CommentRepo.GetComment(ctx, taskID, id)
+}
+
+func (c *comments) GetCommentRevisions(ctx context.Context, taskID int, id int) ([]*db.CommentRevision, error) {
+	if _, err := c.repo.authorizeTask(ctx, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	return c.CommentRepo.GetCommentRevisions(ctx, taskID, id)
+}
+
+func (c *comments) UpdateComment(ctx context.Context, comment *db.Comment) error {
+	principal, err := c.repo.authorizeTask(ctx, comment.TaskID, ActionComment)
+	if err != nil {
+		return err
+	}
+
+	current, err := c.CommentRepo.GetComment(ctx, comment.TaskID, comment.ID)
+	if err != nil {
+		return err
+	}
+	if current.AuthorID != principal.UserID && !principal.IsAdmin() {
+		return auth.ErrForbidden
+	}
+
+	if comment.Mentions, err = c.mentionable(ctx, comment.TaskID, comment.Mentions); err != nil {
+		return err
+	}
+	return c.CommentRepo.UpdateComment(ctx, comment)
+}
+
+func (c *comments) DeleteComment(ctx context.Context, taskID int, id int) (int64, error) {
+	principal, err := c.repo.authorizeTask(ctx, taskID, ActionView)
+	if err != nil {
+		return 0, err
+	}
+
+	current, err := c.CommentRepo.GetComment(ctx, taskID, id)
+	if errors.Is(err, db.ErrCommentNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	action := ActionDelete
+	if current.AuthorID == principal.UserID {
+		action = ActionComment
+	}
+	if _, err := c.repo.authorizeTask(ctx, taskID, action); err != nil {
+		return 0, err
+	}
+	return c.CommentRepo.DeleteComment(ctx, taskID, id)
+}
+
+// mentionable оставляет упоминания пользователей, которым видна задача: остальные не
+// должны получать уведомления о ней.
+func (c *comments) mentionable(ctx context.Context, taskID int, userIDs []int) ([]int, error) {
+	var allowed []int
+	for _, userID := range db.NormalizeUserIDs(userIDs) {
+		user, err := c.repo.store.GetUserById(userID)
+		if errors.Is(err, db.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if user.Role != auth.RoleAdmin {
+			access, err := c.repo.store.TaskAccess(ctx, taskID, userID)
+			if err != nil {
+				return nil, err
+			}
+			if access == db.AccessNone {
+				continue
+			}
+		}
+		allowed = append(allowed, userID)
+	}
+	return allowed, nil
+}
+
+// Attachments возвращает вложения с проверкой прав на задачу. Прикреплять файлы может тот, кто
+// редактирует задачу; удалять - загрузивший файл или владелец задачи.
+func (r *Repo) Attachments() db.AttachmentRepo {
+	return &attachments{AttachmentRepo: r.store, repo: r}
+}
+
+type attachments struct {
+	db.AttachmentRepo
+	repo *Repo
+}
+
+func (a *attachments) CreateAttachment(ctx context.Context, attachment *db.Attachment) (*db.Attachment, error) {
+	principal, err := a.repo.authorizeTask(ctx, attachment.TaskID, ActionEdit)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.repo.store.GetTaskById(ctx, attachment.TaskID); err != nil {
+		return nil, err
+	}
+
+	attachment.UploadedBy = principal.UserID
+	return a.AttachmentRepo.CreateAttachment(ctx, attachment)
+}
+
+func (a *attachments) GetAttachments(ctx context.Context, taskID int) ([]*db.Attachment, error) {
+	if _, err := a.repo.authorizeTask(ctx, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	return a.AttachmentRepo.GetAttachments(ctx, taskID)
+}
+
+func (a *attachments) GetAttachment(ctx context.Context, taskID int, id int) (*db.Attachment, error) {
+	if _, err := a.repo.authorizeTask(ctx, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	return a.AttachmentRepo.GetAttachment(ctx, taskID, id)
+}
+
+func (a *attachments) DeleteAttachment(ctx context.Context, taskID int, id int) (int64, error) {
+	principal, err := a.repo.authorizeTask(ctx, taskID, ActionView)
+	if err != nil {
+		return 0, err
+	}
+
+	current, err := a.AttachmentRepo.GetAttachment(ctx, taskID, id)
+	if errors.Is(err, db.ErrAttachmentNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	action := ActionDelete
+	if current.UploadedBy == principal.UserID {
+		action = ActionEdit
+	}
+	if _, err := a.repo.authorizeTask(ctx, taskID, action); err != nil {
+		return 0, err
+	}
+	return a.AttachmentRepo.DeleteAttachment(ctx, taskID, id)
+}
+
+// Checklists возвращает чек-листы с проверкой прав на задачу: смотреть может тот, кто видит
+// задачу, менять пункты - тот, кто ее редактирует.
+func (r *Repo) Checklists() db.ChecklistRepo {
+	return &checklists{ChecklistRepo: r.store, repo: r}
+}
+
+type checklists struct {
+	db.ChecklistRepo
+	repo *Repo
+}
+
+func (c *checklists) GetChecklist(ctx context.Context, taskID int) ([]*db.ChecklistItem, error) {
+	if _, err := c.repo.authorizeTask(ctx, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	return c.ChecklistRepo.GetChecklist(ctx, taskID)
+}
+
+func (c *checklists) AddChecklistItem(ctx context.Context, taskID int, item *db.ChecklistItem) (*db.ChecklistItem, error) {
+	if _, err := c.repo.authorizeTask(ctx, taskID, ActionEdit); err != nil {
+		return nil, err
+	}
+	if _, err := c.repo.store.GetTaskById(ctx, taskID); err != nil {
+		return nil, err
+	}
+	return c.ChecklistRepo.AddChecklistItem(ctx, taskID, item)
+}
+
+func (c *checklists) UpdateChecklistItem(ctx context.Context, taskID int, item *db.ChecklistItem) error {
+	if _, err := c.repo.authorizeTask(ctx, taskID, ActionEdit); err != nil {
+		return err
+	}
+	return c.ChecklistRepo.UpdateChecklistItem(ctx, taskID, item)
+}
+
+func (c *checklists) ReorderChecklist(ctx context.Context, taskID int, ids []int) error {
+	if _, err := c.repo.authorizeTask(ctx, taskID, ActionEdit); err != nil {
+		return err
+	}
+	return c.ChecklistRepo.ReorderChecklist(ctx, taskID, ids)
+}
+
+func (c *checklists) DeleteChecklistItem(ctx context.Context, taskID int, id int) (int64, error) {
+	if _, err := c.repo.authorizeTask(ctx, taskID, ActionEdit); err != nil {
+		return 0, err
+	}
+	return c.ChecklistRepo.DeleteChecklistItem(ctx, taskID, id)
+}
+
+// Worklogs возвращает учет времени с проверкой прав на задачу. Время записывает тот, кто
+// редактирует задачу, и только на себя; чужие записи удаляет владелец задачи. Отчет строится
+// по видимым задачам. StopStaleTimers вызывается фоновой задачей и проходит без проверки.
+func (r *Repo) Worklogs() db.WorklogRepo {
+	return &worklogs{WorklogRepo: r.store, repo: r}
+}
+
+type worklogs struct {
+	db.WorklogRepo
+	repo *Repo
+}
+
+func (w *worklogs) StartTimer(ctx context.Context, taskID int, _ int) (*db.Worklog, error) {
+	principal, err := w.repo.authorizeTask(ctx, taskID, ActionEdit)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.repo.store.GetTaskById(ctx, taskID); err != nil {
+		return nil, err
+	}
+	return w.WorklogRepo.StartTimer(ctx, taskID, principal.UserID)
+}
+
+// StopTimer требует только просмотра: свой таймер можно остановить, даже потеряв право на правку.
+func (w *worklogs) StopTimer(ctx context.Context, taskID int, _ int) (*db.Worklog, error) {
+	principal, err := w.repo.authorizeTask(ctx, taskID, ActionView)
+	if err != nil {
+		return nil, err
+	}
+	return w.WorklogRepo.StopTimer(ctx, taskID, principal.UserID)
+}
+
+func (w *worklogs) GetRunningTimer(ctx context.Context, userID int) (*db.Worklog, error) {
+	principal, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if userID != principal.UserID && !principal.IsAdmin() {
+		return nil, auth.ErrForbidden
+	}
+	return w.WorklogRepo.GetRunningTimer(ctx, userID)
+}
+
+func (w *worklogs) CreateWorklog(ctx context.Context, worklog *db.Worklog) (*db.Worklog, error) {
+	principal, err := w.repo.authorizeTask(ctx, worklog.TaskID, ActionEdit)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.repo.store.GetTaskById(ctx, worklog.TaskID); err != nil {
+		return nil, err
+	}
+
+	worklog.UserID = principal.UserID
+	return w.WorklogRepo.CreateWorklog(ctx, worklog)
+}
+
+func (w *worklogs) GetWorklogs(ctx context.Context, taskID int) ([]*db.Worklog, error) {
+	if _, err := w.repo.authorizeTask(ctx, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	return w.WorklogRepo.GetWorklogs(ctx, taskID)
+}
+
+func (w *worklogs) GetWorklog(ctx context.Context, taskID int, id int) (*db.Worklog, error) {
+	if _, err := w.repo.authorizeTask(ctx, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	return w.WorklogRepo.GetWorklog(ctx, taskID, id)
+}
+
+func (w *worklogs) DeleteWorklog(ctx context.Context, taskID int, id int) (int64, error) {
+	principal, err := w.repo.authorizeTask(ctx, taskID, ActionView)
+	if err != nil {
+		return 0, err
+	}
+
+	current, err := w.WorklogRepo.GetWorklog(ctx, taskID, id)
+	if errors.Is(err, db.ErrWorklogNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	action := ActionDelete
+	if current.UserID == principal.UserID {
+		action = ActionEdit
+	}
+	if _, err := w.repo.authorizeTask(ctx, taskID, action); err != nil {
+		return 0, err
+	}
+	return w.WorklogRepo.DeleteWorklog(ctx, taskID, id)
+}
+
+func (w *worklogs) GetTimeReport(ctx context.Context, filter *db.TimeReportFilter) ([]*db.TimeReportRow, error) {
+	principal, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.HasScope(auth.ScopeTasksRead) {
+		return nil, auth.ErrForbidden
+	}
+
+	filter.VisibleTo = 0
+	if !principal.IsAdmin() {
+		filter.VisibleTo = principal.UserID
+	}
+	return w.WorklogRepo.GetTimeReport(ctx, filter)
+}
+
+// CustomFields возвращает пользовательские поля проектов. Видит их тот, кто видит проект,
+// а задает - администратор или владелец проекта.
+func (r *Repo) CustomFields() db.CustomFieldRepo {
+	return &customFields{CustomFieldRepo: r.store, repo: r}
+}
+
+type customFields struct {
+	db.CustomFieldRepo
+	repo *Repo
+}
+
+func (c *customFields) GetCustomFields(ctx context.Context, projectID int) ([]*db.CustomField, error) {
+	if _, err := c.repo.authorizeProject(ctx, projectID, ActionView); err != nil {
+		return nil, err
+	}
+	return c.CustomFieldRepo.GetCustomFields(ctx, projectID)
+}
+
+func (c *customFields) CreateCustomField(ctx context.Context, field *db.CustomField) (*db.CustomField, error) {
+	if _, err := c.repo.authorizeProject(ctx, field.ProjectID, ActionShare); err != nil {
+		return nil, err
+	}
+	if _, err := c.repo.store.GetProjectById(ctx, field.ProjectID); err != nil {
+		return nil, err
+	}
+	return c.CustomFieldRepo.CreateCustomField(ctx, field)
+}
+
+func (c *customFields) UpdateCustomField(ctx context.Context, field *db.CustomField) error {
+	if _, err := c.repo.authorizeProject(ctx, field.ProjectID, ActionShare); err != nil {
+		return err
+	}
+	return c.CustomFieldRepo.UpdateCustomField(ctx, field)
+}
+
+func (c *customFields) DeleteCustomField(ctx context.Context, projectID int, key string) (int64, error) {
+	if _, err := c.repo.authorizeProject(ctx, projectID, ActionShare); err != nil {
+		return 0, err
+	}
+	return c.CustomFieldRepo.DeleteCustomField(ctx, projectID, key)
+}
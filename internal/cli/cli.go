@@ -0,0 +1,203 @@
+// Package cli - команды программы lwo-go: сервер, миграции, работа с задачами, интерактивный
+// список задач, выгрузка и загрузка задач, резервные копии базы. Все команды читают настройки
+// через config.Loader.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"todo/pkg/config"
+)
+
+// Name - имя программы в справке и скриптах дополнения.
+const Name = "lwo-go"
+
+// command - команда верхнего уровня. subcommands и flags нужны только для дополнения в оболочке.
+type command struct {
+	name        string
+	summary     string
+	subcommands []string
+	flags       []string
+	run         func(e *env, args []string) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "serve", summary: "start the HTTP server (default)", run: runServe},
+		{name: "migrate", summary: "apply database migrations", run: runMigrate},
+		{name: "task", summary: "list and change tasks", subcommands: taskCommandNames(), flags: taskFlags, run: runTask},
+		{name: "tui", summary: "browse and change tasks in an interactive list", flags: []string{"--refresh", "--url", "--token", "--workspace"}, run: runTUI},
+		{name: "export", summary: "write all tasks as JSON or NDJSON", flags: []string{"--format", "--file", "--url", "--token", "--workspace"}, run: runExport},
+		{name: "import", summary: "create tasks from an export", flags: []string{"--url", "--token", "--workspace"}, run: runImport},
+		{name: "backup", summary: "save a copy of the database", flags: []string{"--force", "--workspace"}, run: runBackup},
+		{name: "restore", summary: "replace the database with a backup", flags: []string{"--workspace"}, run: runRestore},
+		{name: "completion", summary: "print a shell completion script", subcommands: shells, run: runCompletion},
+		{name: "help", summary: "show help", run: runHelp},
+	}
+}
+
+// env - окружение выполнения команды.
+type env struct {
+	ctx    context.Context
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// usageError - неверный вызов команды. Run печатает его и завершается с кодом 2.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usagef(format string, args ...any) error {
+	return &usageError{message: fmt.Sprintf(format, args...)}
+}
+
+// configError - настройки не прошли проверку.
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string {
+	return e.err.Error()
+}
+
+// errDone - команда уже выполнила все, что требовалось (например, напечатала настройки).
+var errDone = errors.New("done")
+
+// Run выполняет команду args (без имени программы) и возвращает код завершения.
+// Без команды или с флагом первым аргументом запускается сервер, как раньше.
+func Run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	e := &env{ctx: ctx, stdin: os.Stdin, stdout: stdout, stderr: stderr}
+
+	cmd, rest := commands[0], args
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, rest = lookup(args[0]), args[1:]
+		if cmd == nil {
+			fmt.Fprintf(stderr, "%s: unknown command %q\n\n", Name, args[0])
+			writeUsage(stderr)
+			return 2
+		}
+	}
+
+	err := cmd.run(e, rest)
+	var usageErr *usageError
+	var configErr *configError
+	switch {
+	case err == nil, errors.Is(err, errDone), errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "%s %s: %v\n", Name, cmd.name, err)
+		return 2
+	case errors.As(err, &configErr):
+		// Ошибки проверки многострочные, поэтому печатаются как есть
+		fmt.Fprintf(stderr, "invalid configuration:\n%v\n", err)
+		return 2
+	default:
+		fmt.Fprintf(stderr, "%s %s: %v\n", Name, cmd.name, err)
+		return 1
+	}
+}
+
+func lookup(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func writeUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags] [arguments]\n\nCommands:\n", Name)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of a command. Every command also accepts\nthe configuration flags (--config, --env-file and one flag per setting).\n", Name)
+}
+
+func runHelp(e *env, args []string) error {
+	if len(args) > 0 {
+		if cmd := lookup(args[0]); cmd != nil && cmd.name != "help" {
+			return cmd.run(e, []string{"-h"})
+		}
+		return usagef("unknown command %q", args[0])
+	}
+	writeUsage(e.stdout)
+	return nil
+}
+
+// flags - флаги команды вместе с флагами настроек.
+type flags struct {
+	*flag.FlagSet
+	loader *config.Loader
+}
+
+// newFlags создает набор флагов команды name. usage - строка вызова для справки.
+func (e *env) newFlags(name string, usage string) *flags {
+	fs := flag.NewFlagSet(Name+" "+name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: %s %s\n\nFlags:\n", Name, usage)
+		fs.PrintDefaults()
+	}
+	return &flags{FlagSet: fs, loader: config.NewLoader(fs)}
+}
+
+// alias добавляет флаг name - короткое имя флага настройки key.
+func (f *flags) alias(name string, key string) {
+	f.Func(name, "shorthand for --"+key, func(value string) error { return f.Set(key, value) })
+}
+
+// remoteFlags добавляет короткие имена настроек cli.*: --url, --token, --workspace.
+func (f *flags) remoteFlags() {
+	f.alias("url", "cli.url")
+	f.alias("token", "cli.token")
+	f.alias("workspace", "cli.workspace")
+}
+
+// parse разбирает args, допуская флаги после аргументов, и загружает настройки.
+// Возвращает настройки и аргументы без флагов.
+func (f *flags) parse(e *env, args []string) (*config.Config, []string, error) {
+	// После "--" флагов нет, даже если аргумент начинается с "-"
+	var tail []string
+	if i := slices.Index(args, "--"); i >= 0 {
+		args, tail = args[:i], args[i+1:]
+	}
+	var positional []string
+	for {
+		if err := f.Parse(args); err != nil {
+			return nil, nil, err
+		}
+		if args = f.Args(); len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	positional = append(positional, tail...)
+
+	cfg, err := f.loader.Load()
+	if err != nil {
+		return nil, nil, &configError{err: err}
+	}
+	if f.loader.PrintConfig {
+		if err := cfg.WriteYAML(e.stdout); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, errDone
+	}
+	return cfg, positional, nil
+}
@@ -0,0 +1,254 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"todo/internal/app"
+	"todo/internal/db"
+	"todo/internal/handlers"
+	"todo/pkg/config"
+	"todo/pkg/sqlite3"
+)
+
+// run выполняет команду и возвращает код завершения и вывод.
+func run(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// mustRun выполняет команду, ожидая успеха.
+func mustRun(t *testing.T, args ...string) string {
+	t.Helper()
+	code, stdout, stderr := run(t, args...)
+	if code != 0 {
+		t.Fatalf("%s: exit code %d: %s", strings.Join(args, " "), code, stderr)
+	}
+	return stdout
+}
+
+func decodeTasks(t *testing.T, output string) []*db.Task {
+	t.Helper()
+	var tasks []*db.Task
+	if err := json.Unmarshal([]byte(output), &tasks); err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	return tasks
+}
+
+func setupEnv(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	t.Setenv("FILEPATH", path)
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LWO_URL", "")
+	return path
+}
+
+func TestLocalTasks(t *testing.T) {
+	setupEnv(t)
+
+	out := mustRun(t, "task", "add", "Buy", "milk", "--due", "2099-01-02", "--estimate", "30")
+	if !strings.Contains(out, "Buy milk") || !strings.Contains(out, "2099-01-02 23:59:59") {
+		t.Errorf("add output:\n%s", out)
+	}
+	added := decodeTasks(t, mustRun(t, "task", "add", "--title", "Second", "--due", "2099-01-03", "-o", "json"))
+	if len(added) != 1 || added[0].Title != "Second" || added[0].Status != "todo" {
+		t.Fatalf("added = %+v", added)
+	}
+
+	mustRun(t, "task", "done", "1")
+	edited := decodeTasks(t, mustRun(t, "task", "edit", "2", "--title", "Renamed", "--status", "in_progress", "--output", "json"))
+	if edited[0].Title != "Renamed" || edited[0].Status != "in_progress" {
+		t.Errorf("edited = %+v", edited[0])
+	}
+
+	open := decodeTasks(t, mustRun(t, "task", "ls", "--open", "-o", "json"))
+	if len(open) != 1 || open[0].ID != 2 {
+		t.Errorf("open tasks = %+v", open)
+	}
+
+	code, out, stderr := run(t, "task", "rm", "2", "42")
+	if code != 1 || !strings.Contains(out, "task 2 deleted") || !strings.Contains(stderr, "task 42 not found") {
+		t.Errorf("rm: code %d, stdout %q, stderr %q", code, out, stderr)
+	}
+
+	for _, args := range [][]string{
+		{"task", "add"},
+		{"task", "edit", "1"},
+		{"task", "done", "first"},
+		{"task", "ls", "-o", "yaml"},
+		{"task", "frobnicate"},
+		{"tui", "extra"},
+		{"tui", "--refresh", "-1s"},
+		{"frobnicate"},
+	} {
+		if code, _, _ := run(t, args...); code != 2 {
+			t.Errorf("%v: exit code %d, want 2", args, code)
+		}
+	}
+	if code, _, stderr := run(t, "task", "ls", "--jobs.interval", "0s"); code != 2 || !strings.Contains(stderr, "invalid configuration") {
+		t.Errorf("invalid configuration: code %d: %s", code, stderr)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	path := setupEnv(t)
+	// Просроченная задача тоже переносится: локальная загрузка не проверяет сроки
+	repository, err := db.TaskRepositoryInit(config.Database{Path: path}, config.Defaults().Workflow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	title, description, due := "Old", "", "2000-01-01 23:59:59"
+	_, err = repository.CreateTask(context.Background(), &db.TaskInput{Title: &title, Description: &description, DueDate: &due, CreatedAt: "1999-12-01 10:00:00"})
+	repository.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustRun(t, "task", "add", "Done", "--due", "2099-01-02")
+	mustRun(t, "task", "done", "2")
+
+	exported := filepath.Join(t.TempDir(), "tasks.ndjson")
+	mustRun(t, "export", "--format", "ndjson", "--file", exported)
+	backup := filepath.Join(t.TempDir(), "backup.db")
+	mustRun(t, "backup", backup)
+	if code, _, stderr := run(t, "backup", backup); code != 1 || !strings.Contains(stderr, "--force") {
+		t.Errorf("existing backup is overwritten: %s", stderr)
+	}
+
+	target := filepath.Join(t.TempDir(), "copy.db")
+	if out := mustRun(t, "import", exported, "--database.path", target); !strings.Contains(out, "imported 2 tasks") {
+		t.Errorf("import output: %s", out)
+	}
+	imported := decodeTasks(t, mustRun(t, "task", "ls", "-o", "json", "--database.path", target))
+	if len(imported) != 2 || !imported[0].IsOverdue || imported[0].CreatedAt != "1999-12-01 10:00:00" || imported[1].Status != "done" {
+		t.Errorf("imported = %+v %+v", imported[0], imported[1])
+	}
+
+	mustRun(t, "task", "rm", "1", "2")
+	mustRun(t, "restore", backup)
+	restored := decodeTasks(t, mustRun(t, "task", "ls", "-o", "json"))
+	if len(restored) != 2 || !restored[0].IsOverdue {
+		t.Errorf("restored = %+v", restored)
+	}
+
+	if code, _, _ := run(t, "restore", exported); code != 1 {
+		t.Error("not a database is restored")
+	}
+
+	// База с открытой транзакцией другого процесса не заменяется
+	conn, err := sqlite3.Open(path, sqlite3.Options{MaxOpenConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM tasks")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr := run(t, "restore", backup, "--database.busy_timeout", "50ms")
+	tx.Rollback()
+	conn.Close()
+	if code != 1 || !strings.Contains(stderr, "in use") {
+		t.Errorf("database in use is restored: %d %s", code, stderr)
+	}
+	if len(decodeTasks(t, mustRun(t, "task", "ls", "-o", "json"))) != 2 {
+		t.Error("failed restore changed the database")
+	}
+}
+
+func TestMigrateWorkflowStatuses(t *testing.T) {
+	path := setupEnv(t)
+	t.Setenv("WORKFLOW_TRANSITIONS", "open:closed;closed:open")
+	t.Setenv("WORKFLOW_INITIAL", "open")
+	t.Setenv("WORKFLOW_DONE", "closed")
+
+	// База первой версии схемы: статусов еще нет, есть только completed
+	conn, err := sqlite3.Open(path, sqlite3.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`CREATE TABLE tasks (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL, description TEXT,
+		due_date TEXT NOT NULL, completed INTEGER NOT NULL, overdue INTEGER NOT NULL, created_at TEXT NOT NULL);
+		INSERT INTO tasks (title, due_date, completed, overdue, created_at) VALUES
+			('pending', '2099-01-01 23:59:59', 0, 0, '2024-01-01 00:00:00'),
+			('finished', '2099-01-01 23:59:59', 1, 0, '2024-01-01 00:00:00');
+		PRAGMA user_version = 1;`)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mustRun(t, "migrate")
+	tasks := decodeTasks(t, mustRun(t, "task", "ls", "-o", "json"))
+	if len(tasks) != 2 || tasks[0].Status != "open" || tasks[1].Status != "closed" || !tasks[1].IsCompleted {
+		t.Errorf("migrated tasks = %+v %+v", tasks[0], tasks[1])
+	}
+}
+
+func TestRemoteTasks(t *testing.T) {
+	setupEnv(t)
+	t.Setenv("AUTH_ADMIN_USERNAME", "admin")
+	t.Setenv("AUTH_ADMIN_PASSWORD", "password-admin")
+	t.Setenv("NOTIFIERS", "")
+	t.Setenv("ATTACHMENTS_DIR", filepath.Join(t.TempDir(), "attachments"))
+
+	cfg, err := config.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := app.NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a.Routes())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/auth/login", "application/json", strings.NewReader(`{"username":"admin","password":"password-admin"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var login handlers.LoginResponse
+	json.NewDecoder(resp.Body).Decode(&login)
+	resp.Body.Close()
+
+	remote := []string{"--url", server.URL, "--token", login.Token, "-o", "json"}
+	added := decodeTasks(t, mustRun(t, append([]string{"task", "add", "Remote", "--due", "2099-01-01"}, remote...)...))
+	mustRun(t, append([]string{"task", "done", "1"}, remote...)...)
+	listed := decodeTasks(t, mustRun(t, append([]string{"task", "ls"}, remote...)...))
+	if len(listed) != 1 || listed[0].ID != added[0].ID || !listed[0].IsCompleted {
+		t.Errorf("listed = %+v", listed)
+	}
+
+	code, _, stderr := run(t, "task", "ls", "--url", server.URL, "--token", "wrong")
+	if code != 1 || !strings.Contains(stderr, "401") {
+		t.Errorf("unauthenticated: code %d: %s", code, stderr)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	bash := mustRun(t, "completion", "bash")
+	for _, want := range []string{"complete -o default -F _lwo_go lwo-go", `"add ls done rm edit"`, `"json ndjson"`} {
+		if !strings.Contains(bash, want) {
+			t.Errorf("bash completion does not contain %q:\n%s", want, bash)
+		}
+	}
+	if zsh := mustRun(t, "completion", "zsh"); !strings.HasPrefix(zsh, "#compdef lwo-go") {
+		t.Errorf("zsh completion:\n%s", zsh)
+	}
+	if fish := mustRun(t, "completion", "fish"); !strings.Contains(fish, "-a task -d 'list and change tasks'") {
+		t.Errorf("fish completion:\n%s", fish)
+	}
+	if code, _, _ := run(t, "completion", "powershell"); code != 2 {
+		t.Errorf("unknown shell: exit code %d", code)
+	}
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+var shells = []string{"bash", "zsh", "fish"}
+
+// commonFlags - флаги загрузки настроек, которые есть у всех команд.
+var commonFlags = []string{"--config", "--env-file", "--print-config"}
+
+// fileCommands принимают путь к файлу аргументом.
+var fileCommands = []string{"import", "backup", "restore"}
+
+// flagValues - значения флагов с фиксированным набором вариантов.
+var flagValues = map[string][]string{
+	"--output": {"table", "json"},
+	"--format": {"json", "ndjson"},
+}
+
+// runCompletion печатает скрипт дополнения для оболочки из аргумента.
+func runCompletion(e *env, args []string) error {
+	if len(args) != 1 {
+		return usagef("expected one of %s", strings.Join(shells, ", "))
+	}
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(e.stdout, false)
+	case "zsh":
+		writeBashCompletion(e.stdout, true)
+	case "fish":
+		writeFishCompletion(e.stdout)
+	default:
+		return usagef("unknown shell %q, expected %s", args[0], strings.Join(shells, ", "))
+	}
+	return nil
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+// writeBashCompletion печатает функцию дополнения для bash. zsh использует ее же через bashcompinit.
+func writeBashCompletion(w io.Writer, zsh bool) {
+	function := "_" + strings.ReplaceAll(Name, "-", "_")
+	if zsh {
+		fmt.Fprintf(w, "#compdef %s\nautoload -U +X bashcompinit && bashcompinit\n\n", Name)
+	}
+	fmt.Fprintf(w, "%s() {\n", function)
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "    if [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n        return\n    fi\n", strings.Join(commandNames(), " "))
+
+	fmt.Fprintf(w, "    case \"$prev\" in\n")
+	for _, flag := range []string{"--output", "--format"} {
+		fmt.Fprintf(w, "    %s)\n        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n        return;;\n", flag, strings.Join(flagValues[flag], " "))
+	}
+	fmt.Fprintf(w, "    esac\n")
+
+	fmt.Fprintf(w, "    case \"${COMP_WORDS[1]}\" in\n")
+	for _, cmd := range commands {
+		if len(cmd.subcommands) == 0 && len(cmd.flags) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "    %s)\n", cmd.name)
+		if len(cmd.subcommands) > 0 {
+			fmt.Fprintf(w, "        if [[ $COMP_CWORD -eq 2 ]]; then\n            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n            return\n        fi\n", strings.Join(cmd.subcommands, " "))
+		}
+		fmt.Fprintf(w, "        if [[ \"$cur\" == -* ]]; then\n            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n        fi;;\n", strings.Join(slices.Concat(cmd.flags, commonFlags), " "))
+	}
+	fmt.Fprintf(w, "    *)\n        if [[ \"$cur\" == -* ]]; then\n            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n        fi;;\n", strings.Join(commonFlags, " "))
+	fmt.Fprintf(w, "    esac\n}\n\ncomplete -o default -F %s %s\n", function, Name)
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintf(w, "complete -c %s -f\n", Name)
+	for _, flag := range commonFlags {
+		fmt.Fprintf(w, "complete -c %s -l %s\n", Name, strings.TrimPrefix(flag, "--"))
+	}
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from %s' -F\n", Name, strings.Join(fileCommands, " "))
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a %s -d %s\n", Name, cmd.name, fishQuote(cmd.summary))
+		seen := "__fish_seen_subcommand_from " + cmd.name
+		if len(cmd.subcommands) > 0 {
+			subcommands := strings.Join(cmd.subcommands, " ")
+			fmt.Fprintf(w, "complete -c %s -n '%s; and not __fish_seen_subcommand_from %s' -a '%s'\n", Name, seen, subcommands, subcommands)
+		}
+		for _, flag := range cmd.flags {
+			name := strings.TrimPrefix(flag, "--")
+			if values, ok := flagValues[flag]; ok {
+				fmt.Fprintf(w, "complete -c %s -n '%s' -l %s -xa '%s'\n", Name, seen, name, strings.Join(values, " "))
+				continue
+			}
+			fmt.Fprintf(w, "complete -c %s -n '%s' -l %s\n", Name, seen, name)
+		}
+	}
+}
+
+func fishQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"todo/internal/auth"
+	"todo/internal/db"
+	"todo/internal/tenant"
+	"todo/pkg/config"
+	"todo/pkg/sqlite3"
+)
+
+// runMigrate доводит схему основной базы до версии программы, а в режиме workspaces.mode=file -
+// и базы активных пространств.
+func runMigrate(e *env, args []string) error {
+	f := e.newFlags("migrate", "migrate [flags]")
+	cfg, rest, err := f.parse(e, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usagef("unexpected arguments %q", rest)
+	}
+
+	before, err := userVersion(cfg.Database)
+	if err != nil {
+		return err
+	}
+	repository, err := db.TaskRepositoryInit(cfg.Database, cfg.Workflow)
+	if err != nil {
+		return err
+	}
+	defer repository.Close()
+
+	after, latest, err := repository.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if after > latest {
+		return fmt.Errorf("%s: schema version %d is newer than this build supports (%d)", cfg.Database.Path, after, latest)
+	}
+	if before == after {
+		fmt.Fprintf(e.stdout, "%s: schema version %d is up to date\n", cfg.Database.Path, after)
+	} else {
+		fmt.Fprintf(e.stdout, "%s: migrated from version %d to %d\n", cfg.Database.Path, before, after)
+	}
+
+	tenants := tenant.NewManager(repository, cfg.Workspaces)
+	defer tenants.Close()
+	if !tenants.Separate() {
+		return nil
+	}
+	// Базы пространств мигрируют при открытии
+	return tenants.Each(auth.SystemContext(e.ctx), func(ctx context.Context, repo *db.TaskRepository) error {
+		workspace, _ := db.WorkspaceFromContext(ctx)
+		version, _, err := repo.SchemaVersion()
+		if err == nil {
+			fmt.Fprintf(e.stdout, "workspace %s: schema version %d\n", workspace.Slug, version)
+		}
+		return err
+	})
+}
+
+// userVersion читает версию схемы, не применяя миграции.
+func userVersion(database config.Database) (int, error) {
+	conn, err := sqlite3.Open(database.Path, sqlite3.Options{BusyTimeout: database.BusyTimeout})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var version int
+	err = conn.QueryRow("PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+// runBackup сохраняет копию базы, не останавливая сервер. С --workspace в режиме file
+// копируется база пространства, иначе основная (в режиме column в ней все пространства).
+func runBackup(e *env, args []string) error {
+	f := e.newFlags("backup", "backup [flags] <file>")
+	f.alias("workspace", "cli.workspace")
+	force := f.Bool("force", false, "overwrite an existing file")
+	cfg, rest, err := f.parse(e, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usagef("backup file is required")
+	}
+	target := rest[0]
+
+	if _, err := os.Stat(target); err == nil {
+		if !*force {
+			return fmt.Errorf("%s already exists, use --force to overwrite it", target)
+		}
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	repository, err := db.TaskRepositoryInit(cfg.Database, cfg.Workflow)
+	if err != nil {
+		return err
+	}
+	defer repository.Close()
+	tenants := tenant.NewManager(repository, cfg.Workspaces)
+	defer tenants.Close()
+
+	source, release := repository, func() {}
+	if cfg.CLI.Workspace != "" && tenants.Separate() {
+		workspace, err := tenants.Resolve(cfg.CLI.Workspace, auth.System)
+		if err != nil {
+			return fmt.Errorf("workspace %s: %w", cfg.CLI.Workspace, err)
+		}
+		var ctx context.Context
+		if ctx, release, err = tenants.Bind(e.ctx, workspace); err != nil {
+			return err
+		}
+		source = repository.ForContext(ctx)
+	}
+	defer release()
+
+	if err := source.Backup(target); err != nil {
+		return err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "backed up %s to %s (%d bytes)\n", source.Path(), target, info.Size())
+	return nil
+}
+
+// runRestore заменяет базу копией из backup. Копия проверяется и мигрирует рядом с базой,
+// а подменяет ее одним переименованием. Сервер на это время нужно остановить: базу, занятую
+// другим процессом, restore не трогает, но простаивающие соединения без WAL он не видит.
+func runRestore(e *env, args []string) error {
+	f := e.newFlags("restore", "restore [flags] <file>")
+	f.alias("workspace", "cli.workspace")
+	cfg, rest, err := f.parse(e, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usagef("backup file is required")
+	}
+
+	target := cfg.Database.Path
+	if slug := cfg.CLI.Workspace; slug != "" && slug != db.DefaultWorkspaceSlug && cfg.Workspaces.Mode == config.WorkspaceModeFile {
+		if !tenant.ValidSlug(slug) {
+			return usagef("invalid workspace %q", slug)
+		}
+		target = filepath.Join(cfg.Workspaces.Dir, slug+".db")
+	}
+
+	restored, err := copyToTemp(rest[0], target)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(restored)
+
+	database := cfg.Database
+	database.Path = restored
+	if err := checkBackup(database, cfg.Workflow); err != nil {
+		return fmt.Errorf("%s: %w", rest[0], err)
+	}
+
+	if err := checkNotInUse(cfg.Database, target); err != nil {
+		return err
+	}
+	// Журналы SQLite относятся к заменяемой базе
+	for _, journal := range []string{target + "-wal", target + "-shm"} {
+		if err := os.Remove(journal); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(restored, target); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "restored %s from %s\n", target, rest[0])
+	return nil
+}
+
+// checkNotInUse проверяет, что базу target никто не использует: берет на нее эксклюзивную
+// блокировку, ожидая не дольше database.busy_timeout. Блокировку не получить, пока в другом
+// процессе идет транзакция, а в режиме WAL - пока база вообще открыта.
+func checkNotInUse(database config.Database, target string) error {
+	if _, err := os.Stat(target); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	conn, err := sqlite3.Open(target, sqlite3.Options{MaxOpenConns: 1, BusyTimeout: database.BusyTimeout})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Exec("PRAGMA locking_mode = EXCLUSIVE"); err != nil {
+		return err
+	}
+	if _, err := conn.Exec("BEGIN EXCLUSIVE"); err != nil {
+		return fmt.Errorf("%s is in use, stop the server before restore: %w", target, err)
+	}
+	_, err = conn.Exec("ROLLBACK")
+	return err
+}
+
+// copyToTemp копирует source во временный файл в каталоге target, чтобы потом подменить
+// target переименованием.
+func copyToTemp(source string, target string) (string, error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+	out, err := os.CreateTemp(filepath.Dir(target), filepath.Base(target)+".restore-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// checkBackup открывает копию: применяет миграции и проверяет целостность и версию схемы.
+func checkBackup(database config.Database, workflow config.Workflow) error {
+	repository, err := db.TaskRepositoryInit(database, workflow)
+	if err != nil {
+		return err
+	}
+	defer repository.Close()
+
+	if err := repository.CheckIntegrity(); err != nil {
+		return err
+	}
+	version, latest, err := repository.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > latest {
+		return fmt.Errorf("schema version %d is newer than this build supports (%d)", version, latest)
+	}
+	return nil
+}
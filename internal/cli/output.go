@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"todo/internal/db"
+)
+
+// writeTasks выводит задачи таблицей или массивом JSON (format "json").
+func writeTasks(w io.Writer, format string, tasks []*db.Task) error {
+	if format == "json" {
+		if tasks == nil {
+			tasks = []*db.Task{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(tasks)
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tSTATUS\tDUE\tPROJECT\tTITLE")
+	for _, task := range tasks {
+		due := task.DueDate
+		if task.IsOverdue {
+			due += " (overdue)"
+		}
+		project := "-"
+		if task.ProjectID != 0 {
+			project = strconv.Itoa(task.ProjectID)
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\n", task.ID, task.Status, due, project, oneLine(task.Title))
+	}
+	return table.Flush()
+}
+
+// oneLine заменяет переводы строк и табуляции пробелами, чтобы не ломать таблицу.
+func oneLine(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
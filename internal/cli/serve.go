@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"todo/internal/app"
+	"todo/internal/tracing"
+)
+
+// runServe запускает сервер и работает до SIGINT или SIGTERM. SIGHUP перечитывает настройки.
+func runServe(e *env, args []string) error {
+	f := e.newFlags("serve", "serve [flags]")
+	cfg, rest, err := f.parse(e, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usagef("unexpected arguments %q", rest)
+	}
+
+	a, err := app.NewApp(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize TODO application: %w", err)
+	}
+
+	server := a.StartServer()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	background, stopBackground := context.WithCancel(e.ctx)
+	a.StartBackgroundTask(background)
+
+	failed := make(chan error, 1)
+	go func() {
+		serve := server.ListenAndServe
+		if server.TLSConfig != nil {
+			serve = func() error { return server.ListenAndServeTLS("", "") }
+		}
+		if err := serve(); err != nil && err != http.ErrServerClosed {
+			failed <- err
+		}
+	}()
+	slog.Info("server started", "address", server.Addr)
+
+	// SIGHUP перечитывает настройки, остальные сигналы завершают программу
+	for {
+		var sig os.Signal
+		select {
+		case err := <-failed:
+			stopBackground()
+			return fmt.Errorf("server failed: %w", err)
+		case sig = <-sigs:
+		}
+		if sig != syscall.SIGHUP {
+			slog.Info("received signal, initiating shutdown", "signal", sig.String())
+			break
+		}
+
+		slog.Info("received signal, reloading configuration", "signal", sig.String())
+		next, err := f.loader.Load()
+		if err == nil {
+			_, err = a.Reload(next)
+		}
+		if err != nil {
+			slog.Error("configuration is not reloaded, keeping the current one", "error", err)
+		}
+	}
+	a.BeginShutdown()
+
+	// Пока балансировщик не увидит 503 на /readyz, сервер продолжает принимать запросы.
+	// Повторный сигнал завершает ожидание сразу.
+	if delay := cfg.Server.ShutdownDelay; delay > 0 {
+		slog.Info("draining before shutdown", "delay", delay.String())
+		select {
+		case <-time.After(delay):
+		case <-sigs:
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	stopBackground()
+
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("server shutdown failed: %w", err)
+	}
+	a.Wait()
+	a.Close()
+	if err := tracing.Default().Shutdown(ctx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+	slog.Info("server gracefully stopped")
+	return nil
+}
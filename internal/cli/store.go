@@ -0,0 +1,325 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"todo/internal/auth"
+	"todo/internal/db"
+	"todo/internal/events"
+	"todo/internal/handlers"
+	"todo/internal/tenant"
+	"todo/internal/webhook"
+	"todo/pkg/client"
+	"todo/pkg/config"
+)
+
+// taskStore - задачи, с которыми работают команды: локальная база или сервер cli.url.
+type taskStore interface {
+	List(ctx context.Context, projectID int) ([]*db.Task, error)
+	Get(ctx context.Context, id int) (*db.Task, error)
+	Create(ctx context.Context, input *db.TaskInput) (*db.Task, error)
+	Update(ctx context.Context, id int, input *db.TaskInput) (*db.Task, error)
+	Complete(ctx context.Context, id int) (*db.Task, error)
+	Transition(ctx context.Context, id int, status string) (*db.Task, error)
+	Delete(ctx context.Context, id int) error
+	// Import создает задачу из выгрузки и переводит ее в статус из выгрузки.
+	Import(ctx context.Context, task *db.Task) (*db.Task, error)
+	Close() error
+}
+
+// openStore открывает сервер, если задан cli.url, иначе локальную базу.
+func openStore(ctx context.Context, cfg *config.Config) (taskStore, error) {
+	if cfg.CLI.URL != "" {
+		return newRemoteStore(cfg.CLI)
+	}
+	return openLocalStore(ctx, cfg)
+}
+
+// localStore работает с базой напрямую через тот же сервисный слой, что и REST, от имени
+// системного принципала. События попадают в очередь вебхуков, которую доставит сервер.
+type localStore struct {
+	repo    *db.TaskRepository
+	tenants *tenant.Manager
+	handler *handlers.Handler
+	// scope - принципал и пространство, привязанные при открытии.
+	scope   context.Context
+	release func()
+}
+
+func openLocalStore(ctx context.Context, cfg *config.Config) (*localStore, error) {
+	repository, err := db.TaskRepositoryInit(cfg.Database, cfg.Workflow)
+	if err != nil {
+		return nil, err
+	}
+	tenants := tenant.NewManager(repository, cfg.Workspaces)
+
+	slug := cfg.CLI.Workspace
+	if slug == "" {
+		slug = db.DefaultWorkspaceSlug
+	}
+	workspace, err := tenants.Resolve(slug, auth.System)
+	if err == nil {
+		ctx, release, bindErr := tenants.Bind(auth.SystemContext(ctx), workspace)
+		if bindErr == nil {
+			bus := events.NewBus()
+			bus.Subscribe(webhook.DispatcherFromConfig(repository, cfg.Webhooks).Enqueue)
+			handler := handlers.NewHandler(repository, bus)
+			handler.AutoCompleteChecklist = cfg.Tasks.ChecklistAutoComplete
+			return &localStore{repo: repository, tenants: tenants, handler: handler, scope: ctx, release: release}, nil
+		}
+		err = bindErr
+	}
+	tenants.Close()
+	repository.Close()
+	return nil, fmt.Errorf("workspace %s: %w", slug, err)
+}
+
+// scoped добавляет к ctx значения scope: принципала и пространство.
+type scoped struct {
+	context.Context
+	scope context.Context
+}
+
+func (c scoped) Value(key any) any {
+	if value := c.scope.Value(key); value != nil {
+		return value
+	}
+	return c.Context.Value(key)
+}
+
+func (s *localStore) context(ctx context.Context) context.Context {
+	return scoped{Context: ctx, scope: s.scope}
+}
+
+func (s *localStore) List(ctx context.Context, projectID int) ([]*db.Task, error) {
+	ctx = s.context(ctx)
+	return s.repo.GetAllTasks(ctx, &db.TaskFilter{ProjectID: projectID})
+}
+
+func (s *localStore) Get(ctx context.Context, id int) (*db.Task, error) {
+	return s.repo.GetTaskById(s.context(ctx), id)
+}
+
+func (s *localStore) Create(ctx context.Context, input *db.TaskInput) (*db.Task, error) {
+	return s.handler.CreateTask(s.context(ctx), input)
+}
+
+func (s *localStore) Update(ctx context.Context, id int, input *db.TaskInput) (*db.Task, error) {
+	return s.handler.UpdateTask(s.context(ctx), id, input)
+}
+
+func (s *localStore) Complete(ctx context.Context, id int) (*db.Task, error) {
+	return s.handler.CompleteTask(s.context(ctx), id)
+}
+
+func (s *localStore) Transition(ctx context.Context, id int, status string) (*db.Task, error) {
+	return s.handler.TransitionTask(s.context(ctx), id, status)
+}
+
+func (s *localStore) Delete(ctx context.Context, id int) error {
+	deleted, err := s.handler.DeleteTask(s.context(ctx), id)
+	if err == nil && !deleted {
+		err = db.ErrTaskNotFound
+	}
+	return err
+}
+
+// Import в локальной базе сохраняет задачу как есть, с датой создания и сроком из выгрузки,
+// без проверок сервисного слоя: иначе не перенести просроченные задачи.
+func (s *localStore) Import(ctx context.Context, task *db.Task) (*db.Task, error) {
+	ctx = s.context(ctx)
+	input := importInput(task)
+	input.DueDate = &task.DueDate
+	input.CreatedAt = task.CreatedAt
+	if input.CreatedAt == "" {
+		input.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
+	}
+
+	created, err := s.repo.CreateTask(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status == "" || task.Status == created.Status {
+		return created, nil
+	}
+	if err := s.repo.TransitionTask(ctx, created.ID, task.Status); err != nil {
+		return created, fmt.Errorf("task %d is created, but status is not set: %w", created.ID, err)
+	}
+	return s.repo.GetTaskById(ctx, created.ID)
+}
+
+func (s *localStore) Close() error {
+	s.release()
+	s.tenants.Close()
+	return s.repo.Close()
+}
+
+// importInput - поля задачи из выгрузки, которые принимает создание.
+func importInput(task *db.Task) *db.TaskInput {
+	input := &db.TaskInput{
+		Title:        &task.Title,
+		Description:  &task.Description,
+		CustomFields: task.CustomFields,
+	}
+	if task.ProjectID != 0 {
+		input.ProjectID = &task.ProjectID
+	}
+	if task.EstimateMinutes != 0 {
+		input.EstimateMinutes = &task.EstimateMinutes
+	}
+	if len(task.Assignees) > 0 {
+		input.Assignees = &task.Assignees
+	}
+	if len(task.Watchers) > 0 {
+		input.Watchers = &task.Watchers
+	}
+	return input
+}
+
+// remoteStore работает с задачами через REST API сервера.
+type remoteStore struct {
+	client     *client.Client
+	httpClient *http.Client
+}
+
+func newRemoteStore(cfg config.CLI) (*remoteStore, error) {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	c, err := newClient(cfg, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteStore{client: c, httpClient: httpClient}, nil
+}
+
+// newClient создает клиент сервера cli.url с токеном и пространством из настроек.
+func newClient(cfg config.CLI, httpClient *http.Client) (*client.Client, error) {
+	options := []client.Option{client.WithHTTPClient(httpClient), client.WithUserAgent(Name), client.WithWorkspace(cfg.Workspace)}
+	if cfg.Token != "" {
+		options = append(options, client.WithAuth(client.BearerToken(cfg.Token)))
+	}
+	return client.New(cfg.URL, options...)
+}
+
+func (s *remoteStore) List(ctx context.Context, projectID int) ([]*db.Task, error) {
+	tasks, err := s.client.ListTasks(ctx, &client.ListOptions{ProjectID: projectID})
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]*db.Task, len(tasks))
+	for i, task := range tasks {
+		converted[i] = fromClient(task)
+	}
+	return converted, nil
+}
+
+func (s *remoteStore) Get(ctx context.Context, id int) (*db.Task, error) {
+	return remoteTask(s.client.GetTask(ctx, id))
+}
+
+func (s *remoteStore) Create(ctx context.Context, input *db.TaskInput) (*db.Task, error) {
+	return remoteTask(s.client.CreateTask(ctx, clientInput(input)))
+}
+
+func (s *remoteStore) Update(ctx context.Context, id int, input *db.TaskInput) (*db.Task, error) {
+	return remoteTask(s.client.UpdateTask(ctx, id, clientInput(input)))
+}
+
+func (s *remoteStore) Complete(ctx context.Context, id int) (*db.Task, error) {
+	return remoteTask(s.client.CompleteTask(ctx, id))
+}
+
+func (s *remoteStore) Transition(ctx context.Context, id int, status string) (*db.Task, error) {
+	return remoteTask(s.client.SetTaskStatus(ctx, id, status))
+}
+
+func (s *remoteStore) Delete(ctx context.Context, id int) error {
+	return s.client.DeleteTask(ctx, id)
+}
+
+// Import через сервер проходит обычные проверки создания задачи: срок должен быть в будущем.
+func (s *remoteStore) Import(ctx context.Context, task *db.Task) (*db.Task, error) {
+	input := importInput(task)
+	if date, _, _ := strings.Cut(task.DueDate, " "); date != "" {
+		input.DueDate = &date
+	}
+
+	created, err := s.Create(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status == "" || task.Status == created.Status {
+		return created, nil
+	}
+	updated, err := s.Transition(ctx, created.ID, task.Status)
+	if err != nil {
+		return created, fmt.Errorf("task %d is created, but status is not set: %w", created.ID, err)
+	}
+	return updated, nil
+}
+
+func (s *remoteStore) Close() error {
+	s.httpClient.CloseIdleConnections()
+	return nil
+}
+
+func remoteTask(task *client.Task, err error) (*db.Task, error) {
+	if err != nil {
+		return nil, err
+	}
+	return fromClient(task), nil
+}
+
+// fromClient переводит задачу из ответа сервера в тип базы, общий для обоих хранилищ.
+func fromClient(task *client.Task) *db.Task {
+	converted := &db.Task{
+		ID:              task.ID,
+		Title:           task.Title,
+		Description:     task.Description,
+		DueDate:         task.DueDate,
+		Status:          task.Status,
+		IsCompleted:     task.IsCompleted,
+		IsOverdue:       task.IsOverdue,
+		CreatedAt:       task.CreatedAt,
+		OwnerID:         task.OwnerID,
+		ProjectID:       task.ProjectID,
+		EstimateMinutes: task.EstimateMinutes,
+		Assignees:       task.Assignees,
+		Watchers:        task.Watchers,
+		CustomFields:    task.CustomFields,
+	}
+	if task.IsCompleted {
+		converted.Completed = 1
+	}
+	if task.IsOverdue {
+		converted.Overdue = 1
+	}
+	for _, item := range task.Checklist {
+		converted.Checklist = append(converted.Checklist, &db.ChecklistItem{ID: item.ID, Text: item.Text, Done: item.Done, Position: item.Position})
+	}
+	if progress := task.ChecklistProgress; progress != nil {
+		converted.ChecklistProgress = &db.ChecklistProgress{Done: progress.Done, Total: progress.Total}
+	}
+	return converted
+}
+
+func clientInput(input *db.TaskInput) *client.TaskInput {
+	return &client.TaskInput{
+		Title:           input.Title,
+		Description:     input.Description,
+		DueDate:         input.DueDate,
+		ProjectID:       input.ProjectID,
+		Assignees:       input.Assignees,
+		Watchers:        input.Watchers,
+		EstimateMinutes: input.EstimateMinutes,
+		CustomFields:    input.CustomFields,
+	}
+}
+
+// isNotFound сообщает, что задачи нет ни в базе, ни на сервере.
+func isNotFound(err error) bool {
+	return errors.Is(err, db.ErrTaskNotFound) || errors.Is(err, client.ErrNotFound)
+}
@@ -0,0 +1,279 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"todo/internal/db"
+)
+
+// taskCommand - подкоманда lwo-go task.
+type taskCommand struct {
+	name  string
+	usage string
+	run   func(e *env, args []string) error
+}
+
+// taskCommands не зависят от списка команд, поэтому объявлены без init.
+var taskCommands = []taskCommand{
+	{name: "add", usage: "task add [flags] <title>", run: runTaskAdd},
+	{name: "ls", usage: "task ls [flags]", run: runTaskList},
+	{name: "done", usage: "task done [flags] <id>...", run: runTaskDone},
+	{name: "rm", usage: "task rm [flags] <id>...", run: runTaskRemove},
+	{name: "edit", usage: "task edit [flags] <id>", run: runTaskEdit},
+}
+
+// taskFlags - флаги подкоманд task для дополнения в оболочке.
+var taskFlags = []string{"--output", "--url", "--token", "--workspace", "--title", "--description", "--due", "--project", "--estimate", "--status", "--overdue", "--open"}
+
+func taskCommandNames() []string {
+	names := make([]string, 0, len(taskCommands))
+	for _, cmd := range taskCommands {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+func runTask(e *env, args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprintf(e.stderr, "Usage: %s task <command> [flags]\n\nCommands:\n", Name)
+		for _, cmd := range taskCommands {
+			fmt.Fprintf(e.stderr, "  %s\n", cmd.usage)
+		}
+		fmt.Fprintf(e.stderr, "\nWith --url (LWO_URL) commands use the server, otherwise the database file.\n")
+		if len(args) == 0 {
+			return usagef("command is required")
+		}
+		return flag.ErrHelp
+	}
+	for _, cmd := range taskCommands {
+		if cmd.name == args[0] {
+			return cmd.run(e, args[1:])
+		}
+	}
+	return usagef("unknown command %q, expected %s", args[0], strings.Join(taskCommandNames(), ", "))
+}
+
+// taskFlagSet - флаги подкоманды task: настройки, сервер и формат вывода.
+type taskFlagSet struct {
+	*flags
+	output string
+}
+
+func (e *env) taskFlags(usage string) *taskFlagSet {
+	f := &taskFlagSet{flags: e.newFlags("task", usage)}
+	f.remoteFlags()
+	f.StringVar(&f.output, "output", "table", "output format: table or json")
+	f.StringVar(&f.output, "o", "table", "shorthand for --output")
+	return f
+}
+
+// open разбирает args, проверяет формат вывода и открывает хранилище задач.
+func (f *taskFlagSet) open(e *env, args []string) (taskStore, []string, error) {
+	cfg, rest, err := f.parse(e, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.output != "table" && f.output != "json" {
+		return nil, nil, usagef("unknown output format %q, expected table or json", f.output)
+	}
+	store, err := openStore(e.ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, rest, nil
+}
+
+// taskFields - флаги полей задачи для add и edit. Пустые указатели - флаг не задан.
+type taskFields struct {
+	input db.TaskInput
+}
+
+func (t *taskFields) register(f *taskFlagSet) {
+	stringField := func(target **string) func(string) error {
+		return func(value string) error {
+			*target = &value
+			return nil
+		}
+	}
+	intField := func(target **int) func(string) error {
+		return func(value string) error {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("expected an integer, got %q", value)
+			}
+			*target = &parsed
+			return nil
+		}
+	}
+	f.Func("title", "task title", stringField(&t.input.Title))
+	f.Func("description", "task description", stringField(&t.input.Description))
+	f.Func("due", "due date, YYYY-MM-DD", stringField(&t.input.DueDate))
+	f.Func("project", "project ID", intField(&t.input.ProjectID))
+	f.Func("estimate", "estimate in minutes", intField(&t.input.EstimateMinutes))
+}
+
+func runTaskAdd(e *env, args []string) error {
+	f := e.taskFlags("task add [flags] <title>")
+	var fields taskFields
+	fields.register(f)
+	store, rest, err := f.open(e, args)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if len(rest) > 0 {
+		title := strings.Join(rest, " ")
+		fields.input.Title = &title
+	}
+	if fields.input.Title == nil {
+		return usagef("title is required")
+	}
+
+	task, err := store.Create(e.ctx, &fields.input)
+	if err != nil {
+		return err
+	}
+	return writeTasks(e.stdout, f.output, []*db.Task{task})
+}
+
+func runTaskList(e *env, args []string) error {
+	f := e.taskFlags("task ls [flags]")
+	projectID := f.Int("project", 0, "only tasks of the project")
+	status := f.String("status", "", "only tasks with the status")
+	overdue := f.Bool("overdue", false, "only overdue tasks")
+	open := f.Bool("open", false, "only tasks that are not completed")
+	store, rest, err := f.open(e, args)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if len(rest) > 0 {
+		return usagef("unexpected arguments %q", rest)
+	}
+
+	tasks, err := store.List(e.ctx, *projectID)
+	if err != nil {
+		return err
+	}
+	filtered := tasks[:0]
+	for _, task := range tasks {
+		if (*status == "" || task.Status == *status) && (!*overdue || task.IsOverdue) && (!*open || !task.IsCompleted) {
+			filtered = append(filtered, task)
+		}
+	}
+	return writeTasks(e.stdout, f.output, filtered)
+}
+
+func runTaskDone(e *env, args []string) error {
+	return eachTask(e, "task done [flags] <id>...", args, func(store taskStore, id int) (*db.Task, error) {
+		return store.Complete(e.ctx, id)
+	})
+}
+
+func runTaskRemove(e *env, args []string) error {
+	return eachTask(e, "task rm [flags] <id>...", args, func(store taskStore, id int) (*db.Task, error) {
+		return nil, store.Delete(e.ctx, id)
+	})
+}
+
+// eachTask применяет action к задачам из аргументов и выводит измененные. Ошибка на одной
+// задаче не останавливает остальные.
+func eachTask(e *env, usage string, args []string, action func(store taskStore, id int) (*db.Task, error)) error {
+	f := e.taskFlags(usage)
+	store, rest, err := f.open(e, args)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ids, err := parseIDs(rest)
+	if err != nil {
+		return err
+	}
+
+	var changed []*db.Task
+	failed := 0
+	for _, id := range ids {
+		task, err := action(store, id)
+		if err != nil {
+			if isNotFound(err) {
+				err = fmt.Errorf("task %d not found", id)
+			}
+			fmt.Fprintf(e.stderr, "%s task: %v\n", Name, err)
+			failed++
+			continue
+		}
+		if task != nil {
+			changed = append(changed, task)
+		} else if f.output == "table" {
+			fmt.Fprintf(e.stdout, "task %d deleted\n", id)
+		}
+	}
+	if len(changed) > 0 {
+		if err := writeTasks(e.stdout, f.output, changed); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tasks failed", failed, len(ids))
+	}
+	return nil
+}
+
+func runTaskEdit(e *env, args []string) error {
+	f := e.taskFlags("task edit [flags] <id>")
+	var fields taskFields
+	fields.register(f)
+	status := f.String("status", "", "move the task to the status")
+	store, rest, err := f.open(e, args)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ids, err := parseIDs(rest)
+	if err != nil {
+		return err
+	}
+	if len(ids) != 1 {
+		return usagef("exactly one task ID is expected")
+	}
+	id := ids[0]
+
+	input := fields.input
+	changed := input.Title != nil || input.Description != nil || input.DueDate != nil || input.ProjectID != nil || input.EstimateMinutes != nil
+	if !changed && *status == "" {
+		return usagef("nothing to change, set --title, --description, --due, --project, --estimate or --status")
+	}
+
+	var task *db.Task
+	if changed {
+		if task, err = store.Update(e.ctx, id, &input); err != nil {
+			return err
+		}
+	}
+	if *status != "" {
+		if task, err = store.Transition(e.ctx, id, *status); err != nil {
+			return err
+		}
+	}
+	return writeTasks(e.stdout, f.output, []*db.Task{task})
+}
+
+func parseIDs(args []string) ([]int, error) {
+	if len(args) == 0 {
+		return nil, usagef("task ID is required")
+	}
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil || id <= 0 {
+			return nil, usagef("invalid task ID %q", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
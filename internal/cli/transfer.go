@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"todo/internal/db"
+)
+
+// runExport выводит все задачи пространства массивом JSON или по строке JSON на задачу (NDJSON).
+func runExport(e *env, args []string) error {
+	f := e.newFlags("export", "export [flags]")
+	f.remoteFlags()
+	format := f.String("format", "json", "output format: json or ndjson")
+	file := f.String("file", "", "write to the file instead of stdout")
+	cfg, rest, err := f.parse(e, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usagef("unexpected arguments %q", rest)
+	}
+	if *format != "json" && *format != "ndjson" {
+		return usagef("unknown format %q, expected json or ndjson", *format)
+	}
+
+	store, err := openStore(e.ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	tasks, err := store.List(e.ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	out := e.stdout
+	if *file != "" {
+		created, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer created.Close()
+		out = created
+	}
+	buffered := bufio.NewWriter(out)
+	if err := writeExport(buffered, *format, tasks); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if *file != "" {
+		fmt.Fprintf(e.stderr, "exported %d tasks to %s\n", len(tasks), *file)
+	}
+	return nil
+}
+
+func writeExport(w io.Writer, format string, tasks []*db.Task) error {
+	encoder := json.NewEncoder(w)
+	if format == "ndjson" {
+		for _, task := range tasks {
+			if err := encoder.Encode(task); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if tasks == nil {
+		tasks = []*db.Task{}
+	}
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(tasks)
+}
+
+// runImport создает задачи из выгрузки export. Формат (массив JSON или NDJSON) определяется
+// по содержимому. Задачи получают новые идентификаторы; ошибка в одной задаче не мешает
+// загрузить остальные.
+func runImport(e *env, args []string) error {
+	f := e.newFlags("import", "import [flags] [file]")
+	f.remoteFlags()
+	cfg, rest, err := f.parse(e, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 1 {
+		return usagef("expected at most one file, got %q", rest)
+	}
+
+	in := e.stdin
+	if len(rest) == 1 && rest[0] != "-" {
+		file, err := os.Open(rest[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	store, err := openStore(e.ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	imported, failed := 0, 0
+	err = readExport(bufio.NewReader(in), func(n int, task *db.Task) error {
+		created, err := store.Import(e.ctx, task)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "%s import: task %d (%q): %v\n", Name, n, task.Title, err)
+			failed++
+		}
+		if created != nil {
+			imported++
+		}
+		return e.ctx.Err()
+	})
+	fmt.Fprintf(e.stdout, "imported %d tasks\n", imported)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d tasks failed", failed)
+	}
+	return nil
+}
+
+// readExport читает задачи по одной и передает их fn с номером задачи, начиная с 1.
+func readExport(r *bufio.Reader, fn func(n int, task *db.Task) error) error {
+	first, err := firstByte(r)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r)
+	array := first == '['
+	if array {
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+	}
+
+	for n := 1; decoder.More(); n++ {
+		var task db.Task
+		if err := decoder.Decode(&task); err != nil {
+			return fmt.Errorf("task %d: %w", n, err)
+		}
+		if err := fn(n, &task); err != nil {
+			return err
+		}
+	}
+
+	if array {
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("unterminated array: %w", err)
+		}
+	}
+	return nil
+}
+
+// firstByte возвращает первый значащий символ, не забирая его из r.
+func firstByte(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			r.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
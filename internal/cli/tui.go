@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+	"todo/internal/tui"
+	"todo/pkg/client"
+	"todo/pkg/config"
+
+	"golang.org/x/term"
+)
+
+// runTUI показывает интерактивный список задач. С сервером список обновляется по потоку
+// событий, с локальной базой - по таймеру --refresh.
+func runTUI(e *env, args []string) error {
+	f := e.newFlags("tui", "tui [flags]")
+	f.remoteFlags()
+	refresh := f.Duration("refresh", 2*time.Second, "how often to reload tasks without the event stream, 0 disables")
+	cfg, rest, err := f.parse(e, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usagef("unexpected arguments %q", rest)
+	}
+	if *refresh < 0 {
+		return usagef("--refresh must not be negative")
+	}
+	in, ok := e.stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(in.Fd())) {
+		return errors.New("standard input is not a terminal")
+	}
+
+	store, err := openStore(e.ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+	opts := tui.Options{Store: store, In: in, Out: e.stdout, Poll: *refresh, Source: cfg.Database.Path, NoColor: os.Getenv("NO_COLOR") != ""}
+	if cfg.CLI.URL != "" {
+		opts.Source = cfg.CLI.URL
+		// Без потока событий (например, сервер старый) список обновляется по таймеру
+		if changes, err := watchTasks(ctx, cfg.CLI); err == nil {
+			opts.Changes = changes
+		}
+	}
+	if cfg.CLI.Workspace != "" {
+		opts.Source += " · " + cfg.CLI.Workspace
+	}
+	return tui.Run(ctx, opts)
+}
+
+// watchTasks подписывается на события задач сервера. В канал приходит сигнал после каждого
+// изменения; несколько изменений подряд сливаются в один сигнал. Канал закрывается, когда
+// подписка заканчивается.
+func watchTasks(ctx context.Context, cfg config.CLI) (<-chan struct{}, error) {
+	// У потока событий нет ограничения по времени ответа
+	c, err := newClient(cfg, &http.Client{})
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := c.Subscribe(ctx, &client.SubscribeOptions{Types: []string{
+		client.EventTaskCreated, client.EventTaskUpdated, client.EventTaskCompleted,
+		client.EventTaskDeleted, client.EventTaskOverdue, client.EventTaskReassigned,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer close(changes)
+		for range subscription.Events() {
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return changes, nil
+}
@@ -0,0 +1,215 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// Attachment - файл, прикрепленный к задаче. Содержимое лежит в хранилище под SHA-256,
+// одинаковые файлы хранятся один раз.
+type Attachment struct {
+	ID          int    `json:"id"`
+	TaskID      int    `json:"task_id"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	Hash        string `json:"sha256"`
+	UploadedBy  int    `json:"uploaded_by"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// Blob - содержимое в хранилище. Число ссылающихся на него вложений хранится в blobs.refs.
+type Blob struct {
+	Hash        string
+	Size        int64
+	ContentType string
+}
+
+type AttachmentRepo interface {
+	CreateAttachment(ctx context.Context, attachment *Attachment) (*Attachment, error)
+	GetAttachments(ctx context.Context, taskID int) ([]*Attachment, error)
+	GetAttachment(ctx context.Context, taskID int, id int) (*Attachment, error)
+	DeleteAttachment(ctx context.Context, taskID int, id int) (int64, error)
+	GetStorageUsage(ctx context.Context) (int64, error)
+}
+
+// BlobRepo ведет счетчики ссылок на содержимое в основной базе: одно содержимое могут
+// использовать вложения разных пространств.
+type BlobRepo interface {
+	AcquireBlob(blob *Blob) error
+	ReleaseBlobs(hashes []string) error
+	GetOrphanBlobs(releasedBefore string) ([]string, error)
+	DeleteOrphanBlob(hash string) (bool, error)
+	HasBlob(hash string) (bool, error)
+}
+
+const attachmentColumns = "id, task_id, filename, size, mime, hash, uploaded_by, created_at"
+
+func (repository *TaskRepository) CreateAttachment(ctx context.Context, attachment *Attachment) (*Attachment, error) {
+	attachment.CreatedAt = time.Now().Format(timeLayout)
+	result, err := repository.ForContext(ctx).db.Exec("INSERT INTO attachments (task_id, workspace_id, hash, filename, size, mime, uploaded_by, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		attachment.TaskID, workspaceID(ctx), attachment.Hash, attachment.Filename, attachment.Size, attachment.ContentType, attachment.UploadedBy, attachment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	attachment.ID = int(id)
+	return attachment, nil
+}
+
+func (repository *TaskRepository) GetAttachments(ctx context.Context, taskID int) ([]*Attachment, error) {
+	rows, err := repository.ForContext(ctx).db.Query("SELECT "+attachmentColumns+" FROM attachments WHERE task_id = $1 ORDER BY id", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+func (repository *TaskRepository) GetAttachment(ctx context.Context, taskID int, id int) (*Attachment, error) {
+	row := repository.ForContext(ctx).db.QueryRow("SELECT "+attachmentColumns+" FROM attachments WHERE task_id = $1 AND id = $2", taskID, id)
+	attachment, err := scanAttachment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAttachmentNotFound
+	}
+	return attachment, err
+}
+
+// DeleteAttachment удаляет вложение и освобождает ссылку на его содержимое.
+// Сам файл удаляет сборщик мусора.
+func (repository *TaskRepository) DeleteAttachment(ctx context.Context, taskID int, id int) (int64, error) {
+	attachment, err := repository.GetAttachment(ctx, taskID, id)
+	if errors.Is(err, ErrAttachmentNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := repository.ForContext(ctx).db.Exec("DELETE FROM attachments WHERE id = $1", id)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil || count == 0 {
+		return count, err
+	}
+	return count, repository.ReleaseBlobs([]string{attachment.Hash})
+}
+
+// GetStorageUsage возвращает суммарный размер вложений пространства из ctx.
+// Повторы одного файла учитываются каждый раз: квота считается по вложениям, а не по диску.
+func (repository *TaskRepository) GetStorageUsage(ctx context.Context) (int64, error) {
+	scope, args := workspaceScope(ctx, "workspace_id", nil)
+	var usage int64
+	err := repository.ForContext(ctx).db.QueryRow("SELECT COALESCE(SUM(size), 0) FROM attachments WHERE "+scope, args...).Scan(&usage)
+	return usage, err
+}
+
+// GetAttachmentHashes возвращает содержимое всех вложений базы пространства из ctx,
+// чтобы освободить ссылки перед удалением файла пространства.
+func (repository *TaskRepository) GetAttachmentHashes(ctx context.Context) ([]string, error) {
+	return repository.ForContext(ctx).attachmentHashes("SELECT hash FROM attachments")
+}
+
+// deleteTaskAttachments удаляет вложения задачи из базы tenant и освобождает ссылки
+// в основной базе repository.
+func (repository *TaskRepository) deleteTaskAttachments(tenant *TaskRepository, taskID int) error {
+	hashes, err := tenant.attachmentHashes("SELECT hash FROM attachments WHERE task_id = $1", taskID)
+	if err != nil {
+		return err
+	}
+	if _, err := tenant.db.Exec("DELETE FROM attachments WHERE task_id = $1", taskID); err != nil {
+		return err
+	}
+	return repository.ReleaseBlobs(hashes)
+}
+
+func (repository *TaskRepository) attachmentHashes(query string, args ...any) ([]string, error) {
+	rows, err := repository.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+func scanAttachment(row rowScanner) (*Attachment, error) {
+	var attachment Attachment
+	err := row.Scan(&attachment.ID, &attachment.TaskID, &attachment.Filename, &attachment.Size, &attachment.ContentType,
+		&attachment.Hash, &attachment.UploadedBy, &attachment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// AcquireBlob добавляет ссылку на содержимое, создавая запись при первой загрузке.
+func (repository *TaskRepository) AcquireBlob(blob *Blob) error {
+	_, err := repository.db.Exec(`INSERT INTO blobs (hash, size, mime, refs, created_at) VALUES ($1, $2, $3, 1, $4)
+		ON CONFLICT (hash) DO UPDATE SET refs = refs + 1, released_at = NULL`,
+		blob.Hash, blob.Size, blob.ContentType, time.Now().Format(timeLayout))
+	return err
+}
+
+// ReleaseBlobs снимает по одной ссылке на каждый хэш. Содержимое без ссылок помечается
+// временем освобождения и позже удаляется сборщиком мусора.
+func (repository *TaskRepository) ReleaseBlobs(hashes []string) error {
+	now := time.Now().Format(timeLayout)
+	for _, hash := range hashes {
+		_, err := repository.db.Exec("UPDATE blobs SET refs = refs - 1, released_at = CASE WHEN refs <= 1 THEN $1 ELSE released_at END WHERE hash = $2", now, hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOrphanBlobs возвращает содержимое, на которое никто не ссылается с releasedBefore.
+func (repository *TaskRepository) GetOrphanBlobs(releasedBefore string) ([]string, error) {
+	return repository.attachmentHashes("SELECT hash FROM blobs WHERE refs <= 0 AND released_at < $1 ORDER BY hash", releasedBefore)
+}
+
+// DeleteOrphanBlob удаляет запись о содержимом, если на него так и нет ссылок.
+func (repository *TaskRepository) DeleteOrphanBlob(hash string) (bool, error) {
+	result, err := repository.db.Exec("DELETE FROM blobs WHERE hash = $1 AND refs <= 0", hash)
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	return count > 0, err
+}
+
+func (repository *TaskRepository) HasBlob(hash string) (bool, error) {
+	var count int
+	err := repository.db.QueryRow("SELECT COUNT(*) FROM blobs WHERE hash = $1", hash).Scan(&count)
+	return count > 0, err
+}
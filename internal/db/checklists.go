@@ -0,0 +1,230 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrChecklistItemNotFound = errors.New("checklist item not found")
+	ErrChecklistOrder        = errors.New("order must list every checklist item exactly once")
+)
+
+// ChecklistItem - шаг чек-листа задачи. Position считается с нуля и не имеет пропусков.
+type ChecklistItem struct {
+	ID       int    `json:"id"`
+	Text     string `json:"text"`
+	Done     bool   `json:"done"`
+	Position int    `json:"position"`
+}
+
+type ChecklistItemInput struct {
+	Text     *string `json:"text"`
+	Done     *bool   `json:"done,omitempty"`
+	Position *int    `json:"position,omitempty"`
+}
+
+type ChecklistProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Progress считает выполненные пункты. Для пустого чек-листа возвращает nil.
+func Progress(items []*ChecklistItem) *ChecklistProgress {
+	if len(items) == 0 {
+		return nil
+	}
+	progress := &ChecklistProgress{Total: len(items)}
+	for _, item := range items {
+		if item.Done {
+			progress.Done++
+		}
+	}
+	return progress
+}
+
+type ChecklistRepo interface {
+	GetChecklist(ctx context.Context, taskID int) ([]*ChecklistItem, error)
+	AddChecklistItem(ctx context.Context, taskID int, item *ChecklistItem) (*ChecklistItem, error)
+	UpdateChecklistItem(ctx context.Context, taskID int, item *ChecklistItem) error
+	ReorderChecklist(ctx context.Context, taskID int, ids []int) error
+	DeleteChecklistItem(ctx context.Context, taskID int, id int) (int64, error)
+}
+
+func (repository *TaskRepository) GetChecklist(ctx context.Context, taskID int) ([]*ChecklistItem, error) {
+	rows, err := repository.ForContext(ctx).db.Query("SELECT id, text, done, position FROM checklist_items WHERE task_id = $1 ORDER BY position, id", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*ChecklistItem{}
+	for rows.Next() {
+		var item ChecklistItem
+		if err := rows.Scan(&item.ID, &item.Text, &item.Done, &item.Position); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+// AddChecklistItem вставляет пункт на item.Position; позиция за концом списка добавляет его в конец.
+func (repository *TaskRepository) AddChecklistItem(ctx context.Context, taskID int, item *ChecklistItem) (*ChecklistItem, error) {
+	items, err := repository.GetChecklist(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Пункт сначала добавляется в конец, а на место его ставит renumberChecklist
+	target := min(max(item.Position, 0), len(items))
+	item.Position = len(items)
+	result, err := repository.ForContext(ctx).db.Exec("INSERT INTO checklist_items (task_id, position, text, done, created_at) VALUES ($1, $2, $3, $4, $5)",
+		taskID, item.Position, item.Text, item.Done, time.Now().Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	item.ID = int(id)
+
+	items = slices.Insert(items, target, item)
+	return item, repository.ForContext(ctx).renumberChecklist(items)
+}
+
+// UpdateChecklistItem меняет текст, отметку и позицию пункта.
+func (repository *TaskRepository) UpdateChecklistItem(ctx context.Context, taskID int, item *ChecklistItem) error {
+	items, err := repository.GetChecklist(ctx, taskID)
+	if err != nil {
+		return err
+	}
+
+	index := slices.IndexFunc(items, func(current *ChecklistItem) bool { return current.ID == item.ID })
+	if index < 0 {
+		return ErrChecklistItemNotFound
+	}
+
+	tenant := repository.ForContext(ctx)
+	if _, err := tenant.db.Exec("UPDATE checklist_items SET text = $1, done = $2 WHERE id = $3", item.Text, item.Done, item.ID); err != nil {
+		return err
+	}
+
+	target := min(max(item.Position, 0), len(items)-1)
+	item.Position = index
+	if target == index {
+		return nil
+	}
+	items = slices.Delete(items, index, index+1)
+	items = slices.Insert(items, target, item)
+	return tenant.renumberChecklist(items)
+}
+
+// ReorderChecklist расставляет пункты в порядке ids. ids должен перечислять все пункты задачи.
+func (repository *TaskRepository) ReorderChecklist(ctx context.Context, taskID int, ids []int) error {
+	items, err := repository.GetChecklist(ctx, taskID)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int]*ChecklistItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	ordered := make([]*ChecklistItem, 0, len(ids))
+	for _, id := range ids {
+		item, ok := byID[id]
+		if !ok {
+			return ErrChecklistOrder
+		}
+		delete(byID, id)
+		ordered = append(ordered, item)
+	}
+	if len(byID) > 0 {
+		return ErrChecklistOrder
+	}
+
+	return repository.ForContext(ctx).renumberChecklist(ordered)
+}
+
+func (repository *TaskRepository) DeleteChecklistItem(ctx context.Context, taskID int, id int) (int64, error) {
+	tenant := repository.ForContext(ctx)
+	result, err := tenant.db.Exec("DELETE FROM checklist_items WHERE id = $1 AND task_id = $2", id, taskID)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil || count == 0 {
+		return count, err
+	}
+
+	items, err := repository.GetChecklist(ctx, taskID)
+	if err != nil {
+		return 0, err
+	}
+	return count, tenant.renumberChecklist(items)
+}
+
+// renumberChecklist записывает позиции по порядку items, обновляя только изменившиеся.
+func (repository *TaskRepository) renumberChecklist(items []*ChecklistItem) error {
+	for position, item := range items {
+		if item.Position == position {
+			continue
+		}
+		if _, err := repository.db.Exec("UPDATE checklist_items SET position = $1 WHERE id = $2", position, item.ID); err != nil {
+			return err
+		}
+		item.Position = position
+	}
+	return nil
+}
+
+// loadChecklists заполняет Checklist и ChecklistProgress одним запросом на каждые 500 задач.
+func (repository *TaskRepository) loadChecklists(tasks []*Task) error {
+	byID := make(map[int]*Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+
+	for start := 0; start < len(tasks); start += 500 {
+		batch := tasks[start:min(start+500, len(tasks))]
+		placeholders := make([]string, len(batch))
+		args := make([]any, len(batch))
+		for i, task := range batch {
+			placeholders[i] = "$" + strconv.Itoa(i+1)
+			args[i] = task.ID
+		}
+
+		rows, err := repository.db.Query("SELECT task_id, id, text, done, position FROM checklist_items WHERE task_id IN ("+strings.Join(placeholders, ", ")+") ORDER BY task_id, position, id", args...)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var taskID int
+			var item ChecklistItem
+			if err := rows.Scan(&taskID, &item.ID, &item.Text, &item.Done, &item.Position); err != nil {
+				rows.Close()
+				return err
+			}
+			byID[taskID].Checklist = append(byID[taskID].Checklist, &item)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	for _, task := range tasks {
+		task.ChecklistProgress = Progress(task.Checklist)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"todo/pkg/markdown"
+)
+
+var ErrCommentNotFound = errors.New("comment not found")
+
+// Comment - комментарий к задаче. Body хранится в Markdown, BodyHTML - его безопасный HTML.
+// Удаленный комментарий остается в ленте без текста, чтобы не терять контекст обсуждения.
+type Comment struct {
+	ID        int    `json:"id"`
+	TaskID    int    `json:"task_id"`
+	AuthorID  int    `json:"author_id"`
+	Author    string `json:"author,omitempty"`
+	Body      string `json:"body"`
+	BodyHTML  string `json:"body_html"`
+	Mentions  []int  `json:"mentions,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+	Deleted   bool   `json:"deleted,omitempty"`
+}
+
+type CommentInput struct {
+	Body *string `json:"body"`
+}
+
+// CommentRevision - прежняя версия текста комментария.
+type CommentRevision struct {
+	Body     string `json:"body"`
+	EditedAt string `json:"edited_at"`
+}
+
+type CommentRepo interface {
+	CreateComment(ctx context.Context, comment *Comment) (*Comment, error)
+	GetComments(ctx context.Context, taskID int, after int, limit int) ([]*Comment, error)
+	GetLatestComments(ctx context.Context, taskID int, limit int) ([]*Comment, error)
+	GetComment(ctx context.Context, taskID int, id int) (*Comment, error)
+	UpdateComment(ctx context.Context, comment *Comment) error
+	DeleteComment(ctx context.Context, taskID int, id int) (int64, error)
+	GetCommentRevisions(ctx context.Context, taskID int, id int) ([]*CommentRevision, error)
+}
+
+const commentColumns = "id, task_id, author_id, body, created_at, COALESCE(updated_at, ''), deleted_at IS NOT NULL"
+
+func (repository *TaskRepository) CreateComment(ctx context.Context, comment *Comment) (*Comment, error) {
+	tenant := repository.ForContext(ctx)
+	comment.CreatedAt = time.Now().Format(timeLayout)
+	result, err := tenant.db.Exec("INSERT INTO comments (task_id, author_id, body, created_at) VALUES ($1, $2, $3, $4)",
+		comment.TaskID, comment.AuthorID, comment.Body, comment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	comment.ID = int(id)
+
+	if err := tenant.saveMentions(comment); err != nil {
+		return nil, err
+	}
+	if err := repository.fillComments(ctx, []*Comment{comment}); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// GetComments возвращает до limit комментариев задачи с ID больше after, от старых к новым.
+func (repository *TaskRepository) GetComments(ctx context.Context, taskID int, after int, limit int) ([]*Comment, error) {
+	return repository.queryComments(ctx, "SELECT "+commentColumns+" FROM comments WHERE task_id = $1 AND id > $2 ORDER BY id LIMIT $3", taskID, after, limit)
+}
+
+// GetLatestComments возвращает последние limit комментариев задачи, от старых к новым.
+func (repository *TaskRepository) GetLatestComments(ctx context.Context, taskID int, limit int) ([]*Comment, error) {
+	return repository.queryComments(ctx, "SELECT * FROM (SELECT "+commentColumns+" FROM comments WHERE task_id = $1 ORDER BY id DESC LIMIT $2) ORDER BY id", taskID, limit)
+}
+
+func (repository *TaskRepository) GetComment(ctx context.Context, taskID int, id int) (*Comment, error) {
+	comments, err := repository.queryComments(ctx, "SELECT "+commentColumns+" FROM comments WHERE task_id = $1 AND id = $2", taskID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(comments) == 0 {
+		return nil, ErrCommentNotFound
+	}
+	return comments[0], nil
+}
+
+// UpdateComment сохраняет прежний текст в истории и заменяет текст и упоминания.
+func (repository *TaskRepository) UpdateComment(ctx context.Context, comment *Comment) error {
+	tenant := repository.ForContext(ctx)
+
+	var body string
+	err := tenant.db.QueryRow("SELECT body FROM comments WHERE id = $1 AND task_id = $2 AND deleted_at IS NULL", comment.ID, comment.TaskID).Scan(&body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCommentNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	comment.UpdatedAt = time.Now().Format(timeLayout)
+	if _, err := tenant.db.Exec("INSERT INTO comment_revisions (comment_id, body, edited_at) VALUES ($1, $2, $3)", comment.ID, body, comment.UpdatedAt); err != nil {
+		return err
+	}
+	if _, err := tenant.db.Exec("UPDATE comments SET body = $1, updated_at = $2 WHERE id = $3", comment.Body, comment.UpdatedAt, comment.ID); err != nil {
+		return err
+	}
+
+	if err := tenant.saveMentions(comment); err != nil {
+		return err
+	}
+	return repository.fillComments(ctx, []*Comment{comment})
+}
+
+// DeleteComment помечает комментарий удаленным и стирает его текст и историю.
+func (repository *TaskRepository) DeleteComment(ctx context.Context, taskID int, id int) (int64, error) {
+	tenant := repository.ForContext(ctx)
+	result, err := tenant.db.Exec("UPDATE comments SET body = '', deleted_at = $1 WHERE id = $2 AND task_id = $3 AND deleted_at IS NULL",
+		time.Now().Format(timeLayout), id, taskID)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil || count == 0 {
+		return count, err
+	}
+
+	if err := tenant.saveMentions(&Comment{ID: id}); err != nil {
+		return 0, err
+	}
+	_, err = tenant.db.Exec("DELETE FROM comment_revisions WHERE comment_id = $1", id)
+	return count, err
+}
+
+func (repository *TaskRepository) GetCommentRevisions(ctx context.Context, taskID int, id int) ([]*CommentRevision, error) {
+	if _, err := repository.GetComment(ctx, taskID, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.ForContext(ctx).db.Query("SELECT body, edited_at FROM comment_revisions WHERE comment_id = $1 ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*CommentRevision{}
+	for rows.Next() {
+		var revision CommentRevision
+		if err := rows.Scan(&revision.Body, &revision.EditedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	return revisions, rows.Err()
+}
+
+// deleteTaskComments удаляет комментарии задачи вместе с историей и упоминаниями.
+func (repository *TaskRepository) deleteTaskComments(taskID int) error {
+	queries := []string{
+		"DELETE FROM comment_revisions WHERE comment_id IN (SELECT id FROM comments WHERE task_id = $1)",
+		"DELETE FROM comment_mentions WHERE comment_id IN (SELECT id FROM comments WHERE task_id = $1)",
+		"DELETE FROM comments WHERE task_id = $1",
+	}
+	for _, query := range queries {
+		if _, err := repository.db.Exec(query, taskID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repository *TaskRepository) saveMentions(comment *Comment) error {
+	if _, err := repository.db.Exec("DELETE FROM comment_mentions WHERE comment_id = $1", comment.ID); err != nil {
+		return err
+	}
+	for _, userID := range comment.Mentions {
+		if _, err := repository.db.Exec("INSERT OR IGNORE INTO comment_mentions (comment_id, user_id) VALUES ($1, $2)", comment.ID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repository *TaskRepository) queryComments(ctx context.Context, query string, args ...any) ([]*Comment, error) {
+	rows, err := repository.ForContext(ctx).db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+	for rows.Next() {
+		var comment Comment
+		err := rows.Scan(&comment.ID, &comment.TaskID, &comment.AuthorID, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt, &comment.Deleted)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, &comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	return comments, repository.fillComments(ctx, comments)
+}
+
+// fillComments дополняет комментарии HTML, упоминаниями из базы пространства и именами
+// авторов из основной базы: в режиме file это разные файлы.
+func (repository *TaskRepository) fillComments(ctx context.Context, comments []*Comment) error {
+	tenant := repository.ForContext(ctx)
+	authors := make(map[int]string)
+	for _, comment := range comments {
+		comment.BodyHTML = markdown.Render(comment.Body)
+
+		rows, err := tenant.db.Query("SELECT user_id FROM comment_mentions WHERE comment_id = $1 ORDER BY user_id", comment.ID)
+		if err != nil {
+			return err
+		}
+		comment.Mentions = nil
+		for rows.Next() {
+			var userID int
+			if err := rows.Scan(&userID); err != nil {
+				rows.Close()
+				return err
+			}
+			comment.Mentions = append(comment.Mentions, userID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		name, ok := authors[comment.AuthorID]
+		if !ok {
+			if user, err := repository.GetUserById(comment.AuthorID); err == nil {
+				name = user.Username
+			} else if !errors.Is(err, ErrUserNotFound) {
+				return err
+			}
+			authors[comment.AuthorID] = name
+		}
+		comment.Author = name
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FieldText   = "text"
+	FieldNumber = "number"
+	FieldDate   = "date"
+	FieldEnum   = "enum"
+	FieldBool   = "bool"
+)
+
+var (
+	FieldTypes = []string{FieldText, FieldNumber, FieldDate, FieldEnum, FieldBool}
+
+	ErrCustomFieldNotFound = errors.New("custom field not found")
+	ErrCustomFieldExists   = errors.New("custom field already exists")
+)
+
+// CustomField - поле задачи, которое задает администратор проекта. Значения хранятся
+// в JSON-колонке tasks.custom_fields под ключом Key.
+type CustomField struct {
+	ID        int      `json:"id"`
+	ProjectID int      `json:"project_id"`
+	Key       string   `json:"key"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Options   []string `json:"options,omitempty"`
+	Required  bool     `json:"required"`
+	CreatedAt string   `json:"created_at"`
+}
+
+type CustomFieldInput struct {
+	Key      *string   `json:"key"`
+	Name     *string   `json:"name"`
+	Type     *string   `json:"type"`
+	Options  *[]string `json:"options,omitempty"`
+	Required *bool     `json:"required,omitempty"`
+}
+
+// FieldFilter отбирает задачи, у которых поле Key равно Value. Value уже приведено к типу
+// поля: float64 для number, 0/1 для bool, строка для остальных.
+type FieldFilter struct {
+	Key   string
+	Value any
+}
+
+// TaskFilter ограничивает и упорядочивает список задач. nil возвращает все задачи.
+type TaskFilter struct {
+	ProjectID int
+	Fields    []FieldFilter
+	// SortField - ключ пользовательского поля для сортировки; задачи без значения идут последними.
+	SortField string
+	SortDesc  bool
+	// AfterID и Limit читают задачи страницами по возрастанию ID: задачи с ID больше AfterID,
+	// не больше Limit штук. 0 - без ограничения. С SortField порядок задает сортировка, и AfterID
+	// пропускал бы или повторял задачи, поэтому страницы читаются только без нее.
+	AfterID int
+	Limit   int
+}
+
+type CustomFieldRepo interface {
+	GetCustomFields(ctx context.Context, projectID int) ([]*CustomField, error)
+	CreateCustomField(ctx context.Context, field *CustomField) (*CustomField, error)
+	UpdateCustomField(ctx context.Context, field *CustomField) error
+	DeleteCustomField(ctx context.Context, projectID int, key string) (int64, error)
+}
+
+const customFieldColumns = "id, project_id, key, name, type, options, required, created_at"
+
+func (repository *TaskRepository) GetCustomFields(ctx context.Context, projectID int) ([]*CustomField, error) {
+	rows, err := repository.ForContext(ctx).db.Query("SELECT "+customFieldColumns+" FROM custom_fields WHERE project_id = $1 ORDER BY id", projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := []*CustomField{}
+	for rows.Next() {
+		field, err := scanCustomField(rows)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, rows.Err()
+}
+
+func (repository *TaskRepository) CreateCustomField(ctx context.Context, field *CustomField) (*CustomField, error) {
+	options, err := json.Marshal(field.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	field.CreatedAt = time.Now().Format(timeLayout)
+	result, err := repository.ForContext(ctx).db.Exec("INSERT INTO custom_fields (project_id, workspace_id, key, name, type, options, required, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		field.ProjectID, workspaceID(ctx), field.Key, field.Name, field.Type, string(options), field.Required, field.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, ErrCustomFieldExists
+		}
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	field.ID = int(id)
+	return field, nil
+}
+
+// UpdateCustomField меняет название, варианты и обязательность поля. Тип и ключ не меняются:
+// иначе уже сохраненные значения перестанут ему соответствовать.
+func (repository *TaskRepository) UpdateCustomField(ctx context.Context, field *CustomField) error {
+	options, err := json.Marshal(field.Options)
+	if err != nil {
+		return err
+	}
+
+	result, err := repository.ForContext(ctx).db.Exec("UPDATE custom_fields SET name = $1, options = $2, required = $3 WHERE project_id = $4 AND key = $5",
+		field.Name, string(options), field.Required, field.ProjectID, field.Key)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err == nil && count == 0 {
+		return ErrCustomFieldNotFound
+	}
+	return err
+}
+
+// DeleteCustomField удаляет поле проекта вместе со значениями в задачах.
+func (repository *TaskRepository) DeleteCustomField(ctx context.Context, projectID int, key string) (int64, error) {
+	repository = repository.ForContext(ctx)
+	result, err := repository.db.Exec("DELETE FROM custom_fields WHERE project_id = $1 AND key = $2", projectID, key)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil || count == 0 {
+		return count, err
+	}
+
+	_, err = repository.db.Exec("UPDATE tasks SET custom_fields = json_remove(custom_fields, $1) WHERE project_id = $2 AND custom_fields IS NOT NULL", fieldPath(key), projectID)
+	return count, err
+}
+
+// fieldPath - путь JSON к значению поля. Ключ берется в кавычки, поэтому не может выйти за пределы пути.
+func fieldPath(key string) string {
+	return `$."` + key + `"`
+}
+
+// filterClause дополняет аргументы запроса задач условиями filter и возвращает условие WHERE.
+func filterClause(filter *TaskFilter, args []any) (string, []any) {
+	if filter == nil {
+		return "1 = 1", args
+	}
+
+	clauses := []string{"1 = 1"}
+	if filter.ProjectID != 0 {
+		args = append(args, filter.ProjectID)
+		clauses = append(clauses, "project_id = $"+strconv.Itoa(len(args)))
+	}
+	if filter.AfterID != 0 {
+		args = append(args, filter.AfterID)
+		clauses = append(clauses, "id > $"+strconv.Itoa(len(args)))
+	}
+	for _, field := range filter.Fields {
+		args = append(args, fieldPath(field.Key), field.Value)
+		clauses = append(clauses, "json_extract(custom_fields, $"+strconv.Itoa(len(args)-1)+") = $"+strconv.Itoa(len(args)))
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// orderClause возвращает ORDER BY для filter. Вызывается последним: SQLite нумерует
+// параметры $N в порядке их появления в тексте запроса.
+func orderClause(filter *TaskFilter, args []any) (string, []any) {
+	if filter == nil {
+		return "", args
+	}
+
+	order := ""
+	switch {
+	case filter.SortField != "":
+		direction := "ASC"
+		if filter.SortDesc {
+			direction = "DESC"
+		}
+		args = append(args, fieldPath(filter.SortField))
+		order = " ORDER BY json_extract(custom_fields, $" + strconv.Itoa(len(args)) + ") " + direction + " NULLS LAST, id"
+	case filter.Limit > 0:
+		order = " ORDER BY id"
+	}
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		order += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	return order, args
+}
+
+// encodeCustomFields сериализует значения полей; пустой набор хранится как NULL.
+func encodeCustomFields(values map[string]any) (any, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func decodeCustomFields(data string) (map[string]any, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var values map[string]any
+	if err := json.Unmarshal([]byte(data), &values); err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values, nil
+}
+
+func scanCustomField(row rowScanner) (*CustomField, error) {
+	var field CustomField
+	var options string
+	err := row.Scan(&field.ID, &field.ProjectID, &field.Key, &field.Name, &field.Type, &options, &field.Required, &field.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCustomFieldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(options), &field.Options); err != nil {
+		return nil, err
+	}
+	return &field, nil
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Ping проверяет, что база отвечает.
+func (repository *TaskRepository) Ping() error {
+	if pinger, ok := repository.conn().(interface{ Ping() error }); ok {
+		return pinger.Ping()
+	}
+	var one int
+	return repository.db.QueryRow("SELECT 1").Scan(&one)
+}
+
+// SchemaVersion возвращает версию схемы базы и версию, до которой ее доводят миграции кода.
+func (repository *TaskRepository) SchemaVersion() (int, int, error) {
+	version, err := repository.schemaVersion()
+	return version, len(migrations), err
+}
+
+// Path - путь к файлу базы. Пустой для баз в памяти и соединений, которые путь не сообщают.
+func (repository *TaskRepository) Path() string {
+	conn, ok := repository.conn().(interface{ Path() string })
+	if !ok {
+		return ""
+	}
+	path := conn.Path()
+	if path == "" || path == ":memory:" || strings.HasPrefix(path, "file::memory:") {
+		return ""
+	}
+	return strings.TrimPrefix(path, "file:")
+}
+
+// CheckWritable проверяет, что файл базы и его каталог (для журналов SQLite) доступны на запись.
+func (repository *TaskRepository) CheckWritable() error {
+	path := repository.Path()
+	if path == "" {
+		return nil
+	}
+	path, _, _ = strings.Cut(path, "?")
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("database file is not writable: %w", err)
+	}
+	file.Close()
+
+	probe, err := os.CreateTemp(filepath.Dir(path), ".writable-*")
+	if err != nil {
+		return fmt.Errorf("database directory is not writable: %w", err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// FileSize - размер файла базы вместе с журналом WAL, если он есть.
+func (repository *TaskRepository) FileSize() (int64, error) {
+	path := repository.Path()
+	if path == "" {
+		return 0, nil
+	}
+	path, _, _ = strings.Cut(path, "?")
+
+	var size int64
+	for _, name := range []string{path, path + "-wal"} {
+		info, err := os.Stat(name)
+		if os.IsNotExist(err) && name != path {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// CheckIntegrity проверяет файл базы (PRAGMA integrity_check).
+func (repository *TaskRepository) CheckIntegrity() error {
+	var result string
+	if err := repository.db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("database is corrupted: %s", result)
+	}
+	return nil
+}
+
+// Backup сохраняет согласованную копию базы в новый файл path (VACUUM INTO). Работать
+// с базой в это время можно, копия получается на момент начала.
+func (repository *TaskRepository) Backup(path string) error {
+	_, err := repository.db.Exec("VACUUM INTO $1", path)
+	return err
+}
+
+// Close закрывает соединение с базой.
+func (repository *TaskRepository) Close() error {
+	if closer, ok := repository.conn().(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
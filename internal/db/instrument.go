@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+	"todo/internal/metrics"
+	"todo/internal/tracing"
+	"unicode"
+)
+
+var dbPackage = reflect.TypeOf(TaskRepository{}).PkgPath() + "."
+
+// instrumentedDB записывает длительность каждого запроса в метрики с именем экспортированного
+// метода репозитория, из которого он выполнен: так не нужно размечать каждый метод вручную.
+// Если задан ctx с записываемым спаном (см. ForContext), запрос становится дочерним спаном.
+type instrumentedDB struct {
+	DbInterface
+	ctx context.Context
+}
+
+func (d instrumentedDB) Exec(query string, args ...any) (sql.Result, error) {
+	finish := d.start(callerMethod(), query)
+	result, err := d.DbInterface.Exec(query, args...)
+	finish(err)
+	return result, err
+}
+
+// Query завершает спан сразу после выполнения запроса, чтение строк в него не входит.
+func (d instrumentedDB) Query(query string, args ...any) (*sql.Rows, error) {
+	finish := d.start(callerMethod(), query)
+	rows, err := d.DbInterface.Query(query, args...)
+	finish(err)
+	return rows, err
+}
+
+func (d instrumentedDB) QueryRow(query string, args ...any) *sql.Row {
+	finish := d.start(callerMethod(), query)
+	row := d.DbInterface.QueryRow(query, args...)
+	finish(row.Err())
+	return row
+}
+
+func (d instrumentedDB) start(method string, query string) func(err error) {
+	start := time.Now()
+	var span *tracing.Span
+	if d.ctx != nil {
+		_, span = tracing.Start(d.ctx, "db "+method, tracing.KindClient,
+			"db.system", "sqlite", "db.operation", method, "db.statement", query)
+	}
+
+	return func(err error) {
+		metrics.ObserveQuery(method, start)
+		if span != nil {
+			span.SetError(err)
+			span.End()
+		}
+	}
+}
+
+// traced возвращает копию репозитория, запросы которой пишутся в спан из ctx. Без
+// записываемого спана возвращается сам репозиторий, чтобы не тратить память зря.
+func (repository *TaskRepository) traced(ctx context.Context) *TaskRepository {
+	if !tracing.SpanFromContext(ctx).Recording() {
+		return repository
+	}
+	bound := *repository
+	bound.db = instrumentedDB{DbInterface: repository.conn(), ctx: ctx}
+	return &bound
+}
+
+// callerMethod находит в стеке ближайший экспортированный метод или функцию пакета db.
+func callerMethod() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if name, ok := strings.CutPrefix(frame.Function, dbPackage); ok {
+			name = name[strings.LastIndex(name, ".")+1:]
+			if name != "" && unicode.IsUpper(rune(name[0])) {
+				return name
+			}
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// DBStats возвращает статистику пула соединений, если соединение ее предоставляет.
+func (repository *TaskRepository) DBStats() (sql.DBStats, bool) {
+	if stats, ok := repository.conn().(interface{ Stats() sql.DBStats }); ok {
+		return stats.Stats(), true
+	}
+	return sql.DBStats{}, false
+}
+
+// conn возвращает исходное соединение без учета метрик.
+func (repository *TaskRepository) conn() DbInterface {
+	if instrumented, ok := repository.db.(instrumentedDB); ok {
+		return instrumented.DbInterface
+	}
+	return repository.db
+}
@@ -0,0 +1,287 @@
+package db
+
+import "fmt"
+
+// migrations применяются по порядку, номер версии схемы хранится в PRAGMA user_version.
+// Уже выпущенные миграции менять нельзя - только добавлять новые в конец.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS tasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		description TEXT,
+		due_date TEXT NOT NULL,
+		completed INTEGER NOT NULL CHECK (completed IN (0, 1)),
+		overdue INTEGER NOT NULL CHECK (overdue IN (0, 1)),
+		created_at TEXT NOT NULL
+	);`,
+	`ALTER TABLE tasks ADD COLUMN status TEXT NOT NULL DEFAULT 'todo';
+	UPDATE tasks SET status = 'done' WHERE completed = 1;`,
+	`CREATE TABLE IF NOT EXISTS reminders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		remind_at TEXT,
+		before_seconds INTEGER NOT NULL DEFAULT 0,
+		state TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		delivered_at TEXT,
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS reminders_task_id ON reminders (task_id);
+	CREATE TABLE IF NOT EXISTS reminder_deliveries (
+		reminder_id INTEGER NOT NULL,
+		sink TEXT NOT NULL,
+		delivered_at TEXT NOT NULL,
+		PRIMARY KEY (reminder_id, sink)
+	);
+	CREATE VIEW IF NOT EXISTS reminder_schedule AS
+		SELECT r.*,
+			COALESCE(r.remind_at, datetime(t.due_date, printf('-%d seconds', r.before_seconds))) AS fire_at,
+			t.title AS task_title, t.due_date AS task_due_date, t.status AS task_status
+		FROM reminders r JOIN tasks t ON t.id = r.task_id;`,
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		events TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		active INTEGER NOT NULL DEFAULT 1,
+		created_at TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		state TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TEXT,
+		last_status INTEGER,
+		last_error TEXT,
+		created_at TEXT NOT NULL,
+		delivered_at TEXT
+	);
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_pending ON webhook_deliveries (state, next_attempt_at);
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);`,
+	`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		role TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS sessions (
+		token_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		expires_at TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS sessions_user_id ON sessions (user_id);
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		last_used_at TEXT,
+		revoked_at TEXT
+	);
+	CREATE INDEX IF NOT EXISTS api_keys_user_id ON api_keys (user_id);
+	ALTER TABLE tasks ADD COLUMN owner_id INTEGER;
+	CREATE INDEX IF NOT EXISTS tasks_owner_id ON tasks (owner_id);`,
+	`UPDATE users SET role = 'editor' WHERE role = 'user';
+	CREATE TABLE IF NOT EXISTS projects (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		owner_id INTEGER,
+		created_at TEXT NOT NULL
+	);
+	ALTER TABLE tasks ADD COLUMN project_id INTEGER;
+	CREATE INDEX IF NOT EXISTS tasks_project_id ON tasks (project_id);
+	CREATE TABLE IF NOT EXISTS task_shares (
+		task_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		permission TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (task_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS task_shares_user_id ON task_shares (user_id);
+	CREATE TABLE IF NOT EXISTS project_shares (
+		project_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		permission TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (project_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS project_shares_user_id ON project_shares (user_id);`,
+	`CREATE TABLE IF NOT EXISTS workspaces (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		slug TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'active',
+		created_at TEXT NOT NULL
+	);
+	INSERT OR IGNORE INTO workspaces (id, slug, name, status, created_at) VALUES (1, 'default', 'Default', 'active', datetime('now', 'localtime'));
+	CREATE TABLE IF NOT EXISTS workspace_members (
+		workspace_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (workspace_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS workspace_members_user_id ON workspace_members (user_id);
+	ALTER TABLE tasks ADD COLUMN workspace_id INTEGER NOT NULL DEFAULT 1;
+	CREATE INDEX IF NOT EXISTS tasks_workspace_id ON tasks (workspace_id);
+	ALTER TABLE projects ADD COLUMN workspace_id INTEGER NOT NULL DEFAULT 1;
+	CREATE INDEX IF NOT EXISTS projects_workspace_id ON projects (workspace_id);`,
+	`CREATE TABLE IF NOT EXISTS task_assignees (
+		task_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (task_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS task_assignees_user_id ON task_assignees (user_id);
+	CREATE TABLE IF NOT EXISTS task_watchers (
+		task_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (task_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS task_watchers_user_id ON task_watchers (user_id);`,
+	`CREATE TABLE IF NOT EXISTS comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		author_id INTEGER NOT NULL,
+		body TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		updated_at TEXT,
+		deleted_at TEXT
+	);
+	CREATE INDEX IF NOT EXISTS comments_task_id ON comments (task_id, id);
+	CREATE TABLE IF NOT EXISTS comment_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		comment_id INTEGER NOT NULL,
+		body TEXT NOT NULL,
+		edited_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS comment_revisions_comment_id ON comment_revisions (comment_id);
+	CREATE TABLE IF NOT EXISTS comment_mentions (
+		comment_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		PRIMARY KEY (comment_id, user_id)
+	);`,
+	`CREATE TABLE IF NOT EXISTS blobs (
+		hash TEXT PRIMARY KEY,
+		size INTEGER NOT NULL,
+		mime TEXT NOT NULL,
+		refs INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL,
+		released_at TEXT
+	);
+	CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		workspace_id INTEGER NOT NULL DEFAULT 1,
+		hash TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		mime TEXT NOT NULL,
+		uploaded_by INTEGER NOT NULL,
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS attachments_task_id ON attachments (task_id);
+	CREATE INDEX IF NOT EXISTS attachments_workspace_id ON attachments (workspace_id);
+	ALTER TABLE workspaces ADD COLUMN storage_quota INTEGER NOT NULL DEFAULT 0;`,
+	`CREATE TABLE IF NOT EXISTS checklist_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		text TEXT NOT NULL,
+		done INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS checklist_items_task_id ON checklist_items (task_id, position);`,
+	`ALTER TABLE tasks ADD COLUMN estimate_minutes INTEGER;
+	CREATE TABLE IF NOT EXISTS worklogs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		workspace_id INTEGER NOT NULL DEFAULT 1,
+		user_id INTEGER NOT NULL,
+		started_at TEXT NOT NULL,
+		ended_at TEXT,
+		seconds INTEGER NOT NULL DEFAULT 0,
+		note TEXT NOT NULL DEFAULT '',
+		source TEXT NOT NULL,
+		auto_stopped INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS worklogs_task_id ON worklogs (task_id);
+	CREATE INDEX IF NOT EXISTS worklogs_started_at ON worklogs (workspace_id, started_at);
+	CREATE UNIQUE INDEX IF NOT EXISTS worklogs_running ON worklogs (user_id) WHERE ended_at IS NULL;`,
+	`CREATE TABLE IF NOT EXISTS custom_fields (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		project_id INTEGER NOT NULL,
+		workspace_id INTEGER NOT NULL DEFAULT 1,
+		key TEXT NOT NULL,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		options TEXT NOT NULL DEFAULT 'null',
+		required INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL,
+		UNIQUE (project_id, key)
+	);
+	ALTER TABLE tasks ADD COLUMN custom_fields TEXT;`,
+	`ALTER TABLE tasks ADD COLUMN external_id TEXT;
+	CREATE UNIQUE INDEX IF NOT EXISTS tasks_external_id ON tasks (workspace_id, external_id) WHERE external_id IS NOT NULL;`,
+	`DROP INDEX IF EXISTS worklogs_running;
+	CREATE UNIQUE INDEX IF NOT EXISTS worklogs_running_workspace ON worklogs (workspace_id, user_id) WHERE ended_at IS NULL;`,
+}
+
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+func (repository *TaskRepository) migrate() error {
+	version, err := repository.schemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if _, err := repository.db.Exec(migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := repository.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+
+	return repository.adoptStatuses()
+}
+
+// adoptStatuses переводит статусы todo и done, которые миграция 2 записала в задачи, созданные
+// до появления статусов, в начальный и завершающий статусы workflow, если в нем таких статусов нет.
+// Это не миграция: результат зависит от настроек, поэтому шаг выполняется при каждом открытии базы.
+func (repository *TaskRepository) adoptStatuses() error {
+	statuses := map[string]string{
+		defaultInitialStatus: repository.workflow.Initial,
+		defaultDoneStatus:    repository.workflow.Done,
+	}
+	for from, to := range statuses {
+		if repository.workflow.HasStatus(from) {
+			continue
+		}
+		if _, err := repository.db.Exec("UPDATE tasks SET status = $1 WHERE status = $2", to, from); err != nil {
+			return fmt.Errorf("adopt %s status: %w", from, err)
+		}
+	}
+	return nil
+}
+
+func (repository *TaskRepository) schemaVersion() (int, error) {
+	var version int
+	if err := repository.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
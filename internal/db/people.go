@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Отношения пользователя к задаче для GET /me/tasks.
+const (
+	RelationAssigned = "assigned"
+	RelationWatching = "watching"
+	RelationCreated  = "created"
+)
+
+var Relations = []string{RelationAssigned, RelationWatching, RelationCreated}
+
+// PeopleRepo - задачи, связанные с пользователем. Исполнители и наблюдатели задачи
+// сохраняются вместе с ней в CreateTask и UpdateTask.
+type PeopleRepo interface {
+	GetUserTasks(ctx context.Context, userID int, relations []string) ([]*Task, error)
+}
+
+var relationClauses = map[string]string{
+	RelationAssigned: "id IN (SELECT task_id FROM task_assignees WHERE user_id = $1)",
+	RelationWatching: "id IN (SELECT task_id FROM task_watchers WHERE user_id = $1)",
+	RelationCreated:  "owner_id = $1",
+}
+
+// GetUserTasks возвращает задачи, где пользователь исполнитель, наблюдатель или автор,
+// в порядке срока. Пустой relations означает все три отношения.
+func (repository *TaskRepository) GetUserTasks(ctx context.Context, userID int, relations []string) ([]*Task, error) {
+	if len(relations) == 0 {
+		relations = Relations
+	}
+
+	var clauses []string
+	for _, relation := range relations {
+		if clause, ok := relationClauses[relation]; ok {
+			clauses = append(clauses, clause)
+		}
+	}
+	if len(clauses) == 0 {
+		return []*Task{}, nil
+	}
+
+	scope, args := workspaceScope(ctx, "workspace_id", []any{userID})
+	return repository.ForContext(ctx).queryTasks(ctx, "SELECT "+taskColumns+" FROM tasks WHERE ("+strings.Join(clauses, " OR ")+") AND "+scope+" ORDER BY due_date, id", args...)
+}
+
+// NormalizeUserIDs убирает повторы и сортирует ID, чтобы списки людей можно было сравнивать.
+func NormalizeUserIDs(ids []int) []int {
+	normalized := slices.Clone(ids)
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+// savePeople заменяет исполнителей и наблюдателей задачи.
+func (repository *TaskRepository) savePeople(task *Task) error {
+	tables := map[string][]int{"task_assignees": task.Assignees, "task_watchers": task.Watchers}
+	createdAt := time.Now().Format(timeLayout)
+	for table, userIDs := range tables {
+		if _, err := repository.db.Exec("DELETE FROM "+table+" WHERE task_id = $1", task.ID); err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			_, err := repository.db.Exec("INSERT OR IGNORE INTO "+table+" (task_id, user_id, created_at) VALUES ($1, $2, $3)", task.ID, userID, createdAt)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadPeople заполняет Assignees и Watchers одним запросом на каждые 500 задач.
+func (repository *TaskRepository) loadPeople(tasks []*Task) error {
+	byID := make(map[int]*Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+
+	for start := 0; start < len(tasks); start += 500 {
+		batch := tasks[start:min(start+500, len(tasks))]
+		placeholders := make([]string, len(batch))
+		args := make([]any, len(batch))
+		for i, task := range batch {
+			placeholders[i] = "$" + strconv.Itoa(i+1)
+			args[i] = task.ID
+		}
+		in := strings.Join(placeholders, ", ")
+
+		rows, err := repository.db.Query(`SELECT task_id, user_id, 'assignee' FROM task_assignees WHERE task_id IN (`+in+`)
+			UNION ALL SELECT task_id, user_id, 'watcher' FROM task_watchers WHERE task_id IN (`+in+`)
+			ORDER BY 1, 2`, args...)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var taskID, userID int
+			var kind string
+			if err := rows.Scan(&taskID, &userID, &kind); err != nil {
+				rows.Close()
+				return err
+			}
+			if kind == "assignee" {
+				byID[taskID].Assignees = append(byID[taskID].Assignees, userID)
+			} else {
+				byID[taskID].Watchers = append(byID[taskID].Watchers, userID)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrProjectNotFound = errors.New("project not found")
+
+// Project объединяет задачи. Доступ к проекту дает доступ ко всем его задачам.
+type Project struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	OwnerID   int    `json:"owner_id,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ProjectInput struct {
+	Name *string `json:"name"`
+}
+
+// ProjectRepo, как и Repo, права не проверяет - см. authz.Repo.
+type ProjectRepo interface {
+	CreateProject(ctx context.Context, project *Project) (*Project, error)
+	GetAllProjects(ctx context.Context) ([]*Project, error)
+	GetProjectById(ctx context.Context, id int) (*Project, error)
+	UpdateProject(ctx context.Context, project *Project) error
+	DeleteProject(ctx context.Context, id int) (int64, error)
+}
+
+const projectColumns = "id, name, COALESCE(owner_id, 0), created_at"
+
+func (repository *TaskRepository) CreateProject(ctx context.Context, project *Project) (*Project, error) {
+	project.CreatedAt = time.Now().Format(timeLayout)
+	result, err := repository.ForContext(ctx).db.Exec("INSERT INTO projects (name, owner_id, created_at, workspace_id) VALUES ($1, NULLIF($2, 0), $3, $4)",
+		project.Name, project.OwnerID, project.CreatedAt, workspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	project.ID = int(id)
+	return project, nil
+}
+
+func (repository *TaskRepository) queryProjects(query string, args ...any) ([]*Project, error) {
+	rows, err := repository.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []*Project{}
+	for rows.Next() {
+		var project Project
+		if err := rows.Scan(&project.ID, &project.Name, &project.OwnerID, &project.CreatedAt); err != nil {
+			return nil, err
+		}
+		projects = append(projects, &project)
+	}
+
+	return projects, rows.Err()
+}
+
+func (repository *TaskRepository) GetAllProjects(ctx context.Context) ([]*Project, error) {
+	scope, args := workspaceScope(ctx, "workspace_id", nil)
+	return repository.ForContext(ctx).queryProjects("SELECT "+projectColumns+" FROM projects WHERE "+scope+" ORDER BY id", args...)
+}
+
+// GetVisibleProjects возвращает свои проекты и проекты, расшаренные пользователю.
+func (repository *TaskRepository) GetVisibleProjects(ctx context.Context, userID int) ([]*Project, error) {
+	scope, args := workspaceScope(ctx, "workspace_id", []any{userID})
+	return repository.ForContext(ctx).queryProjects("SELECT "+projectColumns+" FROM projects WHERE (owner_id = $1 OR id IN (SELECT project_id FROM project_shares WHERE user_id = $1)) AND "+scope+" ORDER BY id", args...)
+}
+
+func (repository *TaskRepository) GetProjectById(ctx context.Context, id int) (*Project, error) {
+	var project Project
+	scope, args := workspaceScope(ctx, "workspace_id", []any{id})
+	err := repository.ForContext(ctx).db.QueryRow("SELECT "+projectColumns+" FROM projects WHERE id = $1 AND "+scope, args...).
+		Scan(&project.ID, &project.Name, &project.OwnerID, &project.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (repository *TaskRepository) UpdateProject(ctx context.Context, project *Project) error {
+	scope, args := workspaceScope(ctx, "workspace_id", []any{project.Name, project.ID})
+	result, err := repository.ForContext(ctx).db.Exec("UPDATE projects SET name = $1 WHERE id = $2 AND "+scope, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrProjectNotFound
+	}
+
+	return nil
+}
+
+// DeleteProject удаляет проект и его доступы. Задачи остаются без проекта.
+func (repository *TaskRepository) DeleteProject(ctx context.Context, id int) (int64, error) {
+	if _, err := repository.GetProjectById(ctx, id); errors.Is(err, ErrProjectNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	repository = repository.ForContext(ctx)
+	// Пользовательские поля принадлежат проекту и уходят вместе с ним
+	if _, err := repository.db.Exec("UPDATE tasks SET project_id = NULL, custom_fields = NULL WHERE project_id = $1", id); err != nil {
+		return 0, err
+	}
+
+	if _, err := repository.db.Exec("DELETE FROM custom_fields WHERE project_id = $1", id); err != nil {
+		return 0, err
+	}
+
+	if _, err := repository.db.Exec("DELETE FROM project_shares WHERE project_id = $1", id); err != nil {
+		return 0, err
+	}
+
+	result, err := repository.db.Exec("DELETE FROM projects WHERE id = $1", id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
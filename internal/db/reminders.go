@@ -0,0 +1,275 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ReminderPending   = "pending"
+	ReminderSending   = "sending"
+	ReminderDelivered = "delivered"
+	ReminderFailed    = "failed"
+	ReminderSkipped   = "skipped"
+)
+
+var ErrReminderNotFound = errors.New("reminder not found")
+
+// Reminder срабатывает либо в абсолютное время RemindAt, либо за Before до due_date задачи.
+// FireAt вычисляется при чтении, поэтому перенос срока задачи сдвигает и напоминание.
+type Reminder struct {
+	ID          int    `json:"id"`
+	TaskID      int    `json:"task_id"`
+	RemindAt    string `json:"remind_at,omitempty"`
+	Before      string `json:"before,omitempty"`
+	FireAt      string `json:"fire_at"`
+	State       string `json:"state"`
+	Attempts    int    `json:"attempts"`
+	LastError   string `json:"last_error,omitempty"`
+	DeliveredAt string `json:"delivered_at,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type ReminderInput struct {
+	At     *string `json:"at,omitempty"`
+	Before *string `json:"before,omitempty"`
+}
+
+// DueReminder - напоминание вместе с задачей, к которой оно относится.
+type DueReminder struct {
+	Reminder
+	Task       Task
+	TaskClosed bool
+}
+
+type ReminderRepo interface {
+	CreateReminder(ctx context.Context, taskID int, input *ReminderInput) (*Reminder, error)
+	GetReminders(ctx context.Context, taskID int) ([]*Reminder, error)
+	DeleteReminder(ctx context.Context, taskID int, id int) (int64, error)
+	GetDueReminders(now string, limit int) ([]*DueReminder, error)
+	ClaimReminder(id int) (bool, error)
+	IsReminderSinkDelivered(id int, sink string) (bool, error)
+	MarkReminderSinkDelivered(id int, sink string, now string) error
+	FinishReminder(id int, state string, lastError string, now string) error
+	RetryReminder(id int, lastError string, maxAttempts int) error
+	RecoverReminders() (int64, error)
+	SkipMissedReminders(before string, keepLatest bool) (int64, error)
+}
+
+const reminderColumns = "id, task_id, COALESCE(remind_at, ''), before_seconds, fire_at, state, attempts, COALESCE(last_error, ''), COALESCE(delivered_at, ''), created_at"
+
+// ParseBefore разбирает смещение вида "1h", "90m" или "2d".
+func ParseBefore(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset %q", value)
+	}
+	return d, nil
+}
+
+func scanReminder(row rowScanner, dest ...any) (*Reminder, error) {
+	var reminder Reminder
+	var beforeSeconds int64
+	err := row.Scan(append([]any{&reminder.ID, &reminder.TaskID, &reminder.RemindAt, &beforeSeconds, &reminder.FireAt,
+		&reminder.State, &reminder.Attempts, &reminder.LastError, &reminder.DeliveredAt, &reminder.CreatedAt}, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if reminder.RemindAt == "" {
+		reminder.Before = (time.Duration(beforeSeconds) * time.Second).String()
+	}
+	return &reminder, nil
+}
+
+func (repository *TaskRepository) CreateReminder(ctx context.Context, taskID int, input *ReminderInput) (*Reminder, error) {
+	repository = repository.ForContext(ctx)
+	if _, err := repository.GetTaskById(ctx, taskID); err != nil {
+		return nil, err
+	}
+
+	var beforeSeconds int64
+	if input.Before != nil {
+		before, err := ParseBefore(*input.Before)
+		if err != nil {
+			return nil, err
+		}
+		beforeSeconds = int64(before / time.Second)
+	}
+
+	result, err := repository.db.Exec("INSERT INTO reminders (task_id, remind_at, before_seconds, state, attempts, created_at) VALUES ($1, $2, $3, $4, 0, $5)",
+		taskID, input.At, beforeSeconds, ReminderPending, time.Now().Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	row := repository.db.QueryRow("SELECT "+reminderColumns+" FROM reminder_schedule WHERE id = $1", id)
+	return scanReminder(row)
+}
+
+func (repository *TaskRepository) GetReminders(ctx context.Context, taskID int) ([]*Reminder, error) {
+	repository = repository.ForContext(ctx)
+	if _, err := repository.GetTaskById(ctx, taskID); err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.db.Query("SELECT "+reminderColumns+" FROM reminder_schedule WHERE task_id = $1 ORDER BY fire_at", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []*Reminder{}
+	for rows.Next() {
+		reminder, err := scanReminder(rows)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, rows.Err()
+}
+
+// DeleteReminder удаляет напоминание задачи taskID вместе с отметками о доставке. Отметки
+// удаляются, только если напоминание действительно принадлежит задаче: иначе чужое напоминание
+// потеряло бы их и было бы отправлено повторно.
+func (repository *TaskRepository) DeleteReminder(ctx context.Context, taskID int, id int) (int64, error) {
+	if _, err := repository.ForContext(ctx).GetTaskById(ctx, taskID); err != nil {
+		return 0, err
+	}
+
+	// Без отдельной транзакции (внутри уже начатой или при одном соединении) порядок запросов
+	// все равно не оставляет напоминание без отметок
+	txCtx, tx, err := repository.Begin(ctx)
+	if errors.Is(err, ErrNoTransactions) {
+		txCtx, tx = ctx, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if tx != nil {
+		defer tx.Rollback()
+	}
+	repository = repository.ForContext(txCtx)
+
+	result, err := repository.db.Exec("DELETE FROM reminders WHERE id = $1 AND task_id = $2", id, taskID)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil || count == 0 {
+		return count, err
+	}
+
+	if _, err := repository.db.Exec("DELETE FROM reminder_deliveries WHERE reminder_id = $1", id); err != nil {
+		return 0, err
+	}
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
+
+// GetDueReminders возвращает ожидающие напоминания, время которых наступило.
+func (repository *TaskRepository) GetDueReminders(now string, limit int) ([]*DueReminder, error) {
+	rows, err := repository.db.Query("SELECT "+reminderColumns+", task_title, task_due_date, task_status FROM reminder_schedule WHERE state = $1 AND fire_at <= $2 ORDER BY fire_at LIMIT $3",
+		ReminderPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []*DueReminder
+	for rows.Next() {
+		var task Task
+		reminder, err := scanReminder(rows, &task.Title, &task.DueDate, &task.Status)
+		if err != nil {
+			return nil, err
+		}
+		task.ID = reminder.TaskID
+		repository.deriveState(&task, now)
+		reminders = append(reminders, &DueReminder{Reminder: *reminder, Task: task, TaskClosed: repository.workflow.IsClosed(task.Status)})
+	}
+
+	return reminders, rows.Err()
+}
+
+// ClaimReminder помечает напоминание как отправляемое. false означает, что его уже забрали.
+func (repository *TaskRepository) ClaimReminder(id int) (bool, error) {
+	result, err := repository.db.Exec("UPDATE reminders SET state = $1 WHERE id = $2 AND state = $3", ReminderSending, id, ReminderPending)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
+
+func (repository *TaskRepository) IsReminderSinkDelivered(id int, sink string) (bool, error) {
+	var count int
+	err := repository.db.QueryRow("SELECT COUNT(*) FROM reminder_deliveries WHERE reminder_id = $1 AND sink = $2", id, sink).Scan(&count)
+	return count > 0, err
+}
+
+func (repository *TaskRepository) MarkReminderSinkDelivered(id int, sink string, now string) error {
+	_, err := repository.db.Exec("INSERT OR IGNORE INTO reminder_deliveries (reminder_id, sink, delivered_at) VALUES ($1, $2, $3)", id, sink, now)
+	return err
+}
+
+func (repository *TaskRepository) FinishReminder(id int, state string, lastError string, now string) error {
+	_, err := repository.db.Exec("UPDATE reminders SET state = $1, last_error = NULLIF($2, ''), delivered_at = $3 WHERE id = $4", state, lastError, now, id)
+	return err
+}
+
+// RetryReminder возвращает напоминание в очередь или помечает его failed после maxAttempts попыток.
+func (repository *TaskRepository) RetryReminder(id int, lastError string, maxAttempts int) error {
+	_, err := repository.db.Exec("UPDATE reminders SET attempts = attempts + 1, last_error = $1, state = CASE WHEN attempts + 1 >= $2 THEN $3 ELSE $4 END WHERE id = $5",
+		lastError, maxAttempts, ReminderFailed, ReminderPending, id)
+	return err
+}
+
+// RecoverReminders возвращает в очередь напоминания, отправка которых прервалась при остановке.
+// Уже доставленные каналы записаны в reminder_deliveries и повторно не получат уведомление.
+func (repository *TaskRepository) RecoverReminders() (int64, error) {
+	result, err := repository.db.Exec("UPDATE reminders SET state = $1 WHERE state = $2", ReminderPending, ReminderSending)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SkipMissedReminders пропускает напоминания, которые должны были сработать раньше before.
+// При keepLatest у каждой задачи остается самое позднее из пропущенных напоминаний.
+func (repository *TaskRepository) SkipMissedReminders(before string, keepLatest bool) (int64, error) {
+	query := "UPDATE reminders SET state = $1 WHERE id IN (SELECT id FROM reminder_schedule s WHERE state = $2 AND fire_at < $3"
+	if keepLatest {
+		query += " AND EXISTS (SELECT 1 FROM reminder_schedule l WHERE l.task_id = s.task_id AND l.state = $2 AND l.fire_at < $3 AND (l.fire_at > s.fire_at OR (l.fire_at = s.fire_at AND l.id > s.id)))"
+	}
+	query += ")"
+
+	result, err := repository.db.Exec(query, ReminderSkipped, ReminderPending, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
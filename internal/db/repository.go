@@ -1,56 +1,118 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"todo/internal/logging"
+	"todo/pkg/config"
 	"todo/pkg/sqlite3"
 )
 
-func TaskRepositoryInit() (*TaskRepository, error) {
-	dbConn, err := sqlite3.ConnectorInit()
+const (
+	timeLayout  = "2006-01-02 15:04:05"
+	taskColumns = "id, title, COALESCE(description, ''), due_date, status, created_at, COALESCE(owner_id, 0), COALESCE(project_id, 0), COALESCE(estimate_minutes, 0), COALESCE(custom_fields, ''), COALESCE(external_id, '')"
+)
+
+// TaskRepositoryInit открывает базу из настроек database и применяет миграции.
+func TaskRepositoryInit(database config.Database, workflowConfig config.Workflow) (*TaskRepository, error) {
+	workflow, err := ParseWorkflow(workflowConfig.Transitions, workflowConfig.Initial, workflowConfig.Done, workflowConfig.Closed)
+	if err != nil {
+		return nil, err
+	}
+
+	dbConn, err := sqlite3.Open(database.Path, sqlite3.Options{
+		MaxOpenConns: database.MaxOpenConns,
+		BusyTimeout:  database.BusyTimeout,
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	return NewTaskRepository(dbConn, workflow)
+}
+
+// NewTaskRepository создает репозиторий поверх готового соединения и применяет миграции.
+func NewTaskRepository(dbConn DbInterface, workflow *Workflow) (*TaskRepository, error) {
 	dbRepo := &TaskRepository{
-		db: dbConn,
+		db:       instrumentedDB{DbInterface: dbConn},
+		workflow: workflow,
 	}
-	err = dbRepo.createTaskTable()
 
-	if err != nil {
+	if err := dbRepo.migrate(); err != nil {
 		return nil, err
 	}
 
-	return dbRepo, err
+	return dbRepo, nil
 }
 
-func (repository *TaskRepository) createTaskTable() error {
+func (repository *TaskRepository) Workflow() *Workflow {
+	return repository.workflow
+}
 
-	query := `CREATE TABLE IF NOT EXISTS tasks (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT NOT NULL,
-		description TEXT,
-		due_date TEXT NOT NULL,
-		completed INTEGER NOT NULL CHECK (completed IN (0, 1)),
-		overdue INTEGER NOT NULL CHECK (overdue IN (0, 1)),
-		created_at TEXT NOT NULL
-	);`
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
-	_, err := repository.db.Exec(query)
+func (repository *TaskRepository) scanTask(row rowScanner) (*Task, error) {
+	var task Task
+	var customFields string
+	err := row.Scan(&task.ID, &task.Title, &task.Description, &task.DueDate, &task.Status, &task.CreatedAt, &task.OwnerID, &task.ProjectID, &task.EstimateMinutes, &customFields, &task.ExternalID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if task.CustomFields, err = decodeCustomFields(customFields); err != nil {
+		return nil, err
 	}
 
-	return nil
+	repository.deriveState(&task, time.Now().Format(timeLayout))
+	return &task, nil
+}
+
+// deriveState вычисляет признаки выполнения и просрочки из статуса и срока задачи.
+func (repository *TaskRepository) deriveState(task *Task, now string) {
+	task.IsCompleted = task.Status == repository.workflow.Done
+	task.IsOverdue = !repository.workflow.IsClosed(task.Status) && task.DueDate < now
+
+	task.Completed, task.Overdue = 0, 0
+	if task.IsCompleted {
+		task.Completed = 1
+	}
+	if task.IsOverdue {
+		task.Overdue = 1
+	}
 }
 
-func (repository *TaskRepository) CreateTask(input *TaskInput) (*Task, error) {
-	result, err := repository.db.Exec("INSERT INTO tasks (title, description, due_date, completed, overdue, created_at) VALUES ($1, $2, $3, 0, 0, $4)",
-		input.Title, input.Description, input.DueDate, input.CreatedAt)
+func (repository *TaskRepository) CreateTask(ctx context.Context, input *TaskInput) (*Task, error) {
+	repository = repository.ForContext(ctx)
+	projectID, estimate := 0, 0
+	if input.ProjectID != nil {
+		projectID = *input.ProjectID
+	}
+	if input.EstimateMinutes != nil {
+		estimate = *input.EstimateMinutes
+	}
+
+	customFields, err := encodeCustomFields(input.CustomFields)
 	if err != nil {
 		return nil, err
 	}
 
+	externalID := ""
+	if input.ExternalID != nil {
+		externalID = *input.ExternalID
+	}
+
+	result, err := repository.db.Exec("INSERT INTO tasks (title, description, due_date, completed, overdue, created_at, status, owner_id, project_id, workspace_id, estimate_minutes, custom_fields, external_id) VALUES ($1, $2, $3, 0, 0, $4, $5, NULLIF($6, 0), NULLIF($7, 0), $8, NULLIF($9, 0), $10, NULLIF($11, ''))",
+		input.Title, input.Description, input.DueDate, input.CreatedAt, repository.workflow.Initial, input.OwnerID, projectID, workspaceID(ctx), estimate, customFields, externalID)
+	if err != nil {
+		return nil, externalIDError(err)
+	}
+
 	// Получаем ID вставленной задачи
 	taskID, err := result.LastInsertId()
 	if err != nil {
@@ -58,20 +120,74 @@ func (repository *TaskRepository) CreateTask(input *TaskInput) (*Task, error) {
 	}
 
 	task := &Task{
-		ID:          int(taskID),
-		Title:       *input.Title,
-		Description: *input.Description,
-		CreatedAt:   input.CreatedAt,
-		DueDate:     *input.DueDate,
-		Completed:   0,
-		Overdue:     0,
+		ID:              int(taskID),
+		Title:           *input.Title,
+		Description:     *input.Description,
+		CreatedAt:       input.CreatedAt,
+		DueDate:         *input.DueDate,
+		Status:          repository.workflow.Initial,
+		OwnerID:         input.OwnerID,
+		ProjectID:       projectID,
+		EstimateMinutes: estimate,
+		CustomFields:    input.CustomFields,
+		ExternalID:      externalID,
+	}
+	if len(task.CustomFields) == 0 {
+		task.CustomFields = nil
+	}
+	if input.Assignees != nil {
+		task.Assignees = NormalizeUserIDs(*input.Assignees)
 	}
+	if input.Watchers != nil {
+		task.Watchers = NormalizeUserIDs(*input.Watchers)
+	}
+	if err := repository.savePeople(task); err != nil {
+		return nil, err
+	}
+	repository.deriveState(task, time.Now().Format(timeLayout))
 
 	return task, nil
 }
 
-func (repository *TaskRepository) GetAllTasks() ([]*Task, error) {
-	rows, err := repository.db.Query("SELECT id, title, COALESCE(description, ''), due_date, completed, overdue, created_at FROM tasks")
+// GetAllTasks возвращает задачи пространства из ctx, отобранные filter.
+func (repository *TaskRepository) GetAllTasks(ctx context.Context, filter *TaskFilter) ([]*Task, error) {
+	where, args := filterClause(filter, nil)
+	scope, args := workspaceScope(ctx, "workspace_id", args)
+	order, args := orderClause(filter, args)
+	return repository.ForContext(ctx).queryTasks(ctx, "SELECT "+taskColumns+" FROM tasks WHERE "+where+" AND "+scope+order, args...)
+}
+
+// GetVisibleTasks возвращает задачи, доступные пользователю: свои, расшаренные ему напрямую
+// или через проект. Фильтрация выполняется в SQL, чтобы не читать чужие задачи.
+func (repository *TaskRepository) GetVisibleTasks(ctx context.Context, userID int, filter *TaskFilter) ([]*Task, error) {
+	where, args := filterClause(filter, []any{userID})
+	scope, args := workspaceScope(ctx, "workspace_id", args)
+	order, args := orderClause(filter, args)
+	return repository.ForContext(ctx).queryTasks(ctx, "SELECT "+taskColumns+" FROM tasks WHERE "+visibleTasksClause+" AND "+where+" AND "+scope+order, args...)
+}
+
+// queryTasks выполняет запрос задач и пишет в журнал запроса (уровень debug) его длительность.
+func (repository *TaskRepository) queryTasks(ctx context.Context, query string, args ...any) ([]*Task, error) {
+	start := time.Now()
+	tasks, err := repository.scanTasks(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	err = repository.loadDetails(tasks)
+	logging.FromContext(ctx).Debug("tasks query", "count", len(tasks), "duration_ms", float64(time.Since(start).Microseconds())/1000)
+	return tasks, err
+}
+
+// loadDetails дополняет задачи людьми и чек-листами.
+func (repository *TaskRepository) loadDetails(tasks []*Task) error {
+	if err := repository.loadPeople(tasks); err != nil {
+		return err
+	}
+	return repository.loadChecklists(tasks)
+}
+
+func (repository *TaskRepository) scanTasks(query string, args ...any) ([]*Task, error) {
+	rows, err := repository.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -79,48 +195,112 @@ func (repository *TaskRepository) GetAllTasks() ([]*Task, error) {
 
 	var tasks []*Task
 	for rows.Next() {
-		var task Task
-		err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.DueDate, &task.Completed, &task.Overdue, &task.CreatedAt)
+		task, err := repository.scanTask(rows)
 		if err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, &task)
+		tasks = append(tasks, task)
 	}
 
-	return tasks, nil
+	return tasks, rows.Err()
 }
 
-func (repository *TaskRepository) GetTaskById(id int) (*Task, error) {
-	row := repository.db.QueryRow("SELECT id, title, COALESCE(description, ''), due_date, completed, overdue, created_at FROM tasks WHERE id = $1", id)
+func (repository *TaskRepository) GetTaskById(ctx context.Context, id int) (*Task, error) {
+	repository = repository.ForContext(ctx)
+	scope, args := workspaceScope(ctx, "workspace_id", []any{id})
+	row := repository.db.QueryRow("SELECT "+taskColumns+" FROM tasks WHERE id = $1 AND "+scope, args...)
 
-	var task Task
-	err := row.Scan(&task.ID, &task.Title, &task.Description, &task.DueDate, &task.Completed, &task.Overdue, &task.CreatedAt)
+	task, err := repository.scanTask(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTaskNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &task, nil
+	return task, repository.loadDetails([]*Task{task})
 }
 
-func (repository *TaskRepository) UpdateTask(task *Task) error {
-	result, err := repository.db.Exec("UPDATE tasks SET title = $1, description = $2, due_date = $3, completed = $4, overdue = $5 WHERE id = $6", task.Title, task.Description, task.DueDate, task.Completed, task.Overdue, task.ID)
+// UpdateTask обновляет поля задачи вместе с исполнителями и наблюдателями.
+// Статус меняется только через TransitionTask.
+func (repository *TaskRepository) UpdateTask(ctx context.Context, task *Task) error {
+	repository = repository.ForContext(ctx)
+	customFields, err := encodeCustomFields(task.CustomFields)
 	if err != nil {
 		return err
 	}
 
+	scope, args := workspaceScope(ctx, "workspace_id", []any{task.Title, task.Description, task.DueDate, task.ProjectID, task.EstimateMinutes, customFields, task.ExternalID, task.ID})
+	result, err := repository.db.Exec("UPDATE tasks SET title = $1, description = $2, due_date = $3, project_id = NULLIF($4, 0), estimate_minutes = NULLIF($5, 0), custom_fields = $6, external_id = NULLIF($7, '') WHERE id = $8 AND "+scope, args...)
+	if err != nil {
+		return externalIDError(err)
+	}
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("task not found")
+		return ErrTaskNotFound
+	}
+
+	task.Assignees = NormalizeUserIDs(task.Assignees)
+	task.Watchers = NormalizeUserIDs(task.Watchers)
+	if err := repository.savePeople(task); err != nil {
+		return err
 	}
 
+	repository.deriveState(task, time.Now().Format(timeLayout))
 	return nil
 }
 
-func (repository *TaskRepository) DeleteTask(taskID int) (int64, error) {
+func (repository *TaskRepository) DeleteTask(ctx context.Context, taskID int) (int64, error) {
+	// Счетчики ссылок на содержимое вложений ведет основная база
+	main := repository
+	repository = repository.ForContext(ctx)
+	if _, err := repository.GetTaskById(ctx, taskID); errors.Is(err, ErrTaskNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	_, err := repository.db.Exec("DELETE FROM reminder_deliveries WHERE reminder_id IN (SELECT id FROM reminders WHERE task_id = $1)", taskID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = repository.db.Exec("DELETE FROM reminders WHERE task_id = $1", taskID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = repository.db.Exec("DELETE FROM task_shares WHERE task_id = $1", taskID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := repository.deleteTaskComments(taskID); err != nil {
+		return 0, err
+	}
+
+	if err := main.deleteTaskAttachments(repository, taskID); err != nil {
+		return 0, err
+	}
+
+	if _, err := repository.db.Exec("DELETE FROM checklist_items WHERE task_id = $1", taskID); err != nil {
+		return 0, err
+	}
+
+	if _, err := repository.db.Exec("DELETE FROM worklogs WHERE task_id = $1", taskID); err != nil {
+		return 0, err
+	}
+
+	// Пустые списки удаляют исполнителей и наблюдателей
+	if err := repository.savePeople(&Task{ID: taskID}); err != nil {
+		return 0, err
+	}
+
 	result, err := repository.db.Exec("DELETE FROM tasks WHERE id = $1", taskID)
 	if err != nil {
 		return 0, err
@@ -138,8 +318,30 @@ func (repository *TaskRepository) DeleteTask(taskID int) (int64, error) {
 	return rowsAffected, nil
 }
 
-func (repository *TaskRepository) CompleteTask(taskID int) error {
-	result, err := repository.db.Exec("UPDATE tasks SET completed = 1 WHERE id = $1 AND overdue = 0", taskID)
+// CompleteTask переводит задачу в финальный статус workflow.
+func (repository *TaskRepository) CompleteTask(ctx context.Context, taskID int) error {
+	return repository.TransitionTask(ctx, taskID, repository.workflow.Done)
+}
+
+// TransitionTask меняет статус задачи, если переход разрешен workflow.
+// Колонка completed поддерживается для совместимости со старыми версиями.
+func (repository *TaskRepository) TransitionTask(ctx context.Context, taskID int, status string) error {
+	repository = repository.ForContext(ctx)
+	task, err := repository.GetTaskById(ctx, taskID)
+	if err != nil {
+		return err
+	}
+
+	if err := repository.workflow.CanTransition(task.Status, status); err != nil {
+		return err
+	}
+
+	completed := 0
+	if status == repository.workflow.Done {
+		completed = 1
+	}
+
+	result, err := repository.db.Exec("UPDATE tasks SET status = $1, completed = $2 WHERE id = $3 AND status = $4", status, completed, taskID, task.Status)
 	if err != nil {
 		return err
 	}
@@ -150,23 +352,63 @@ func (repository *TaskRepository) CompleteTask(taskID int) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("task can't be updated")
+		return errors.New("task was modified concurrently")
 	}
 
 	return nil
 }
 
-// UpdateOverdueTasks обновляет статус просроченных задач
-func (repository *TaskRepository) UpdateOverdueTasks(now string) (int64, error) {
-	result, err := repository.db.Exec("UPDATE tasks SET overdue = 1 WHERE due_date < $1 AND completed = 0 AND overdue = 0", now)
+// UpdateOverdueTasks синхронизирует устаревшую колонку overdue с вычисляемым признаком
+// и возвращает задачи, ставшие просроченными.
+func (repository *TaskRepository) UpdateOverdueTasks(ctx context.Context, now string) ([]*Task, error) {
+	repository = repository.ForContext(ctx)
+	closed := repository.closedPlaceholders()
+	args := []any{now}
+	for _, status := range repository.workflow.Closed {
+		args = append(args, status)
+	}
+	scope, args := workspaceScope(ctx, "workspace_id", args)
+
+	_, err := repository.db.Exec("UPDATE tasks SET overdue = 0 WHERE overdue = 1 AND (due_date >= $1 OR status IN ("+closed+")) AND "+scope, args...)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return repository.queryTasks(ctx, "UPDATE tasks SET overdue = 1 WHERE overdue = 0 AND due_date < $1 AND status NOT IN ("+closed+") AND "+scope+" RETURNING "+taskColumns, args...)
+}
+
+// TaskCounts - число открытых, просроченных и выполненных задач.
+type TaskCounts struct {
+	Open      int
+	Overdue   int
+	Completed int
+}
+
+// CountTasks считает задачи пространства из ctx (без пространства - всей базы).
+func (repository *TaskRepository) CountTasks(ctx context.Context) (*TaskCounts, error) {
+	repository = repository.ForContext(ctx)
+	closed := repository.closedPlaceholders()
+	args := []any{time.Now().Format(timeLayout)}
+	for _, status := range repository.workflow.Closed {
+		args = append(args, status)
+	}
+	args = append(args, repository.workflow.Done)
+	done := "$" + strconv.Itoa(len(args))
+	scope, args := workspaceScope(ctx, "workspace_id", args)
+
+	var counts TaskCounts
+	err := repository.db.QueryRow("SELECT COALESCE(SUM(due_date < $1 AND status NOT IN ("+closed+")), 0), COALESCE(SUM(status NOT IN ("+closed+")), 0), COALESCE(SUM(status = "+done+"), 0) FROM tasks WHERE "+scope, args...).
+		Scan(&counts.Overdue, &counts.Open, &counts.Completed)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	return &counts, nil
+}
 
-	return rowsAffected, nil
+func (repository *TaskRepository) closedPlaceholders() string {
+	placeholders := make([]string, len(repository.workflow.Closed))
+	for i := range placeholders {
+		placeholders[i] = "$" + strconv.Itoa(i+2)
+	}
+	return strings.Join(placeholders, ", ")
 }
@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	PermissionView = "view"
+	PermissionEdit = "edit"
+)
+
+// Access - уровень доступа пользователя к задаче или проекту. Уровни упорядочены:
+// больший включает меньший.
+type Access int
+
+const (
+	AccessNone Access = iota
+	AccessView
+	AccessEdit
+	AccessOwner
+)
+
+// PermissionAccess переводит право из доступа в уровень.
+func PermissionAccess(permission string) Access {
+	switch permission {
+	case PermissionEdit:
+		return AccessEdit
+	case PermissionView:
+		return AccessView
+	}
+	return AccessNone
+}
+
+// Share - доступ пользователя к задаче или проекту.
+type Share struct {
+	UserID     int    `json:"user_id"`
+	Username   string `json:"username"`
+	Permission string `json:"permission"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type ShareInput struct {
+	UserID     int    `json:"user_id,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Permission string `json:"permission"`
+}
+
+type ShareRepo interface {
+	GetTaskShares(ctx context.Context, taskID int) ([]*Share, error)
+	ShareTask(ctx context.Context, taskID int, userID int, permission string) error
+	DeleteTaskShare(ctx context.Context, taskID int, userID int) (int64, error)
+	GetProjectShares(ctx context.Context, projectID int) ([]*Share, error)
+	ShareProject(ctx context.Context, projectID int, userID int, permission string) error
+	DeleteProjectShare(ctx context.Context, projectID int, userID int) (int64, error)
+}
+
+// visibleTasksClause - условие видимости задачи для пользователя $1. Должно совпадать с TaskAccess.
+const visibleTasksClause = `(owner_id = $1
+	OR id IN (SELECT task_id FROM task_shares WHERE user_id = $1)
+	OR id IN (SELECT task_id FROM task_assignees WHERE user_id = $1 UNION SELECT task_id FROM task_watchers WHERE user_id = $1)
+	OR project_id IN (SELECT id FROM projects WHERE owner_id = $1 UNION SELECT project_id FROM project_shares WHERE user_id = $1))`
+
+// TaskAccess вычисляет максимальный уровень доступа к задаче: владелец задачи или проекта,
+// прямой доступ или доступ через проект. Исполнитель может редактировать задачу,
+// наблюдатель - просматривать.
+func (repository *TaskRepository) TaskAccess(ctx context.Context, taskID int, userID int) (Access, error) {
+	if _, err := repository.GetTaskById(ctx, taskID); errors.Is(err, ErrTaskNotFound) {
+		return AccessNone, nil
+	} else if err != nil {
+		return AccessNone, err
+	}
+
+	var access Access
+	err := repository.ForContext(ctx).db.QueryRow(`SELECT COALESCE(MAX(level), 0) FROM (
+		SELECT 3 AS level FROM tasks WHERE id = $1 AND owner_id = $2
+		UNION ALL SELECT 3 FROM tasks t JOIN projects p ON p.id = t.project_id WHERE t.id = $1 AND p.owner_id = $2
+		UNION ALL SELECT CASE permission WHEN 'edit' THEN 2 ELSE 1 END FROM task_shares WHERE task_id = $1 AND user_id = $2
+		UNION ALL SELECT 2 FROM task_assignees WHERE task_id = $1 AND user_id = $2
+		UNION ALL SELECT 1 FROM task_watchers WHERE task_id = $1 AND user_id = $2
+		UNION ALL SELECT CASE s.permission WHEN 'edit' THEN 2 ELSE 1 END FROM project_shares s JOIN tasks t ON t.project_id = s.project_id WHERE t.id = $1 AND s.user_id = $2
+	)`, taskID, userID).Scan(&access)
+	return access, err
+}
+
+func (repository *TaskRepository) ProjectAccess(ctx context.Context, projectID int, userID int) (Access, error) {
+	if _, err := repository.GetProjectById(ctx, projectID); errors.Is(err, ErrProjectNotFound) {
+		return AccessNone, nil
+	} else if err != nil {
+		return AccessNone, err
+	}
+
+	var access Access
+	err := repository.ForContext(ctx).db.QueryRow(`SELECT COALESCE(MAX(level), 0) FROM (
+		SELECT 3 AS level FROM projects WHERE id = $1 AND owner_id = $2
+		UNION ALL SELECT CASE permission WHEN 'edit' THEN 2 ELSE 1 END FROM project_shares WHERE project_id = $1 AND user_id = $2
+	)`, projectID, userID).Scan(&access)
+	return access, err
+}
+
+// queryShares читает доступы из базы пространства, а имена пользователей - из основной базы:
+// в режиме file это разные файлы.
+func (repository *TaskRepository) queryShares(ctx context.Context, query string, id int) ([]*Share, error) {
+	rows, err := repository.ForContext(ctx).db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []*Share{}
+	for rows.Next() {
+		var share Share
+		if err := rows.Scan(&share.UserID, &share.Permission, &share.CreatedAt); err != nil {
+			return nil, err
+		}
+		shares = append(shares, &share)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, share := range shares {
+		user, err := repository.GetUserById(share.UserID)
+		if errors.Is(err, ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		share.Username = user.Username
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].Username < shares[j].Username })
+
+	return shares, nil
+}
+
+func (repository *TaskRepository) GetTaskShares(ctx context.Context, taskID int) ([]*Share, error) {
+	return repository.queryShares(ctx, "SELECT user_id, permission, created_at FROM task_shares WHERE task_id = $1", taskID)
+}
+
+// ShareTask выдает или меняет доступ к задаче.
+func (repository *TaskRepository) ShareTask(ctx context.Context, taskID int, userID int, permission string) error {
+	_, err := repository.ForContext(ctx).db.Exec("INSERT INTO task_shares (task_id, user_id, permission, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (task_id, user_id) DO UPDATE SET permission = excluded.permission",
+		taskID, userID, permission, time.Now().Format(timeLayout))
+	return err
+}
+
+func (repository *TaskRepository) DeleteTaskShare(ctx context.Context, taskID int, userID int) (int64, error) {
+	result, err := repository.ForContext(ctx).db.Exec("DELETE FROM task_shares WHERE task_id = $1 AND user_id = $2", taskID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (repository *TaskRepository) GetProjectShares(ctx context.Context, projectID int) ([]*Share, error) {
+	return repository.queryShares(ctx, "SELECT user_id, permission, created_at FROM project_shares WHERE project_id = $1", projectID)
+}
+
+func (repository *TaskRepository) ShareProject(ctx context.Context, projectID int, userID int, permission string) error {
+	_, err := repository.ForContext(ctx).db.Exec("INSERT INTO project_shares (project_id, user_id, permission, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (project_id, user_id) DO UPDATE SET permission = excluded.permission",
+		projectID, userID, permission, time.Now().Format(timeLayout))
+	return err
+}
+
+func (repository *TaskRepository) DeleteProjectShare(ctx context.Context, projectID int, userID int) (int64, error) {
+	result, err := repository.ForContext(ctx).db.Exec("DELETE FROM project_shares WHERE project_id = $1 AND user_id = $2", projectID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrExternalIDExists = errors.New("external_id is already used by another task")
+	// ErrNoTransactions - соединение не поддерживает транзакции или транзакция заблокировала бы
+	// пул из одного соединения: запросы вне транзакции ждали бы ее конца.
+	ErrNoTransactions = errors.New("transactions are not available, database.max_open_conns must not be 1")
+)
+
+// TransferRepo - то, что нужно загрузке задач помимо Repo: транзакция и поиск задачи по
+// внешнему идентификатору.
+type TransferRepo interface {
+	Begin(ctx context.Context) (context.Context, *Tx, error)
+	GetTaskIDByExternalID(ctx context.Context, externalID string) (int, error)
+}
+
+type txKey struct{}
+
+// Tx - транзакция в базе пространства. Запросы репозитория с ctx из Begin выполняются в ней.
+type Tx struct {
+	tx *sql.Tx
+}
+
+// Begin начинает транзакцию в базе пространства из ctx и возвращает ctx, привязанный к ней.
+// Запросы к основной базе без ForContext (например, к пользователям) идут мимо транзакции.
+// Транзакция откатывается, если ctx отменен до Commit.
+func (repository *TaskRepository) Begin(ctx context.Context) (context.Context, *Tx, error) {
+	base := repository.ForContext(ctx)
+	conn, ok := base.conn().(interface {
+		BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	})
+	if !ok {
+		return ctx, nil, ErrNoTransactions
+	}
+	if stats, ok := base.DBStats(); ok && stats.MaxOpenConnections == 1 {
+		return ctx, nil, ErrNoTransactions
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return ctx, nil, err
+	}
+	bound := *base
+	bound.db = instrumentedDB{DbInterface: tx}
+	return context.WithValue(ctx, txKey{}, &bound), &Tx{tx: tx}, nil
+}
+
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback откатывает транзакцию; после Commit ничего не делает.
+func (t *Tx) Rollback() error {
+	if err := t.tx.Rollback(); !errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+	return nil
+}
+
+// Savepoint выполняет fn так, что при ошибке откатываются только ее изменения, а транзакция
+// продолжается.
+func (t *Tx) Savepoint(fn func() error) error {
+	if _, err := t.tx.Exec("SAVEPOINT step"); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if _, rollbackErr := t.tx.Exec("ROLLBACK TO step; RELEASE step"); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+	_, err := t.tx.Exec("RELEASE step")
+	return err
+}
+
+// GetTaskIDByExternalID возвращает ID задачи пространства из ctx с внешним идентификатором
+// externalID. Права доступа не проверяются: задачу нужно читать через Repo.
+func (repository *TaskRepository) GetTaskIDByExternalID(ctx context.Context, externalID string) (int, error) {
+	scope, args := workspaceScope(ctx, "workspace_id", []any{externalID})
+	var id int
+	err := repository.ForContext(ctx).db.QueryRow("SELECT id FROM tasks WHERE external_id = $1 AND "+scope, args...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrTaskNotFound
+	}
+	return id, err
+}
+
+// externalIDError заменяет нарушение уникальности внешнего идентификатора на ErrExternalIDExists.
+func externalIDError(err error) error {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed") && strings.Contains(err.Error(), "external_id") {
+		return ErrExternalIDExists
+	}
+	return err
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 )
 
@@ -9,9 +10,28 @@ type Task struct {
 	Title       string `json:"title"`
 	Description string `json:"description,omitempty"`
 	DueDate     string `json:"due_date"`
-	Completed   int8   `json:"completed"`
-	Overdue     int8   `json:"overdue"`
-	CreatedAt   string `json:"created_at"`
+	Status      string `json:"status"`
+	IsCompleted bool   `json:"is_completed"`
+	IsOverdue   bool   `json:"is_overdue"`
+	// Deprecated: используйте Status и IsCompleted. Поле будет удалено в следующей версии.
+	Completed int8 `json:"completed"`
+	// Deprecated: используйте IsOverdue. Поле будет удалено в следующей версии.
+	Overdue   int8   `json:"overdue"`
+	CreatedAt string `json:"created_at"`
+	OwnerID   int    `json:"owner_id,omitempty"`
+	ProjectID int    `json:"project_id,omitempty"`
+	// EstimateMinutes - оценка трудозатрат, 0 - без оценки.
+	EstimateMinutes int   `json:"estimate_minutes,omitempty"`
+	Assignees       []int `json:"assignees,omitempty"`
+	Watchers        []int `json:"watchers,omitempty"`
+	// Checklist и ChecklistProgress заполняются, если у задачи есть пункты чек-листа.
+	Checklist         []*ChecklistItem   `json:"checklist,omitempty"`
+	ChecklistProgress *ChecklistProgress `json:"checklist_progress,omitempty"`
+	// CustomFields - значения пользовательских полей проекта задачи по ключам.
+	CustomFields map[string]any `json:"custom_fields,omitempty"`
+	// ExternalID - идентификатор задачи во внешней системе, уникальный в пространстве.
+	// По нему загрузка /import находит задачу, чтобы изменить ее, а не создать заново.
+	ExternalID string `json:"external_id,omitempty"`
 }
 
 type DbInterface interface {
@@ -21,22 +41,35 @@ type DbInterface interface {
 }
 
 type TaskRepository struct {
-	db DbInterface
+	db       DbInterface
+	workflow *Workflow
 }
 
 type TaskInput struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description,omitempty"`
-	DueDate     *string `json:"due_date,omitempty"`
-	CreatedAt   string  `json:"created_at"`
+	Title           *string `json:"title"`
+	Description     *string `json:"description,omitempty"`
+	DueDate         *string `json:"due_date,omitempty"`
+	ProjectID       *int    `json:"project_id,omitempty"`
+	Assignees       *[]int  `json:"assignees,omitempty"`
+	Watchers        *[]int  `json:"watchers,omitempty"`
+	EstimateMinutes *int    `json:"estimate_minutes,omitempty"`
+	// CustomFields задает значения пользовательских полей; null удаляет значение.
+	CustomFields map[string]any `json:"custom_fields,omitempty"`
+	// ExternalID задает внешний идентификатор; пустая строка его снимает.
+	ExternalID *string `json:"external_id,omitempty"`
+	CreatedAt  string  `json:"created_at"`
+	OwnerID    int     `json:"-"`
 }
 
+// Repo - хранилище задач. TaskRepository права доступа не проверяет: это делает
+// authz.Repo, который реализует тот же интерфейс поверх TaskRepository.
 type Repo interface {
-	GetAllTasks() ([]*Task, error)
-	CreateTask(input *TaskInput) (*Task, error)
-	GetTaskById(id int) (*Task, error)
-	UpdateTask(task *Task) error
-	DeleteTask(id int) (int64, error)
-	CompleteTask(id int) error
-	UpdateOverdueTasks(now string) (int64, error)
+	GetAllTasks(ctx context.Context, filter *TaskFilter) ([]*Task, error)
+	CreateTask(ctx context.Context, input *TaskInput) (*Task, error)
+	GetTaskById(ctx context.Context, id int) (*Task, error)
+	UpdateTask(ctx context.Context, task *Task) error
+	DeleteTask(ctx context.Context, id int) (int64, error)
+	CompleteTask(ctx context.Context, id int) error
+	TransitionTask(ctx context.Context, id int, status string) error
+	UpdateOverdueTasks(ctx context.Context, now string) ([]*Task, error)
 }
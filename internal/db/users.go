@@ -0,0 +1,238 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"todo/internal/auth"
+)
+
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrUserExists     = errors.New("user already exists")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+type User struct {
+	ID           int    `json:"id"`
+	Username     string `json:"username"`
+	Role         string `json:"role"`
+	PasswordHash string `json:"-"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// APIKey - ключ доступа для скриптов и интеграций. Сам ключ показывается только при создании.
+type APIKey struct {
+	ID         int      `json:"id"`
+	UserID     int      `json:"user_id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	Key        string   `json:"key,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	RevokedAt  string   `json:"revoked_at,omitempty"`
+}
+
+type UserRepo interface {
+	CreateUser(user *User) (*User, error)
+	GetUserByUsername(username string) (*User, error)
+	GetUserById(id int) (*User, error)
+	GetUsers() ([]*User, error)
+	SetUserRole(id int, role string) error
+	CreateSession(tokenHash string, userID int, expiresAt string) error
+	DeleteSession(tokenHash string) error
+	AuthenticateSession(tokenHash string, now string) (*auth.Principal, error)
+	CreateAPIKey(key *APIKey, keyHash string) (*APIKey, error)
+	GetAPIKeys(userID int) ([]*APIKey, error)
+	RevokeAPIKey(userID int, id int, now string) error
+	AuthenticateAPIKey(keyHash string, now string) (*auth.Principal, error)
+}
+
+const userColumns = "id, username, role, password_hash, created_at"
+
+func scanUser(row rowScanner) (*User, error) {
+	var user User
+	err := row.Scan(&user.ID, &user.Username, &user.Role, &user.PasswordHash, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (repository *TaskRepository) CreateUser(user *User) (*User, error) {
+	user.CreatedAt = time.Now().Format(timeLayout)
+	result, err := repository.db.Exec("INSERT INTO users (username, password_hash, role, created_at) VALUES ($1, $2, $3, $4)",
+		user.Username, user.PasswordHash, user.Role, user.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, ErrUserExists
+		}
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	user.ID = int(id)
+	return user, nil
+}
+
+func (repository *TaskRepository) GetUserByUsername(username string) (*User, error) {
+	return scanUser(repository.db.QueryRow("SELECT "+userColumns+" FROM users WHERE username = $1", username))
+}
+
+func (repository *TaskRepository) GetUserById(id int) (*User, error) {
+	return scanUser(repository.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1", id))
+}
+
+func (repository *TaskRepository) GetUsers() ([]*User, error) {
+	rows, err := repository.db.Query("SELECT " + userColumns + " FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (repository *TaskRepository) SetUserRole(id int, role string) error {
+	result, err := repository.db.Exec("UPDATE users SET role = $1 WHERE id = $2", role, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (repository *TaskRepository) CreateSession(tokenHash string, userID int, expiresAt string) error {
+	_, err := repository.db.Exec("INSERT INTO sessions (token_hash, user_id, expires_at, created_at) VALUES ($1, $2, $3, $4)",
+		tokenHash, userID, expiresAt, time.Now().Format(timeLayout))
+	return err
+}
+
+func (repository *TaskRepository) DeleteSession(tokenHash string) error {
+	_, err := repository.db.Exec("DELETE FROM sessions WHERE token_hash = $1", tokenHash)
+	return err
+}
+
+// AuthenticateSession возвращает принципала по хешу токена. Истекшие сессии удаляются.
+func (repository *TaskRepository) AuthenticateSession(tokenHash string, now string) (*auth.Principal, error) {
+	if _, err := repository.db.Exec("DELETE FROM sessions WHERE expires_at <= $1", now); err != nil {
+		return nil, err
+	}
+
+	principal := &auth.Principal{Via: "session"}
+	err := repository.db.QueryRow("SELECT u.id, u.username, u.role FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.token_hash = $1",
+		tokenHash).Scan(&principal.UserID, &principal.Username, &principal.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return principal, nil
+}
+
+func (repository *TaskRepository) CreateAPIKey(key *APIKey, keyHash string) (*APIKey, error) {
+	key.CreatedAt = time.Now().Format(timeLayout)
+	result, err := repository.db.Exec("INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		key.UserID, key.Name, key.Prefix, keyHash, strings.Join(key.Scopes, ","), key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	key.ID = int(id)
+	return key, nil
+}
+
+func (repository *TaskRepository) GetAPIKeys(userID int) ([]*APIKey, error) {
+	rows, err := repository.db.Query("SELECT id, user_id, name, prefix, scopes, created_at, COALESCE(last_used_at, ''), COALESCE(revoked_at, '') FROM api_keys WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		var key APIKey
+		var scopes string
+		if err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &scopes, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+			return nil, err
+		}
+		key.Scopes = strings.Split(scopes, ",")
+		keys = append(keys, &key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (repository *TaskRepository) RevokeAPIKey(userID int, id int, now string) error {
+	result, err := repository.db.Exec("UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL", now, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey возвращает принципала по хешу ключа и отмечает время использования.
+func (repository *TaskRepository) AuthenticateAPIKey(keyHash string, now string) (*auth.Principal, error) {
+	var keyID int
+	var scopes string
+	principal := &auth.Principal{Via: "api_key"}
+	err := repository.db.QueryRow("SELECT k.id, k.scopes, u.id, u.username, u.role FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.key_hash = $1 AND k.revoked_at IS NULL",
+		keyHash).Scan(&keyID, &scopes, &principal.UserID, &principal.Username, &principal.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	principal.Scopes = strings.Split(scopes, ",")
+
+	if _, err := repository.db.Exec("UPDATE api_keys SET last_used_at = $1 WHERE id = $2", now, keyID); err != nil {
+		return nil, err
+	}
+
+	return principal, nil
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Webhook - подписка внешней системы на события задач. Events содержит типы
+// событий ("task.created") или "*" для всех событий.
+type Webhook struct {
+	ID        int      `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"`
+	Active    bool     `json:"active"`
+	CreatedAt string   `json:"created_at"`
+}
+
+type WebhookInput struct {
+	URL    *string  `json:"url"`
+	Events []string `json:"events,omitempty"`
+	Secret *string  `json:"secret,omitempty"`
+	Active *bool    `json:"active,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID            int             `json:"id"`
+	WebhookID     int             `json:"webhook_id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	State         string          `json:"state"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt string          `json:"next_attempt_at,omitempty"`
+	LastStatus    int             `json:"last_status,omitempty"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     string          `json:"created_at"`
+	DeliveredAt   string          `json:"delivered_at,omitempty"`
+}
+
+type WebhookRepo interface {
+	CreateWebhook(webhook *Webhook) (*Webhook, error)
+	GetWebhooks() ([]*Webhook, error)
+	GetWebhookById(id int) (*Webhook, error)
+	UpdateWebhook(webhook *Webhook) error
+	DeleteWebhook(id int) (int64, error)
+	EnqueueDelivery(webhookID int, event string, payload string, now string) error
+	GetDeliveries(webhookID int, limit int) ([]*WebhookDelivery, error)
+	GetPendingDeliveries(now string, limit int) ([]*WebhookDelivery, error)
+	MarkDeliveryDelivered(id int, status int, now string) error
+	MarkDeliveryFailed(id int, status int, lastError string, nextAttemptAt string, dead bool) error
+}
+
+// Matches проверяет, подписан ли вебхук на событие.
+func (webhook *Webhook) Matches(event string) bool {
+	for _, filter := range webhook.Events {
+		if filter == "*" || filter == event {
+			return true
+		}
+	}
+	return false
+}
+
+const webhookColumns = "id, url, events, secret, active, created_at"
+
+func scanWebhook(row rowScanner) (*Webhook, error) {
+	var webhook Webhook
+	var events string
+	if err := row.Scan(&webhook.ID, &webhook.URL, &events, &webhook.Secret, &webhook.Active, &webhook.CreatedAt); err != nil {
+		return nil, err
+	}
+	webhook.Events = strings.Split(events, ",")
+	return &webhook, nil
+}
+
+func (repository *TaskRepository) CreateWebhook(webhook *Webhook) (*Webhook, error) {
+	webhook.CreatedAt = time.Now().Format(timeLayout)
+	result, err := repository.db.Exec("INSERT INTO webhooks (url, events, secret, active, created_at) VALUES ($1, $2, $3, $4, $5)",
+		webhook.URL, strings.Join(webhook.Events, ","), webhook.Secret, webhook.Active, webhook.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.ID = int(id)
+	return webhook, nil
+}
+
+func (repository *TaskRepository) GetWebhooks() ([]*Webhook, error) {
+	rows, err := repository.db.Query("SELECT " + webhookColumns + " FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (repository *TaskRepository) GetWebhookById(id int) (*Webhook, error) {
+	webhook, err := scanWebhook(repository.db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+func (repository *TaskRepository) UpdateWebhook(webhook *Webhook) error {
+	result, err := repository.db.Exec("UPDATE webhooks SET url = $1, events = $2, secret = $3, active = $4 WHERE id = $5",
+		webhook.URL, strings.Join(webhook.Events, ","), webhook.Secret, webhook.Active, webhook.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (repository *TaskRepository) DeleteWebhook(id int) (int64, error) {
+	if _, err := repository.db.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = $1", id); err != nil {
+		return 0, err
+	}
+
+	result, err := repository.db.Exec("DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (repository *TaskRepository) EnqueueDelivery(webhookID int, event string, payload string, now string) error {
+	_, err := repository.db.Exec("INSERT INTO webhook_deliveries (webhook_id, event, payload, state, attempts, next_attempt_at, created_at) VALUES ($1, $2, $3, $4, 0, $5, $5)",
+		webhookID, event, payload, DeliveryPending, now)
+	return err
+}
+
+const deliveryColumns = "id, webhook_id, event, payload, state, attempts, COALESCE(next_attempt_at, ''), COALESCE(last_status, 0), COALESCE(last_error, ''), created_at, COALESCE(delivered_at, '')"
+
+func (repository *TaskRepository) queryDeliveries(query string, args ...any) ([]*WebhookDelivery, error) {
+	rows, err := repository.db.Query("SELECT "+deliveryColumns+" FROM webhook_deliveries "+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.State, &d.Attempts, &d.NextAttemptAt, &d.LastStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, &d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (repository *TaskRepository) GetDeliveries(webhookID int, limit int) ([]*WebhookDelivery, error) {
+	return repository.queryDeliveries("WHERE webhook_id = $1 ORDER BY id DESC LIMIT $2", webhookID, limit)
+}
+
+// GetPendingDeliveries возвращает доставки, время очередной попытки которых наступило.
+func (repository *TaskRepository) GetPendingDeliveries(now string, limit int) ([]*WebhookDelivery, error) {
+	return repository.queryDeliveries("WHERE state = $1 AND next_attempt_at <= $2 ORDER BY next_attempt_at, id LIMIT $3", DeliveryPending, now, limit)
+}
+
+func (repository *TaskRepository) MarkDeliveryDelivered(id int, status int, now string) error {
+	_, err := repository.db.Exec("UPDATE webhook_deliveries SET state = $1, attempts = attempts + 1, last_status = $2, last_error = NULL, next_attempt_at = NULL, delivered_at = $3 WHERE id = $4",
+		DeliveryDelivered, status, now, id)
+	return err
+}
+
+// MarkDeliveryFailed фиксирует неудачную попытку. При dead доставка больше не повторяется.
+func (repository *TaskRepository) MarkDeliveryFailed(id int, status int, lastError string, nextAttemptAt string, dead bool) error {
+	state := DeliveryPending
+	if dead {
+		state = DeliveryDead
+	}
+
+	_, err := repository.db.Exec("UPDATE webhook_deliveries SET state = $1, attempts = attempts + 1, last_status = NULLIF($2, 0), last_error = $3, next_attempt_at = NULLIF($4, '') WHERE id = $5",
+		state, status, lastError, nextAttemptAt, id)
+	return err
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrTaskNotFound      = errors.New("task not found")
+	ErrUnknownStatus     = errors.New("unknown task status")
+	ErrTransitionDenied  = errors.New("status transition is not allowed")
+	defaultTransitions   = "todo:in_progress,done,cancelled;in_progress:todo,review,done,cancelled;review:in_progress,done,cancelled;done:todo;cancelled:todo"
+	defaultInitialStatus = "todo"
+	defaultDoneStatus    = "done"
+	defaultClosedStatus  = "done,cancelled"
+)
+
+// Workflow описывает допустимые статусы задачи и переходы между ними.
+// Closed-статусы (например, done и cancelled) не считаются просроченными.
+type Workflow struct {
+	Initial     string              `json:"initial"`
+	Done        string              `json:"done"`
+	Closed      []string            `json:"closed"`
+	Statuses    []string            `json:"statuses"`
+	Transitions map[string][]string `json:"transitions"`
+}
+
+func DefaultWorkflow() *Workflow {
+	w, err := ParseWorkflow(defaultTransitions, defaultInitialStatus, defaultDoneStatus, defaultClosedStatus)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// ParseWorkflow разбирает переходы в формате "from:to1,to2;from2:to3".
+// Пустые значения заменяются значениями по умолчанию.
+func ParseWorkflow(transitions, initial, done, closed string) (*Workflow, error) {
+	if transitions == "" {
+		transitions = defaultTransitions
+	}
+	if initial == "" {
+		initial = defaultInitialStatus
+	}
+	if done == "" {
+		done = defaultDoneStatus
+	}
+	if closed == "" {
+		closed = done
+	}
+
+	w := &Workflow{
+		Initial:     initial,
+		Done:        done,
+		Transitions: make(map[string][]string),
+	}
+
+	addStatus := func(status string) {
+		if !slices.Contains(w.Statuses, status) {
+			w.Statuses = append(w.Statuses, status)
+		}
+	}
+
+	for _, rule := range strings.Split(transitions, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		from, targets, ok := strings.Cut(rule, ":")
+		from = strings.TrimSpace(from)
+		if !ok || from == "" {
+			return nil, fmt.Errorf("invalid workflow rule %q, expected from:to1,to2", rule)
+		}
+		addStatus(from)
+
+		for _, to := range strings.Split(targets, ",") {
+			to = strings.TrimSpace(to)
+			if to == "" {
+				continue
+			}
+			addStatus(to)
+			if !slices.Contains(w.Transitions[from], to) {
+				w.Transitions[from] = append(w.Transitions[from], to)
+			}
+		}
+	}
+
+	for _, status := range strings.Split(closed, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			w.Closed = append(w.Closed, status)
+		}
+	}
+
+	for _, status := range append([]string{initial, done}, w.Closed...) {
+		if !w.HasStatus(status) {
+			return nil, fmt.Errorf("status %q is not defined in workflow transitions", status)
+		}
+	}
+
+	return w, nil
+}
+
+func (w *Workflow) HasStatus(status string) bool {
+	return slices.Contains(w.Statuses, status)
+}
+
+func (w *Workflow) IsClosed(status string) bool {
+	return slices.Contains(w.Closed, status)
+}
+
+// CanTransition проверяет переход from -> to. Переход в тот же статус
+// считается допустимым и ничего не меняет.
+func (w *Workflow) CanTransition(from, to string) error {
+	if !w.HasStatus(to) {
+		return fmt.Errorf("%w: %s", ErrUnknownStatus, to)
+	}
+	if from == to || slices.Contains(w.Transitions[from], to) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s -> %s", ErrTransitionDenied, from, to)
+}
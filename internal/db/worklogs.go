@@ -0,0 +1,323 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WorklogTimer  = "timer"
+	WorklogManual = "manual"
+
+	GroupByTask    = "task"
+	GroupByProject = "project"
+	GroupByUser    = "user"
+)
+
+var (
+	ErrWorklogNotFound = errors.New("worklog not found")
+	ErrTimerRunning    = errors.New("timer is already running")
+	ErrTimerNotRunning = errors.New("timer is not running")
+	ErrUnknownGroupBy  = errors.New("unknown group_by, expected task, project or user")
+)
+
+// Worklog - затраченное на задачу время. Запущенный таймер - запись без EndedAt.
+type Worklog struct {
+	ID          int    `json:"id"`
+	TaskID      int    `json:"task_id"`
+	UserID      int    `json:"user_id"`
+	StartedAt   string `json:"started_at"`
+	EndedAt     string `json:"ended_at,omitempty"`
+	Seconds     int    `json:"seconds"`
+	Minutes     int    `json:"minutes"`
+	Note        string `json:"note,omitempty"`
+	Source      string `json:"source"`
+	AutoStopped bool   `json:"auto_stopped,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type WorklogInput struct {
+	Minutes   *int    `json:"minutes"`
+	StartedAt *string `json:"started_at,omitempty"`
+	Note      *string `json:"note,omitempty"`
+}
+
+// TimeReportFilter задает отчет по времени. From и To - границы started_at, To не входит
+// в период; пустая граница не ограничивает. VisibleTo ограничивает отчет задачами,
+// доступными пользователю, 0 - все задачи.
+type TimeReportFilter struct {
+	From      string
+	To        string
+	GroupBy   string
+	VisibleTo int
+}
+
+// TimeReportRow - сумма времени по задаче, проекту или пользователю. Name для пользователей
+// заполняет вызывающий: пользователи хранятся в основной базе.
+type TimeReportRow struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Seconds int    `json:"seconds"`
+	Minutes int    `json:"minutes"`
+	Entries int    `json:"entries"`
+}
+
+type WorklogRepo interface {
+	StartTimer(ctx context.Context, taskID int, userID int) (*Worklog, error)
+	StopTimer(ctx context.Context, taskID int, userID int) (*Worklog, error)
+	GetRunningTimer(ctx context.Context, userID int) (*Worklog, error)
+	CreateWorklog(ctx context.Context, worklog *Worklog) (*Worklog, error)
+	GetWorklogs(ctx context.Context, taskID int) ([]*Worklog, error)
+	GetWorklog(ctx context.Context, taskID int, id int) (*Worklog, error)
+	DeleteWorklog(ctx context.Context, taskID int, id int) (int64, error)
+	GetTimeReport(ctx context.Context, filter *TimeReportFilter) ([]*TimeReportRow, error)
+	StopStaleTimers(ctx context.Context, limit time.Duration) ([]*Worklog, error)
+}
+
+// Minutes округляет секунды до ближайшей минуты.
+func Minutes(seconds int) int {
+	return (seconds + 30) / 60
+}
+
+const worklogColumns = "id, task_id, user_id, started_at, COALESCE(ended_at, ''), seconds, note, source, auto_stopped, created_at"
+
+// StartTimer запускает таймер пользователя на задаче. У пользователя может идти только один таймер.
+func (repository *TaskRepository) StartTimer(ctx context.Context, taskID int, userID int) (*Worklog, error) {
+	if _, err := repository.GetRunningTimer(ctx, userID); err == nil {
+		return nil, ErrTimerRunning
+	} else if !errors.Is(err, ErrTimerNotRunning) {
+		return nil, err
+	}
+
+	now := time.Now().Format(timeLayout)
+	worklog := &Worklog{TaskID: taskID, UserID: userID, StartedAt: now, Source: WorklogTimer, CreatedAt: now}
+	result, err := repository.ForContext(ctx).db.Exec("INSERT INTO worklogs (task_id, workspace_id, user_id, started_at, source, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		taskID, workspaceID(ctx), userID, now, WorklogTimer, now)
+	if err != nil {
+		// Таймер мог запуститься параллельно: единственность обеспечивает индекс worklogs_running_workspace
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, ErrTimerRunning
+		}
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	worklog.ID = int(id)
+	return worklog, nil
+}
+
+// StopTimer останавливает таймер пользователя на задаче и сохраняет затраченное время.
+func (repository *TaskRepository) StopTimer(ctx context.Context, taskID int, userID int) (*Worklog, error) {
+	worklog, err := repository.GetRunningTimer(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if worklog.TaskID != taskID {
+		return nil, ErrTimerNotRunning
+	}
+
+	return worklog, repository.ForContext(ctx).finishWorklog(worklog, time.Now(), false)
+}
+
+// GetRunningTimer возвращает запущенный таймер пользователя или ErrTimerNotRunning. Таймеры
+// независимы в каждом пространстве: в обоих режимах хранения у пользователя идет не больше
+// одного таймера на пространство.
+func (repository *TaskRepository) GetRunningTimer(ctx context.Context, userID int) (*Worklog, error) {
+	scope, args := workspaceScope(ctx, "workspace_id", []any{userID})
+	row := repository.ForContext(ctx).db.QueryRow("SELECT "+worklogColumns+" FROM worklogs WHERE user_id = $1 AND ended_at IS NULL AND "+scope, args...)
+	worklog, err := scanWorklog(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTimerNotRunning
+	}
+	return worklog, err
+}
+
+// CreateWorklog добавляет запись о времени вручную. Конец периода вычисляется из StartedAt и Seconds.
+func (repository *TaskRepository) CreateWorklog(ctx context.Context, worklog *Worklog) (*Worklog, error) {
+	started, err := time.ParseInLocation(timeLayout, worklog.StartedAt, time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	worklog.EndedAt = started.Add(time.Duration(worklog.Seconds) * time.Second).Format(timeLayout)
+	worklog.Minutes = Minutes(worklog.Seconds)
+	worklog.Source = WorklogManual
+	worklog.CreatedAt = time.Now().Format(timeLayout)
+	result, err := repository.ForContext(ctx).db.Exec("INSERT INTO worklogs (task_id, workspace_id, user_id, started_at, ended_at, seconds, note, source, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		worklog.TaskID, workspaceID(ctx), worklog.UserID, worklog.StartedAt, worklog.EndedAt, worklog.Seconds, worklog.Note, worklog.Source, worklog.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	worklog.ID = int(id)
+	return worklog, nil
+}
+
+func (repository *TaskRepository) GetWorklogs(ctx context.Context, taskID int) ([]*Worklog, error) {
+	rows, err := repository.ForContext(ctx).db.Query("SELECT "+worklogColumns+" FROM worklogs WHERE task_id = $1 ORDER BY started_at, id", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	worklogs := []*Worklog{}
+	for rows.Next() {
+		worklog, err := scanWorklog(rows)
+		if err != nil {
+			return nil, err
+		}
+		worklogs = append(worklogs, worklog)
+	}
+	return worklogs, rows.Err()
+}
+
+func (repository *TaskRepository) GetWorklog(ctx context.Context, taskID int, id int) (*Worklog, error) {
+	row := repository.ForContext(ctx).db.QueryRow("SELECT "+worklogColumns+" FROM worklogs WHERE task_id = $1 AND id = $2", taskID, id)
+	worklog, err := scanWorklog(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWorklogNotFound
+	}
+	return worklog, err
+}
+
+func (repository *TaskRepository) DeleteWorklog(ctx context.Context, taskID int, id int) (int64, error) {
+	result, err := repository.ForContext(ctx).db.Exec("DELETE FROM worklogs WHERE task_id = $1 AND id = $2", taskID, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetTimeReport суммирует завершенные записи о времени по задачам, проектам или пользователям.
+// Запущенные таймеры в отчет не входят.
+func (repository *TaskRepository) GetTimeReport(ctx context.Context, filter *TimeReportFilter) ([]*TimeReportRow, error) {
+	var key, name, join string
+	switch filter.GroupBy {
+	case GroupByTask:
+		key, name = "t.id", "t.title"
+	case GroupByProject:
+		key, name, join = "COALESCE(t.project_id, 0)", "COALESCE(p.name, '')", " LEFT JOIN projects p ON p.id = t.project_id"
+	case GroupByUser:
+		key, name = "w.user_id", "''"
+	default:
+		return nil, ErrUnknownGroupBy
+	}
+
+	var clauses []string
+	var args []any
+	// visibleTasksClause ссылается на пользователя как на $1
+	if filter.VisibleTo != 0 {
+		args = append(args, filter.VisibleTo)
+		clauses = append(clauses, "w.task_id IN (SELECT id FROM tasks WHERE "+visibleTasksClause+")")
+	}
+	if filter.From != "" {
+		args = append(args, filter.From)
+		clauses = append(clauses, "w.started_at >= $"+strconv.Itoa(len(args)))
+	}
+	if filter.To != "" {
+		args = append(args, filter.To)
+		clauses = append(clauses, "w.started_at < $"+strconv.Itoa(len(args)))
+	}
+	scope, args := workspaceScope(ctx, "w.workspace_id", args)
+	clauses = append(clauses, "w.ended_at IS NOT NULL", scope)
+
+	rows, err := repository.ForContext(ctx).db.Query("SELECT "+key+", "+name+", SUM(w.seconds), COUNT(*) FROM worklogs w JOIN tasks t ON t.id = w.task_id"+join+
+		" WHERE "+strings.Join(clauses, " AND ")+" GROUP BY 1 ORDER BY 3 DESC, 1", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := []*TimeReportRow{}
+	for rows.Next() {
+		var row TimeReportRow
+		if err := rows.Scan(&row.ID, &row.Name, &row.Seconds, &row.Entries); err != nil {
+			return nil, err
+		}
+		row.Minutes = Minutes(row.Seconds)
+		report = append(report, &row)
+	}
+	return report, rows.Err()
+}
+
+// StopStaleTimers останавливает таймеры, идущие дольше limit. Записывается ровно limit:
+// время после него, скорее всего, не работали над задачей.
+func (repository *TaskRepository) StopStaleTimers(ctx context.Context, limit time.Duration) ([]*Worklog, error) {
+	repository = repository.ForContext(ctx)
+	scope, args := workspaceScope(ctx, "workspace_id", []any{time.Now().Add(-limit).Format(timeLayout)})
+	rows, err := repository.db.Query("SELECT "+worklogColumns+" FROM worklogs WHERE ended_at IS NULL AND started_at < $1 AND "+scope, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []*Worklog
+	for rows.Next() {
+		worklog, err := scanWorklog(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stale = append(stale, worklog)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, worklog := range stale {
+		started, err := time.ParseInLocation(timeLayout, worklog.StartedAt, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		if err := repository.finishWorklog(worklog, started.Add(limit), true); err != nil {
+			return nil, err
+		}
+	}
+	return stale, nil
+}
+
+// finishWorklog завершает запущенный таймер в момент end.
+func (repository *TaskRepository) finishWorklog(worklog *Worklog, end time.Time, auto bool) error {
+	started, err := time.ParseInLocation(timeLayout, worklog.StartedAt, time.Local)
+	if err != nil {
+		return err
+	}
+
+	worklog.EndedAt = end.Format(timeLayout)
+	worklog.Seconds = max(int(end.Sub(started).Seconds()), 0)
+	worklog.Minutes = Minutes(worklog.Seconds)
+	worklog.AutoStopped = auto
+	result, err := repository.db.Exec("UPDATE worklogs SET ended_at = $1, seconds = $2, auto_stopped = $3 WHERE id = $4 AND ended_at IS NULL",
+		worklog.EndedAt, worklog.Seconds, auto, worklog.ID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err == nil && count == 0 {
+		return ErrTimerNotRunning
+	}
+	return err
+}
+
+func scanWorklog(row rowScanner) (*Worklog, error) {
+	var worklog Worklog
+	err := row.Scan(&worklog.ID, &worklog.TaskID, &worklog.UserID, &worklog.StartedAt, &worklog.EndedAt, &worklog.Seconds,
+		&worklog.Note, &worklog.Source, &worklog.AutoStopped, &worklog.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	worklog.Minutes = Minutes(worklog.Seconds)
+	return &worklog, nil
+}
@@ -0,0 +1,280 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrWorkspaceExists   = errors.New("workspace already exists")
+)
+
+const (
+	WorkspaceActive    = "active"
+	WorkspaceSuspended = "suspended"
+
+	// Пространство по умолчанию создается миграцией, в нем остаются данные,
+	// появившиеся до разделения на пространства.
+	DefaultWorkspaceID   = 1
+	DefaultWorkspaceSlug = "default"
+)
+
+// Workspace - рабочее пространство одной команды. Задачи и проекты пространств изолированы.
+// StorageQuota - лимит вложений в байтах; 0 означает лимит по умолчанию.
+type Workspace struct {
+	ID           int    `json:"id"`
+	Slug         string `json:"slug"`
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	StorageQuota int64  `json:"storage_quota,omitempty"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type WorkspaceInput struct {
+	Slug         *string `json:"slug"`
+	Name         *string `json:"name"`
+	Status       *string `json:"status,omitempty"`
+	StorageQuota *int64  `json:"storage_quota,omitempty"`
+}
+
+// WorkspaceRepo хранит сами пространства и участников в основной базе.
+type WorkspaceRepo interface {
+	CreateWorkspace(workspace *Workspace) (*Workspace, error)
+	GetWorkspaces() ([]*Workspace, error)
+	GetWorkspaceById(id int) (*Workspace, error)
+	GetWorkspaceBySlug(slug string) (*Workspace, error)
+	UpdateWorkspace(workspace *Workspace) error
+	DeleteWorkspace(id int) (int64, error)
+	DeleteWorkspaceData(id int) error
+	IsWorkspaceMember(workspaceID int, userID int) (bool, error)
+	GetWorkspaceMembers(workspaceID int) ([]*User, error)
+	AddWorkspaceMember(workspaceID int, userID int) error
+	RemoveWorkspaceMember(workspaceID int, userID int) (int64, error)
+}
+
+type workspaceKey struct{}
+
+type boundWorkspace struct {
+	workspace *Workspace
+	repo      *TaskRepository
+}
+
+// WithWorkspace привязывает ctx к пространству. repo - отдельная база пространства (режим file);
+// nil означает общую базу, где данные разделяет колонка workspace_id (режим column).
+func WithWorkspace(ctx context.Context, workspace *Workspace, repo *TaskRepository) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, boundWorkspace{workspace: workspace, repo: repo})
+}
+
+func WorkspaceFromContext(ctx context.Context) (*Workspace, bool) {
+	bound, ok := ctx.Value(workspaceKey{}).(boundWorkspace)
+	return bound.workspace, ok
+}
+
+// ForContext возвращает репозиторий отдельной базы пространства из ctx, если она есть, а внутри
+// транзакции (см. Begin) - репозиторий транзакции. Его запросы попадают в трассу текущего спана из ctx.
+func (repository *TaskRepository) ForContext(ctx context.Context) *TaskRepository {
+	if tx, ok := ctx.Value(txKey{}).(*TaskRepository); ok {
+		return tx.traced(ctx)
+	}
+	if bound, ok := ctx.Value(workspaceKey{}).(boundWorkspace); ok && bound.repo != nil {
+		return bound.repo.traced(ctx)
+	}
+	return repository.traced(ctx)
+}
+
+// workspaceScope добавляет к args условие на workspace_id для режима column. Без пространства
+// в ctx (фоновые задачи по всей базе) и в режиме file условие пустое.
+// SQLite нумерует $N в порядке появления в запросе, поэтому условие ставится после остальных.
+func workspaceScope(ctx context.Context, column string, args []any) (string, []any) {
+	bound, ok := ctx.Value(workspaceKey{}).(boundWorkspace)
+	if !ok || bound.repo != nil {
+		return "1 = 1", args
+	}
+
+	args = append(args, bound.workspace.ID)
+	return column + " = $" + strconv.Itoa(len(args)), args
+}
+
+// workspaceID - значение workspace_id для новых строк.
+func workspaceID(ctx context.Context) int {
+	if bound, ok := ctx.Value(workspaceKey{}).(boundWorkspace); ok && bound.repo == nil {
+		return bound.workspace.ID
+	}
+	return DefaultWorkspaceID
+}
+
+const workspaceColumns = "id, slug, name, status, storage_quota, created_at"
+
+func scanWorkspace(row rowScanner) (*Workspace, error) {
+	var workspace Workspace
+	err := row.Scan(&workspace.ID, &workspace.Slug, &workspace.Name, &workspace.Status, &workspace.StorageQuota, &workspace.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+func (repository *TaskRepository) CreateWorkspace(workspace *Workspace) (*Workspace, error) {
+	workspace.CreatedAt = time.Now().Format(timeLayout)
+	result, err := repository.db.Exec("INSERT INTO workspaces (slug, name, status, storage_quota, created_at) VALUES ($1, $2, $3, $4, $5)",
+		workspace.Slug, workspace.Name, workspace.Status, workspace.StorageQuota, workspace.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, ErrWorkspaceExists
+		}
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	workspace.ID = int(id)
+	return workspace, nil
+}
+
+func (repository *TaskRepository) GetWorkspaces() ([]*Workspace, error) {
+	rows, err := repository.db.Query("SELECT " + workspaceColumns + " FROM workspaces ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []*Workspace{}
+	for rows.Next() {
+		workspace, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+
+	return workspaces, rows.Err()
+}
+
+func (repository *TaskRepository) GetWorkspaceById(id int) (*Workspace, error) {
+	return scanWorkspace(repository.db.QueryRow("SELECT "+workspaceColumns+" FROM workspaces WHERE id = $1", id))
+}
+
+func (repository *TaskRepository) GetWorkspaceBySlug(slug string) (*Workspace, error) {
+	return scanWorkspace(repository.db.QueryRow("SELECT "+workspaceColumns+" FROM workspaces WHERE slug = $1", slug))
+}
+
+func (repository *TaskRepository) UpdateWorkspace(workspace *Workspace) error {
+	result, err := repository.db.Exec("UPDATE workspaces SET name = $1, status = $2, storage_quota = $3 WHERE id = $4",
+		workspace.Name, workspace.Status, workspace.StorageQuota, workspace.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrWorkspaceNotFound
+	}
+
+	return nil
+}
+
+// DeleteWorkspace удаляет пространство и список участников. Данные пространства
+// удаляются отдельно: DeleteWorkspaceData в режиме column или вместе с файлом в режиме file.
+func (repository *TaskRepository) DeleteWorkspace(id int) (int64, error) {
+	if _, err := repository.db.Exec("DELETE FROM workspace_members WHERE workspace_id = $1", id); err != nil {
+		return 0, err
+	}
+
+	result, err := repository.db.Exec("DELETE FROM workspaces WHERE id = $1", id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteWorkspaceData удаляет задачи и проекты пространства из общей базы вместе с
+// напоминаниями, доступами, исполнителями, наблюдателями, комментариями, вложениями,
+// чек-листами, учетом времени и пользовательскими полями.
+func (repository *TaskRepository) DeleteWorkspaceData(id int) error {
+	hashes, err := repository.attachmentHashes("SELECT hash FROM attachments WHERE workspace_id = $1", id)
+	if err != nil {
+		return err
+	}
+	if err := repository.ReleaseBlobs(hashes); err != nil {
+		return err
+	}
+
+	queries := []string{
+		"DELETE FROM attachments WHERE workspace_id = $1",
+		"DELETE FROM checklist_items WHERE task_id IN (SELECT id FROM tasks WHERE workspace_id = $1)",
+		"DELETE FROM worklogs WHERE workspace_id = $1",
+		"DELETE FROM custom_fields WHERE workspace_id = $1",
+		"DELETE FROM reminder_deliveries WHERE reminder_id IN (SELECT r.id FROM reminders r JOIN tasks t ON t.id = r.task_id WHERE t.workspace_id = $1)",
+		"DELETE FROM reminders WHERE task_id IN (SELECT id FROM tasks WHERE workspace_id = $1)",
+		"DELETE FROM task_shares WHERE task_id IN (SELECT id FROM tasks WHERE workspace_id = $1)",
+		"DELETE FROM task_assignees WHERE task_id IN (SELECT id FROM tasks WHERE workspace_id = $1)",
+		"DELETE FROM task_watchers WHERE task_id IN (SELECT id FROM tasks WHERE workspace_id = $1)",
+		"DELETE FROM comment_revisions WHERE comment_id IN (SELECT c.id FROM comments c JOIN tasks t ON t.id = c.task_id WHERE t.workspace_id = $1)",
+		"DELETE FROM comment_mentions WHERE comment_id IN (SELECT c.id FROM comments c JOIN tasks t ON t.id = c.task_id WHERE t.workspace_id = $1)",
+		"DELETE FROM comments WHERE task_id IN (SELECT id FROM tasks WHERE workspace_id = $1)",
+		"DELETE FROM project_shares WHERE project_id IN (SELECT id FROM projects WHERE workspace_id = $1)",
+		"DELETE FROM tasks WHERE workspace_id = $1",
+		"DELETE FROM projects WHERE workspace_id = $1",
+	}
+	for _, query := range queries {
+		if _, err := repository.db.Exec(query, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repository *TaskRepository) IsWorkspaceMember(workspaceID int, userID int) (bool, error) {
+	var count int
+	err := repository.db.QueryRow("SELECT COUNT(*) FROM workspace_members WHERE workspace_id = $1 AND user_id = $2", workspaceID, userID).Scan(&count)
+	return count > 0, err
+}
+
+func (repository *TaskRepository) GetWorkspaceMembers(workspaceID int) ([]*User, error) {
+	rows, err := repository.db.Query("SELECT u.id, u.username, u.role, u.password_hash, u.created_at FROM workspace_members m JOIN users u ON u.id = m.user_id WHERE m.workspace_id = $1 ORDER BY u.username", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (repository *TaskRepository) AddWorkspaceMember(workspaceID int, userID int) error {
+	_, err := repository.db.Exec("INSERT OR IGNORE INTO workspace_members (workspace_id, user_id, created_at) VALUES ($1, $2, $3)",
+		workspaceID, userID, time.Now().Format(timeLayout))
+	return err
+}
+
+func (repository *TaskRepository) RemoveWorkspaceMember(workspaceID int, userID int) (int64, error) {
+	result, err := repository.db.Exec("DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2", workspaceID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
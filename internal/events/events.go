@@ -0,0 +1,85 @@
+package events
+
+import (
+	"sync"
+	"time"
+	"todo/internal/db"
+)
+
+const (
+	TaskCreated    = "task.created"
+	TaskUpdated    = "task.updated"
+	TaskCompleted  = "task.completed"
+	TaskDeleted    = "task.deleted"
+	TaskOverdue    = "task.overdue"
+	TaskReassigned = "task.reassigned"
+
+	CommentCreated = "comment.created"
+	CommentUpdated = "comment.updated"
+	CommentDeleted = "comment.deleted"
+
+	ConfigChanged = "config.changed"
+)
+
+var Types = []string{
+	TaskCreated, TaskUpdated, TaskCompleted, TaskDeleted, TaskOverdue, TaskReassigned,
+	CommentCreated, CommentUpdated, CommentDeleted,
+	ConfigChanged,
+}
+
+// Event - изменение задачи. Task содержит состояние задачи после изменения
+// (для удаления - последнее известное состояние). PreviousAssignees заполняется
+// только для task.reassigned. У событий комментариев Comment - сам комментарий,
+// а Mentioned - пользователи, впервые упомянутые в нем. config.changed не относится к задаче:
+// в нем заполнен только Config.
+type Event struct {
+	Type              string        `json:"event"`
+	TaskID            int           `json:"task_id"`
+	Task              *db.Task      `json:"task,omitempty"`
+	Comment           *db.Comment   `json:"comment,omitempty"`
+	PreviousAssignees []int         `json:"previous_assignees,omitempty"`
+	Mentioned         []int         `json:"mentioned,omitempty"`
+	Config            *ConfigChange `json:"config,omitempty"`
+	Workspace         string        `json:"workspace,omitempty"`
+	OccurredAt        time.Time     `json:"occurred_at"`
+}
+
+// ConfigChange - результат перечитывания настроек: новая версия, примененные настройки
+// и настройки, которые вступят в силу только после перезапуска.
+type ConfigChange struct {
+	Version         int64    `json:"version"`
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+func New(eventType string, task *db.Task) Event {
+	return Event{Type: eventType, TaskID: task.ID, Task: task, OccurredAt: time.Now().UTC()}
+}
+
+type Publisher interface {
+	Publish(event Event)
+}
+
+// Bus синхронно раздает события подписчикам. Подписчики не должны блокироваться надолго.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []func(Event)
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, fn)
+}
+
+func (b *Bus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, fn := range b.subscribers {
+		fn(event)
+	}
+}
@@ -0,0 +1,182 @@
+package events
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Envelope - событие с порядковым ID потока. ID имеет вид "<epoch>-<seq>":
+// epoch меняется при перезапуске, поэтому ID прошлого процесса не спутать с текущими.
+type Envelope struct {
+	ID    string
+	Seq   uint64
+	Event Event
+}
+
+// Filter отбирает события для подписчика. Пустые поля означают "все". ProjectIDs
+// сравниваются с проектом задачи после изменения: перенос в другой проект виден подписчикам
+// нового проекта. Allow дополнительно проверяет доступ подписчика к задаче события.
+type Filter struct {
+	Types      []string
+	TaskIDs    []int
+	ProjectIDs []int
+	Allow      func(Event) bool
+}
+
+func (f Filter) Match(event Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return false
+	}
+	if len(f.TaskIDs) > 0 && !slices.Contains(f.TaskIDs, event.TaskID) {
+		return false
+	}
+	if len(f.ProjectIDs) > 0 && (event.Task == nil || !slices.Contains(f.ProjectIDs, event.Task.ProjectID)) {
+		return false
+	}
+	if f.Allow != nil && !f.Allow(event) {
+		return false
+	}
+	return true
+}
+
+// Subscription получает события из C. Канал закрывается при отписке, остановке хаба
+// или если подписчик не успевает читать; в последнем случае Dropped возвращает true.
+type Subscription struct {
+	C       chan Envelope
+	filter  Filter
+	dropped bool
+}
+
+func (s *Subscription) Dropped() bool {
+	return s.dropped
+}
+
+// Hub раздает события подписчикам и хранит последние события для возобновления потока.
+type Hub struct {
+	mu          sync.Mutex
+	epoch       string
+	seq         uint64
+	buffer      []Envelope
+	size        int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewHub(size int) *Hub {
+	return &Hub{
+		epoch:       strconv.FormatInt(time.Now().Unix(), 36),
+		size:        size,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+const subscriberBuffer = 64
+
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.seq++
+	envelope := Envelope{ID: fmt.Sprintf("%s-%d", h.epoch, h.seq), Seq: h.seq, Event: event}
+	h.buffer = append(h.buffer, envelope)
+	if len(h.buffer) > h.size {
+		h.buffer = slices.Delete(h.buffer, 0, len(h.buffer)-h.size)
+	}
+
+	for sub := range h.subscribers {
+		if !sub.filter.Match(event) {
+			continue
+		}
+		select {
+		case sub.C <- envelope:
+		default:
+			// Медленный клиент отключается и переподключится с Last-Event-ID
+			sub.dropped = true
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribe подписывает на новые события. Если lastEventID задан, возвращает пропущенные
+// с тех пор события; complete=false означает, что часть из них уже вытеснена из буфера
+// (или ID от прошлого процесса) и клиенту нужно перечитать состояние целиком.
+func (h *Hub) Subscribe(lastEventID string, filter Filter) (sub *Subscription, replay []Envelope, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub = &Subscription{C: make(chan Envelope, subscriberBuffer), filter: filter}
+	if h.closed {
+		close(sub.C)
+		return sub, nil, true
+	}
+	h.subscribers[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil, true
+	}
+
+	seq, ok := h.parseID(lastEventID)
+	if !ok || seq > h.seq {
+		return sub, h.matching(h.buffer, filter), false
+	}
+
+	complete = len(h.buffer) == 0 || h.buffer[0].Seq <= seq+1
+	for i, envelope := range h.buffer {
+		if envelope.Seq > seq {
+			return sub, h.matching(h.buffer[i:], filter), complete
+		}
+	}
+
+	return sub, nil, complete
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+// Close отключает всех подписчиков. Вызывается при остановке сервера.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subscribers {
+		h.remove(sub)
+	}
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.C)
+	}
+}
+
+func (h *Hub) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != h.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
+
+func (h *Hub) matching(envelopes []Envelope, filter Filter) []Envelope {
+	var result []Envelope
+	for _, envelope := range envelopes {
+		if filter.Match(envelope.Event) {
+			result = append(result, envelope)
+		}
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"testing"
+	"todo/internal/db"
+)
+
+func publishN(h *Hub, n int) {
+	for i := 1; i <= n; i++ {
+		h.Publish(New(TaskUpdated, &db.Task{ID: i}))
+	}
+}
+
+func TestHubResume(t *testing.T) {
+	h := NewHub(3)
+	publishN(h, 4)
+
+	// Буфер хранит события 2..4, клиент видел 2 - пропусков нет
+	sub, replay, complete := h.Subscribe(h.epoch+"-2", Filter{})
+	defer h.Unsubscribe(sub)
+	if !complete || len(replay) != 2 || replay[0].Seq != 3 || replay[1].Seq != 4 {
+		t.Errorf("unexpected replay: complete=%v %+v", complete, replay)
+	}
+
+	// Событие 1 уже вытеснено из буфера
+	_, replay, complete = h.Subscribe(h.epoch+"-0", Filter{})
+	if complete || len(replay) != 3 {
+		t.Errorf("expected incomplete replay of the whole buffer, got complete=%v len=%d", complete, len(replay))
+	}
+
+	// ID прошлого процесса
+	_, _, complete = h.Subscribe("other-4", Filter{})
+	if complete {
+		t.Error("expected incomplete replay for foreign epoch")
+	}
+}
+
+func TestHubFilterAndSlowSubscriber(t *testing.T) {
+	h := NewHub(10)
+	sub, _, _ := h.Subscribe("", Filter{TaskIDs: []int{2}})
+
+	publishN(h, 3)
+	if got := <-sub.C; got.Event.TaskID != 2 || len(sub.C) != 0 {
+		t.Errorf("filter passed unexpected events: %+v, %d buffered", got, len(sub.C))
+	}
+
+	slow, _, _ := h.Subscribe("", Filter{})
+	for i := 0; i <= subscriberBuffer; i++ {
+		h.Publish(New(TaskCreated, &db.Task{ID: 1}))
+	}
+	for range slow.C {
+	}
+	if !slow.Dropped() {
+		t.Error("slow subscriber was not dropped")
+	}
+
+	h.Close()
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription was not closed by Close")
+	}
+}
+
+func TestFilterAllow(t *testing.T) {
+	filter := Filter{Allow: func(event Event) bool { return event.Task != nil && event.Task.OwnerID == 7 }}
+	if !filter.Match(New(TaskCreated, &db.Task{ID: 1, OwnerID: 7})) {
+		t.Error("own task filtered out")
+	}
+	if filter.Match(New(TaskCreated, &db.Task{ID: 2, OwnerID: 8})) || filter.Match(New(TaskCreated, &db.Task{ID: 3})) {
+		t.Error("foreign task passed allow filter")
+	}
+}
+
+func TestFilterProjects(t *testing.T) {
+	filter := Filter{ProjectIDs: []int{3}}
+	if !filter.Match(New(TaskUpdated, &db.Task{ID: 1, ProjectID: 3})) {
+		t.Error("project task filtered out")
+	}
+	if filter.Match(New(TaskUpdated, &db.Task{ID: 2, ProjectID: 4})) || filter.Match(New(TaskUpdated, &db.Task{ID: 3})) ||
+		filter.Match(Event{Type: ConfigChanged}) {
+		t.Error("event outside the project passed the filter")
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"todo/internal/db"
+	"todo/internal/storage"
+	"todo/pkg/config"
+	"unicode"
+)
+
+const (
+	// multipartOverhead - запас на заголовки частей multipart сверх размера файла
+	multipartOverhead = 64 << 10
+	maxFilenameLength = 255
+)
+
+// AttachmentHandler принимает и отдает вложения задач. Права проверяет authz через Handler.
+type AttachmentHandler struct {
+	handler *Handler
+	store   *storage.Store
+	maxSize int64
+	quota   int64
+}
+
+func NewAttachmentHandler(handler *Handler, store *storage.Store, maxSize int64, quota int64) *AttachmentHandler {
+	return &AttachmentHandler{handler: handler, store: store, maxSize: maxSize, quota: quota}
+}
+
+// AttachmentHandlerFromConfig берет из секции attachments предельный размер файла и квоту
+// пространства по умолчанию, 0 снимает ограничение.
+func AttachmentHandlerFromConfig(handler *Handler, store *storage.Store, cfg config.Attachments) *AttachmentHandler {
+	return NewAttachmentHandler(handler, store, int64(cfg.MaxSize), int64(cfg.Quota))
+}
+
+// cleanFilename оставляет от имени файла клиента только последний элемент пути без управляющих символов.
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	if name == "" || name == "." || name == "/" || name == ".." {
+		return "file"
+	}
+
+	if len(name) > maxFilenameLength {
+		name = strings.ToValidUTF8(name[:maxFilenameLength], "")
+	}
+	return name
+}
+
+// uploadLimit возвращает наибольший размер файла, который можно загрузить в пространство из ctx,
+// и признак того, что его ограничивает квота, а не ATTACHMENTS_MAX_SIZE.
+func (h *AttachmentHandler) uploadLimit(ctx context.Context) (int64, bool, error) {
+	quota := h.quota
+	if workspace, ok := db.WorkspaceFromContext(ctx); ok && workspace.StorageQuota > 0 {
+		quota = workspace.StorageQuota
+	}
+	if quota == 0 {
+		return h.maxSize, false, nil
+	}
+
+	usage, err := h.handler.attachments.GetStorageUsage(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	if remaining := quota - usage; remaining < h.maxSize {
+		return remaining, true, nil
+	}
+	return h.maxSize, false, nil
+}
+
+func (h *AttachmentHandler) HandleTaskAttachments(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.getAttachments(w, r, taskID)
+	case "POST":
+		h.uploadAttachment(w, r, taskID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *AttachmentHandler) HandleTaskAttachment(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	attachmentID, err := strconv.Atoi(r.PathValue("attachmentID"))
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET", "HEAD":
+		h.downloadAttachment(w, r, taskID, attachmentID)
+	case "DELETE":
+		h.deleteAttachment(w, r, taskID, attachmentID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /tasks/{id}/attachments - Вложения задачи
+func (h *AttachmentHandler) getAttachments(w http.ResponseWriter, r *http.Request, taskID int) {
+	attachments, err := h.handler.attachments.GetAttachments(r.Context(), taskID)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve attachments", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, attachments)
+}
+
+// POST /tasks/{id}/attachments - Загрузить файл из части file формы multipart/form-data.
+// Файл пишется на диск по мере чтения, не накапливаясь в памяти.
+func (h *AttachmentHandler) uploadAttachment(w http.ResponseWriter, r *http.Request, taskID int) {
+	ctx := r.Context()
+
+	// Недоступная задача отвечает 404 до чтения файла
+	if _, err := h.handler.repo.GetTaskById(ctx, taskID); err != nil {
+		writeTaskError(w, "Failed to upload attachment", err)
+		return
+	}
+
+	limit, byQuota, err := h.uploadLimit(ctx)
+	if err != nil {
+		writeTaskError(w, "Failed to upload attachment", err)
+		return
+	}
+	if limit <= 0 {
+		http.Error(w, "Storage quota exceeded", http.StatusInsufficientStorage)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, limit+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var part io.Reader
+	var filename string
+	for part == nil {
+		next, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			http.Error(w, "Validation error: file part is required", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if next.FormName() == "file" && next.FileName() != "" {
+			part, filename = next, next.FileName()
+		}
+	}
+
+	blob, err := h.store.Put(part, limit)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, storage.ErrTooLarge) && byQuota:
+		http.Error(w, "Storage quota exceeded", http.StatusInsufficientStorage)
+		return
+	case errors.Is(err, storage.ErrTooLarge), errors.As(err, &maxBytesErr):
+		http.Error(w, fmt.Sprintf("File is too large, max %d bytes", h.maxSize), http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to upload attachment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	attachment, err := h.handler.attachments.CreateAttachment(ctx, &db.Attachment{
+		TaskID:      taskID,
+		Filename:    cleanFilename(filename),
+		Size:        blob.Size,
+		ContentType: blob.ContentType,
+		Hash:        blob.Hash,
+	})
+	if err != nil {
+		h.store.Release(blob.Hash)
+		writeTaskError(w, "Failed to upload attachment", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, attachment)
+}
+
+// GET /tasks/{id}/attachments/{attachmentID} - Скачать файл, в том числе по частям (Range)
+func (h *AttachmentHandler) downloadAttachment(w http.ResponseWriter, r *http.Request, taskID int, attachmentID int) {
+	attachment, err := h.handler.attachments.GetAttachment(r.Context(), taskID, attachmentID)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve attachment", err)
+		return
+	}
+
+	file, err := h.store.Open(attachment.Hash)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to open attachment: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("ETag", `"`+attachment.Hash+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	modified, _ := time.ParseInLocation("2006-01-02 15:04:05", attachment.CreatedAt, time.Local)
+	http.ServeContent(w, r, attachment.Filename, modified, file)
+}
+
+// DELETE /tasks/{id}/attachments/{attachmentID} - Удалить вложение
+func (h *AttachmentHandler) deleteAttachment(w http.ResponseWriter, r *http.Request, taskID int, attachmentID int) {
+	count, err := h.handler.attachments.DeleteAttachment(r.Context(), taskID, attachmentID)
+	if err != nil {
+		writeTaskError(w, "Failed to delete attachment", err)
+		return
+	}
+
+	if count > 0 {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
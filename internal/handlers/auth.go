@@ -0,0 +1,610 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"todo/internal/auth"
+	"todo/internal/db"
+	"todo/internal/logging"
+	"todo/internal/tenant"
+	"todo/pkg/config"
+	"todo/pkg/jwt"
+)
+
+const (
+	defaultSessionTTL = 24 * time.Hour
+	minPasswordLength = 8
+)
+
+// dummyPasswordHash - хеш, с которым сверяется пароль неизвестного пользователя при входе.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("dummy-password")
+	return hash
+})
+
+type CredentialsInput struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LoginResponse struct {
+	Token     string   `json:"token"`
+	ExpiresAt string   `json:"expires_at"`
+	User      *db.User `json:"user"`
+}
+
+type TokenResponse struct {
+	Token     string `json:"token"`
+	TokenType string `json:"token_type"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type RoleInput struct {
+	Role string `json:"role"`
+}
+
+type APIKeyInput struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// AuthHandler регистрирует пользователей, выдает сессии и API-ключи
+// и определяет принципала запроса.
+type AuthHandler struct {
+	users       db.UserRepo
+	sessionTTL  time.Duration
+	allowSignup bool
+	now         func() time.Time
+
+	tokens         *jwt.Validator
+	tokenTTL       time.Duration
+	reloadInterval time.Duration
+}
+
+func NewAuthHandler(users db.UserRepo, sessionTTL time.Duration, allowSignup bool) *AuthHandler {
+	return &AuthHandler{users: users, sessionTTL: sessionTTL, allowSignup: allowSignup, now: time.Now}
+}
+
+// AuthHandlerFromConfig применяет секцию auth и, если задан каталог ключей, настройки JWT.
+func AuthHandlerFromConfig(users db.UserRepo, cfg config.Auth, jwtConfig config.JWT) (*AuthHandler, error) {
+	h := NewAuthHandler(users, cfg.SessionTTL, cfg.AllowSignup)
+
+	if jwtConfig.KeysDir != "" {
+		keys, err := jwt.LoadDir(jwtConfig.KeysDir, jwtConfig.SigningKey)
+		if err != nil {
+			return nil, err
+		}
+		h.SetJWT(&jwt.Validator{
+			Keys:     keys,
+			Issuer:   jwtConfig.Issuer,
+			Audience: jwtConfig.Audience,
+			Skew:     jwtConfig.ClockSkew,
+		}, jwtConfig.TTL, jwtConfig.ReloadInterval)
+	}
+
+	return h, nil
+}
+
+// SetJWT включает прием JWT. Выпуск токенов доступен, если в наборе выбран ключ подписи.
+func (h *AuthHandler) SetJWT(validator *jwt.Validator, ttl time.Duration, reloadInterval time.Duration) {
+	if validator.Now == nil {
+		validator.Now = func() time.Time { return h.now() }
+	}
+	h.tokens = validator
+	h.tokenTTL = ttl
+	h.reloadInterval = reloadInterval
+}
+
+// WatchKeys перечитывает каталог ключей JWT до отмены ctx, чтобы ключи можно было
+// менять без перезапуска.
+func (h *AuthHandler) WatchKeys(ctx context.Context) {
+	if h.tokens == nil || h.reloadInterval <= 0 {
+		return
+	}
+
+	h.tokens.Keys.Watch(ctx, h.reloadInterval, func(changed bool, err error) {
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to reload JWT keys", "error", err)
+		} else if changed {
+			logging.FromContext(ctx).Info("JWT keys reloaded", "keys", strings.Join(h.tokens.Keys.KeyIDs(), ", "))
+		}
+	})
+}
+
+func validateCredentials(input *CredentialsInput) error {
+	input.Username = strings.TrimSpace(input.Username)
+	if input.Username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if len(input.Password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+func validateAPIKeyInput(input *APIKeyInput) error {
+	if strings.TrimSpace(input.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(input.Scopes) == 0 {
+		input.Scopes = []string{auth.ScopeTasksRead, auth.ScopeTasksWrite}
+	}
+	for _, scope := range input.Scopes {
+		if !slices.Contains(auth.Scopes, scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// EnsureAdmin создает администратора, если пользователя с таким именем еще нет.
+func (h *AuthHandler) EnsureAdmin(username string, password string) error {
+	if _, err := h.users.GetUserByUsername(username); !errors.Is(err, db.ErrUserNotFound) {
+		return err
+	}
+
+	_, err := h.createUser(&CredentialsInput{Username: username, Password: password}, auth.RoleAdmin)
+	return err
+}
+
+func (h *AuthHandler) createUser(input *CredentialsInput, role string) (*db.User, error) {
+	hash, err := auth.HashPassword(input.Password)
+	if err != nil {
+		return nil, err
+	}
+	return h.users.CreateUser(&db.User{Username: input.Username, PasswordHash: hash, Role: role})
+}
+
+// bearerToken извлекает токен из Authorization или X-API-Key. Для /events и /ws
+// допускается параметр access_token: EventSource и WebSocket в браузере не умеют слать заголовки.
+func bearerToken(r *http.Request) string {
+	if value := r.Header.Get("Authorization"); value != "" {
+		scheme, token, ok := strings.Cut(value, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if value := r.Header.Get("X-API-Key"); value != "" {
+		return value
+	}
+	if r.URL.Path == "/events" || r.URL.Path == "/ws" {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
+
+// Authenticate возвращает принципала запроса. Токены из трех частей через точку считаются JWT
+// (если JWT настроен), с префиксом lwo_ - API-ключами, остальные - токенами сессий.
+func (h *AuthHandler) Authenticate(r *http.Request) (*auth.Principal, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, auth.ErrUnauthenticated
+	}
+
+	if h.tokens != nil && jwt.LooksLikeJWT(token) {
+		return h.authenticateJWT(token)
+	}
+
+	now := h.now().Format("2006-01-02 15:04:05")
+	if auth.IsAPIKey(token) {
+		return h.users.AuthenticateAPIKey(auth.HashToken(token), now)
+	}
+	return h.users.AuthenticateSession(auth.HashToken(token), now)
+}
+
+// authenticateJWT сопоставляет claims с пользователем: sub - ID или имя пользователя,
+// role заменяет роль из базы, scope ограничивает права так же, как у API-ключа.
+func (h *AuthHandler) authenticateJWT(token string) (*auth.Principal, error) {
+	claims, err := h.tokens.Parse(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", auth.ErrInvalidCredentials, err)
+	}
+
+	var user *db.User
+	if id, convErr := strconv.Atoi(claims.Subject); convErr == nil {
+		user, err = h.users.GetUserById(id)
+	} else {
+		user, err = h.users.GetUserByUsername(claims.Subject)
+	}
+	if errors.Is(err, db.ErrUserNotFound) {
+		return nil, fmt.Errorf("%w: unknown subject %q", auth.ErrInvalidCredentials, claims.Subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	principal := &auth.Principal{UserID: user.ID, Username: user.Username, Role: user.Role, Via: "jwt"}
+	if claims.Role != "" {
+		if !slices.Contains(auth.Roles, claims.Role) {
+			return nil, fmt.Errorf("%w: unknown role %q", auth.ErrInvalidCredentials, claims.Role)
+		}
+		principal.Role = claims.Role
+	}
+	principal.Workspace = claims.Workspace
+
+	if claims.Scope != "" {
+		for _, scope := range strings.Fields(claims.Scope) {
+			if slices.Contains(auth.Scopes, scope) {
+				principal.Scopes = append(principal.Scopes, scope)
+			}
+		}
+		// Пустой список означает "все права", поэтому токен без известных прав отклоняем
+		if len(principal.Scopes) == 0 {
+			return nil, fmt.Errorf("%w: token grants no known scopes", auth.ErrInvalidCredentials)
+		}
+	}
+
+	return principal, nil
+}
+
+func (h *AuthHandler) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.register(w, r)
+}
+
+func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.login(w, r)
+}
+
+func (h *AuthHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.logout(w, r)
+}
+
+func (h *AuthHandler) HandleMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, principal)
+}
+
+// GET /.well-known/jwks.json - Публичные ключи, которыми сервер подписывает JWT
+func (h *AuthHandler) HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	set := jwt.JWKS{Keys: []jwt.JWK{}}
+	if h.tokens != nil {
+		set = h.tokens.Keys.JWKS()
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	writeJSON(w, http.StatusOK, set)
+}
+
+// POST /auth/token - Выпустить JWT для текущего пользователя
+func (h *AuthHandler) HandleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	principal, ok := sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	if h.tokens == nil || h.tokens.Keys.SigningKey() == nil {
+		http.Error(w, "JWT issuing is not configured", http.StatusNotImplemented)
+		return
+	}
+
+	now := h.now()
+	claims := &jwt.Claims{
+		Issuer:    h.tokens.Issuer,
+		Subject:   strconv.Itoa(principal.UserID),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(h.tokenTTL).Unix(),
+		Username:  principal.Username,
+		Role:      principal.Role,
+	}
+	// Токен, выпущенный с X-Workspace, действует только в этом пространстве.
+	// Участие в пространстве проверяется при каждом запросе, а не здесь.
+	if workspace := strings.ToLower(r.Header.Get(tenant.Header)); workspace != "" {
+		if !tenant.ValidSlug(workspace) {
+			http.Error(w, "Validation error: invalid workspace", http.StatusBadRequest)
+			return
+		}
+		claims.Workspace = workspace
+	}
+	if h.tokens.Audience != "" {
+		claims.Audience = jwt.Audience{h.tokens.Audience}
+	}
+
+	token, err := h.tokens.Keys.Sign(claims)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to issue token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TokenResponse{Token: token, TokenType: "Bearer", ExpiresAt: now.Add(h.tokenTTL).Format("2006-01-02 15:04:05")})
+}
+
+func (h *AuthHandler) HandleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.getAPIKeys(w, r)
+	case "POST":
+		h.createAPIKey(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *AuthHandler) HandleAPIKeyByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "DELETE" {
+		h.revokeAPIKey(w, r, id)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// POST /auth/register - Зарегистрироваться с ролью editor. Регистрация открыта только при
+// AUTH_ALLOW_SIGNUP=true; администратор создается при старте из AUTH_ADMIN_USERNAME и AUTH_ADMIN_PASSWORD.
+func (h *AuthHandler) register(w http.ResponseWriter, r *http.Request) {
+	var input CredentialsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateCredentials(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if !h.allowSignup {
+		http.Error(w, "Registration is disabled", http.StatusForbidden)
+		return
+	}
+
+	user, err := h.createUser(&input, auth.RoleEditor)
+	if errors.Is(err, db.ErrUserExists) {
+		http.Error(w, "Username is already taken", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to register: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// POST /auth/login - Получить токен сессии
+func (h *AuthHandler) login(w http.ResponseWriter, r *http.Request) {
+	var input CredentialsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.users.GetUserByUsername(strings.TrimSpace(input.Username))
+	if err != nil && !errors.Is(err, db.ErrUserNotFound) {
+		http.Error(w, fmt.Sprintf("Failed to login: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// Для неизвестного имени пароль все равно сверяется с bcrypt-хешем, иначе по времени
+	// ответа можно было бы перебирать существующие имена
+	hash := dummyPasswordHash()
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if !auth.CheckPassword(hash, input.Password) || user == nil {
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := auth.NewSessionToken()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to login: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	expiresAt := h.now().Add(h.sessionTTL).Format("2006-01-02 15:04:05")
+	if err := h.users.CreateSession(auth.HashToken(token), user.ID, expiresAt); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to login: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, LoginResponse{Token: token, ExpiresAt: expiresAt, User: user})
+}
+
+// POST /auth/logout - Завершить текущую сессию
+func (h *AuthHandler) logout(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if token == "" || auth.IsAPIKey(token) {
+		http.Error(w, "Session token required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.users.DeleteSession(auth.HashToken(token)); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to logout: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionPrincipal возвращает принципала, вошедшего по паролю. Управлять ключами и выпускать
+// JWT по API-ключу или JWT нельзя, иначе токен с узкими правами мог бы выпустить себе более широкий.
+func sessionPrincipal(w http.ResponseWriter, r *http.Request) (*auth.Principal, bool) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	if principal.Via != "session" {
+		http.Error(w, "Session token required", http.StatusForbidden)
+		return nil, false
+	}
+	return principal, true
+}
+
+// GET /auth/api-keys - Получить свои API-ключи
+func (h *AuthHandler) getAPIKeys(w http.ResponseWriter, r *http.Request) {
+	principal, ok := sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.users.GetAPIKeys(principal.UserID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to retrieve API keys: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// POST /auth/api-keys - Выпустить API-ключ. Ключ возвращается только в этом ответе.
+func (h *AuthHandler) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	principal, ok := sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	var input APIKeyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateAPIKeyInput(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if slices.Contains(input.Scopes, auth.ScopeAdmin) && !principal.IsAdmin() {
+		http.Error(w, "Only administrators can issue admin keys", http.StatusForbidden)
+		return
+	}
+
+	key, prefix, err := auth.NewAPIKey()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create API key: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	created, err := h.users.CreateAPIKey(&db.APIKey{UserID: principal.UserID, Name: input.Name, Prefix: prefix, Scopes: input.Scopes}, auth.HashToken(key))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create API key: %v", err), http.StatusInternalServerError)
+		return
+	}
+	created.Key = key
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// DELETE /auth/api-keys/{id} - Отозвать API-ключ
+func (h *AuthHandler) revokeAPIKey(w http.ResponseWriter, r *http.Request, id int) {
+	principal, ok := sessionPrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.users.RevokeAPIKey(principal.UserID, id, h.now().Format("2006-01-02 15:04:05"))
+	if errors.Is(err, db.ErrAPIKeyNotFound) {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to revoke API key: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GET /users - Список пользователей (только для администратора)
+func (h *AuthHandler) HandleUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	users, err := h.users.GetUsers()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to retrieve users: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, users)
+}
+
+// PATCH /users/{id} - Сменить роль пользователя (только для администратора)
+func (h *AuthHandler) HandleUserByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != "PATCH" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input RoleInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if !slices.Contains(auth.Roles, input.Role) {
+		http.Error(w, fmt.Sprintf("Validation error: unknown role %q", input.Role), http.StatusBadRequest)
+		return
+	}
+
+	err = h.users.SetUserRole(id, input.Role)
+	if errors.Is(err, db.ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.users.GetUserById(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"todo/internal/auth"
+	"todo/internal/authz"
+	"todo/internal/db"
+	"todo/pkg/jwt"
+	"todo/pkg/sqlite3"
+)
+
+func newAuthTestRepository(t *testing.T) *db.TaskRepository {
+	conn, err := sqlite3.NewConnector(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repository, err := db.NewTaskRepository(conn, db.DefaultWorkflow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repository
+}
+
+func postJSON(t *testing.T, handler http.HandlerFunc, token string, body any) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/auth", bytes.NewReader(data))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func login(t *testing.T, h *AuthHandler, username string, password string) string {
+	rr := postJSON(t, h.HandleLogin, "", CredentialsInput{Username: username, Password: password})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("login %s: status %d: %s", username, rr.Code, rr.Body.String())
+	}
+	var response LoginResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	return response.Token
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	repository := newAuthTestRepository(t)
+	h := NewAuthHandler(repository, defaultSessionTTL, false)
+
+	// При закрытой регистрации не зарегистрироваться и первому пользователю
+	if rr := postJSON(t, h.HandleRegister, "", CredentialsInput{Username: "alice", Password: "correct-horse"}); rr.Code != http.StatusForbidden {
+		t.Fatalf("signup should be closed, got %d", rr.Code)
+	}
+
+	// Первый зарегистрированный пользователь не становится администратором
+	open := NewAuthHandler(repository, defaultSessionTTL, true)
+	rr := postJSON(t, open.HandleRegister, "", CredentialsInput{Username: "alice", Password: "correct-horse"})
+	var user db.User
+	json.NewDecoder(rr.Body).Decode(&user)
+	if rr.Code != http.StatusCreated || user.Role != auth.RoleEditor {
+		t.Fatalf("registered user should be an editor: %d %+v", rr.Code, user)
+	}
+
+	for _, credentials := range []CredentialsInput{{"alice", "wrong-password"}, {"nobody", "correct-horse"}} {
+		if rr := postJSON(t, h.HandleLogin, "", credentials); rr.Code != http.StatusUnauthorized || rr.Body.String() != "Invalid username or password\n" {
+			t.Errorf("login %s accepted: %d %q", credentials.Username, rr.Code, rr.Body.String())
+		}
+	}
+
+	token := login(t, h, "alice", "correct-horse")
+	req := httptest.NewRequest("GET", "/tasks", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	principal, err := h.Authenticate(req)
+	if err != nil || principal.Username != "alice" || principal.Via != "session" {
+		t.Fatalf("session not accepted: %+v %v", principal, err)
+	}
+
+	if rr := postJSON(t, h.HandleLogout, token, nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("logout: %d", rr.Code)
+	}
+	if _, err := h.Authenticate(req); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("session still valid after logout: %v", err)
+	}
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	repository := newAuthTestRepository(t)
+	h := NewAuthHandler(repository, defaultSessionTTL, true)
+	if err := h.EnsureAdmin("admin", "admin-password"); err != nil {
+		t.Fatal(err)
+	}
+	postJSON(t, h.HandleRegister, "", CredentialsInput{Username: "bob", Password: "bob-password"})
+
+	session, err := repository.AuthenticateSession(auth.HashToken(login(t, h, "bob", "bob-password")), "2000-01-01 00:00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(principal *auth.Principal, input APIKeyInput) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(input)
+		req := httptest.NewRequest("POST", "/auth/api-keys", bytes.NewReader(data))
+		rr := httptest.NewRecorder()
+		h.HandleAPIKeys(rr, req.WithContext(auth.WithPrincipal(req.Context(), principal)))
+		return rr
+	}
+
+	if rr := create(session, APIKeyInput{Name: "ci", Scopes: []string{auth.ScopeAdmin}}); rr.Code != http.StatusForbidden {
+		t.Errorf("non-admin issued admin key: %d", rr.Code)
+	}
+
+	rr := create(session, APIKeyInput{Name: "ci", Scopes: []string{auth.ScopeTasksRead}})
+	var key db.APIKey
+	json.NewDecoder(rr.Body).Decode(&key)
+	if rr.Code != http.StatusCreated || !auth.IsAPIKey(key.Key) {
+		t.Fatalf("create key: %d %+v", rr.Code, key)
+	}
+
+	req := httptest.NewRequest("GET", "/tasks", nil)
+	req.Header.Set("X-API-Key", key.Key)
+	principal, err := h.Authenticate(req)
+	if err != nil || principal.Username != "bob" || principal.HasScope(auth.ScopeTasksWrite) {
+		t.Fatalf("unexpected key principal: %+v %v", principal, err)
+	}
+
+	if rr := create(principal, APIKeyInput{Name: "escalate"}); rr.Code != http.StatusForbidden {
+		t.Errorf("API key was able to issue another key: %d", rr.Code)
+	}
+
+	if err := repository.RevokeAPIKey(session.UserID, key.ID, "2000-01-01 00:00:00"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Authenticate(req); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("revoked key still valid: %v", err)
+	}
+}
+
+func TestTasksScopedToOwner(t *testing.T) {
+	repository := authz.New(newAuthTestRepository(t))
+	handler := &Handler{repo: repository}
+	alice := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: 1, Username: "alice", Role: auth.RoleEditor})
+	bob := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: 2, Username: "bob", Role: auth.RoleEditor})
+
+	title, dueDate := "Private", "2099-01-01"
+	task, err := handler.CreateTask(alice, &db.TaskInput{Title: &title, DueDate: &dueDate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tasks, _ := repository.GetAllTasks(bob, nil); len(tasks) != 0 {
+		t.Errorf("bob sees foreign tasks: %+v", tasks)
+	}
+	if _, err := handler.CompleteTask(bob, task.ID); !errors.Is(err, db.ErrTaskNotFound) {
+		t.Errorf("bob completed foreign task: %v", err)
+	}
+	if deleted, _ := handler.DeleteTask(bob, task.ID); deleted {
+		t.Error("bob deleted foreign task")
+	}
+
+	if tasks, _ := repository.GetAllTasks(alice, nil); len(tasks) != 1 {
+		t.Errorf("alice should see her task, got %d", len(tasks))
+	}
+	if tasks, _ := repository.GetAllTasks(auth.SystemContext(context.Background()), nil); len(tasks) != 1 {
+		t.Errorf("system should see all tasks, got %d", len(tasks))
+	}
+	if _, err := repository.GetAllTasks(context.Background(), nil); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("anonymous access allowed: %v", err)
+	}
+}
+
+func writePEM(t *testing.T, path string, blockType string, der []byte) {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	repository := newAuthTestRepository(t)
+	h := NewAuthHandler(repository, defaultSessionTTL, true)
+	postJSON(t, h.HandleRegister, "", CredentialsInput{Username: "root", Password: "root-password"})
+	postJSON(t, h.HandleRegister, "", CredentialsInput{Username: "svc", Password: "svc-password"})
+
+	dir := t.TempDir()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	writePEM(t, filepath.Join(dir, "rsa-1.pem"), "PRIVATE KEY", der)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ = x509.MarshalPKCS8PrivateKey(edKey)
+	writePEM(t, filepath.Join(dir, "ed-1.pem"), "PRIVATE KEY", der)
+	os.WriteFile(filepath.Join(dir, "shared.secret"), []byte("0123456789abcdef0123456789abcdef\n"), 0o600)
+
+	keys, err := jwt.LoadDir(dir, "rsa-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+	h.SetJWT(&jwt.Validator{Keys: keys, Issuer: "lwo", Audience: "tasks", Skew: time.Minute}, time.Hour, 0)
+
+	authenticate := func(token string) (*auth.Principal, error) {
+		req := httptest.NewRequest("GET", "/tasks", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return h.Authenticate(req)
+	}
+	claims := func() *jwt.Claims {
+		return &jwt.Claims{Issuer: "lwo", Audience: jwt.Audience{"tasks"}, Subject: "svc", ExpiresAt: now.Add(time.Minute).Unix()}
+	}
+
+	for _, kid := range []string{"rsa-1", "ed-1", "shared"} {
+		token, err := jwt.NewKeySet(kid, mustKey(t, keys, kid)).Sign(claims())
+		if err != nil {
+			t.Fatal(err)
+		}
+		principal, err := authenticate(token)
+		if err != nil || principal.Username != "svc" || principal.Role != auth.RoleEditor || principal.Via != "jwt" {
+			t.Errorf("%s: unexpected principal %+v, %v", kid, principal, err)
+		}
+	}
+
+	cases := map[string]func(c *jwt.Claims){
+		"expired":        func(c *jwt.Claims) { c.ExpiresAt = now.Add(-2 * time.Minute).Unix() },
+		"wrong audience": func(c *jwt.Claims) { c.Audience = jwt.Audience{"billing"} },
+		"wrong issuer":   func(c *jwt.Claims) { c.Issuer = "other" },
+		"unknown user":   func(c *jwt.Claims) { c.Subject = "ghost" },
+		"unknown scope":  func(c *jwt.Claims) { c.Scope = "openid" },
+	}
+	for name, mutate := range cases {
+		c := claims()
+		mutate(c)
+		token, _ := keys.Sign(c)
+		if _, err := authenticate(token); !errors.Is(err, auth.ErrInvalidCredentials) {
+			t.Errorf("%s: token accepted: %v", name, err)
+		}
+	}
+
+	// Расхождение часов в пределах Skew допустимо, role и scope берутся из токена
+	c := claims()
+	c.ExpiresAt = now.Add(-30 * time.Second).Unix()
+	c.Role, c.Scope = auth.RoleAdmin, "openid tasks:read"
+	token, _ := keys.Sign(c)
+	principal, err := authenticate(token)
+	if err != nil || !principal.IsAdmin() || principal.HasScope(auth.ScopeTasksWrite) || !principal.HasScope(auth.ScopeTasksRead) {
+		t.Errorf("claims not mapped: %+v, %v", principal, err)
+	}
+
+	// JWKS содержит только собственные асимметричные ключи
+	rr := httptest.NewRecorder()
+	h.HandleJWKS(rr, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	var set jwt.JWKS
+	json.NewDecoder(rr.Body).Decode(&set)
+	if len(set.Keys) != 2 || set.Keys[0].Kid != "ed-1" || set.Keys[0].Kty != "OKP" || set.Keys[1].Kty != "RSA" {
+		t.Errorf("unexpected JWKS: %+v", set)
+	}
+
+	// Ротация: старый ключ удален из каталога - выпущенные им токены больше не принимаются
+	oldToken, _ := keys.Sign(claims())
+	os.Remove(filepath.Join(dir, "rsa-1.pem"))
+	der, _ = x509.MarshalPKCS8PrivateKey(rsaKey)
+	writePEM(t, filepath.Join(dir, "rsa-2.pem"), "PRIVATE KEY", der)
+	if _, err := keys.Reload(); err == nil {
+		t.Error("reload without signing key should fail and keep the previous keys")
+	}
+	if _, err := authenticate(oldToken); err != nil {
+		t.Errorf("previous keys were dropped after failed reload: %v", err)
+	}
+
+	rotated, err := jwt.LoadDir(dir, "rsa-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetJWT(&jwt.Validator{Keys: rotated, Issuer: "lwo", Audience: "tasks", Skew: time.Minute}, time.Hour, 0)
+	if _, err := authenticate(oldToken); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("token signed by removed key accepted: %v", err)
+	}
+}
+
+func mustKey(t *testing.T, keys *jwt.KeySet, kid string) *jwt.Key {
+	for _, key := range keys.Keys() {
+		if key.ID == kid {
+			return key
+		}
+	}
+	t.Fatalf("key %s not loaded", kid)
+	return nil
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"todo/internal/db"
+	"todo/internal/events"
+)
+
+const maxChecklistItemLength = 500
+
+// ChecklistResponse - чек-лист после изменения. TaskStatus показывает, не завершилась ли задача
+// вместе с последним отмеченным пунктом.
+type ChecklistResponse struct {
+	Items      []*db.ChecklistItem   `json:"items"`
+	Progress   *db.ChecklistProgress `json:"checklist_progress"`
+	TaskStatus string                `json:"task_status"`
+}
+
+type ChecklistOrderInput struct {
+	IDs []int `json:"ids"`
+}
+
+func validateChecklistItemInput(input *db.ChecklistItemInput, create bool) error {
+	if input.Text == nil {
+		if create {
+			return fmt.Errorf("text is required")
+		}
+	} else if strings.TrimSpace(*input.Text) == "" {
+		return fmt.Errorf("text must not be empty")
+	} else if len(*input.Text) > maxChecklistItemLength {
+		return fmt.Errorf("text is too long, max %d bytes", maxChecklistItemLength)
+	}
+	if input.Position != nil && *input.Position < 0 {
+		return fmt.Errorf("position must not be negative")
+	}
+	if !create && input.Text == nil && input.Done == nil && input.Position == nil {
+		return fmt.Errorf("nothing to update")
+	}
+	return nil
+}
+
+// AddChecklistItem добавляет пункт в чек-лист задачи; без position - в конец.
+func (h *Handler) AddChecklistItem(ctx context.Context, taskID int, input *db.ChecklistItemInput) (*ChecklistResponse, error) {
+	if err := validateChecklistItemInput(input, true); err != nil {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+
+	item := &db.ChecklistItem{Text: strings.TrimSpace(*input.Text), Position: math.MaxInt32}
+	if input.Done != nil {
+		item.Done = *input.Done
+	}
+	if input.Position != nil {
+		item.Position = *input.Position
+	}
+
+	if _, err := h.checklists.AddChecklistItem(ctx, taskID, item); err != nil {
+		return nil, err
+	}
+	return h.checklistChanged(ctx, taskID)
+}
+
+// UpdateChecklistItem меняет текст, отметку или позицию пункта. Отсутствующие поля не меняются.
+func (h *Handler) UpdateChecklistItem(ctx context.Context, taskID int, id int, input *db.ChecklistItemInput) (*ChecklistResponse, error) {
+	if err := validateChecklistItemInput(input, false); err != nil {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+
+	items, err := h.checklists.GetChecklist(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	var item *db.ChecklistItem
+	for _, current := range items {
+		if current.ID == id {
+			item = current
+		}
+	}
+	if item == nil {
+		return nil, db.ErrChecklistItemNotFound
+	}
+
+	if input.Text != nil {
+		item.Text = strings.TrimSpace(*input.Text)
+	}
+	if input.Done != nil {
+		item.Done = *input.Done
+	}
+	if input.Position != nil {
+		item.Position = *input.Position
+	}
+
+	if err := h.checklists.UpdateChecklistItem(ctx, taskID, item); err != nil {
+		return nil, err
+	}
+	return h.checklistChanged(ctx, taskID)
+}
+
+// ReorderChecklist расставляет пункты в порядке ids.
+func (h *Handler) ReorderChecklist(ctx context.Context, taskID int, ids []int) (*ChecklistResponse, error) {
+	err := h.checklists.ReorderChecklist(ctx, taskID, ids)
+	if errors.Is(err, db.ErrChecklistOrder) {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.checklistChanged(ctx, taskID)
+}
+
+// DeleteChecklistItem удаляет пункт и возвращает nil, если его не было.
+func (h *Handler) DeleteChecklistItem(ctx context.Context, taskID int, id int) (*ChecklistResponse, error) {
+	count, err := h.checklists.DeleteChecklistItem(ctx, taskID, id)
+	if err != nil || count == 0 {
+		return nil, err
+	}
+	return h.checklistChanged(ctx, taskID)
+}
+
+// checklistChanged публикует изменение задачи. Если включен AutoCompleteChecklist и отмечены
+// все пункты, задача завершается так же, как через POST /tasks/{id}/complete.
+func (h *Handler) checklistChanged(ctx context.Context, taskID int) (*ChecklistResponse, error) {
+	task, err := h.repo.GetTaskById(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := task.ChecklistProgress
+	if h.AutoCompleteChecklist && !task.IsCompleted && progress != nil && progress.Done == progress.Total {
+		completed, err := h.CompleteTask(ctx, taskID)
+		switch {
+		case err == nil:
+			task = completed
+		case errors.Is(err, db.ErrTransitionDenied):
+			// Рабочий процесс проекта не пускает задачу в завершенный статус - оставляем как есть
+			h.publish(ctx, events.TaskUpdated, task)
+		default:
+			return nil, err
+		}
+	} else {
+		h.publish(ctx, events.TaskUpdated, task)
+	}
+
+	return newChecklistResponse(task), nil
+}
+
+func newChecklistResponse(task *db.Task) *ChecklistResponse {
+	response := &ChecklistResponse{Items: task.Checklist, Progress: task.ChecklistProgress, TaskStatus: task.Status}
+	if response.Items == nil {
+		response.Items = []*db.ChecklistItem{}
+		response.Progress = &db.ChecklistProgress{}
+	}
+	return response
+}
+
+func (h *Handler) HandleTaskChecklist(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.getChecklist(w, r, taskID)
+	case "POST":
+		h.addChecklistItem(w, r, taskID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) HandleTaskChecklistOrder(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "PUT" {
+		h.reorderChecklist(w, r, taskID)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) HandleTaskChecklistItem(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	itemID, err := strconv.Atoi(r.PathValue("itemID"))
+	if err != nil {
+		http.Error(w, "Invalid checklist item ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "PATCH":
+		h.updateChecklistItem(w, r, taskID, itemID)
+	case "DELETE":
+		h.deleteChecklistItem(w, r, taskID, itemID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /tasks/{id}/checklist - Пункты чек-листа и прогресс
+func (h *Handler) getChecklist(w http.ResponseWriter, r *http.Request, taskID int) {
+	task, err := h.repo.GetTaskById(r.Context(), taskID)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve checklist", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newChecklistResponse(task))
+}
+
+// POST /tasks/{id}/checklist - Добавить пункт; position необязательна
+func (h *Handler) addChecklistItem(w http.ResponseWriter, r *http.Request, taskID int) {
+	var input db.ChecklistItemInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.AddChecklistItem(r.Context(), taskID, &input)
+	if err != nil {
+		writeTaskError(w, "Failed to add checklist item", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// PATCH /tasks/{id}/checklist/{itemID} - Отметить пункт, изменить текст или переместить
+func (h *Handler) updateChecklistItem(w http.ResponseWriter, r *http.Request, taskID int, itemID int) {
+	var input db.ChecklistItemInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.UpdateChecklistItem(r.Context(), taskID, itemID, &input)
+	if err != nil {
+		writeTaskError(w, "Failed to update checklist item", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// PUT /tasks/{id}/checklist/order - Новый порядок пунктов: {"ids": [...]}
+func (h *Handler) reorderChecklist(w http.ResponseWriter, r *http.Request, taskID int) {
+	var input ChecklistOrderInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.ReorderChecklist(r.Context(), taskID, input.IDs)
+	if err != nil {
+		writeTaskError(w, "Failed to reorder checklist", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// DELETE /tasks/{id}/checklist/{itemID} - Удалить пункт
+func (h *Handler) deleteChecklistItem(w http.ResponseWriter, r *http.Request, taskID int, itemID int) {
+	response, err := h.DeleteChecklistItem(r.Context(), taskID, itemID)
+	if err != nil {
+		writeTaskError(w, "Failed to delete checklist item", err)
+		return
+	}
+
+	if response == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"todo/internal/db"
+	"todo/internal/events"
+	"todo/pkg/markdown"
+)
+
+const (
+	maxCommentLength       = 10000
+	defaultCommentsLimit   = 50
+	maxCommentsLimit       = 200
+	defaultIncludeComments = 10
+)
+
+// TaskWithComments - задача с последними комментариями для GET /tasks/{id}?include=comments.
+type TaskWithComments struct {
+	*db.Task
+	Comments []*db.Comment `json:"comments"`
+}
+
+func validateCommentInput(input *db.CommentInput) error {
+	if input.Body == nil || strings.TrimSpace(*input.Body) == "" {
+		return fmt.Errorf("body is required")
+	}
+	if len(*input.Body) > maxCommentLength {
+		return fmt.Errorf("body is too long, max %d bytes", maxCommentLength)
+	}
+	return nil
+}
+
+// queryInt читает целый параметр запроса из диапазона [low, high].
+func queryInt(r *http.Request, name string, fallback int, low int, high int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < low || parsed > high {
+		return 0, fmt.Errorf("invalid %s, expected %d..%d", name, low, high)
+	}
+	return parsed, nil
+}
+
+// mentionedUsers находит пользователей по упоминаниям @name. Неизвестные имена
+// остаются обычным текстом.
+func (h *Handler) mentionedUsers(body string) ([]int, error) {
+	var userIDs []int
+	for _, name := range markdown.Mentions(body) {
+		user, err := h.users.GetUserByUsername(name)
+		if errors.Is(err, db.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+	return userIDs, nil
+}
+
+// CreateComment добавляет комментарий к задаче от имени принципала из ctx.
+func (h *Handler) CreateComment(ctx context.Context, taskID int, input *db.CommentInput) (*db.Comment, error) {
+	if err := validateCommentInput(input); err != nil {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+
+	mentions, err := h.mentionedUsers(*input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := h.comments.CreateComment(ctx, &db.Comment{TaskID: taskID, Body: *input.Body, Mentions: mentions})
+	if err != nil {
+		return nil, err
+	}
+	h.publishComment(ctx, events.CommentCreated, comment, newMentions(comment, nil))
+
+	return comment, nil
+}
+
+// UpdateComment меняет текст комментария. Уведомления получают только впервые упомянутые.
+func (h *Handler) UpdateComment(ctx context.Context, taskID int, id int, input *db.CommentInput) (*db.Comment, error) {
+	if err := validateCommentInput(input); err != nil {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+
+	current, err := h.comments.GetComment(ctx, taskID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	mentions, err := h.mentionedUsers(*input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *current
+	updated.Body = *input.Body
+	updated.Mentions = mentions
+	if err := h.comments.UpdateComment(ctx, &updated); err != nil {
+		return nil, err
+	}
+	h.publishComment(ctx, events.CommentUpdated, &updated, newMentions(&updated, current.Mentions))
+
+	return &updated, nil
+}
+
+// DeleteComment помечает комментарий удаленным и возвращает false, если его не было.
+func (h *Handler) DeleteComment(ctx context.Context, taskID int, id int) (bool, error) {
+	count, err := h.comments.DeleteComment(ctx, taskID, id)
+	if err != nil || count == 0 {
+		return false, err
+	}
+
+	if comment, err := h.comments.GetComment(ctx, taskID, id); err == nil {
+		h.publishComment(ctx, events.CommentDeleted, comment, nil)
+	}
+	return true, nil
+}
+
+// newMentions возвращает упомянутых в comment, кроме автора и уже упомянутых ранее.
+func newMentions(comment *db.Comment, previous []int) []int {
+	var mentioned []int
+	for _, userID := range comment.Mentions {
+		if userID != comment.AuthorID && !slices.Contains(previous, userID) {
+			mentioned = append(mentioned, userID)
+		}
+	}
+	return mentioned
+}
+
+func (h *Handler) publishComment(ctx context.Context, eventType string, comment *db.Comment, mentioned []int) {
+	task, err := h.repo.GetTaskById(ctx, comment.TaskID)
+	if err != nil {
+		return
+	}
+
+	event := events.New(eventType, task)
+	event.Comment = comment
+	event.Mentioned = mentioned
+	h.publishEvent(ctx, event)
+}
+
+func (h *Handler) HandleTaskComments(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.getComments(w, r, taskID)
+	case "POST":
+		h.createComment(w, r, taskID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) HandleTaskComment(w http.ResponseWriter, r *http.Request) {
+	taskID, commentID, ok := commentPath(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.getComment(w, r, taskID, commentID)
+	case "PUT":
+		h.updateComment(w, r, taskID, commentID)
+	case "DELETE":
+		h.deleteComment(w, r, taskID, commentID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) HandleTaskCommentHistory(w http.ResponseWriter, r *http.Request) {
+	taskID, commentID, ok := commentPath(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == "GET" {
+		h.getCommentHistory(w, r, taskID, commentID)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func commentPath(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	commentID, err := strconv.Atoi(r.PathValue("commentID"))
+	if err != nil {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return taskID, commentID, true
+}
+
+// GET /tasks/{id}/comments?after=ID&limit=N - Комментарии задачи от старых к новым.
+// Если есть следующая страница, ссылка на нее передается в заголовке Link.
+func (h *Handler) getComments(w http.ResponseWriter, r *http.Request, taskID int) {
+	limit, err := queryInt(r, "limit", defaultCommentsLimit, 1, maxCommentsLimit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+	after, err := queryInt(r, "after", 0, 0, math.MaxInt32)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Лишний комментарий показывает, есть ли следующая страница
+	comments, err := h.comments.GetComments(r.Context(), taskID, after, limit+1)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve comments", err)
+		return
+	}
+
+	if len(comments) > limit {
+		comments = comments[:limit]
+		query := url.Values{}
+		query.Set("after", strconv.Itoa(comments[limit-1].ID))
+		query.Set("limit", strconv.Itoa(limit))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, query.Encode()))
+	}
+
+	writeJSON(w, http.StatusOK, comments)
+}
+
+// POST /tasks/{id}/comments - Добавить комментарий
+func (h *Handler) createComment(w http.ResponseWriter, r *http.Request, taskID int) {
+	var input db.CommentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	comment, err := h.CreateComment(r.Context(), taskID, &input)
+	if err != nil {
+		writeTaskError(w, "Failed to create comment", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+// GET /tasks/{id}/comments/{commentID} - Получить комментарий
+func (h *Handler) getComment(w http.ResponseWriter, r *http.Request, taskID int, commentID int) {
+	comment, err := h.comments.GetComment(r.Context(), taskID, commentID)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve comment", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comment)
+}
+
+// PUT /tasks/{id}/comments/{commentID} - Изменить текст комментария
+func (h *Handler) updateComment(w http.ResponseWriter, r *http.Request, taskID int, commentID int) {
+	var input db.CommentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	comment, err := h.UpdateComment(r.Context(), taskID, commentID, &input)
+	if err != nil {
+		writeTaskError(w, "Failed to update comment", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comment)
+}
+
+// DELETE /tasks/{id}/comments/{commentID} - Удалить комментарий
+func (h *Handler) deleteComment(w http.ResponseWriter, r *http.Request, taskID int, commentID int) {
+	deleted, err := h.DeleteComment(r.Context(), taskID, commentID)
+	if err != nil {
+		writeTaskError(w, "Failed to delete comment", err)
+		return
+	}
+
+	if deleted {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// GET /tasks/{id}/comments/{commentID}/history - Прежние версии текста комментария
+func (h *Handler) getCommentHistory(w http.ResponseWriter, r *http.Request, taskID int, commentID int) {
+	revisions, err := h.comments.GetCommentRevisions(r.Context(), taskID, commentID)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve comment history", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revisions)
+}
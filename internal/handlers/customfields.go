@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"todo/internal/db"
+)
+
+const (
+	maxCustomFieldText    = 1000
+	maxCustomFieldOptions = 100
+	customFieldParam      = "cf."
+)
+
+var customFieldKey = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+func validateCustomFieldInput(input *db.CustomFieldInput, create bool) error {
+	if create {
+		if input.Key == nil || !customFieldKey.MatchString(*input.Key) {
+			return fmt.Errorf("key is required and must match %s", customFieldKey)
+		}
+		if input.Type == nil || !slices.Contains(db.FieldTypes, *input.Type) {
+			return fmt.Errorf("type is required, expected one of %s", strings.Join(db.FieldTypes, ", "))
+		}
+		if input.Name == nil {
+			return fmt.Errorf("name is required")
+		}
+	} else if input.Key != nil || input.Type != nil {
+		return fmt.Errorf("key and type cannot be changed")
+	}
+
+	if input.Name != nil && strings.TrimSpace(*input.Name) == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if input.Options != nil {
+		options := *input.Options
+		if len(options) > maxCustomFieldOptions {
+			return fmt.Errorf("too many options, max %d", maxCustomFieldOptions)
+		}
+		for i, option := range options {
+			if strings.TrimSpace(option) == "" || slices.Contains(options[:i], option) {
+				return fmt.Errorf("options must be unique and not empty")
+			}
+		}
+	}
+	return nil
+}
+
+// validateFieldDefinition проверяет, что варианты заданы только у поля enum, и у него - всегда.
+func validateFieldDefinition(field *db.CustomField) error {
+	if field.Type == db.FieldEnum && len(field.Options) == 0 {
+		return fmt.Errorf("enum field requires options")
+	}
+	if field.Type != db.FieldEnum && len(field.Options) > 0 {
+		return fmt.Errorf("options are allowed only for enum fields")
+	}
+	return nil
+}
+
+// validateCustomFields проверяет переданные значения по определениям полей проекта.
+// null удаляет значение и проверку проходит всегда.
+func validateCustomFields(values map[string]any, fields []*db.CustomField) error {
+	for key, value := range values {
+		index := slices.IndexFunc(fields, func(field *db.CustomField) bool { return field.Key == key })
+		if index < 0 {
+			return fmt.Errorf("unknown custom field %q", key)
+		}
+		if value == nil {
+			continue
+		}
+		if err := validateFieldValue(fields[index], value); err != nil {
+			return fmt.Errorf("custom field %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func validateFieldValue(field *db.CustomField, value any) error {
+	switch field.Type {
+	case db.FieldNumber:
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("expected number")
+		}
+		return nil
+	case db.FieldBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("expected true or false")
+		}
+		return nil
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("expected string")
+	}
+	switch field.Type {
+	case db.FieldDate:
+		if _, err := time.Parse("2006-01-02", text); err != nil {
+			return fmt.Errorf("invalid date format, expected YYYY-MM-DD")
+		}
+	case db.FieldEnum:
+		if !slices.Contains(field.Options, text) {
+			return fmt.Errorf("expected one of %s", strings.Join(field.Options, ", "))
+		}
+	default:
+		if len(text) > maxCustomFieldText {
+			return fmt.Errorf("text is too long, max %d bytes", maxCustomFieldText)
+		}
+	}
+	return nil
+}
+
+// checkRequiredFields проверяет, что у задачи заполнены обязательные поля проекта.
+func checkRequiredFields(values map[string]any, fields []*db.CustomField) error {
+	for _, field := range fields {
+		if field.Required && values[field.Key] == nil {
+			return fmt.Errorf("custom field %q is required", field.Key)
+		}
+	}
+	return nil
+}
+
+// mergeCustomFields накладывает изменения на текущие значения. Значения полей, которых нет
+// в проекте (например, после переноса задачи), отбрасываются.
+func mergeCustomFields(current map[string]any, changes map[string]any, fields []*db.CustomField) map[string]any {
+	merged := map[string]any{}
+	for _, field := range fields {
+		if value, ok := current[field.Key]; ok {
+			merged[field.Key] = value
+		}
+	}
+	maps.Copy(merged, changes)
+	maps.DeleteFunc(merged, func(_ string, value any) bool { return value == nil })
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// projectFields возвращает поля проекта задачи; у задачи без проекта полей нет.
+func (h *Handler) projectFields(ctx context.Context, projectID int) ([]*db.CustomField, error) {
+	if projectID == 0 || h.fields == nil {
+		return nil, nil
+	}
+	return h.fields.GetCustomFields(ctx, projectID)
+}
+
+// taskFilter разбирает параметры списка задач: project_id, cf.<key>=value и sort=[-]cf.<key>.
+// Пользовательские поля разных проектов могут иметь разные типы, поэтому фильтр и
+// сортировка по ним требуют project_id.
+func (h *Handler) taskFilter(ctx context.Context, query url.Values) (*db.TaskFilter, error) {
+	filter := &db.TaskFilter{}
+	if value := query.Get("project_id"); value != "" {
+		projectID, err := strconv.Atoi(value)
+		if err != nil || projectID <= 0 {
+			return nil, &InputError{Message: "Validation error", Err: fmt.Errorf("invalid project_id")}
+		}
+		filter.ProjectID = projectID
+	}
+
+	sort := query.Get("sort")
+	if sort != "" {
+		filter.SortDesc = strings.HasPrefix(sort, "-")
+		filter.SortField, _ = strings.CutPrefix(strings.TrimPrefix(sort, "-"), customFieldParam)
+		if filter.SortField == strings.TrimPrefix(sort, "-") {
+			return nil, &InputError{Message: "Validation error", Err: fmt.Errorf("unknown sort %q, expected [-]cf.<key>", sort)}
+		}
+	}
+
+	var keys []string
+	for key := range query {
+		if strings.HasPrefix(key, customFieldParam) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	if len(keys) == 0 && filter.SortField == "" {
+		return filter, nil
+	}
+	if filter.ProjectID == 0 {
+		return nil, &InputError{Message: "Validation error", Err: fmt.Errorf("custom field filters and sorting require project_id")}
+	}
+
+	fields, err := h.fields.GetCustomFields(ctx, filter.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	field := func(key string) (*db.CustomField, error) {
+		index := slices.IndexFunc(fields, func(field *db.CustomField) bool { return field.Key == key })
+		if index < 0 {
+			return nil, &InputError{Message: "Validation error", Err: fmt.Errorf("unknown custom field %q", key)}
+		}
+		return fields[index], nil
+	}
+
+	if filter.SortField != "" {
+		if _, err := field(filter.SortField); err != nil {
+			return nil, err
+		}
+	}
+	for _, param := range keys {
+		definition, err := field(strings.TrimPrefix(param, customFieldParam))
+		if err != nil {
+			return nil, err
+		}
+		value, err := filterValue(definition, query.Get(param))
+		if err != nil {
+			return nil, &InputError{Message: "Validation error", Err: fmt.Errorf("%s: %w", param, err)}
+		}
+		filter.Fields = append(filter.Fields, db.FieldFilter{Key: definition.Key, Value: value})
+	}
+	return filter, nil
+}
+
+// filterValue приводит значение из строки запроса к тому, что вернет json_extract для поля.
+func filterValue(field *db.CustomField, value string) (any, error) {
+	switch field.Type {
+	case db.FieldNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected number")
+		}
+		return number, nil
+	case db.FieldBool:
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false")
+		}
+		if flag {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return value, validateFieldValue(field, value)
+}
+
+func (h *Handler) HandleProjectFields(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.getCustomFields(w, r, projectID)
+	case "POST":
+		h.createCustomField(w, r, projectID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) HandleProjectField(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	key := r.PathValue("key")
+
+	switch r.Method {
+	case "PUT":
+		h.updateCustomField(w, r, projectID, key)
+	case "DELETE":
+		h.deleteCustomField(w, r, projectID, key)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /projects/{id}/fields - Пользовательские поля проекта
+func (h *Handler) getCustomFields(w http.ResponseWriter, r *http.Request, projectID int) {
+	fields, err := h.fields.GetCustomFields(r.Context(), projectID)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve custom fields", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, fields)
+}
+
+// POST /projects/{id}/fields - Добавить поле: {"key", "name", "type", "options", "required"}
+func (h *Handler) createCustomField(w http.ResponseWriter, r *http.Request, projectID int) {
+	var input db.CustomFieldInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := validateCustomFieldInput(&input, true); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	field := &db.CustomField{ProjectID: projectID, Key: *input.Key, Name: strings.TrimSpace(*input.Name), Type: *input.Type}
+	if input.Options != nil {
+		field.Options = *input.Options
+	}
+	if input.Required != nil {
+		field.Required = *input.Required
+	}
+	if err := validateFieldDefinition(field); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	field, err := h.fields.CreateCustomField(r.Context(), field)
+	if err != nil {
+		writeTaskError(w, "Failed to create custom field", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, field)
+}
+
+// PUT /projects/{id}/fields/{key} - Изменить название, варианты или обязательность поля
+func (h *Handler) updateCustomField(w http.ResponseWriter, r *http.Request, projectID int, key string) {
+	var input db.CustomFieldInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := validateCustomFieldInput(&input, false); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	fields, err := h.fields.GetCustomFields(r.Context(), projectID)
+	if err != nil {
+		writeTaskError(w, "Failed to update custom field", err)
+		return
+	}
+	index := slices.IndexFunc(fields, func(field *db.CustomField) bool { return field.Key == key })
+	if index < 0 {
+		writeTaskError(w, "Failed to update custom field", db.ErrCustomFieldNotFound)
+		return
+	}
+
+	field := fields[index]
+	if input.Name != nil {
+		field.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Options != nil {
+		field.Options = *input.Options
+	}
+	if input.Required != nil {
+		field.Required = *input.Required
+	}
+	if err := validateFieldDefinition(field); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.fields.UpdateCustomField(r.Context(), field); err != nil {
+		writeTaskError(w, "Failed to update custom field", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, field)
+}
+
+// DELETE /projects/{id}/fields/{key} - Удалить поле вместе со значениями в задачах
+func (h *Handler) deleteCustomField(w http.ResponseWriter, r *http.Request, projectID int, key string) {
+	count, err := h.fields.DeleteCustomField(r.Context(), projectID, key)
+	if err != nil {
+		writeTaskError(w, "Failed to delete custom field", err)
+		return
+	}
+
+	if count > 0 {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
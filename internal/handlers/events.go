@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"todo/internal/auth"
+	"todo/internal/events"
+)
+
+// EventsHandler отдает изменения задач потоком Server-Sent Events.
+type EventsHandler struct {
+	hub       *events.Hub
+	handler   *Handler
+	heartbeat time.Duration
+}
+
+func NewEventsHandler(hub *events.Hub, handler *Handler, heartbeat time.Duration) *EventsHandler {
+	return &EventsHandler{hub: hub, handler: handler, heartbeat: heartbeat}
+}
+
+// parseEventsFilter разбирает фильтр из запроса. Пользователь без роли admin
+// получает только события доступных ему задач.
+func (h *EventsHandler) parseEventsFilter(r *http.Request) (events.Filter, error) {
+	var filter events.Filter
+	query := r.URL.Query()
+
+	if _, ok := auth.FromContext(r.Context()); !ok {
+		return filter, auth.ErrUnauthenticated
+	}
+	filter.Allow = h.handler.eventFilter(r.Context())
+
+	for _, value := range strings.Split(query.Get("type"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		if !slices.Contains(events.Types, value) {
+			return filter, fmt.Errorf("unknown event type %q", value)
+		}
+		filter.Types = append(filter.Types, value)
+	}
+
+	var err error
+	if filter.TaskIDs, err = parseIDList(query.Get("task_id"), "task_id"); err != nil {
+		return filter, err
+	}
+	if filter.ProjectIDs, err = parseIDList(query.Get("project_id"), "project_id"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseIDList разбирает список ID через запятую из параметра name.
+func parseIDList(value string, name string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", name, part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// GET /events?type=task.created,task.updated&task_id=1,2&project_id=3 - Поток событий задач
+func (h *EventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := h.parseEventsFilter(r)
+	if errors.Is(err, auth.ErrUnauthenticated) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid filter: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	rc := http.NewResponseController(w)
+	sub, replay, complete := h.hub.Subscribe(lastEventID, filter)
+	defer h.hub.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", 3000)
+
+	// Часть событий уже вытеснена из буфера: клиент должен перечитать GET /tasks
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, envelope := range replay {
+		if err := writeEvent(w, envelope); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case envelope, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, envelope); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, envelope events.Envelope) error {
+	data, err := json.Marshal(envelope.Event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", envelope.ID, envelope.Event.Type, data)
+	return err
+}
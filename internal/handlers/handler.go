@@ -1,21 +1,123 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"strconv"
+	"time"
+	"todo/internal/auth"
+	"todo/internal/authz"
 	"todo/internal/db"
+	"todo/internal/events"
 )
 
 type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
+// Handler работает с задачами через authz.Repo: права проверяются на каждом вызове,
+// а не в отдельных обработчиках.
 type Handler struct {
-	repo db.Repo
+	repo        db.Repo
+	reminders   db.ReminderRepo
+	projects    db.ProjectRepo
+	shares      db.ShareRepo
+	people      db.PeopleRepo
+	comments    db.CommentRepo
+	attachments db.AttachmentRepo
+	checklists  db.ChecklistRepo
+	worklogs    db.WorklogRepo
+	fields      db.CustomFieldRepo
+	users       db.UserRepo
+	webhooks    db.WebhookRepo
+	transfer    db.TransferRepo
+	events      events.Publisher
+
+	// AutoCompleteChecklist завершает задачу, когда отмечены все пункты ее чек-листа.
+	AutoCompleteChecklist bool
+	// TimerAutoStop - сколько может идти таймер, пока его не остановит фоновая задача. 0 - без ограничения.
+	TimerAutoStop time.Duration
+}
+
+func NewHandler(repo *db.TaskRepository, publisher events.Publisher) *Handler {
+	guarded := authz.New(repo)
+	return &Handler{
+		repo:        guarded,
+		reminders:   guarded.Reminders(),
+		projects:    guarded,
+		shares:      guarded,
+		people:      guarded,
+		comments:    guarded.Comments(),
+		attachments: guarded.Attachments(),
+		checklists:  guarded.Checklists(),
+		worklogs:    guarded.Worklogs(),
+		fields:      guarded.CustomFields(),
+		users:       repo,
+		webhooks:    repo,
+		transfer:    repo,
+		events:      publisher,
+	}
+}
+
+// publish отправляет событие задачи, помечая его пространством из ctx.
+func (h *Handler) publish(ctx context.Context, eventType string, task *db.Task) {
+	if task != nil {
+		h.publishEvent(ctx, events.New(eventType, task))
+	}
+}
+
+func (h *Handler) publishEvent(ctx context.Context, event events.Event) {
+	if h.events == nil {
+		return
+	}
+	if workspace, ok := db.WorkspaceFromContext(ctx); ok {
+		event.Workspace = workspace.Slug
+	}
+	h.events.Publish(event)
+}
+
+// canSee проверяет, доступна ли задача принципалу из ctx. ownerID, если известен,
+// избавляет владельца от запроса к базе.
+func (h *Handler) canSee(ctx context.Context, taskID int, ownerID int) bool {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	if principal.IsAdmin() || (ownerID != 0 && ownerID == principal.UserID) {
+		return true
+	}
+	_, err := h.repo.GetTaskById(ctx, taskID)
+	return err == nil
 }
 
-func NewHandler(repo *db.TaskRepository) *Handler {
-	return &Handler{repo: repo}
+// eventFilter отбирает события задач, доступных принципалу из ctx, в его пространстве.
+// Для администратора вне пространства возвращает nil - ему видны все события. Событие
+// удаления видит только владелец: после удаления доступ через шаринг уже не проверить.
+// config.changed видят только администраторы.
+func (h *Handler) eventFilter(ctx context.Context) func(events.Event) bool {
+	principal, _ := auth.FromContext(ctx)
+	workspace, bound := db.WorkspaceFromContext(ctx)
+	if principal != nil && principal.IsAdmin() {
+		if !bound {
+			return nil
+		}
+		return func(event events.Event) bool {
+			return event.Workspace == workspace.Slug || event.Type == events.ConfigChanged
+		}
+	}
+	return func(event events.Event) bool {
+		if event.Type == events.ConfigChanged {
+			return false
+		}
+		if bound && event.Workspace != workspace.Slug {
+			return false
+		}
+		var ownerID int
+		if event.Task != nil {
+			ownerID = event.Task.OwnerID
+		}
+		return h.canSee(ctx, event.TaskID, ownerID)
+	}
 }
 
 func (h *Handler) HandleTasks(w http.ResponseWriter, r *http.Request) {
@@ -30,13 +132,15 @@ func (h *Handler) HandleTasks(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) HandleTaskByID(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Path[len("/tasks/"):])
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		http.Error(w, "Invalid task ID", http.StatusBadRequest)
 		return
 	}
 
 	switch r.Method {
+	case "GET":
+		h.getTask(w, r, id)
 	case "PUT":
 		h.updateTask(w, r, id)
 	case "DELETE":
@@ -47,7 +151,7 @@ func (h *Handler) HandleTaskByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) HandleCompleteTask(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.URL.Path[len("/tasks/complete/"):])
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		http.Error(w, "Invalid task ID", http.StatusBadRequest)
 		return
@@ -59,3 +163,17 @@ func (h *Handler) HandleCompleteTask(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
+
+func (h *Handler) HandleTaskStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "PATCH" {
+		h.transitionTask(w, r, id)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -201,3 +201,65 @@ func TestCompleteTask(t *testing.T) {
 		t.Errorf("handler returned unexpected body: got %v want %v", mockRepo.tasks[0].Completed, 1)
 	}
 }
+
+func TestTransitionTask(t *testing.T) {
+	mockRepo := NewMockRepository()
+	handler := &Handler{repo: mockRepo}
+
+	mockRepo.tasks[1] = db.Task{ID: 1, Title: "Task 1", DueDate: "2006-01-02 15:55:08", Status: "done", CreatedAt: "2006-01-02 15:45:08"}
+
+	// Из done по умолчанию можно вернуться только в todo
+	req, err := http.NewRequest("PATCH", "/tasks/1/status", bytes.NewBufferString(`{"status": "review"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.transitionTask(rr, req, 1)
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+	}
+
+	req, err = http.NewRequest("PATCH", "/tasks/1/status", bytes.NewBufferString(`{"status": "todo"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.transitionTask(rr, req, 1)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var gotTask db.Task
+	if err := json.NewDecoder(rr.Body).Decode(&gotTask); err != nil {
+		t.Fatalf("Failed to decode response body: %v", err)
+	}
+
+	if gotTask.Status != "todo" || gotTask.IsCompleted || gotTask.Completed != 0 {
+		t.Errorf("handler returned unexpected body: got %+v", gotTask)
+	}
+}
+
+func TestValidateReminderInput(t *testing.T) {
+	at, before, badBefore := "2030-01-01", "1h", "soon"
+
+	input := db.ReminderInput{At: &at}
+	if err := validateReminderInput(&input); err != nil || *input.At != "2030-01-01 00:00:00" {
+		t.Errorf("unexpected result for date-only reminder: %v, %v", err, *input.At)
+	}
+
+	if err := validateReminderInput(&db.ReminderInput{Before: &before}); err != nil {
+		t.Errorf("unexpected error for offset reminder: %v", err)
+	}
+
+	if err := validateReminderInput(&db.ReminderInput{Before: &badBefore}); err == nil {
+		t.Error("expected error for invalid offset")
+	}
+
+	if err := validateReminderInput(&db.ReminderInput{}); err == nil {
+		t.Error("expected error for empty reminder")
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"todo/internal/logging"
+)
+
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// GET /admin/log-level - Текущий уровень журнала
+// PUT /admin/log-level - Сменить уровень без перезапуска: {"level": "debug"}
+func HandleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "PUT":
+		var input LogLevel
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		previous := logging.Level()
+		if err := logging.SetLevel(input.Level); err != nil {
+			http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+			return
+		}
+		logging.FromContext(r.Context()).Info("log level changed", slog.String("from", previous), slog.String("to", logging.Level()))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, LogLevel{Level: logging.Level()})
+}
@@ -1,29 +1,59 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
 	"time"
 	"todo/internal/db"
 )
 
 type MockRepository struct {
-	tasks map[int]db.Task
+	tasks    map[int]db.Task
+	workflow *db.Workflow
 }
 
 func NewMockRepository() *MockRepository {
 	return &MockRepository{
-		tasks: make(map[int]db.Task),
+		tasks:    make(map[int]db.Task),
+		workflow: db.DefaultWorkflow(),
 	}
 }
 
-func (m *MockRepository) GetAllTasks() ([]*db.Task, error) {
+// flow возвращает workflow мока; по умолчанию - как у репозитория без настроек.
+func (m *MockRepository) flow() *db.Workflow {
+	if m.workflow == nil {
+		m.workflow = db.DefaultWorkflow()
+	}
+	return m.workflow
+}
+
+// find ищет задачу по ключу карты, а если такого ключа нет - по ID задачи: тесты кладут задачи
+// и под их ID, и под произвольными ключами. Задача без статуса, как строка до появления статусов
+// после миграции, получает начальный статус workflow.
+func (m *MockRepository) find(id int) (int, db.Task, bool) {
+	key := id
+	task, exists := m.tasks[id]
+	if !exists {
+		for k, candidate := range m.tasks {
+			if candidate.ID == id {
+				key, task, exists = k, candidate, true
+				break
+			}
+		}
+	}
+	if exists && task.Status == "" {
+		task.Status = m.flow().Initial
+	}
+	return key, task, exists
+}
+
+func (m *MockRepository) GetAllTasks(ctx context.Context, filter *db.TaskFilter) ([]*db.Task, error) {
 	var result []*db.Task
 	for _, task := range m.tasks {
 		result = append(result, &task)
 	}
 	return result, nil
 }
-func (m *MockRepository) CreateTask(input *db.TaskInput) (*db.Task, error) {
+func (m *MockRepository) CreateTask(ctx context.Context, input *db.TaskInput) (*db.Task, error) {
 	// Инициализируем карту, если она еще не была инициализирована
 	if m.tasks == nil {
 		m.tasks = make(map[int]db.Task)
@@ -34,6 +64,7 @@ func (m *MockRepository) CreateTask(input *db.TaskInput) (*db.Task, error) {
 		ID:        len(m.tasks),
 		Title:     *input.Title,
 		DueDate:   *input.DueDate,
+		Status:    m.flow().Initial,
 		Completed: 0,
 		Overdue:   0,
 		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
@@ -45,43 +76,56 @@ func (m *MockRepository) CreateTask(input *db.TaskInput) (*db.Task, error) {
 	return &task, nil
 }
 
-func (m *MockRepository) GetTaskById(id int) (*db.Task, error) {
-	task, exists := m.tasks[id]
+func (m *MockRepository) GetTaskById(ctx context.Context, id int) (*db.Task, error) {
+	_, task, exists := m.find(id)
 	if !exists {
-		return nil, fmt.Errorf("task not found")
+		return nil, db.ErrTaskNotFound
 	}
 	return &task, nil
 }
 
-func (m *MockRepository) UpdateTask(task *db.Task) error {
-	_, exists := m.tasks[task.ID]
+func (m *MockRepository) UpdateTask(ctx context.Context, task *db.Task) error {
+	key, _, exists := m.find(task.ID)
 	if !exists {
-		return fmt.Errorf("task not found")
+		return db.ErrTaskNotFound
 	}
-	m.tasks[task.ID] = *task
+	m.tasks[key] = *task
 	return nil
 }
 
-func (m *MockRepository) DeleteTask(id int) (int64, error) {
-	_, exists := m.tasks[id]
+func (m *MockRepository) DeleteTask(ctx context.Context, id int) (int64, error) {
+	key, _, exists := m.find(id)
 	if !exists {
 		return 0, nil
 	}
-	delete(m.tasks, id)
+	delete(m.tasks, key)
 	return 1, nil
 }
 
-func (m *MockRepository) CompleteTask(id int) error {
-	task, exists := m.tasks[id]
+func (m *MockRepository) CompleteTask(ctx context.Context, id int) error {
+	return m.TransitionTask(ctx, id, m.flow().Done)
+}
+
+// TransitionTask, как и TaskRepository, меняет статус только по переходам workflow.
+func (m *MockRepository) TransitionTask(ctx context.Context, id int, status string) error {
+	key, task, exists := m.find(id)
 	if !exists {
-		return fmt.Errorf("task not found")
+		return db.ErrTaskNotFound
 	}
-	task.Completed = 1
-	m.tasks[task.ID] = task
+	if err := m.flow().CanTransition(task.Status, status); err != nil {
+		return err
+	}
+	task.Status = status
+	task.IsCompleted = status == m.flow().Done
+	task.Completed = 0
+	if task.IsCompleted {
+		task.Completed = 1
+	}
+	m.tasks[key] = task
 	return nil
 }
 
-func (m *MockRepository) UpdateOverdueTasks(currentTime string) (int64, error) {
+func (m *MockRepository) UpdateOverdueTasks(ctx context.Context, currentTime string) ([]*db.Task, error) {
 	// Возвращаем заранее заданные данные
-	return 0, nil
+	return nil, nil
 }
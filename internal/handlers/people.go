@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"todo/internal/auth"
+	"todo/internal/db"
+)
+
+// GET /me/tasks?filter=assigned,watching,created - Задачи, где пользователь исполнитель,
+// наблюдатель или автор. Без filter возвращаются все три списка вместе.
+func (h *Handler) HandleMyTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var relations []string
+	if value := r.URL.Query().Get("filter"); value != "" {
+		for _, relation := range strings.Split(value, ",") {
+			relation = strings.TrimSpace(relation)
+			if !slices.Contains(db.Relations, relation) {
+				http.Error(w, fmt.Sprintf("Validation error: unknown filter %q, expected assigned, watching or created", relation), http.StatusBadRequest)
+				return
+			}
+			relations = append(relations, relation)
+		}
+	}
+
+	tasks, err := h.people.GetUserTasks(r.Context(), principal.UserID, relations)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve tasks", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tasks)
+}
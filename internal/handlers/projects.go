@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"todo/internal/db"
+)
+
+func validateProjectInput(input *db.ProjectInput) error {
+	if input.Name == nil || strings.TrimSpace(*input.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
+
+func (h *Handler) HandleProjects(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.getProjects(w, r)
+	case "POST":
+		h.createProject(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) HandleProjectByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.getProject(w, r, id)
+	case "PUT":
+		h.updateProject(w, r, id)
+	case "DELETE":
+		h.deleteProject(w, r, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /projects - Получить доступные проекты
+func (h *Handler) getProjects(w http.ResponseWriter, r *http.Request) {
+	projects, err := h.projects.GetAllProjects(r.Context())
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve projects", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, projects)
+}
+
+// POST /projects - Создать проект
+func (h *Handler) createProject(w http.ResponseWriter, r *http.Request) {
+	var input db.ProjectInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateProjectInput(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	project, err := h.projects.CreateProject(r.Context(), &db.Project{Name: strings.TrimSpace(*input.Name)})
+	if err != nil {
+		writeTaskError(w, "Failed to create project", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, project)
+}
+
+// GET /projects/{id} - Получить проект
+func (h *Handler) getProject(w http.ResponseWriter, r *http.Request, id int) {
+	project, err := h.projects.GetProjectById(r.Context(), id)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve project", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, project)
+}
+
+// PUT /projects/{id} - Переименовать проект
+func (h *Handler) updateProject(w http.ResponseWriter, r *http.Request, id int) {
+	project, err := h.projects.GetProjectById(r.Context(), id)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve project", err)
+		return
+	}
+
+	var input db.ProjectInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateProjectInput(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	project.Name = strings.TrimSpace(*input.Name)
+	if err := h.projects.UpdateProject(r.Context(), project); err != nil {
+		writeTaskError(w, "Failed to update project", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, project)
+}
+
+// DELETE /projects/{id} - Удалить проект. Задачи проекта остаются у своих владельцев.
+func (h *Handler) deleteProject(w http.ResponseWriter, r *http.Request, id int) {
+	count, err := h.projects.DeleteProject(r.Context(), id)
+	if err != nil {
+		writeTaskError(w, "Failed to delete project", err)
+		return
+	}
+
+	if count > 0 {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
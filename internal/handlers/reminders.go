@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"todo/internal/db"
+)
+
+func validateReminderInput(input *db.ReminderInput) error {
+	if (input.At == nil) == (input.Before == nil) {
+		return fmt.Errorf("exactly one of at or before is required")
+	}
+
+	if input.At != nil {
+		if _, err := time.Parse("2006-01-02 15:04:05", *input.At); err == nil {
+			return nil
+		}
+		if _, err := time.Parse("2006-01-02", *input.At); err != nil {
+			return fmt.Errorf("invalid at format, expected YYYY-MM-DD or YYYY-MM-DD HH:MM:SS")
+		}
+		*input.At += " 00:00:00"
+		return nil
+	}
+
+	before, err := db.ParseBefore(*input.Before)
+	if err != nil {
+		return err
+	}
+	if before < 0 {
+		return fmt.Errorf("before must not be negative")
+	}
+
+	return nil
+}
+
+func (h *Handler) HandleTaskReminders(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.getReminders(w, r, taskID)
+	case "POST":
+		h.createReminder(w, r, taskID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) HandleTaskReminder(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	reminderID, err := strconv.Atoi(r.PathValue("reminderID"))
+	if err != nil {
+		http.Error(w, "Invalid reminder ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "DELETE" {
+		h.deleteReminder(w, r, taskID, reminderID)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /tasks/{id}/reminders - Получить напоминания задачи
+func (h *Handler) getReminders(w http.ResponseWriter, r *http.Request, taskID int) {
+	reminders, err := h.reminders.GetReminders(r.Context(), taskID)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve reminders", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(reminders)
+}
+
+// POST /tasks/{id}/reminders - Добавить напоминание
+func (h *Handler) createReminder(w http.ResponseWriter, r *http.Request, taskID int) {
+	var input db.ReminderInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateReminderInput(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	reminder, err := h.reminders.CreateReminder(r.Context(), taskID, &input)
+	if err != nil {
+		writeTaskError(w, "Failed to create reminder", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reminder)
+}
+
+// DELETE /tasks/{id}/reminders/{reminderID} - Удалить напоминание
+func (h *Handler) deleteReminder(w http.ResponseWriter, r *http.Request, taskID int, reminderID int) {
+	count, err := h.reminders.DeleteReminder(r.Context(), taskID, reminderID)
+	if err != nil {
+		writeTaskError(w, "Failed to delete reminder", err)
+		return
+	}
+
+	if count > 0 {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
+	"todo/internal/auth"
 	"todo/internal/db"
+	"todo/internal/events"
 
 	"math/rand"
 )
@@ -15,6 +20,10 @@ type CompleteResponse struct {
 	Status bool `json:"status"`
 }
 
+type StatusInput struct {
+	Status string `json:"status"`
+}
+
 func ifEmptyUseCurrent(updatedValue *string, currentValue string) string {
 	if updatedValue == nil {
 		return currentValue
@@ -22,24 +31,86 @@ func ifEmptyUseCurrent(updatedValue *string, currentValue string) string {
 	return *updatedValue
 }
 
-func validateTaskInput(input *db.TaskInput) error {
+// validateTaskInput проверяет новую задачу. fields - пользовательские поля ее проекта.
+func validateTaskInput(input *db.TaskInput, users db.UserRepo, fields []*db.CustomField) error {
 	if input.Title == nil {
 		return fmt.Errorf("title is required")
 	}
 
-	// Проверка на правильность формата даты (если указана)
-	if input.DueDate != nil {
-		if _, err := time.Parse("2006-01-02", *input.DueDate); err != nil {
+	// Проверка на правильность формата даты (если указана): дата или дата со временем, как в выгрузке
+	if input.DueDate != nil && !isDate(*input.DueDate) {
+		if _, err := time.Parse("2006-01-02 15:04:05", *input.DueDate); err != nil {
 			return fmt.Errorf("invalid due_date format, expected YYYY-MM-DD")
 		}
 	}
 
+	if err := validateEstimate(input); err != nil {
+		return err
+	}
+	if err := validateExternalID(input); err != nil {
+		return err
+	}
+	if err := validateCustomFields(input.CustomFields, fields); err != nil {
+		return err
+	}
+	if err := checkRequiredFields(input.CustomFields, fields); err != nil {
+		return err
+	}
+	return validatePeople(input, users)
+}
+
+// validateEstimate проверяет оценку трудозатрат: 0 снимает оценку.
+func validateEstimate(input *db.TaskInput) error {
+	if input.EstimateMinutes != nil && (*input.EstimateMinutes < 0 || *input.EstimateMinutes > maxEstimateMinutes) {
+		return fmt.Errorf("estimate_minutes must be between 0 and %d", maxEstimateMinutes)
+	}
+	return nil
+}
+
+// validateExternalID ограничивает длину внешнего идентификатора; пустой снимает его.
+func validateExternalID(input *db.TaskInput) error {
+	if input.ExternalID != nil && len(*input.ExternalID) > maxExternalIDLength {
+		return fmt.Errorf("external_id is too long, max %d bytes", maxExternalIDLength)
+	}
+	return nil
+}
+
+// isDate проверяет, что срок задан датой без времени: такая задача истекает в конце дня.
+func isDate(dueDate string) bool {
+	_, err := time.Parse("2006-01-02", dueDate)
+	return err == nil
+}
+
+// validatePeople проверяет, что исполнители и наблюдатели - существующие пользователи.
+func validatePeople(input *db.TaskInput, users db.UserRepo) error {
+	fields := []struct {
+		name string
+		ids  *[]int
+	}{{"assignees", input.Assignees}, {"watchers", input.Watchers}}
+
+	for _, field := range fields {
+		if field.ids == nil {
+			continue
+		}
+		for _, id := range *field.ids {
+			if users == nil {
+				return fmt.Errorf("%s: users are not available", field.name)
+			}
+			if _, err := users.GetUserById(id); errors.Is(err, db.ErrUserNotFound) {
+				return fmt.Errorf("%s: unknown user %d", field.name, id)
+			} else if err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
 func transformTaskInput(input *db.TaskInput) *db.TaskInput {
 	if input.DueDate != nil {
-		*input.DueDate += " 23:59:59"
+		if isDate(*input.DueDate) {
+			*input.DueDate += " 23:59:59"
+		}
 	} else {
 		source := rand.NewSource(time.Now().UnixNano())
 		r := rand.New(source)
@@ -70,9 +141,15 @@ func checkDueDate(dueDate string, createdAt string) error {
 
 // GET /tasks - Получить все задачи
 func (h *Handler) getTasks(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.repo.GetAllTasks()
+	filter, err := h.taskFilter(r.Context(), r.URL.Query())
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve tasks", err)
+		return
+	}
+
+	tasks, err := h.repo.GetAllTasks(r.Context(), filter)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to retrieve tasks: %v", err), http.StatusInternalServerError)
+		writeTaskError(w, "Failed to retrieve tasks", err)
 		return
 	}
 
@@ -81,6 +158,167 @@ func (h *Handler) getTasks(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(tasks)
 }
 
+// InputError - ошибка во входных данных задачи. REST отвечает на нее 400, WebSocket - кадром error.
+type InputError struct {
+	Message string
+	Err     error
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Message, e.Err)
+}
+
+// CreateTask проверяет и создает задачу. Общая логика для REST и WebSocket.
+func (h *Handler) CreateTask(ctx context.Context, input *db.TaskInput) (*db.Task, error) {
+	input.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
+
+	var projectID int
+	if input.ProjectID != nil {
+		projectID = *input.ProjectID
+	}
+	fields, err := h.projectFields(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTaskInput(input, h.users, fields); err != nil {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+	input.CustomFields = mergeCustomFields(nil, input.CustomFields, fields)
+
+	input = transformTaskInput(input)
+
+	if err := checkDueDate(*input.DueDate, input.CreatedAt); err != nil {
+		return nil, &InputError{Message: "Invalid dueDate", Err: err}
+	}
+
+	task, err := h.repo.CreateTask(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	h.publish(ctx, events.TaskCreated, task)
+
+	return task, nil
+}
+
+// UpdateTask меняет переданные поля задачи, остальные остаются прежними.
+func (h *Handler) UpdateTask(ctx context.Context, id int, input *db.TaskInput) (*db.Task, error) {
+	currentTask, err := h.repo.GetTaskById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkDueDate(ifEmptyUseCurrent(input.DueDate, currentTask.DueDate), currentTask.CreatedAt); err != nil {
+		return nil, &InputError{Message: "Invalid dueDate", Err: err}
+	}
+	if err := validateEstimate(input); err != nil {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+	if err := validateExternalID(input); err != nil {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+	if err := validatePeople(input, h.users); err != nil {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+
+	projectID := currentTask.ProjectID
+	if input.ProjectID != nil {
+		projectID = *input.ProjectID
+	}
+	fields, err := h.projectFields(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCustomFields(input.CustomFields, fields); err != nil {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+	customFields := mergeCustomFields(currentTask.CustomFields, input.CustomFields, fields)
+	if err := checkRequiredFields(customFields, fields); err != nil {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+
+	var updatedTask = *currentTask
+	updatedTask.CustomFields = customFields
+	updatedTask.Title = ifEmptyUseCurrent(input.Title, currentTask.Title)
+	updatedTask.Description = ifEmptyUseCurrent(input.Description, currentTask.Description)
+	updatedTask.DueDate = ifEmptyUseCurrent(input.DueDate, currentTask.DueDate)
+	if input.ProjectID != nil {
+		updatedTask.ProjectID = *input.ProjectID
+	}
+	if input.EstimateMinutes != nil {
+		updatedTask.EstimateMinutes = *input.EstimateMinutes
+	}
+	if input.Assignees != nil {
+		updatedTask.Assignees = db.NormalizeUserIDs(*input.Assignees)
+	}
+	if input.Watchers != nil {
+		updatedTask.Watchers = db.NormalizeUserIDs(*input.Watchers)
+	}
+	if input.ExternalID != nil {
+		updatedTask.ExternalID = *input.ExternalID
+	}
+
+	if err := h.repo.UpdateTask(ctx, &updatedTask); err != nil {
+		return nil, err
+	}
+	h.publish(ctx, events.TaskUpdated, &updatedTask)
+
+	// Смена исполнителей - отдельное событие, чтобы на него можно было подписаться
+	if !slices.Equal(currentTask.Assignees, updatedTask.Assignees) {
+		event := events.New(events.TaskReassigned, &updatedTask)
+		event.PreviousAssignees = currentTask.Assignees
+		h.publishEvent(ctx, event)
+	}
+
+	return &updatedTask, nil
+}
+
+// DeleteTask удаляет задачу и возвращает false, если ее не было.
+func (h *Handler) DeleteTask(ctx context.Context, id int) (bool, error) {
+	// Последнее состояние задачи нужно для события удаления
+	task, _ := h.repo.GetTaskById(ctx, id)
+
+	count, err := h.repo.DeleteTask(ctx, id)
+	if err != nil || count == 0 {
+		return false, err
+	}
+
+	h.publish(ctx, events.TaskDeleted, task)
+	return true, nil
+}
+
+func (h *Handler) CompleteTask(ctx context.Context, id int) (*db.Task, error) {
+	if err := h.repo.CompleteTask(ctx, id); err != nil {
+		return nil, err
+	}
+	return h.changedTask(ctx, id)
+}
+
+func (h *Handler) TransitionTask(ctx context.Context, id int, status string) (*db.Task, error) {
+	if status == "" {
+		return nil, &InputError{Message: "Validation error", Err: errors.New("status is required")}
+	}
+
+	if err := h.repo.TransitionTask(ctx, id, status); err != nil {
+		return nil, err
+	}
+	return h.changedTask(ctx, id)
+}
+
+// changedTask возвращает актуальное состояние задачи после смены статуса и публикует события.
+func (h *Handler) changedTask(ctx context.Context, id int) (*db.Task, error) {
+	task, err := h.repo.GetTaskById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	h.publish(ctx, events.TaskUpdated, task)
+	if task.IsCompleted {
+		h.publish(ctx, events.TaskCompleted, task)
+	}
+
+	return task, nil
+}
+
 // POST /tasks - Создать новую задачу
 func (h *Handler) createTask(w http.ResponseWriter, r *http.Request) {
 	var input *db.TaskInput
@@ -89,36 +327,59 @@ func (h *Handler) createTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	input.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
+	task, err := h.CreateTask(r.Context(), input)
+	if err != nil {
+		writeTaskError(w, "Failed to create task", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(task)
+}
 
-	if err := validateTaskInput(input); err != nil {
+// GET /tasks/{id}?include=comments&comments_limit=N - Получить задачу, при include=comments
+// вместе с последними N комментариями
+func (h *Handler) getTask(w http.ResponseWriter, r *http.Request, id int) {
+	withComments := false
+	if value := r.URL.Query().Get("include"); value != "" {
+		for _, include := range strings.Split(value, ",") {
+			if strings.TrimSpace(include) != "comments" {
+				http.Error(w, fmt.Sprintf("Validation error: unknown include %q, expected comments", include), http.StatusBadRequest)
+				return
+			}
+			withComments = true
+		}
+	}
+	limit, err := queryInt(r, "comments_limit", defaultIncludeComments, 1, maxCommentsLimit)
+	if err != nil {
 		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	input = transformTaskInput(input)
+	task, err := h.repo.GetTaskById(r.Context(), id)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve task", err)
+		return
+	}
 
-	if err := checkDueDate(*input.DueDate, input.CreatedAt); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid dueDate: %v", err), http.StatusBadRequest)
+	if !withComments {
+		writeJSON(w, http.StatusOK, task)
 		return
 	}
 
-	task, err := h.repo.CreateTask(input)
+	comments, err := h.comments.GetLatestComments(r.Context(), id, limit)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create task: %v", err), http.StatusInternalServerError)
+		writeTaskError(w, "Failed to retrieve comments", err)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(task)
+	writeJSON(w, http.StatusOK, TaskWithComments{Task: task, Comments: comments})
 }
 
 // PUT /tasks/{id} - Обновить задачу
 func (h *Handler) updateTask(w http.ResponseWriter, r *http.Request, id int) {
-	currentTask, err := h.repo.GetTaskById(id)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid task id: %v", err), http.StatusBadRequest)
+	if _, err := h.repo.GetTaskById(r.Context(), id); err != nil {
+		writeTaskError(w, "Invalid task id", err)
 		return
 	}
 
@@ -128,24 +389,9 @@ func (h *Handler) updateTask(w http.ResponseWriter, r *http.Request, id int) {
 		return
 	}
 
-	if err := checkDueDate(ifEmptyUseCurrent(updatedTaskInput.DueDate, currentTask.DueDate), currentTask.CreatedAt); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid dueDate: %v", err), http.StatusBadRequest)
-		return
-	}
-
-	var updatedTask = &db.Task{
-		ID:          currentTask.ID,
-		Title:       ifEmptyUseCurrent(updatedTaskInput.Title, currentTask.Title),
-		Description: ifEmptyUseCurrent(updatedTaskInput.Description, currentTask.Description),
-		DueDate:     ifEmptyUseCurrent(updatedTaskInput.DueDate, currentTask.DueDate),
-		Completed:   currentTask.Completed,
-		Overdue:     currentTask.Overdue,
-		CreatedAt:   currentTask.CreatedAt,
-	}
-
-	err = h.repo.UpdateTask(updatedTask)
+	updatedTask, err := h.UpdateTask(r.Context(), id, updatedTaskInput)
 	if err != nil {
-		http.Error(w, "Failed to update task", http.StatusInternalServerError)
+		writeTaskError(w, "Failed to update task", err)
 		return
 	}
 
@@ -156,13 +402,13 @@ func (h *Handler) updateTask(w http.ResponseWriter, r *http.Request, id int) {
 
 // DELETE /tasks/{id} - Удалить задачу
 func (h *Handler) deleteTask(w http.ResponseWriter, r *http.Request, id int) {
-	count, err := h.repo.DeleteTask(id)
+	deleted, err := h.DeleteTask(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete task: %v", err), http.StatusInternalServerError)
+		writeTaskError(w, "Failed to delete task", err)
 		return
 	}
 
-	if count > 0 {
+	if deleted {
 		w.WriteHeader(http.StatusOK)
 	} else {
 		w.WriteHeader(http.StatusNotFound)
@@ -171,30 +417,78 @@ func (h *Handler) deleteTask(w http.ResponseWriter, r *http.Request, id int) {
 
 // PATCH /tasks/{id}/complete - Завершить задачу
 func (h *Handler) completeTask(w http.ResponseWriter, r *http.Request, id int) {
-	errComplete := h.repo.CompleteTask(id)
-	task, err := h.repo.GetTaskById(id)
-
-	if errComplete != nil {
-		var result interface{}
-		if err != nil {
-			result = err
-		} else {
-			result = *task
-		}
-		http.Error(w, fmt.Sprintf("Failed to mark task as completed: %v\nTask: %+v", errComplete, result), http.StatusInternalServerError)
+	task, err := h.CompleteTask(r.Context(), id)
+	if err != nil {
+		writeTaskError(w, "Failed to mark task as completed", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(task)
+	writeJSON(w, http.StatusOK, task)
+}
+
+// PATCH /tasks/{id}/status - Перевести задачу в другой статус
+func (h *Handler) transitionTask(w http.ResponseWriter, r *http.Request, id int) {
+	var input StatusInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.TransitionTask(r.Context(), id, input.Status)
+	if err != nil {
+		writeTaskError(w, "Failed to change task status", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, task)
 }
 
-func (h *Handler) UpdateOverdueTasks() (int64, error) {
-	now := time.Now().Format("2006-01-02 15:55:05")
-	updatedCount, err := h.repo.UpdateOverdueTasks(now)
+// taskErrorStatus сопоставляет ошибки операций с задачами с HTTP-статусами. Задача, которую
+// пользователь не видит, неотличима от несуществующей (404); видимая, но недоступная для
+// действия - 403.
+func taskErrorStatus(err error) int {
+	var inputErr *InputError
+	switch {
+	case errors.As(err, &inputErr):
+		return http.StatusBadRequest
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, auth.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, db.ErrTaskNotFound), errors.Is(err, db.ErrProjectNotFound), errors.Is(err, db.ErrCommentNotFound),
+		errors.Is(err, db.ErrAttachmentNotFound), errors.Is(err, db.ErrChecklistItemNotFound), errors.Is(err, db.ErrWorklogNotFound),
+		errors.Is(err, db.ErrCustomFieldNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrUnknownStatus):
+		return http.StatusBadRequest
+	case errors.Is(err, db.ErrTransitionDenied), errors.Is(err, db.ErrTimerRunning), errors.Is(err, db.ErrTimerNotRunning),
+		errors.Is(err, db.ErrCustomFieldExists), errors.Is(err, db.ErrExternalIDExists):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func writeTaskError(w http.ResponseWriter, message string, err error) {
+	var inputErr *InputError
+	if errors.As(err, &inputErr) {
+		http.Error(w, inputErr.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, fmt.Sprintf("%s: %v", message, err), taskErrorStatus(err))
+}
+
+// UpdateOverdueTasks отмечает просроченные задачи и возвращает ставшие просроченными
+// с момента прошлой проверки.
+func (h *Handler) UpdateOverdueTasks(ctx context.Context) ([]*db.Task, error) {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	overdueTasks, err := h.repo.UpdateOverdueTasks(ctx, now)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	for _, task := range overdueTasks {
+		h.publish(ctx, events.TaskOverdue, task)
 	}
 
-	return updatedCount, nil
+	return overdueTasks, nil
 }
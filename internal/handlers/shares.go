@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todo/internal/db"
+)
+
+// shareTarget - задача или проект, к которым выдается доступ.
+type shareTarget struct {
+	list   func(r *http.Request, id int) ([]*db.Share, error)
+	share  func(r *http.Request, id int, userID int, permission string) error
+	revoke func(r *http.Request, id int, userID int) (int64, error)
+}
+
+func (h *Handler) taskShares() shareTarget {
+	return shareTarget{
+		list: func(r *http.Request, id int) ([]*db.Share, error) {
+			return h.shares.GetTaskShares(r.Context(), id)
+		},
+		share: func(r *http.Request, id int, userID int, permission string) error {
+			return h.shares.ShareTask(r.Context(), id, userID, permission)
+		},
+		revoke: func(r *http.Request, id int, userID int) (int64, error) {
+			return h.shares.DeleteTaskShare(r.Context(), id, userID)
+		},
+	}
+}
+
+func (h *Handler) projectShares() shareTarget {
+	return shareTarget{
+		list: func(r *http.Request, id int) ([]*db.Share, error) {
+			return h.shares.GetProjectShares(r.Context(), id)
+		},
+		share: func(r *http.Request, id int, userID int, permission string) error {
+			return h.shares.ShareProject(r.Context(), id, userID, permission)
+		},
+		revoke: func(r *http.Request, id int, userID int) (int64, error) {
+			return h.shares.DeleteProjectShare(r.Context(), id, userID)
+		},
+	}
+}
+
+// GET/POST /tasks/{id}/shares - Доступы к задаче
+func (h *Handler) HandleTaskShares(w http.ResponseWriter, r *http.Request) {
+	h.handleShares(w, r, h.taskShares())
+}
+
+// DELETE /tasks/{id}/shares/{userID} - Отозвать доступ к задаче
+func (h *Handler) HandleTaskShare(w http.ResponseWriter, r *http.Request) {
+	h.handleShare(w, r, h.taskShares())
+}
+
+// GET/POST /projects/{id}/shares - Доступы к проекту
+func (h *Handler) HandleProjectShares(w http.ResponseWriter, r *http.Request) {
+	h.handleShares(w, r, h.projectShares())
+}
+
+// DELETE /projects/{id}/shares/{userID} - Отозвать доступ к проекту
+func (h *Handler) HandleProjectShare(w http.ResponseWriter, r *http.Request) {
+	h.handleShare(w, r, h.projectShares())
+}
+
+func (h *Handler) handleShares(w http.ResponseWriter, r *http.Request, target shareTarget) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		shares, err := target.list(r, id)
+		if err != nil {
+			writeTaskError(w, "Failed to retrieve shares", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, shares)
+	case "POST":
+		h.createShare(w, r, id, target)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// createShare выдает доступ пользователю по user_id или username. Повторный вызов меняет право.
+func (h *Handler) createShare(w http.ResponseWriter, r *http.Request, id int, target shareTarget) {
+	var input db.ShareInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if input.Permission == "" {
+		input.Permission = db.PermissionView
+	}
+	if db.PermissionAccess(input.Permission) == db.AccessNone {
+		http.Error(w, fmt.Sprintf("Validation error: unknown permission %q", input.Permission), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.shareUser(&input)
+	if errors.Is(err, db.ErrUserNotFound) {
+		http.Error(w, "Validation error: user not found", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := target.share(r, id, user.ID, input.Permission); err != nil {
+		writeTaskError(w, "Failed to share", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, db.Share{UserID: user.ID, Username: user.Username, Permission: input.Permission})
+}
+
+func (h *Handler) shareUser(input *db.ShareInput) (*db.User, error) {
+	switch {
+	case input.UserID != 0:
+		return h.users.GetUserById(input.UserID)
+	case input.Username != "":
+		return h.users.GetUserByUsername(input.Username)
+	}
+	return nil, fmt.Errorf("user_id or username is required")
+}
+
+func (h *Handler) handleShare(w http.ResponseWriter, r *http.Request, target shareTarget) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	count, err := target.revoke(r, id, userID)
+	if err != nil {
+		writeTaskError(w, "Failed to revoke share", err)
+		return
+	}
+
+	if count > 0 {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -0,0 +1,943 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"todo/internal/auth"
+	"todo/internal/db"
+	"todo/internal/events"
+	"todo/internal/logging"
+	"todo/pkg/config"
+)
+
+const (
+	maxExternalIDLength = 255
+	// exportBatch - сколько задач выгрузка читает из базы за один запрос
+	exportBatch = 500
+)
+
+// Форматы выгрузки и загрузки.
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+var transferContentTypes = map[string]string{
+	formatJSON:   "application/json",
+	formatNDJSON: "application/x-ndjson",
+	formatCSV:    "text/csv; charset=utf-8",
+}
+
+// Действия со строкой загрузки в отчете.
+const (
+	importCreated = "created"
+	importUpdated = "updated"
+	importFailed  = "failed"
+)
+
+// csvFields - поля задачи в CSV в порядке колонок по умолчанию. Кроме них в колонке может
+// быть отдельное пользовательское поле cf.<key>.
+var csvFields = []string{
+	"id", "external_id", "title", "description", "status", "due_date", "created_at", "project_id", "project",
+	"estimate_minutes", "assignees", "watchers", "custom_fields", "is_completed", "is_overdue",
+}
+
+// csvReadOnly - поля, которые есть в выгрузке, но при загрузке пропускаются.
+var csvReadOnly = []string{"id", "created_at", "is_completed", "is_overdue"}
+
+// csvSkip - поле колонки, которую загрузка пропускает, а выгрузка оставляет пустой.
+const csvSkip = "-"
+
+// csvColumn - колонка CSV: заголовок в файле и поле задачи в ней.
+type csvColumn struct {
+	header string
+	field  string
+}
+
+// parseCSVColumns разбирает соответствие колонок CSV полям задачи: "заголовок=поле" или
+// просто "поле", если заголовок совпадает с полем. Пустой список - все поля csvFields.
+func parseCSVColumns(specs []string) ([]csvColumn, error) {
+	if len(specs) == 0 {
+		columns := make([]csvColumn, len(csvFields))
+		for i, field := range csvFields {
+			columns[i] = csvColumn{header: field, field: field}
+		}
+		return columns, nil
+	}
+
+	columns := make([]csvColumn, 0, len(specs))
+	for _, spec := range specs {
+		header, field, found := strings.Cut(spec, "=")
+		header, field = strings.TrimSpace(header), strings.TrimSpace(field)
+		if !found {
+			field = header
+		}
+		if header == "" {
+			return nil, fmt.Errorf("column %q has no header", spec)
+		}
+		if !isCSVField(field) {
+			return nil, fmt.Errorf("column %q: unknown field %q", header, field)
+		}
+		if slices.ContainsFunc(columns, func(column csvColumn) bool { return strings.EqualFold(column.header, header) }) {
+			return nil, fmt.Errorf("duplicate column %q", header)
+		}
+		columns = append(columns, csvColumn{header: header, field: field})
+	}
+	return columns, nil
+}
+
+func isCSVField(field string) bool {
+	key, custom := strings.CutPrefix(field, customFieldParam)
+	return field == csvSkip || slices.Contains(csvFields, field) || custom && key != ""
+}
+
+// TransferHandler выгружает и загружает задачи в JSON, NDJSON и CSV. Права проверяет
+// authz через Handler.
+type TransferHandler struct {
+	handler *Handler
+	// columns - колонки CSV по умолчанию
+	columns       []csvColumn
+	maxImportSize int64
+}
+
+func NewTransferHandler(handler *Handler, columns []string, maxImportSize int64) (*TransferHandler, error) {
+	parsed, err := parseCSVColumns(columns)
+	if err != nil {
+		return nil, fmt.Errorf("transfer.csv_columns: %w", err)
+	}
+	return &TransferHandler{handler: handler, columns: parsed, maxImportSize: maxImportSize}, nil
+}
+
+// TransferHandlerFromConfig берет из секции transfer колонки CSV и предельный размер загрузки.
+func TransferHandlerFromConfig(handler *Handler, cfg config.Transfer) (*TransferHandler, error) {
+	return NewTransferHandler(handler, cfg.CSVColumns, int64(cfg.MaxImportSize))
+}
+
+// csvColumns возвращает колонки из параметра columns запроса или колонки по умолчанию.
+func (h *TransferHandler) csvColumns(query map[string][]string) ([]csvColumn, error) {
+	value := strings.TrimSpace(strings.Join(query["columns"], ","))
+	if value == "" {
+		return h.columns, nil
+	}
+	columns, err := parseCSVColumns(strings.Split(value, ","))
+	if err != nil {
+		return nil, &InputError{Message: "Validation error", Err: fmt.Errorf("columns: %w", err)}
+	}
+	return columns, nil
+}
+
+func (h *TransferHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		h.exportTasks(w, r)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *TransferHandler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		h.importTasks(w, r)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// exportTask - задача в выгрузке: с названием проекта, чтобы загрузить ее в другую базу.
+type exportTask struct {
+	*db.Task
+	Project string `json:"project,omitempty"`
+}
+
+// GET /export?format=json|ndjson|csv - Выгрузить доступные задачи. Фильтры project_id и
+// cf.<key> те же, что у /tasks. Задачи читаются из базы страницами и сразу пишутся в ответ.
+func (h *TransferHandler) exportTasks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = formatJSON
+	}
+	contentType, ok := transferContentTypes[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Validation error: unknown format %q, expected json, ndjson or csv", format), http.StatusBadRequest)
+		return
+	}
+	if query.Get("sort") != "" {
+		http.Error(w, "Validation error: export does not support sort", http.StatusBadRequest)
+		return
+	}
+	var columns []csvColumn
+	if format == formatCSV {
+		var err error
+		if columns, err = h.csvColumns(query); err != nil {
+			writeTaskError(w, "Failed to export tasks", err)
+			return
+		}
+	}
+	// Выгрузка идет страницами по id, поэтому другой порядок пропускал бы или повторял задачи
+	if query.Has("sort") {
+		writeTaskError(w, "Failed to export tasks", &InputError{Message: "Validation error", Err: fmt.Errorf("sort is not supported by export")})
+		return
+	}
+	filter, err := h.handler.taskFilter(ctx, query)
+	if err != nil {
+		writeTaskError(w, "Failed to export tasks", err)
+		return
+	}
+
+	projects, err := h.handler.projects.GetAllProjects(ctx)
+	if err != nil {
+		writeTaskError(w, "Failed to export tasks", err)
+		return
+	}
+	names := make(map[int]string, len(projects))
+	for _, project := range projects {
+		names[project.ID] = project.Name
+	}
+
+	// Ошибка первой страницы (например, нет прав) еще может стать статусом ответа
+	filter.Limit = exportBatch
+	tasks, err := h.handler.repo.GetAllTasks(ctx, filter)
+	if err != nil {
+		writeTaskError(w, "Failed to export tasks", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "tasks." + format}))
+	w.WriteHeader(http.StatusOK)
+	out := newExportWriter(w, format, columns)
+	for {
+		for _, task := range tasks {
+			if err := out.write(&exportTask{Task: task, Project: names[task.ProjectID]}); err != nil {
+				// Клиент отключился
+				return
+			}
+		}
+		if len(tasks) < exportBatch {
+			break
+		}
+		filter.AfterID = tasks[len(tasks)-1].ID
+		if tasks, err = h.handler.repo.GetAllTasks(ctx, filter); err != nil {
+			// Статус уже отправлен: обрыв соединения не дает принять неполную выгрузку за целую
+			logging.FromContext(ctx).Error("export failed", "error", err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+	out.close()
+}
+
+// exportWriter пишет задачи выгрузки в одном из форматов.
+type exportWriter struct {
+	buf     *bufio.Writer
+	format  string
+	csv     *csv.Writer
+	columns []csvColumn
+	count   int
+}
+
+func newExportWriter(w io.Writer, format string, columns []csvColumn) *exportWriter {
+	out := &exportWriter{buf: bufio.NewWriter(w), format: format, columns: columns}
+	switch format {
+	case formatJSON:
+		out.buf.WriteString("[")
+	case formatCSV:
+		out.csv = csv.NewWriter(out.buf)
+		out.csv.UseCRLF = true
+		headers := make([]string, len(columns))
+		for i, column := range columns {
+			headers[i] = column.header
+		}
+		out.csv.Write(headers)
+	}
+	return out
+}
+
+func (out *exportWriter) write(task *exportTask) error {
+	out.count++
+	if out.format == formatCSV {
+		record := make([]string, len(out.columns))
+		for i, column := range out.columns {
+			record[i] = csvValue(task, column.field)
+		}
+		out.csv.Write(record)
+		out.csv.Flush()
+		return out.csv.Error()
+	}
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	if out.format == formatJSON && out.count > 1 {
+		out.buf.WriteString(",")
+	}
+	if out.format == formatJSON {
+		out.buf.WriteString("\n")
+	}
+	out.buf.Write(data)
+	if out.format == formatNDJSON {
+		out.buf.WriteString("\n")
+	}
+	// Ошибка записи запоминается буфером и возвращается при следующих записях
+	_, err = out.buf.Write(nil)
+	return err
+}
+
+func (out *exportWriter) close() {
+	if out.format == formatJSON {
+		if out.count > 0 {
+			out.buf.WriteString("\n")
+		}
+		out.buf.WriteString("]\n")
+	}
+	out.buf.Flush()
+}
+
+// csvValue возвращает значение поля задачи для ячейки CSV. Пустые значения пишутся пустой
+// ячейкой, списки людей - ID через пробел, пользовательские поля - JSON-объектом.
+func csvValue(task *exportTask, field string) string {
+	switch field {
+	case "id":
+		return strconv.Itoa(task.ID)
+	case "external_id":
+		return task.ExternalID
+	case "title":
+		return task.Title
+	case "description":
+		return task.Description
+	case "status":
+		return task.Status
+	case "due_date":
+		return task.DueDate
+	case "created_at":
+		return task.CreatedAt
+	case "project_id":
+		if task.ProjectID == 0 {
+			return ""
+		}
+		return strconv.Itoa(task.ProjectID)
+	case "project":
+		return task.Project
+	case "estimate_minutes":
+		if task.EstimateMinutes == 0 {
+			return ""
+		}
+		return strconv.Itoa(task.EstimateMinutes)
+	case "assignees":
+		return joinIDs(task.Assignees)
+	case "watchers":
+		return joinIDs(task.Watchers)
+	case "custom_fields":
+		if len(task.CustomFields) == 0 {
+			return ""
+		}
+		data, _ := json.Marshal(task.CustomFields)
+		return string(data)
+	case "is_completed":
+		return strconv.FormatBool(task.IsCompleted)
+	case "is_overdue":
+		return strconv.FormatBool(task.IsOverdue)
+	}
+
+	key, _ := strings.CutPrefix(field, customFieldParam)
+	switch value := task.CustomFields[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		// Без экспоненты, чтобы таблицы показывали число как есть
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+}
+
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitIDs разбирает список ID через пробел, запятую или точку с запятой.
+func splitIDs(value string) ([]int, error) {
+	parts := strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' || r == ';' })
+	ids := make([]int, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// importTask - задача из загрузки. Незаданные поля задачи не меняются.
+type importTask struct {
+	db.TaskInput
+	// Status, если отличается от статуса задачи, меняется переходом по процессу.
+	Status string `json:"status"`
+	// Project - название проекта; важнее project_id.
+	Project string `json:"project"`
+	// fields - значения колонок cf.<key> как в CSV: их тип известен только по проекту задачи.
+	fields map[string]string
+}
+
+// importReader читает записи загрузки. Next возвращает io.EOF в конце, *rowError, если
+// не годится одна запись, и любую другую ошибку, если дальше читать нельзя.
+type importReader interface {
+	Next() (*importTask, error)
+}
+
+// rowError - ошибка одной записи загрузки: запись не загружается, остальные читаются дальше.
+type rowError struct {
+	err error
+}
+
+func (e *rowError) Error() string {
+	return e.err.Error()
+}
+
+// formatError - ошибка синтаксиса загрузки, после которой записи уже не разобрать.
+type formatError struct {
+	row int
+	err error
+}
+
+func (e *formatError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.row, e.err)
+}
+
+// jsonImport читает JSON-массив задач или NDJSON - по первому символу.
+type jsonImport struct {
+	body    *bufio.Reader
+	decoder *json.Decoder
+	array   bool
+	row     int
+}
+
+func newJSONImport(r io.Reader) *jsonImport {
+	body := bufio.NewReader(r)
+	return &jsonImport{body: body, decoder: json.NewDecoder(body)}
+}
+
+func (in *jsonImport) Next() (*importTask, error) {
+	if in.row == 0 && !in.array {
+		first, err := firstByte(in.body)
+		if err != nil {
+			return nil, err
+		}
+		if first == '[' {
+			in.decoder.Token()
+			in.array = true
+		}
+	}
+	if !in.decoder.More() {
+		if in.array {
+			if _, err := in.decoder.Token(); err != nil {
+				return nil, in.syntax(err)
+			}
+		}
+		return nil, io.EOF
+	}
+
+	in.row++
+	var raw json.RawMessage
+	if err := in.decoder.Decode(&raw); err != nil {
+		return nil, in.syntax(err)
+	}
+	task := &importTask{}
+	if err := json.Unmarshal(raw, task); err != nil {
+		return task, &rowError{err: fmt.Errorf("invalid task: %w", err)}
+	}
+	return task, nil
+}
+
+// firstByte возвращает первый непробельный символ, не читая его.
+func firstByte(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			return b[0], nil
+		}
+		r.Discard(1)
+	}
+}
+
+// syntax помечает ошибку разбора номером записи; ошибки чтения тела возвращаются как есть.
+func (in *jsonImport) syntax(err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &formatError{row: in.row, err: err}
+	}
+	return err
+}
+
+// csvImport читает CSV с заголовком. Колонки находятся по заголовку без учета регистра:
+// сначала среди колонок соответствия, затем среди полей задачи.
+type csvImport struct {
+	reader  *csv.Reader
+	columns []csvColumn
+	// fields - поле каждой колонки файла
+	fields []string
+	row    int
+}
+
+func newCSVImport(r io.Reader, columns []csvColumn) *csvImport {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	return &csvImport{reader: reader, columns: columns}
+}
+
+func (in *csvImport) Next() (*importTask, error) {
+	if in.fields == nil {
+		if err := in.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+
+	record, err := in.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	in.row++
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount) {
+		return nil, &rowError{err: fmt.Errorf("expected %d columns, got %d", len(in.fields), len(record))}
+	}
+	if errors.As(err, &parseErr) {
+		return nil, &formatError{row: in.row, err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	task := &importTask{}
+	for i, value := range record {
+		if value == "" {
+			continue
+		}
+		if err := task.set(in.fields[i], value); err != nil {
+			return task, &rowError{err: err}
+		}
+	}
+	return task, nil
+}
+
+func (in *csvImport) readHeader() error {
+	headers, err := in.reader.Read()
+	if err == io.EOF {
+		return &formatError{err: errors.New("CSV header is missing")}
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &formatError{err: err}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Excel начинает UTF-8 с BOM
+	headers[0] = strings.TrimPrefix(headers[0], "\ufeff")
+	in.fields = make([]string, len(headers))
+	for i, header := range headers {
+		header = strings.TrimSpace(header)
+		index := slices.IndexFunc(in.columns, func(column csvColumn) bool { return strings.EqualFold(column.header, header) })
+		switch {
+		case index >= 0:
+			in.fields[i] = in.columns[index].field
+		case isCSVField(header) && header != csvSkip:
+			in.fields[i] = header
+		default:
+			return &formatError{err: fmt.Errorf("unknown CSV column %q", header)}
+		}
+	}
+	return nil
+}
+
+// set задает поле задачи из ячейки CSV.
+func (t *importTask) set(field string, value string) error {
+	number := func() (*int, error) {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: expected an integer", field)
+		}
+		return &parsed, nil
+	}
+	if field == csvSkip || slices.Contains(csvReadOnly, field) {
+		return nil
+	}
+	var err error
+	switch field {
+	case "external_id":
+		t.ExternalID = &value
+	case "title":
+		t.Title = &value
+	case "description":
+		t.Description = &value
+	case "status":
+		t.Status = value
+	case "due_date":
+		t.DueDate = &value
+	case "project_id":
+		t.ProjectID, err = number()
+	case "project":
+		t.Project = value
+	case "estimate_minutes":
+		t.EstimateMinutes, err = number()
+	case "assignees", "watchers":
+		var ids []int
+		if ids, err = splitIDs(value); err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		if field == "assignees" {
+			t.Assignees = &ids
+		} else {
+			t.Watchers = &ids
+		}
+	case "custom_fields":
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(value), &fields); err != nil {
+			return fmt.Errorf("custom_fields: expected a JSON object")
+		}
+		if t.CustomFields == nil {
+			t.CustomFields = map[string]any{}
+		}
+		for key, fieldValue := range fields {
+			t.CustomFields[key] = fieldValue
+		}
+	default:
+		key, _ := strings.CutPrefix(field, customFieldParam)
+		if t.fields == nil {
+			t.fields = map[string]string{}
+		}
+		t.fields[key] = value
+	}
+	return err
+}
+
+// csvFieldValue приводит значение колонки cf.<key> к типу поля проекта. Неизвестное поле
+// остается строкой: его отвергнет проверка пользовательских полей.
+func csvFieldValue(fields []*db.CustomField, key string, value string) (any, error) {
+	index := slices.IndexFunc(fields, func(field *db.CustomField) bool { return field.Key == key })
+	if index < 0 {
+		return value, nil
+	}
+	switch fields[index].Type {
+	case db.FieldNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected number")
+		}
+		return number, nil
+	case db.FieldBool:
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false")
+		}
+		return flag, nil
+	}
+	return value, nil
+}
+
+// ImportReport - итог загрузки: что стало с каждой записью.
+type ImportReport struct {
+	DryRun bool `json:"dry_run"`
+	// Committed - изменения сохранены. Загрузка с ошибками не сохраняет ничего.
+	Committed bool         `json:"committed"`
+	Total     int          `json:"total"`
+	Created   int          `json:"created"`
+	Updated   int          `json:"updated"`
+	Failed    int          `json:"failed"`
+	Rows      []*ImportRow `json:"rows"`
+}
+
+// ImportRow - итог записи загрузки. Row - номер записи с 1, TaskID не заполняется при
+// проверке без сохранения.
+type ImportRow struct {
+	Row        int    `json:"row"`
+	Action     string `json:"action"`
+	TaskID     int    `json:"task_id,omitempty"`
+	ExternalID string `json:"external_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// eventBuffer копит события загрузки до конца транзакции: откаченные изменения не должны
+// дойти до подписчиков.
+type eventBuffer struct {
+	events []events.Event
+}
+
+func (b *eventBuffer) Publish(event events.Event) {
+	b.events = append(b.events, event)
+}
+
+// ImportTasks загружает задачи в одной транзакции. Запись с external_id, которая уже есть
+// в пространстве, меняет задачу, остальные создают новые. Каждая запись проверяется теми же
+// правилами, что и в /tasks; если хотя бы одна не прошла, или dryRun, транзакция
+// откатывается. Ошибка возвращается, только если загрузку нельзя дочитать.
+func (h *Handler) ImportTasks(ctx context.Context, reader importReader, dryRun bool) (*ImportReport, error) {
+	if h.transfer == nil {
+		return nil, db.ErrNoTransactions
+	}
+	ctx, tx, err := h.transfer.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	buffer := &eventBuffer{}
+	rows := *h
+	rows.events = buffer
+	projects, err := rows.projectIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{DryRun: dryRun, Rows: []*ImportRow{}}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *rowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return nil, err
+		}
+
+		report.Total++
+		row := &ImportRow{Row: report.Total}
+		report.Rows = append(report.Rows, row)
+		if record != nil && record.ExternalID != nil {
+			row.ExternalID = *record.ExternalID
+		}
+		if err == nil {
+			mark := len(buffer.events)
+			var task *db.Task
+			err = tx.Savepoint(func() error {
+				var err error
+				task, row.Action, err = rows.importTask(ctx, record, projects)
+				return err
+			})
+			if err != nil {
+				buffer.events = buffer.events[:mark]
+			} else if !dryRun {
+				row.TaskID = task.ID
+			}
+		}
+
+		switch {
+		case err != nil:
+			row.Action, row.Error = importFailed, err.Error()
+			report.Failed++
+		case row.Action == importCreated:
+			report.Created++
+		default:
+			report.Updated++
+		}
+	}
+
+	if dryRun || report.Failed > 0 {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	report.Committed = true
+	if h.events != nil {
+		for _, event := range buffer.events {
+			h.events.Publish(event)
+		}
+	}
+	return report, nil
+}
+
+// projectIDs возвращает ID доступных проектов по названиям. У одноименных проектов ID 0:
+// по названию их не различить.
+func (h *Handler) projectIDs(ctx context.Context) (map[string]int, error) {
+	projects, err := h.projects.GetAllProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]int, len(projects))
+	for _, project := range projects {
+		if _, ok := ids[project.Name]; ok {
+			ids[project.Name] = 0
+		} else {
+			ids[project.Name] = project.ID
+		}
+	}
+	return ids, nil
+}
+
+// importTask создает или меняет задачу записи и возвращает ее вместе с действием.
+func (h *Handler) importTask(ctx context.Context, record *importTask, projects map[string]int) (*db.Task, string, error) {
+	input := &record.TaskInput
+	if record.Project != "" {
+		id, ok := projects[record.Project]
+		switch {
+		case !ok:
+			return nil, importFailed, &InputError{Message: "Validation error", Err: fmt.Errorf("unknown project %q", record.Project)}
+		case id == 0:
+			return nil, importFailed, &InputError{Message: "Validation error", Err: fmt.Errorf("several projects are named %q, use project_id", record.Project)}
+		}
+		input.ProjectID = &id
+	}
+
+	var current *db.Task
+	if input.ExternalID != nil && *input.ExternalID != "" {
+		id, err := h.transfer.GetTaskIDByExternalID(ctx, *input.ExternalID)
+		switch {
+		case err == nil:
+			// Чужая задача с тем же external_id не видна и не меняется
+			if current, err = h.repo.GetTaskById(ctx, id); err != nil {
+				return nil, importFailed, fmt.Errorf("external_id %q: %w", *input.ExternalID, err)
+			}
+		case !errors.Is(err, db.ErrTaskNotFound):
+			return nil, importFailed, err
+		}
+	}
+
+	if len(record.fields) > 0 {
+		var projectID int
+		if current != nil {
+			projectID = current.ProjectID
+		}
+		if input.ProjectID != nil {
+			projectID = *input.ProjectID
+		}
+		fields, err := h.projectFields(ctx, projectID)
+		if err != nil {
+			return nil, importFailed, err
+		}
+		if input.CustomFields == nil {
+			input.CustomFields = map[string]any{}
+		}
+		for key, value := range record.fields {
+			converted, err := csvFieldValue(fields, key, value)
+			if err != nil {
+				return nil, importFailed, &InputError{Message: "Validation error", Err: fmt.Errorf("%s%s: %w", customFieldParam, key, err)}
+			}
+			input.CustomFields[key] = converted
+		}
+	}
+
+	action := importCreated
+	var task *db.Task
+	var err error
+	if current != nil {
+		action = importUpdated
+		// Запись загрузки принимает срок датой и при изменении задачи: задача истекает в конце дня
+		if input.DueDate != nil && isDate(*input.DueDate) {
+			*input.DueDate += " 23:59:59"
+		}
+		task, err = h.UpdateTask(ctx, current.ID, input)
+	} else {
+		task, err = h.CreateTask(ctx, input)
+	}
+	if err != nil {
+		return nil, action, err
+	}
+	if record.Status != "" && record.Status != task.Status {
+		task, err = h.TransitionTask(ctx, task.ID, record.Status)
+	}
+	return task, action, err
+}
+
+// POST /import?format=json|ndjson|csv&dry_run=true - Загрузить задачи. Формат можно указать
+// и в Content-Type. Отвечает отчетом по записям: 200, если загрузка сохранена или это
+// проверка (dry_run), 422, если есть ошибки и ничего не сохранено.
+func (h *TransferHandler) importTasks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if _, ok := auth.FromContext(ctx); !ok {
+		writeTaskError(w, "Failed to import tasks", auth.ErrUnauthenticated)
+		return
+	}
+	query := r.URL.Query()
+
+	dryRun := false
+	if value := query.Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "Validation error: invalid dry_run, expected true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = formatFromContentType(r.Header.Get("Content-Type"))
+	}
+	body := http.MaxBytesReader(w, r.Body, h.maxImportSize)
+	var reader importReader
+	switch format {
+	case formatJSON, formatNDJSON:
+		reader = newJSONImport(body)
+	case formatCSV:
+		columns, err := h.csvColumns(query)
+		if err != nil {
+			writeTaskError(w, "Failed to import tasks", err)
+			return
+		}
+		reader = newCSVImport(body, columns)
+	default:
+		http.Error(w, fmt.Sprintf("Validation error: unknown format %q, expected json, ndjson or csv", format), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.handler.ImportTasks(ctx, reader, dryRun)
+	var maxBytesErr *http.MaxBytesError
+	var formatErr *formatError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		http.Error(w, fmt.Sprintf("Import is too large, max %d bytes", h.maxImportSize), http.StatusRequestEntityTooLarge)
+		return
+	case errors.As(err, &formatErr):
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	case err != nil:
+		writeTaskError(w, "Failed to import tasks", err)
+		return
+	}
+
+	status := http.StatusOK
+	if report.Failed > 0 && !dryRun {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, report)
+}
+
+// formatFromContentType определяет формат загрузки по Content-Type; по умолчанию JSON.
+func formatFromContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return formatCSV
+	case "application/x-ndjson", "application/ndjson":
+		return formatNDJSON
+	}
+	return formatJSON
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"todo/internal/db"
+	"todo/internal/events"
+)
+
+func validateWebhookInput(input *db.WebhookInput, requireURL bool) error {
+	if input.URL == nil {
+		if requireURL {
+			return fmt.Errorf("url is required")
+		}
+	} else {
+		u, err := url.Parse(*input.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url must be an absolute http(s) URL")
+		}
+	}
+
+	for _, event := range input.Events {
+		if event != "*" && !slices.Contains(events.Types, event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+
+	return nil
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (h *Handler) HandleWebhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.getWebhooks(w, r)
+	case "POST":
+		h.createWebhook(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) HandleWebhookByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.getWebhook(w, r, id)
+	case "PUT":
+		h.updateWebhook(w, r, id)
+	case "DELETE":
+		h.deleteWebhook(w, r, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) HandleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "GET" {
+		h.getWebhookDeliveries(w, r, id)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// GET /webhooks - Получить подписки. Секреты не возвращаются.
+func (h *Handler) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhooks.GetWebhooks()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to retrieve webhooks: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+	writeJSON(w, http.StatusOK, webhooks)
+}
+
+// POST /webhooks - Создать подписку. Секрет возвращается только в этом ответе.
+func (h *Handler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var input db.WebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateWebhookInput(&input, true); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	webhook := &db.Webhook{URL: *input.URL, Events: input.Events, Active: true}
+	if len(webhook.Events) == 0 {
+		webhook.Events = []string{"*"}
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+	if input.Secret != nil && *input.Secret != "" {
+		webhook.Secret = *input.Secret
+	} else {
+		secret, err := generateSecret()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to generate secret: %v", err), http.StatusInternalServerError)
+			return
+		}
+		webhook.Secret = secret
+	}
+
+	webhook, err := h.webhooks.CreateWebhook(webhook)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, webhook)
+}
+
+// GET /webhooks/{id} - Получить подписку
+func (h *Handler) getWebhook(w http.ResponseWriter, r *http.Request, id int) {
+	webhook, err := h.webhooks.GetWebhookById(id)
+	if errors.Is(err, db.ErrWebhookNotFound) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to retrieve webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	webhook.Secret = ""
+	writeJSON(w, http.StatusOK, webhook)
+}
+
+// PUT /webhooks/{id} - Обновить подписку
+func (h *Handler) updateWebhook(w http.ResponseWriter, r *http.Request, id int) {
+	webhook, err := h.webhooks.GetWebhookById(id)
+	if errors.Is(err, db.ErrWebhookNotFound) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to retrieve webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var input db.WebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateWebhookInput(&input, false); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	webhook.URL = ifEmptyUseCurrent(input.URL, webhook.URL)
+	webhook.Secret = ifEmptyUseCurrent(input.Secret, webhook.Secret)
+	if len(input.Events) > 0 {
+		webhook.Events = input.Events
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+
+	if err := h.webhooks.UpdateWebhook(webhook); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	webhook.Secret = ""
+	writeJSON(w, http.StatusOK, webhook)
+}
+
+// DELETE /webhooks/{id} - Удалить подписку вместе с историей доставок
+func (h *Handler) deleteWebhook(w http.ResponseWriter, r *http.Request, id int) {
+	count, err := h.webhooks.DeleteWebhook(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if count > 0 {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// GET /webhooks/{id}/deliveries?limit=N - Последние доставки подписки
+func (h *Handler) getWebhookDeliveries(w http.ResponseWriter, r *http.Request, id int) {
+	if _, err := h.webhooks.GetWebhookById(id); errors.Is(err, db.ErrWebhookNotFound) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 500 {
+			http.Error(w, "Invalid limit, expected 1..500", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	deliveries, err := h.webhooks.GetDeliveries(id, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to retrieve deliveries: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"todo/internal/auth"
+	"todo/internal/db"
+)
+
+const (
+	maxEstimateMinutes = 100000
+	maxWorklogMinutes  = 24 * 60
+	maxWorklogNote     = 1000
+)
+
+// TimeReport - ответ GET /reports/time. From и To - включительные даты периода.
+type TimeReport struct {
+	From         string              `json:"from,omitempty"`
+	To           string              `json:"to,omitempty"`
+	GroupBy      string              `json:"group_by"`
+	TotalSeconds int                 `json:"total_seconds"`
+	TotalMinutes int                 `json:"total_minutes"`
+	Rows         []*db.TimeReportRow `json:"rows"`
+}
+
+// parseWorklogTime принимает время в формате 2006-01-02 15:04:05 или дату 2006-01-02 (начало дня).
+func parseWorklogTime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid started_at format, expected YYYY-MM-DD or YYYY-MM-DD HH:MM:SS")
+}
+
+func validateWorklogInput(input *db.WorklogInput) error {
+	if input.Minutes == nil {
+		return fmt.Errorf("minutes is required")
+	}
+	if *input.Minutes <= 0 || *input.Minutes > maxWorklogMinutes {
+		return fmt.Errorf("minutes must be between 1 and %d", maxWorklogMinutes)
+	}
+	if input.Note != nil && len(*input.Note) > maxWorklogNote {
+		return fmt.Errorf("note is too long, max %d bytes", maxWorklogNote)
+	}
+	if input.StartedAt != nil {
+		if _, err := parseWorklogTime(*input.StartedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func currentUserID(ctx context.Context) int {
+	if principal, ok := auth.FromContext(ctx); ok {
+		return principal.UserID
+	}
+	return 0
+}
+
+// StartTimer запускает таймер текущего пользователя на задаче.
+func (h *Handler) StartTimer(ctx context.Context, taskID int) (*db.Worklog, error) {
+	return h.worklogs.StartTimer(ctx, taskID, currentUserID(ctx))
+}
+
+// StopTimer останавливает таймер текущего пользователя на задаче.
+func (h *Handler) StopTimer(ctx context.Context, taskID int) (*db.Worklog, error) {
+	return h.worklogs.StopTimer(ctx, taskID, currentUserID(ctx))
+}
+
+// CreateWorklog записывает время вручную. Без started_at считается, что работа только что закончилась.
+func (h *Handler) CreateWorklog(ctx context.Context, taskID int, input *db.WorklogInput) (*db.Worklog, error) {
+	if err := validateWorklogInput(input); err != nil {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+
+	seconds := *input.Minutes * 60
+	started := time.Now().Add(-time.Duration(seconds) * time.Second)
+	if input.StartedAt != nil {
+		started, _ = parseWorklogTime(*input.StartedAt)
+	}
+
+	worklog := &db.Worklog{TaskID: taskID, StartedAt: started.Format("2006-01-02 15:04:05"), Seconds: seconds}
+	if input.Note != nil {
+		worklog.Note = strings.TrimSpace(*input.Note)
+	}
+	return h.worklogs.CreateWorklog(ctx, worklog)
+}
+
+// StopStaleTimers останавливает таймеры, идущие дольше TimerAutoStop, в пространстве из ctx.
+func (h *Handler) StopStaleTimers(ctx context.Context) ([]*db.Worklog, error) {
+	if h.TimerAutoStop <= 0 {
+		return nil, nil
+	}
+	return h.worklogs.StopStaleTimers(ctx, h.TimerAutoStop)
+}
+
+// TimeReport строит отчет по времени за период from..to включительно (YYYY-MM-DD, границы необязательны).
+func (h *Handler) TimeReport(ctx context.Context, from string, to string, groupBy string) (*TimeReport, error) {
+	if groupBy == "" {
+		groupBy = db.GroupByTask
+	}
+	report := &TimeReport{From: from, To: to, GroupBy: groupBy}
+	filter := &db.TimeReportFilter{GroupBy: groupBy}
+
+	var fromDate, toDate time.Time
+	var err error
+	if from != "" {
+		if fromDate, err = time.Parse("2006-01-02", from); err != nil {
+			return nil, &InputError{Message: "Validation error", Err: errors.New("invalid from format, expected YYYY-MM-DD")}
+		}
+		filter.From = fromDate.Format("2006-01-02 15:04:05")
+	}
+	if to != "" {
+		if toDate, err = time.Parse("2006-01-02", to); err != nil {
+			return nil, &InputError{Message: "Validation error", Err: errors.New("invalid to format, expected YYYY-MM-DD")}
+		}
+		filter.To = toDate.AddDate(0, 0, 1).Format("2006-01-02 15:04:05")
+	}
+	if from != "" && to != "" && toDate.Before(fromDate) {
+		return nil, &InputError{Message: "Validation error", Err: errors.New("to is before from")}
+	}
+
+	rows, err := h.worklogs.GetTimeReport(ctx, filter)
+	if errors.Is(err, db.ErrUnknownGroupBy) {
+		return nil, &InputError{Message: "Validation error", Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		report.TotalSeconds += row.Seconds
+		if groupBy == db.GroupByUser {
+			if user, err := h.users.GetUserById(row.ID); err == nil {
+				row.Name = user.Username
+			}
+		}
+	}
+	report.TotalMinutes = db.Minutes(report.TotalSeconds)
+	report.Rows = rows
+	return report, nil
+}
+
+func (h *Handler) HandleTaskTimer(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.PathValue("action") {
+	case "start":
+		h.startTimer(w, r, taskID)
+	case "stop":
+		h.stopTimer(w, r, taskID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) HandleTaskWorklogs(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.getWorklogs(w, r, taskID)
+	case "POST":
+		h.createWorklog(w, r, taskID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) HandleTaskWorklog(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	worklogID, err := strconv.Atoi(r.PathValue("worklogID"))
+	if err != nil {
+		http.Error(w, "Invalid worklog ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "DELETE" {
+		h.deleteWorklog(w, r, taskID, worklogID)
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /me/timer - Запущенный таймер текущего пользователя; 204, если таймер не идет
+func (h *Handler) HandleMyTimer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	timer, err := h.worklogs.GetRunningTimer(r.Context(), currentUserID(r.Context()))
+	if errors.Is(err, db.ErrTimerNotRunning) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve timer", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, timer)
+}
+
+// GET /reports/time?from=YYYY-MM-DD&to=YYYY-MM-DD&group_by=task|project|user - Затраченное время
+func (h *Handler) HandleTimeReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	report, err := h.TimeReport(r.Context(), query.Get("from"), query.Get("to"), query.Get("group_by"))
+	if err != nil {
+		writeTaskError(w, "Failed to build time report", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// POST /tasks/{id}/timer/start - Запустить таймер
+func (h *Handler) startTimer(w http.ResponseWriter, r *http.Request, taskID int) {
+	timer, err := h.StartTimer(r.Context(), taskID)
+	if err != nil {
+		writeTaskError(w, "Failed to start timer", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, timer)
+}
+
+// POST /tasks/{id}/timer/stop - Остановить таймер и записать время
+func (h *Handler) stopTimer(w http.ResponseWriter, r *http.Request, taskID int) {
+	worklog, err := h.StopTimer(r.Context(), taskID)
+	if err != nil {
+		writeTaskError(w, "Failed to stop timer", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, worklog)
+}
+
+// GET /tasks/{id}/worklogs - Записи о времени по задаче
+func (h *Handler) getWorklogs(w http.ResponseWriter, r *http.Request, taskID int) {
+	worklogs, err := h.worklogs.GetWorklogs(r.Context(), taskID)
+	if err != nil {
+		writeTaskError(w, "Failed to retrieve worklogs", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, worklogs)
+}
+
+// POST /tasks/{id}/worklogs - Записать время вручную
+func (h *Handler) createWorklog(w http.ResponseWriter, r *http.Request, taskID int) {
+	var input db.WorklogInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	worklog, err := h.CreateWorklog(r.Context(), taskID, &input)
+	if err != nil {
+		writeTaskError(w, "Failed to create worklog", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, worklog)
+}
+
+// DELETE /tasks/{id}/worklogs/{worklogID} - Удалить запись о времени
+func (h *Handler) deleteWorklog(w http.ResponseWriter, r *http.Request, taskID int, worklogID int) {
+	count, err := h.worklogs.DeleteWorklog(r.Context(), taskID, worklogID)
+	if err != nil {
+		writeTaskError(w, "Failed to delete worklog", err)
+		return
+	}
+
+	if count > 0 {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
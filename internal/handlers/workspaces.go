@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"todo/internal/db"
+	"todo/internal/tenant"
+)
+
+// WorkspaceHandler - API администратора для управления рабочими пространствами.
+type WorkspaceHandler struct {
+	tenants    *tenant.Manager
+	workspaces db.WorkspaceRepo
+	users      db.UserRepo
+}
+
+func NewWorkspaceHandler(tenants *tenant.Manager, repo *db.TaskRepository) *WorkspaceHandler {
+	return &WorkspaceHandler{tenants: tenants, workspaces: repo, users: repo}
+}
+
+type WorkspaceMemberInput struct {
+	UserID   int    `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+func validateWorkspaceInput(input *db.WorkspaceInput) error {
+	if input.Status != nil && *input.Status != db.WorkspaceActive && *input.Status != db.WorkspaceSuspended {
+		return fmt.Errorf("unknown status %q", *input.Status)
+	}
+	if input.StorageQuota != nil && *input.StorageQuota < 0 {
+		return fmt.Errorf("storage_quota must not be negative")
+	}
+	return nil
+}
+
+func writeWorkspaceError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, db.ErrWorkspaceNotFound):
+		http.Error(w, "Workspace not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrWorkspaceExists):
+		http.Error(w, "Workspace already exists", http.StatusConflict)
+	case errors.Is(err, tenant.ErrInUse), errors.Is(err, tenant.ErrDefaultWorkspace):
+		http.Error(w, fmt.Sprintf("%s: %v", message, err), http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf("%s: %v", message, err), http.StatusInternalServerError)
+	}
+}
+
+func (h *WorkspaceHandler) workspace(w http.ResponseWriter, r *http.Request) (*db.Workspace, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	workspace, err := h.workspaces.GetWorkspaceById(id)
+	if err != nil {
+		writeWorkspaceError(w, "Failed to retrieve workspace", err)
+		return nil, false
+	}
+	return workspace, true
+}
+
+// GET /admin/workspaces - Список пространств
+// POST /admin/workspaces - Создать пространство
+func (h *WorkspaceHandler) HandleWorkspaces(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		workspaces, err := h.workspaces.GetWorkspaces()
+		if err != nil {
+			writeWorkspaceError(w, "Failed to retrieve workspaces", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, workspaces)
+	case "POST":
+		h.createWorkspace(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *WorkspaceHandler) createWorkspace(w http.ResponseWriter, r *http.Request) {
+	var input db.WorkspaceInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if input.Slug == nil || !tenant.ValidSlug(*input.Slug) {
+		http.Error(w, "Validation error: slug must match [a-z0-9][a-z0-9-]{0,62}", http.StatusBadRequest)
+		return
+	}
+	if err := validateWorkspaceInput(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	workspace := &db.Workspace{Slug: *input.Slug, Name: *input.Slug, Status: db.WorkspaceActive}
+	if input.Name != nil && strings.TrimSpace(*input.Name) != "" {
+		workspace.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Status != nil {
+		workspace.Status = *input.Status
+	}
+	if input.StorageQuota != nil {
+		workspace.StorageQuota = *input.StorageQuota
+	}
+
+	workspace, err := h.tenants.Create(workspace)
+	if err != nil {
+		writeWorkspaceError(w, "Failed to create workspace", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, workspace)
+}
+
+// GET /admin/workspaces/{id} - Получить пространство
+// PATCH /admin/workspaces/{id} - Переименовать, приостановить, возобновить пространство или сменить квоту
+// DELETE /admin/workspaces/{id} - Удалить пространство со всеми данными
+func (h *WorkspaceHandler) HandleWorkspaceByID(w http.ResponseWriter, r *http.Request) {
+	workspace, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, workspace)
+	case "PATCH":
+		h.updateWorkspace(w, r, workspace)
+	case "DELETE":
+		if err := h.tenants.Delete(workspace); err != nil {
+			writeWorkspaceError(w, "Failed to delete workspace", err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *WorkspaceHandler) updateWorkspace(w http.ResponseWriter, r *http.Request, workspace *db.Workspace) {
+	var input db.WorkspaceInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if input.Slug != nil && *input.Slug != workspace.Slug {
+		http.Error(w, "Validation error: slug cannot be changed", http.StatusBadRequest)
+		return
+	}
+	if err := validateWorkspaceInput(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if input.Name != nil && strings.TrimSpace(*input.Name) != "" {
+		workspace.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Status != nil {
+		workspace.Status = *input.Status
+	}
+	if input.StorageQuota != nil {
+		workspace.StorageQuota = *input.StorageQuota
+	}
+
+	if err := h.workspaces.UpdateWorkspace(workspace); err != nil {
+		writeWorkspaceError(w, "Failed to update workspace", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, workspace)
+}
+
+// GET /admin/workspaces/{id}/members - Участники пространства
+// POST /admin/workspaces/{id}/members - Добавить участника по user_id или username
+func (h *WorkspaceHandler) HandleWorkspaceMembers(w http.ResponseWriter, r *http.Request) {
+	workspace, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		members, err := h.workspaces.GetWorkspaceMembers(workspace.ID)
+		if err != nil {
+			writeWorkspaceError(w, "Failed to retrieve members", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, members)
+	case "POST":
+		h.addMember(w, r, workspace)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *WorkspaceHandler) addMember(w http.ResponseWriter, r *http.Request, workspace *db.Workspace) {
+	var input WorkspaceMemberInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		user *db.User
+		err  error
+	)
+	switch {
+	case input.UserID != 0:
+		user, err = h.users.GetUserById(input.UserID)
+	case input.Username != "":
+		user, err = h.users.GetUserByUsername(input.Username)
+	default:
+		err = fmt.Errorf("user_id or username is required")
+	}
+	if errors.Is(err, db.ErrUserNotFound) {
+		http.Error(w, "Validation error: user not found", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.workspaces.AddWorkspaceMember(workspace.ID, user.ID); err != nil {
+		writeWorkspaceError(w, "Failed to add member", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// DELETE /admin/workspaces/{id}/members/{userID} - Исключить участника
+func (h *WorkspaceHandler) HandleWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workspace, ok := h.workspace(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := h.workspaces.RemoveWorkspaceMember(workspace.ID, userID)
+	if err != nil {
+		writeWorkspaceError(w, "Failed to remove member", err)
+		return
+	}
+	if removed == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+	"todo/internal/auth"
+	"todo/internal/db"
+	"todo/internal/events"
+	"todo/pkg/websocket"
+)
+
+const (
+	wsSendQueue    = 64
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsReadLimit    = 64 << 10
+)
+
+// WSRequest - кадр клиента. Type: subscribe, unsubscribe, view, leave,
+// create, update, move, complete, delete. ID возвращается в ack/error.
+type WSRequest struct {
+	ID          string        `json:"id,omitempty"`
+	Type        string        `json:"type"`
+	TaskID      int           `json:"task_id,omitempty"`
+	TaskIDs     []int         `json:"task_ids,omitempty"`
+	ProjectIDs  []int         `json:"project_ids,omitempty"`
+	Events      []string      `json:"events,omitempty"`
+	LastEventID string        `json:"last_event_id,omitempty"`
+	Status      string        `json:"status,omitempty"`
+	Task        *db.TaskInput `json:"task,omitempty"`
+}
+
+// WSResponse - кадр сервера. Type: ack, error, event, reset, presence.
+type WSResponse struct {
+	Type    string        `json:"type"`
+	ID      string        `json:"id,omitempty"`
+	Task    *db.Task      `json:"task,omitempty"`
+	EventID string        `json:"event_id,omitempty"`
+	Event   *events.Event `json:"event,omitempty"`
+	TaskID  int           `json:"task_id,omitempty"`
+	Viewers []string      `json:"viewers,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Code    int           `json:"code,omitempty"`
+}
+
+// WSHandler - двусторонний канал для доски задач. Операции выполняются той же логикой,
+// что и REST-обработчики, события приходят из общего хаба.
+type WSHandler struct {
+	handler *Handler
+	hub     *events.Hub
+
+	mu       sync.Mutex
+	clients  map[*wsClient]struct{}
+	presence map[presenceKey]map[*wsClient]struct{}
+	closed   bool
+}
+
+// presenceKey - задача в пространстве: в режиме file ID задач разных пространств совпадают.
+type presenceKey struct {
+	workspace string
+	taskID    int
+}
+
+func NewWSHandler(handler *Handler, hub *events.Hub) *WSHandler {
+	return &WSHandler{
+		handler:  handler,
+		hub:      hub,
+		clients:  make(map[*wsClient]struct{}),
+		presence: make(map[presenceKey]map[*wsClient]struct{}),
+	}
+}
+
+type wsClient struct {
+	conn      *websocket.Conn
+	ctx       context.Context
+	principal *auth.Principal
+	user      string
+	workspace string
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	subMu sync.Mutex
+	sub   *events.Subscription
+}
+
+// GET /ws - WebSocket API. Операции выполняются от имени пользователя, открывшего соединение.
+func (h *WSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := websocket.Accept(w, r)
+	if err != nil {
+		return
+	}
+	conn.SetReadLimit(wsReadLimit)
+
+	var workspace string
+	if bound, ok := db.WorkspaceFromContext(r.Context()); ok {
+		workspace = bound.Slug
+	}
+
+	// Контекст соединения сохраняет принципала и пространство запроса, но не отменяется
+	// вместе с ним
+	client := &wsClient{
+		conn:      conn,
+		ctx:       context.WithoutCancel(r.Context()),
+		principal: principal,
+		user:      principal.Username,
+		workspace: workspace,
+		send:      make(chan []byte, wsSendQueue),
+		done:      make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		conn.WriteClose(websocket.CloseGoingAway, "server shutdown")
+		conn.Close()
+		return
+	}
+	h.clients[client] = struct{}{}
+	h.mu.Unlock()
+
+	go h.writeLoop(client)
+	h.readLoop(client)
+}
+
+// Shutdown закрывает все соединения. Hijacked-соединения не отслеживаются server.Shutdown.
+func (h *WSHandler) Shutdown() {
+	h.mu.Lock()
+	h.closed = true
+	clients := make([]*wsClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.Unlock()
+
+	for _, client := range clients {
+		client.close(websocket.CloseGoingAway, "server shutdown")
+	}
+}
+
+func (c *wsClient) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.WriteClose(code, reason)
+		c.conn.Close()
+	})
+}
+
+// enqueue ставит в очередь широковещательный кадр. Если клиент не успевает читать,
+// соединение закрывается: клиент переподключится и дочитает события по last_event_id.
+func (c *wsClient) enqueue(frame []byte) {
+	select {
+	case c.send <- frame:
+	case <-c.done:
+	default:
+		c.close(websocket.CloseTryAgainLater, "slow consumer")
+	}
+}
+
+// reply ставит в очередь ответ на запрос клиента. Ожидание места в очереди
+// приостанавливает чтение новых запросов - так медленный клиент сам себя притормаживает.
+func (c *wsClient) reply(response WSResponse) {
+	frame, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- frame:
+	case <-c.done:
+	}
+}
+
+func (h *WSHandler) writeLoop(client *wsClient) {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-client.done:
+			return
+		case frame := <-client.send:
+			err = client.conn.WriteMessage(websocket.TextMessage, frame, time.Now().Add(wsWriteTimeout))
+		case <-ping.C:
+			err = client.conn.WriteMessage(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+		}
+		if err != nil {
+			client.close(websocket.CloseGoingAway, "write failed")
+			return
+		}
+	}
+}
+
+func (h *WSHandler) readLoop(client *wsClient) {
+	defer h.disconnect(client)
+
+	client.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	client.conn.SetPongHandler(func([]byte) {
+		client.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		opcode, data, err := client.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				client.close(websocket.CloseGoingAway, "")
+			}
+			return
+		}
+		client.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		if opcode != websocket.TextMessage {
+			client.reply(WSResponse{Type: "error", Code: http.StatusBadRequest, Error: "only text frames are supported"})
+			continue
+		}
+
+		var request WSRequest
+		if err := json.Unmarshal(data, &request); err != nil {
+			client.reply(WSResponse{Type: "error", Code: http.StatusBadRequest, Error: fmt.Sprintf("invalid frame: %v", err)})
+			continue
+		}
+
+		h.handle(client, &request)
+	}
+}
+
+func (h *WSHandler) disconnect(client *wsClient) {
+	client.close(websocket.CloseNormal, "")
+	h.setSubscription(client, nil)
+
+	h.mu.Lock()
+	delete(h.clients, client)
+	var left []presenceKey
+	for key, viewers := range h.presence {
+		if _, ok := viewers[client]; ok {
+			delete(viewers, client)
+			left = append(left, key)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, key := range left {
+		h.broadcastPresence(key)
+	}
+}
+
+func (h *WSHandler) handle(client *wsClient, request *WSRequest) {
+	var (
+		task *db.Task
+		err  error
+	)
+
+	switch request.Type {
+	case "create", "update", "move", "complete", "delete":
+		if !client.principal.HasScope(auth.ScopeTasksWrite) {
+			client.reply(WSResponse{Type: "error", ID: request.ID, Code: http.StatusForbidden, Error: auth.ErrForbidden.Error()})
+			return
+		}
+	}
+
+	switch request.Type {
+	case "subscribe":
+		// При успехе subscribe отвечает сам, чтобы ack пришел раньше событий
+		if err = h.subscribe(client, request); err == nil {
+			return
+		}
+	case "unsubscribe":
+		h.setSubscription(client, nil)
+	case "view":
+		err = h.setViewing(client, request.TaskID, true)
+	case "leave":
+		err = h.setViewing(client, request.TaskID, false)
+	case "create":
+		if request.Task == nil {
+			err = &InputError{Message: "Validation error", Err: errors.New("task is required")}
+			break
+		}
+		task, err = h.handler.CreateTask(client.ctx, request.Task)
+	case "update":
+		if request.Task == nil {
+			err = &InputError{Message: "Validation error", Err: errors.New("task is required")}
+			break
+		}
+		task, err = h.handler.UpdateTask(client.ctx, request.TaskID, request.Task)
+	case "move":
+		task, err = h.handler.TransitionTask(client.ctx, request.TaskID, request.Status)
+	case "complete":
+		task, err = h.handler.CompleteTask(client.ctx, request.TaskID)
+	case "delete":
+		var deleted bool
+		deleted, err = h.handler.DeleteTask(client.ctx, request.TaskID)
+		if err == nil && !deleted {
+			err = db.ErrTaskNotFound
+		}
+	default:
+		err = &InputError{Message: "Validation error", Err: fmt.Errorf("unknown frame type %q", request.Type)}
+	}
+
+	if err != nil {
+		client.reply(WSResponse{Type: "error", ID: request.ID, Code: taskErrorStatus(err), Error: err.Error()})
+		return
+	}
+
+	client.reply(WSResponse{Type: "ack", ID: request.ID, Task: task})
+}
+
+func (h *WSHandler) subscribe(client *wsClient, request *WSRequest) error {
+	for _, event := range request.Events {
+		if !slices.Contains(events.Types, event) {
+			return &InputError{Message: "Validation error", Err: fmt.Errorf("unknown event type %q", event)}
+		}
+	}
+
+	sub, replay, complete := h.hub.Subscribe(request.LastEventID, events.Filter{
+		Types: request.Events, TaskIDs: request.TaskIDs, ProjectIDs: request.ProjectIDs, Allow: h.handler.eventFilter(client.ctx),
+	})
+	h.setSubscription(client, sub)
+
+	client.reply(WSResponse{Type: "ack", ID: request.ID})
+	if !complete {
+		client.reply(WSResponse{Type: "reset"})
+	}
+	for _, envelope := range replay {
+		client.reply(eventResponse(envelope))
+	}
+
+	go func() {
+		for envelope := range sub.C {
+			frame, err := json.Marshal(eventResponse(envelope))
+			if err == nil {
+				client.enqueue(frame)
+			}
+		}
+		if sub.Dropped() {
+			client.close(websocket.CloseTryAgainLater, "slow consumer")
+		}
+	}()
+
+	return nil
+}
+
+func eventResponse(envelope events.Envelope) WSResponse {
+	event := envelope.Event
+	return WSResponse{Type: "event", EventID: envelope.ID, Event: &event}
+}
+
+func (h *WSHandler) setSubscription(client *wsClient, sub *events.Subscription) {
+	client.subMu.Lock()
+	previous := client.sub
+	client.sub = sub
+	client.subMu.Unlock()
+
+	if previous != nil {
+		h.hub.Unsubscribe(previous)
+	}
+}
+
+// setViewing отмечает, что клиент открыл или закрыл задачу. Открыть можно только доступную задачу.
+func (h *WSHandler) setViewing(client *wsClient, taskID int, viewing bool) error {
+	if viewing {
+		if _, err := h.handler.repo.GetTaskById(client.ctx, taskID); err != nil {
+			return err
+		}
+	}
+
+	key := presenceKey{workspace: client.workspace, taskID: taskID}
+	h.mu.Lock()
+	viewers := h.presence[key]
+	if viewing {
+		if viewers == nil {
+			viewers = make(map[*wsClient]struct{})
+			h.presence[key] = viewers
+		}
+		viewers[client] = struct{}{}
+	} else if _, ok := viewers[client]; ok {
+		delete(viewers, client)
+	} else {
+		h.mu.Unlock()
+		return nil
+	}
+	h.mu.Unlock()
+
+	h.broadcastPresence(key)
+	return nil
+}
+
+// Viewers возвращает имена пользователей, открывших задачу в пространстве workspace.
+func (h *WSHandler) Viewers(workspace string, taskID int) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.viewersLocked(presenceKey{workspace: workspace, taskID: taskID})
+}
+
+func (h *WSHandler) viewersLocked(key presenceKey) []string {
+	var names []string
+	for client := range h.presence[key] {
+		if !slices.Contains(names, client.user) {
+			names = append(names, client.user)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (h *WSHandler) broadcastPresence(key presenceKey) {
+	h.mu.Lock()
+	viewers := h.viewersLocked(key)
+	if len(h.presence[key]) == 0 {
+		delete(h.presence, key)
+	}
+	clients := make([]*wsClient, 0, len(h.clients))
+	for client := range h.clients {
+		if client.workspace == key.workspace {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.Unlock()
+
+	frame, err := json.Marshal(WSResponse{Type: "presence", TaskID: key.taskID, Viewers: viewers})
+	if err != nil {
+		slog.Error("failed to encode presence", "error", err)
+		return
+	}
+	// Присутствие видно только тем, кому доступна сама задача
+	for _, client := range clients {
+		if h.handler.canSee(client.ctx, key.taskID, 0) {
+			client.enqueue(frame)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"todo/internal/auth"
+	"todo/internal/db"
+	"todo/internal/events"
+	"todo/pkg/websocket"
+)
+
+func newWSTestServer(t *testing.T) (*httptest.Server, *MockRepository) {
+	mockRepo := NewMockRepository()
+	bus := events.NewBus()
+	hub := events.NewHub(100)
+	bus.Subscribe(hub.Publish)
+
+	handler := &Handler{repo: mockRepo, events: bus}
+	ws := NewWSHandler(handler, hub)
+	server := httptest.NewServer(withTestPrincipal(ws))
+	t.Cleanup(func() {
+		ws.Shutdown()
+		server.Close()
+	})
+
+	return server, mockRepo
+}
+
+// withTestPrincipal заменяет middleware аутентификации: принципал берется из ?user=.
+func withTestPrincipal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := &auth.Principal{Username: r.URL.Query().Get("user"), Role: auth.RoleAdmin, Via: "session"}
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+func dialWS(t *testing.T, server *httptest.Server, user string) *websocket.Conn {
+	conn, err := websocket.Dial(strings.Replace(server.URL, "http://", "ws://", 1)+"?user="+user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func sendWS(t *testing.T, conn *websocket.Conn, request WSRequest) {
+	data, _ := json.Marshal(request)
+	if err := conn.WriteMessage(websocket.TextMessage, data, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readWS читает кадры, пока не встретит кадр нужного типа.
+func readWS(t *testing.T, conn *websocket.Conn, frameType string) WSResponse {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %s frame: %v", frameType, err)
+		}
+		var response WSResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Type == frameType {
+			return response
+		}
+	}
+}
+
+func TestWSCreateAndEvents(t *testing.T) {
+	server, _ := newWSTestServer(t)
+	editor := dialWS(t, server, "alice")
+	watcher := dialWS(t, server, "bob")
+
+	sendWS(t, watcher, WSRequest{ID: "s1", Type: "subscribe", Events: []string{events.TaskCreated, events.TaskCompleted}})
+	if ack := readWS(t, watcher, "ack"); ack.ID != "s1" {
+		t.Fatalf("unexpected subscribe ack: %+v", ack)
+	}
+
+	title, dueDate := "From socket", "2099-01-01"
+	sendWS(t, editor, WSRequest{ID: "c1", Type: "create", Task: &db.TaskInput{Title: &title, DueDate: &dueDate}})
+	ack := readWS(t, editor, "ack")
+	if ack.ID != "c1" || ack.Task == nil || ack.Task.Title != title {
+		t.Fatalf("unexpected create ack: %+v", ack)
+	}
+
+	event := readWS(t, watcher, "event")
+	if event.Event.Type != events.TaskCreated || event.Event.TaskID != ack.Task.ID || event.EventID == "" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+
+	sendWS(t, editor, WSRequest{ID: "m1", Type: "move", TaskID: ack.Task.ID, Status: "review"})
+	if response := readWS(t, editor, "error"); response.ID != "m1" || response.Code != http.StatusConflict {
+		t.Errorf("expected conflict for todo -> review, got %+v", response)
+	}
+
+	sendWS(t, editor, WSRequest{ID: "d1", Type: "complete", TaskID: ack.Task.ID})
+	if response := readWS(t, editor, "ack"); response.ID != "d1" || !response.Task.IsCompleted {
+		t.Errorf("unexpected complete ack: %+v", response)
+	}
+
+	// task.updated отфильтрован подпиской, следующим приходит task.completed
+	if event := readWS(t, watcher, "event"); event.Event.Type != events.TaskCompleted {
+		t.Errorf("unexpected event: %+v", event.Event)
+	}
+}
+
+func TestWSPresence(t *testing.T) {
+	server, mockRepo := newWSTestServer(t)
+	mockRepo.tasks[5] = db.Task{ID: 5, Title: "Shared", Status: "todo"}
+	alice := dialWS(t, server, "alice")
+	bob := dialWS(t, server, "bob")
+
+	sendWS(t, alice, WSRequest{Type: "view", TaskID: 5})
+	readWS(t, alice, "ack")
+	sendWS(t, bob, WSRequest{Type: "view", TaskID: 5})
+	readWS(t, bob, "ack")
+
+	var presence WSResponse
+	for len(presence.Viewers) != 2 {
+		presence = readWS(t, alice, "presence")
+	}
+	if presence.TaskID != 5 || presence.Viewers[0] != "alice" || presence.Viewers[1] != "bob" {
+		t.Errorf("unexpected presence: %+v", presence)
+	}
+
+	bob.WriteClose(websocket.CloseNormal, "")
+	for len(presence.Viewers) != 1 {
+		presence = readWS(t, alice, "presence")
+	}
+	if presence.Viewers[0] != "alice" {
+		t.Errorf("unexpected presence after disconnect: %+v", presence)
+	}
+}
+
+func TestWSShutdownClosesConnections(t *testing.T) {
+	mockRepo := NewMockRepository()
+	mockRepo.tasks[1] = db.Task{ID: 1, Title: "Open", Status: "todo"}
+	ws := NewWSHandler(&Handler{repo: mockRepo}, events.NewHub(10))
+	server := httptest.NewServer(withTestPrincipal(ws))
+	defer server.Close()
+
+	conn := dialWS(t, server, "alice")
+	sendWS(t, conn, WSRequest{Type: "view", TaskID: 1})
+	readWS(t, conn, "ack")
+
+	ws.Shutdown()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		closeErr, ok := err.(*websocket.CloseError)
+		if !ok || closeErr.Code != websocket.CloseGoingAway {
+			t.Errorf("expected going away close, got %v", err)
+		}
+		return
+	}
+}
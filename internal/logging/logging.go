@@ -0,0 +1,78 @@
+// Package logging настраивает log/slog: формат вывода, уровень, который можно менять без
+// перезапуска, и логгер запроса, который передается через context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"todo/pkg/config"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// level общий для всех логгеров пакета: его меняет SetLevel, в том числе через API администратора.
+var level = new(slog.LevelVar)
+
+// New создает логгер с выводом в w. format - text или json, levelName - debug, info, warn или error.
+func New(w io.Writer, format string, levelName string) (*slog.Logger, error) {
+	if err := SetLevel(levelName); err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	switch format {
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+}
+
+// FromConfig создает логгер по секции log с выводом в stderr.
+func FromConfig(cfg config.Log) (*slog.Logger, error) {
+	return New(os.Stderr, cfg.Format, cfg.Level)
+}
+
+// Level возвращает текущий уровень в нижнем регистре: debug, info, warn или error.
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// SetLevel меняет уровень всех логгеров пакета.
+func SetLevel(name string) error {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+	}
+	level.Set(parsed)
+	return nil
+}
+
+type loggerKey struct{}
+
+// WithLogger привязывает logger к ctx.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext возвращает логгер из ctx или slog.Default, если его нет.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With добавляет атрибуты к логгеру из ctx.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, FormatJSON, "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetRoute(r.Context(), "/tasks/{id}")
+		seen = RequestID(r.Context())
+		FromContext(r.Context()).Debug("hidden")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest("POST", "/tasks/7", nil)
+	req = req.WithContext(WithLogger(req.Context(), logger))
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if seen != "abc-123" || rr.Header().Get(RequestIDHeader) != "abc-123" {
+		t.Fatalf("incoming request id is not used: %q %q", seen, rr.Header().Get(RequestIDHeader))
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON access log entry: %v: %s", err, out.String())
+	}
+	want := map[string]any{"msg": "request", "request_id": "abc-123", "method": "POST", "path": "/tasks/7",
+		"route": "/tasks/{id}", "status": 201.0, "size": 5.0, "client_ip": "192.0.2.1"}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+	if _, ok := entry["latency_ms"]; !ok {
+		t.Error("latency_ms is missing")
+	}
+
+	// Некорректный идентификатор заменяется новым, debug появляется после смены уровня
+	out.Reset()
+	if err := SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(RequestIDHeader, "bad id\n")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if id := rr.Header().Get(RequestIDHeader); len(id) != 32 || id != seen {
+		t.Errorf("unexpected generated request id: %q (handler saw %q)", id, seen)
+	}
+	if !strings.Contains(out.String(), `"msg":"hidden"`) {
+		t.Errorf("debug entry is missing after level change: %s", out.String())
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	if err := SetLevel("WARN"); err != nil || Level() != "warn" {
+		t.Fatalf("unexpected level: %q %v", Level(), err)
+	}
+	if err := SetLevel("loud"); err == nil || Level() != "warn" {
+		t.Fatalf("unknown level is accepted: %q %v", Level(), err)
+	}
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Fatal("unknown format is accepted")
+	}
+	SetLevel("info")
+}
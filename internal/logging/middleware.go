@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	maxRequestID = 128
+)
+
+type requestKey struct{}
+
+// request - сведения о запросе, которые становятся известны уже внутри обработчиков.
+type request struct {
+	id    string
+	route string
+}
+
+// RequestID возвращает идентификатор текущего запроса или пустую строку вне запроса.
+func RequestID(ctx context.Context) string {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return req.id
+	}
+	return ""
+}
+
+// SetRoute запоминает шаблон маршрута (например, /tasks/{id}) для журнала доступа.
+func SetRoute(ctx context.Context, route string) {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		req.route = route
+	}
+}
+
+// Middleware назначает запросу идентификатор (принимает входящий X-Request-ID), возвращает его
+// в ответе, кладет в ctx логгер с request_id и пишет журнал доступа после ответа.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		req := &request{id: id}
+		logger := FromContext(r.Context()).With("request_id", id)
+		ctx := WithLogger(context.WithValue(r.Context(), requestKey{}, req), logger)
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		logLevel := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			logLevel = slog.LevelError
+		}
+		logger.LogAttrs(ctx, logLevel, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", req.route),
+			slog.Int("status", status),
+			slog.Int64("size", recorder.size),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", clientIP(r)),
+		)
+	})
+}
+
+// validRequestID пропускает только короткие идентификаторы из видимых ASCII-символов,
+// чтобы чужой заголовок не испортил журнал.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// responseRecorder запоминает статус и размер ответа. Flush и остальное доступны
+// через Unwrap для http.ResponseController.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+// Hijack отмечает переход на WebSocket: дальше соединением управляет обработчик.
+func (w *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
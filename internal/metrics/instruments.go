@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	HTTPRequests = register(NewCounterVec("http_requests_total",
+		"HTTP requests by method, route pattern and status.", "method", "route", "status"))
+	HTTPDuration = register(NewHistogramVec("http_request_duration_seconds",
+		"HTTP request latency by method, route pattern and status.", DefaultBuckets, "method", "route", "status"))
+
+	QueryDuration = register(NewHistogramVec("db_query_duration_seconds",
+		"Repository query duration by repository method.", QueryBuckets, "method"))
+
+	JobRuns = register(NewCounterVec("job_runs_total",
+		"Background job runs by job and result (success or error).", "job", "result"))
+	JobDuration = register(NewHistogramVec("job_duration_seconds",
+		"Background job run duration.", DefaultBuckets, "job"))
+	JobLastSuccess = register(NewGaugeVec("job_last_success_timestamp_seconds",
+		"Unix time of the last successful background job run.", "job"))
+)
+
+// unmatchedRoute - метка запросов, не попавших ни в один маршрут: путь в метку не идет,
+// чтобы число рядов не зависело от клиентов.
+const unmatchedRoute = "unmatched"
+
+// ServeHTTP выполняет запрос, записывает его в метрики HTTP с шаблоном маршрута route
+// и возвращает статус ответа.
+func ServeHTTP(w http.ResponseWriter, r *http.Request, route string, next http.Handler) int {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w}
+	next.ServeHTTP(recorder, r)
+
+	if route == "" {
+		route = unmatchedRoute
+	}
+	status := recorder.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	code := strconv.Itoa(status)
+	HTTPRequests.Inc(r.Method, route, code)
+	HTTPDuration.Observe(time.Since(start).Seconds(), r.Method, route, code)
+	return status
+}
+
+// ObserveQuery записывает длительность запроса репозитория method.
+func ObserveQuery(method string, start time.Time) {
+	QueryDuration.Observe(time.Since(start).Seconds(), method)
+}
+
+// ObserveJob записывает запуск фоновой задачи job, начатый в start; err - его результат.
+func ObserveJob(job string, start time.Time, err error) {
+	JobDuration.Observe(time.Since(start).Seconds(), job)
+	if err != nil {
+		JobRuns.Inc(job, "error")
+		return
+	}
+	JobRuns.Inc(job, "success")
+	JobLastSuccess.Set(float64(time.Now().Unix()), job)
+}
+
+// statusRecorder запоминает статус ответа; остальное доступно через Unwrap.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,330 @@
+// Package metrics - минимальная реализация метрик Prometheus: счетчики, гистограммы и
+// вычисляемые при чтении значения в текстовом формате экспозиции без внешних зависимостей.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+
+	// ContentType - версия текстового формата, которую понимает Prometheus.
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	// DefaultBuckets подходят для длительности HTTP-запросов и фоновых задач, в секундах.
+	DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// QueryBuckets подходят для запросов к SQLite, в секундах.
+	QueryBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+)
+
+type collector interface {
+	metricName() string
+	write(w *bufio.Writer)
+}
+
+// Registry собирает метрики для GET /metrics.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: map[string]collector{}}
+}
+
+// Default содержит метрики пакета: HTTP, запросы к базе и фоновые задачи.
+var Default = NewRegistry()
+
+// Register добавляет метрики. Метрика с тем же именем заменяется: так приложение,
+// созданное повторно (например, в тестах), не дублирует вычисляемые значения.
+func (r *Registry) Register(collectors ...collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range collectors {
+		r.collectors[c.metricName()] = c
+	}
+}
+
+// WriteTo пишет все метрики в текстовом формате, упорядочив их по имени.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := make([]collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mu.Unlock()
+	slices.SortFunc(collectors, func(a, b collector) int { return strings.Compare(a.metricName(), b.metricName()) })
+
+	counter := &countingWriter{w: w}
+	buf := bufio.NewWriter(counter)
+	for _, c := range collectors {
+		c.write(buf)
+	}
+	err := buf.Flush()
+	return counter.n, err
+}
+
+// Handler отдает метрики реестра.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+func register[T collector](c T) T {
+	Default.Register(c)
+	return c
+}
+
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d *desc) metricName() string {
+	return d.name
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, help, d.name, d.kind)
+}
+
+// writeSample пишет строку name{labels} value. extra - дополнительная пара, например le гистограммы.
+func (d *desc) writeSample(w *bufio.Writer, suffix string, values []string, extra []string, value float64) {
+	w.WriteString(d.name)
+	w.WriteString(suffix)
+	names := append(slices.Clip(d.labels), extra[:len(extra)/2]...)
+	values = append(slices.Clip(values), extra[len(extra)/2:]...)
+	if len(names) > 0 {
+		w.WriteByte('{')
+		for i, name := range names {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(name)
+			w.WriteString(`="`)
+			w.WriteString(labelEscaper.Replace(values[i]))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// seriesKey - ключ набора значений меток.
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func (d *desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+}
+
+type series struct {
+	values []string
+	value  float64
+}
+
+// vec хранит значения счетчика или gauge по наборам меток.
+type vec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newVec(name string, help string, kind string, labels []string) vec {
+	return vec{desc: desc{name: name, help: help, kind: kind, labels: labels}, series: map[string]*series{}}
+}
+
+func (v *vec) update(values []string, fn func(value float64) float64) {
+	v.checkLabels(values)
+	key := seriesKey(values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: slices.Clone(values)}
+		v.series[key] = s
+	}
+	s.value = fn(s.value)
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.writeHeader(w)
+	for _, key := range sortedKeys(v.series) {
+		s := v.series[key]
+		v.writeSample(w, "", s.values, nil, s.value)
+	}
+}
+
+type CounterVec struct {
+	vec
+}
+
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	return &CounterVec{vec: newVec(name, help, kindCounter, labels)}
+}
+
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *CounterVec) Add(delta float64, values ...string) {
+	c.update(values, func(value float64) float64 { return value + delta })
+}
+
+type GaugeVec struct {
+	vec
+}
+
+func NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{vec: newVec(name, help, kindGauge, labels)}
+}
+
+func (g *GaugeVec) Set(value float64, values ...string) {
+	g.update(values, func(float64) float64 { return value })
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{
+		desc:    desc{name: name, help: help, kind: kindHistogram, labels: labels},
+		buckets: buckets,
+		series:  map[string]*histogramSeries{},
+	}
+}
+
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	h.checkLabels(values)
+	key := seriesKey(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: slices.Clone(values), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i, _ := slices.BinarySearch(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			h.writeSample(w, "_bucket", s.values, []string{"le", formatFloat(bound)}, float64(cumulative))
+		}
+		h.writeSample(w, "_bucket", s.values, []string{"le", "+Inf"}, float64(s.count))
+		h.writeSample(w, "_sum", s.values, nil, s.sum)
+		h.writeSample(w, "_count", s.values, nil, float64(s.count))
+	}
+}
+
+// Sample - значение вычисляемой метрики с метками в порядке их объявления.
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// Func вычисляет значения при каждом чтении метрик, например статистику пула соединений.
+type Func struct {
+	desc
+	fn func() []Sample
+}
+
+func NewGaugeFunc(name string, help string, fn func() []Sample, labels ...string) *Func {
+	return &Func{desc: desc{name: name, help: help, kind: kindGauge, labels: labels}, fn: fn}
+}
+
+func NewCounterFunc(name string, help string, fn func() []Sample, labels ...string) *Func {
+	return &Func{desc: desc{name: name, help: help, kind: kindCounter, labels: labels}, fn: fn}
+}
+
+func (f *Func) write(w *bufio.Writer) {
+	samples := f.fn()
+	f.writeHeader(w)
+	for _, sample := range samples {
+		f.checkLabels(sample.Labels)
+		f.writeSample(w, "", sample.Labels, nil, sample.Value)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
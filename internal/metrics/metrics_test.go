@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	registry := NewRegistry()
+	requests := NewCounterVec("requests_total", "Requests.\nSecond line", "route")
+	latency := NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	registry.Register(requests, latency, NewGaugeFunc("queue", "Queue size.", func() []Sample {
+		return []Sample{{Value: 3}}
+	}))
+
+	requests.Inc(`/a"b`)
+	requests.Add(2, "/tasks")
+	latency.Observe(0.1, "/tasks")
+	latency.Observe(0.5, "/tasks")
+	latency.Observe(7, "/tasks")
+
+	var out strings.Builder
+	if _, err := registry.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/tasks",le="0.1"} 1
+latency_seconds_bucket{route="/tasks",le="1"} 2
+latency_seconds_bucket{route="/tasks",le="+Inf"} 3
+latency_seconds_sum{route="/tasks"} 7.6
+latency_seconds_count{route="/tasks"} 3
+# HELP queue Queue size.
+# TYPE queue gauge
+queue 3
+# HELP requests_total Requests.\nSecond line
+# TYPE requests_total counter
+requests_total{route="/a\"b"} 1
+requests_total{route="/tasks"} 2
+`
+	if out.String() != want {
+		t.Errorf("unexpected exposition:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestRegisterReplaces(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewGaugeFunc("value", "Value.", func() []Sample { return []Sample{{Value: 1}} }))
+	registry.Register(NewGaugeFunc("value", "Value.", func() []Sample { return []Sample{{Value: 2}} }))
+
+	var out strings.Builder
+	registry.WriteTo(&out)
+	if strings.Count(out.String(), "# TYPE value") != 1 || !strings.Contains(out.String(), "value 2\n") {
+		t.Errorf("metric is not replaced:\n%s", out.String())
+	}
+}
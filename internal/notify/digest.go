@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"todo/internal/db"
+)
+
+// OverdueDigest рассылает каждому исполнителю одну сводку по задачам, ставшим
+// просроченными за проверку, вместо отдельного уведомления на каждую задачу.
+// Сводки не сохраняются: если канал недоступен, сводка теряется.
+type OverdueDigest struct {
+	users     db.UserRepo
+	notifiers []Notifier
+	timeout   time.Duration
+}
+
+func NewOverdueDigest(users db.UserRepo, notifiers []Notifier) *OverdueDigest {
+	return &OverdueDigest{users: users, notifiers: notifiers, timeout: 30 * time.Second}
+}
+
+// GroupByAssignee раскладывает задачи по исполнителям. Задачи без исполнителей пропускаются.
+func GroupByAssignee(tasks []*db.Task) map[int][]*db.Task {
+	groups := make(map[int][]*db.Task)
+	for _, task := range tasks {
+		for _, userID := range task.Assignees {
+			groups[userID] = append(groups[userID], task)
+		}
+	}
+	return groups
+}
+
+// Send отправляет сводки и возвращает их число.
+func (d *OverdueDigest) Send(tasks []*db.Task) (int, error) {
+	groups := GroupByAssignee(tasks)
+	userIDs := make([]int, 0, len(groups))
+	for userID := range groups {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Ints(userIDs)
+
+	sent := 0
+	var errs []error
+	for _, userID := range userIDs {
+		user, err := d.users.GetUserById(userID)
+		if errors.Is(err, db.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := deliver(d.notifiers, d.timeout, digestNotification(user, groups[userID])); err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", userID, err))
+			continue
+		}
+		sent++
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+// deliver отправляет уведомление во все каналы, ограничивая каждую попытку timeout.
+func deliver(notifiers []Notifier, timeout time.Duration, notification Notification) error {
+	var errs []error
+	for _, notifier := range notifiers {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := notifier.Notify(ctx, notification); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", notifier.Name(), err))
+		}
+		cancel()
+	}
+	return errors.Join(errs...)
+}
+
+func digestNotification(user *db.User, tasks []*db.Task) Notification {
+	notification := Notification{
+		Kind:      "overdue_digest",
+		Title:     fmt.Sprintf("%d overdue task(s)", len(tasks)),
+		Recipient: user.Username,
+	}
+
+	lines := []string{fmt.Sprintf("%s, tasks assigned to you are overdue:", user.Username)}
+	for _, task := range tasks {
+		notification.TaskIDs = append(notification.TaskIDs, task.ID)
+		lines = append(lines, fmt.Sprintf("- #%d %q, due %s", task.ID, task.Title, task.DueDate))
+	}
+	notification.Message = strings.Join(lines, "\n")
+	return notification
+}
@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"todo/internal/db"
+)
+
+func TestGroupByAssignee(t *testing.T) {
+	tasks := []*db.Task{
+		{ID: 1, Title: "a", Assignees: []int{1, 2}},
+		{ID: 2, Title: "b", Assignees: []int{2}},
+		{ID: 3, Title: "unassigned"},
+	}
+
+	groups := GroupByAssignee(tasks)
+	if len(groups) != 2 || len(groups[1]) != 1 || len(groups[2]) != 2 {
+		t.Fatalf("unexpected groups: %v", groups)
+	}
+
+	notification := digestNotification(&db.User{ID: 2, Username: "bob"}, groups[2])
+	if notification.Recipient != "bob" || len(notification.TaskIDs) != 2 || !strings.Contains(notification.Message, `"b"`) {
+		t.Errorf("unexpected digest: %+v", notification)
+	}
+}
@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileNotifier складывает уведомления JSON-файлами в каталог. Удобно для локальной проверки.
+type FileNotifier struct {
+	Dir string
+}
+
+func (n *FileNotifier) Name() string {
+	return "file"
+}
+
+func (n *FileNotifier) Notify(ctx context.Context, notification Notification) error {
+	if err := os.MkdirAll(n.Dir, 0o755); err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(notification, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s-task%d.json", time.Now().UnixNano(), notification.Kind, notification.TaskID)
+	tmp := filepath.Join(n.Dir, "."+name)
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(n.Dir, name))
+}
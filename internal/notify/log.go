@@ -0,0 +1,17 @@
+package notify
+
+import (
+	"context"
+	"todo/internal/logging"
+)
+
+type LogNotifier struct{}
+
+func (n *LogNotifier) Name() string {
+	return "log"
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	logging.FromContext(ctx).Info("notification", "kind", notification.Kind, "task_id", notification.TaskID, "title", notification.Title, "message", notification.Message)
+	return nil
+}
@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"todo/internal/db"
+	"todo/internal/events"
+	"todo/internal/logging"
+)
+
+const mentionQueueSize = 256
+
+// MentionNotifier уведомляет пользователей, упомянутых в комментариях. События приходят
+// с шины синхронно, поэтому Enqueue только ставит их в очередь, а доставкой занимается Run.
+// При переполненной очереди уведомление теряется.
+type MentionNotifier struct {
+	users     db.UserRepo
+	notifiers []Notifier
+	timeout   time.Duration
+	queue     chan events.Event
+}
+
+func NewMentionNotifier(users db.UserRepo, notifiers []Notifier) *MentionNotifier {
+	return &MentionNotifier{
+		users:     users,
+		notifiers: notifiers,
+		timeout:   30 * time.Second,
+		queue:     make(chan events.Event, mentionQueueSize),
+	}
+}
+
+// Enqueue принимает события шины и отбирает комментарии с новыми упоминаниями.
+func (m *MentionNotifier) Enqueue(event events.Event) {
+	if event.Comment == nil || len(event.Mentioned) == 0 {
+		return
+	}
+	select {
+	case m.queue <- event:
+	default:
+		slog.Warn("mention queue is full, dropping event", "event", event.Type, "comment_id", event.Comment.ID)
+	}
+}
+
+func (m *MentionNotifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			logging.FromContext(ctx).Info("stopping mention notifier")
+			return
+		case event := <-m.queue:
+			if _, err := m.Send(event); err != nil {
+				logging.FromContext(ctx).Error("failed to send mention notifications", "error", err)
+			}
+		}
+	}
+}
+
+// Send уведомляет упомянутых в событии пользователей и возвращает число отправленных.
+func (m *MentionNotifier) Send(event events.Event) (int, error) {
+	sent := 0
+	var errs []error
+	for _, userID := range event.Mentioned {
+		user, err := m.users.GetUserById(userID)
+		if errors.Is(err, db.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := deliver(m.notifiers, m.timeout, mentionNotification(user, event)); err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", userID, err))
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+func mentionNotification(user *db.User, event events.Event) Notification {
+	notification := Notification{
+		Kind:      "mention",
+		TaskID:    event.TaskID,
+		Recipient: user.Username,
+	}
+
+	title := fmt.Sprintf("#%d", event.TaskID)
+	if event.Task != nil {
+		title = fmt.Sprintf("#%d %q", event.TaskID, event.Task.Title)
+		notification.DueDate = event.Task.DueDate
+	}
+	author := event.Comment.Author
+	if author == "" {
+		author = fmt.Sprintf("user %d", event.Comment.AuthorID)
+	}
+
+	notification.Title = fmt.Sprintf("You were mentioned in %s", title)
+	notification.Message = fmt.Sprintf("%s mentioned you in a comment on %s:\n\n%s", author, title, event.Comment.Body)
+	return notification
+}
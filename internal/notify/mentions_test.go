@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"todo/internal/db"
+	"todo/internal/events"
+)
+
+func TestMentionNotifierEnqueue(t *testing.T) {
+	m := NewMentionNotifier(nil, nil)
+	task := &db.Task{ID: 7, Title: "release"}
+
+	m.Enqueue(events.New(events.TaskUpdated, task))
+	comment := events.New(events.CommentCreated, task)
+	comment.Comment = &db.Comment{ID: 1, TaskID: 7, Body: "no mentions"}
+	m.Enqueue(comment)
+	if len(m.queue) != 0 {
+		t.Fatalf("queued %d events without mentions", len(m.queue))
+	}
+
+	comment.Mentioned = []int{2}
+	m.Enqueue(comment)
+	if len(m.queue) != 1 {
+		t.Fatalf("queued %d events, want 1", len(m.queue))
+	}
+}
+
+func TestMentionNotification(t *testing.T) {
+	event := events.New(events.CommentCreated, &db.Task{ID: 7, Title: "release", DueDate: "2026-01-01 23:59:59"})
+	event.Comment = &db.Comment{ID: 1, TaskID: 7, AuthorID: 1, Author: "alice", Body: "@bob please review"}
+
+	notification := mentionNotification(&db.User{ID: 2, Username: "bob"}, event)
+	if notification.Kind != "mention" || notification.Recipient != "bob" || notification.TaskID != 7 {
+		t.Errorf("unexpected notification: %+v", notification)
+	}
+	if !strings.Contains(notification.Message, "alice mentioned you") || !strings.Contains(notification.Message, "please review") {
+		t.Errorf("unexpected message: %q", notification.Message)
+	}
+}
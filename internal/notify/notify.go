@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"todo/pkg/config"
+)
+
+// Notification - уведомление, которое доставляется во все настроенные каналы.
+// Recipient и TaskIDs заполняются у сводок, адресованных одному пользователю.
+type Notification struct {
+	Kind      string `json:"kind"`
+	TaskID    int    `json:"task_id"`
+	Title     string `json:"title"`
+	DueDate   string `json:"due_date"`
+	FireAt    string `json:"fire_at"`
+	Message   string `json:"message"`
+	Recipient string `json:"recipient,omitempty"`
+	TaskIDs   []int  `json:"task_ids,omitempty"`
+}
+
+// Notifier - канал доставки уведомлений. Name используется для учета доставки,
+// поэтому должен быть стабильным между перезапусками.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NotifiersFromConfig собирает каналы из notify.notifiers, например [log, webhook, file].
+func NotifiersFromConfig(cfg config.Notify) ([]Notifier, error) {
+	var notifiers []Notifier
+	for _, name := range cfg.Notifiers {
+		switch name {
+		case "log":
+			notifiers = append(notifiers, &LogNotifier{})
+		case "webhook":
+			if cfg.WebhookURL == "" {
+				return nil, fmt.Errorf("NOTIFY_WEBHOOK_URL is required for webhook notifier")
+			}
+			notifiers = append(notifiers, NewWebhookNotifier(cfg.WebhookURL))
+		case "smtp":
+			notifier, err := SMTPNotifierFromConfig(cfg.SMTP)
+			if err != nil {
+				return nil, err
+			}
+			notifiers = append(notifiers, notifier)
+		case "file":
+			notifiers = append(notifiers, &FileNotifier{Dir: cfg.Dir})
+		default:
+			return nil, fmt.Errorf("unknown notifier %q", name)
+		}
+	}
+
+	return notifiers, nil
+}
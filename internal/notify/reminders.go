@@ -0,0 +1,175 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"todo/internal/db"
+	"todo/pkg/config"
+)
+
+const (
+	CatchUpAll    = "all"
+	CatchUpLatest = "latest"
+	CatchUpSkip   = "skip"
+
+	timeLayout    = "2006-01-02 15:04:05"
+	dispatchBatch = 100
+)
+
+// ReminderDispatcher доставляет наступившие напоминания во все каналы.
+// Доставка в каждый канал фиксируется в базе, поэтому после перезапуска
+// уведомление не будет отправлено в канал повторно.
+type ReminderDispatcher struct {
+	repo          db.ReminderRepo
+	notifiers     []Notifier
+	maxAttempts   int
+	catchUp       string
+	catchUpWindow time.Duration
+	timeout       time.Duration
+}
+
+func NewReminderDispatcher(repo db.ReminderRepo, notifiers []Notifier) *ReminderDispatcher {
+	return &ReminderDispatcher{
+		repo:        repo,
+		notifiers:   notifiers,
+		maxAttempts: 5,
+		catchUp:     CatchUpAll,
+		timeout:     30 * time.Second,
+	}
+}
+
+// ReminderDispatcherFromConfig применяет настройки секции reminders.
+func ReminderDispatcherFromConfig(repo db.ReminderRepo, notifiers []Notifier, cfg config.Reminders) *ReminderDispatcher {
+	d := NewReminderDispatcher(repo, notifiers)
+	d.maxAttempts = cfg.MaxAttempts
+	d.catchUp = cfg.CatchUp
+	d.catchUpWindow = cfg.CatchUpWindow
+	return d
+}
+
+// ForRepo возвращает диспетчер с теми же настройками для другой базы, например
+// отдельной базы рабочего пространства.
+func (d *ReminderDispatcher) ForRepo(repo db.ReminderRepo) *ReminderDispatcher {
+	copied := *d
+	copied.repo = repo
+	return &copied
+}
+
+// CatchUp вызывается один раз при старте: возвращает в очередь прерванные отправки
+// и применяет политику к напоминаниям, пропущенным пока сервис был остановлен.
+func (d *ReminderDispatcher) CatchUp(startedAt time.Time) error {
+	recovered, err := d.repo.RecoverReminders()
+	if err != nil {
+		return err
+	}
+	if recovered > 0 {
+		slog.Info("recovered interrupted reminders", "count", recovered)
+	}
+
+	started := startedAt.Format(timeLayout)
+	var skipped int64
+
+	switch d.catchUp {
+	case CatchUpSkip:
+		skipped, err = d.repo.SkipMissedReminders(started, false)
+	case CatchUpLatest:
+		skipped, err = d.repo.SkipMissedReminders(started, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	if d.catchUpWindow > 0 {
+		count, err := d.repo.SkipMissedReminders(startedAt.Add(-d.catchUpWindow).Format(timeLayout), false)
+		if err != nil {
+			return err
+		}
+		skipped += count
+	}
+
+	if skipped > 0 {
+		slog.Info("skipped missed reminders", "count", skipped, "policy", d.catchUp)
+	}
+
+	return nil
+}
+
+// Dispatch отправляет все напоминания, время которых наступило к now.
+func (d *ReminderDispatcher) Dispatch(now time.Time) (int, error) {
+	reminders, err := d.repo.GetDueReminders(now.Format(timeLayout), dispatchBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, reminder := range reminders {
+		claimed, err := d.repo.ClaimReminder(reminder.ID)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := d.deliver(reminder, now); err != nil {
+			slog.Warn("reminder delivery failed", "reminder_id", reminder.ID, "error", err)
+			if err := d.repo.RetryReminder(reminder.ID, err.Error(), d.maxAttempts); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (d *ReminderDispatcher) deliver(reminder *db.DueReminder, now time.Time) error {
+	finishedAt := now.Format(timeLayout)
+
+	// Для закрытых задач напоминания не отправляем
+	if reminder.TaskClosed {
+		return d.repo.FinishReminder(reminder.ID, db.ReminderSkipped, "", finishedAt)
+	}
+
+	notification := Notification{
+		Kind:    "reminder",
+		TaskID:  reminder.TaskID,
+		Title:   reminder.Task.Title,
+		DueDate: reminder.Task.DueDate,
+		FireAt:  reminder.FireAt,
+		Message: fmt.Sprintf("Task %q is due at %s", reminder.Task.Title, reminder.Task.DueDate),
+	}
+
+	var errs []error
+	for _, notifier := range d.notifiers {
+		delivered, err := d.repo.IsReminderSinkDelivered(reminder.ID, notifier.Name())
+		if err != nil {
+			return err
+		}
+		if delivered {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+		err = notifier.Notify(ctx, notification)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", notifier.Name(), err))
+			continue
+		}
+
+		if err := d.repo.MarkReminderSinkDelivered(reminder.ID, notifier.Name(), finishedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	return d.repo.FinishReminder(reminder.ID, db.ReminderDelivered, "", finishedAt)
+}
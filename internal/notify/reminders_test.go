@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+	"todo/internal/db"
+	"todo/pkg/config"
+	"todo/pkg/sqlite3"
+)
+
+// recorder - канал уведомлений в памяти. Первые failures вызовов завершаются ошибкой.
+type recorder struct {
+	name     string
+	failures int
+	received []Notification
+}
+
+func (r *recorder) Name() string {
+	return r.name
+}
+
+func (r *recorder) Notify(ctx context.Context, n Notification) error {
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("unavailable")
+	}
+	r.received = append(r.received, n)
+	return nil
+}
+
+func newReminderRepository(t *testing.T) *db.TaskRepository {
+	conn, err := sqlite3.NewConnector(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository, err := db.NewTaskRepository(conn, db.DefaultWorkflow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repository
+}
+
+// addReminders создает задачу с напоминаниями на моменты at.
+func addReminders(t *testing.T, repository *db.TaskRepository, at ...string) []int {
+	t.Helper()
+	ctx := context.Background()
+	title, description, due := "release", "", "2030-01-02 23:59:59"
+	task, err := repository.CreateTask(ctx, &db.TaskInput{Title: &title, Description: &description, DueDate: &due, CreatedAt: "2030-01-01 00:00:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, value := range at {
+		reminder, err := repository.CreateReminder(ctx, task.ID, &db.ReminderInput{At: &value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, reminder.ID)
+	}
+	return ids
+}
+
+// reminderStates возвращает состояния напоминаний задачи по порядку срабатывания.
+func reminderStates(t *testing.T, repository *db.TaskRepository) []string {
+	t.Helper()
+	reminders, err := repository.GetReminders(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make([]string, len(reminders))
+	for i, reminder := range reminders {
+		states[i] = reminder.State
+	}
+	return states
+}
+
+var reminderNow = time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestReminderDeliveredOnce(t *testing.T) {
+	repository := newReminderRepository(t)
+	addReminders(t, repository, "2030-01-01 11:00:00")
+	log, mail := &recorder{name: "log"}, &recorder{name: "mail", failures: 1}
+	d := NewReminderDispatcher(repository, []Notifier{log, mail})
+
+	// Почта недоступна: напоминание возвращается в очередь, но в log уже доставлено
+	if sent, err := d.Dispatch(reminderNow); err != nil || sent != 0 {
+		t.Fatalf("first dispatch: sent %d, %v", sent, err)
+	}
+	if len(log.received) != 1 || len(mail.received) != 0 || reminderStates(t, repository)[0] != db.ReminderPending {
+		t.Fatalf("after failure: log %d, mail %d, states %v", len(log.received), len(mail.received), reminderStates(t, repository))
+	}
+
+	// Повтор доставляет только в почту, дальнейшие вызовы ничего не отправляют
+	for i := 0; i < 3; i++ {
+		if _, err := d.Dispatch(reminderNow.Add(time.Duration(i) * time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(log.received) != 1 || len(mail.received) != 1 || reminderStates(t, repository)[0] != db.ReminderDelivered {
+		t.Errorf("after retry: log %d, mail %d, states %v", len(log.received), len(mail.received), reminderStates(t, repository))
+	}
+	if n := mail.received[0]; n.Kind != "reminder" || n.TaskID != 1 || n.FireAt != "2030-01-01 11:00:00" {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+
+	// Канал, который так и не ответил, после maxAttempts попыток помечается failed
+	addReminders(t, repository, "2030-01-01 11:30:00")
+	d = NewReminderDispatcher(repository, []Notifier{&recorder{name: "down", failures: 100}})
+	d.maxAttempts = 2
+	for i := 0; i < 3; i++ {
+		if _, err := d.Dispatch(reminderNow); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reminders, err := repository.GetReminders(context.Background(), 2)
+	if err != nil || reminders[0].State != db.ReminderFailed || reminders[0].Attempts != 2 || reminders[0].LastError == "" {
+		t.Errorf("exhausted reminder: %+v, %v", reminders[0], err)
+	}
+}
+
+func TestReminderRecoveryAfterRestart(t *testing.T) {
+	repository := newReminderRepository(t)
+	id := addReminders(t, repository, "2030-01-01 11:00:00")[0]
+
+	// Процесс остановился посреди отправки: напоминание захвачено, log уже получил уведомление
+	if claimed, err := repository.ClaimReminder(id); err != nil || !claimed {
+		t.Fatalf("claim: %v, %v", claimed, err)
+	}
+	if err := repository.MarkReminderSinkDelivered(id, "log", "2030-01-01 11:00:01"); err != nil {
+		t.Fatal(err)
+	}
+
+	log, mail := &recorder{name: "log"}, &recorder{name: "mail"}
+	d := NewReminderDispatcher(repository, []Notifier{log, mail})
+	if sent, err := d.Dispatch(reminderNow); err != nil || sent != 0 {
+		t.Fatalf("claimed reminder was dispatched before CatchUp: sent %d, %v", sent, err)
+	}
+
+	if err := d.CatchUp(reminderNow); err != nil {
+		t.Fatal(err)
+	}
+	if sent, err := d.Dispatch(reminderNow); err != nil || sent != 1 {
+		t.Fatalf("dispatch after restart: sent %d, %v", sent, err)
+	}
+	if len(log.received) != 0 || len(mail.received) != 1 || reminderStates(t, repository)[0] != db.ReminderDelivered {
+		t.Errorf("after restart: log %d, mail %d, states %v", len(log.received), len(mail.received), reminderStates(t, repository))
+	}
+}
+
+func TestReminderCatchUp(t *testing.T) {
+	// Три напоминания пропущены, пока сервис был остановлен до 12:00, одно еще впереди
+	at := []string{"2030-01-01 09:00:00", "2030-01-01 10:00:00", "2030-01-01 11:00:00", "2030-01-01 13:00:00"}
+	tests := []struct {
+		name  string
+		cfg   config.Reminders
+		fired []string
+	}{
+		{"all", config.Reminders{CatchUp: CatchUpAll}, at[:3]},
+		{"latest", config.Reminders{CatchUp: CatchUpLatest}, at[2:3]},
+		{"skip", config.Reminders{CatchUp: CatchUpSkip}, nil},
+		{"window", config.Reminders{CatchUp: CatchUpAll, CatchUpWindow: 90 * time.Minute}, at[2:3]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := newReminderRepository(t)
+			addReminders(t, repository, at...)
+			log := &recorder{name: "log"}
+			tt.cfg.MaxAttempts = 5
+			d := ReminderDispatcherFromConfig(repository, []Notifier{log}, tt.cfg)
+
+			if err := d.CatchUp(reminderNow); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := d.Dispatch(reminderNow); err != nil {
+				t.Fatal(err)
+			}
+			var fired []string
+			for _, n := range log.received {
+				fired = append(fired, n.FireAt)
+			}
+			if !slices.Equal(fired, tt.fired) {
+				t.Errorf("fired %v, want %v", fired, tt.fired)
+			}
+			if states := reminderStates(t, repository); states[3] != db.ReminderPending {
+				t.Errorf("future reminder is not pending: %v", states)
+			}
+		})
+	}
+}
+
+func TestDeleteReminderKeepsForeignDeliveries(t *testing.T) {
+	repository := newReminderRepository(t)
+	ctx := context.Background()
+	id := addReminders(t, repository, "2030-01-01 11:00:00")[0]
+	addReminders(t, repository)
+	if err := repository.MarkReminderSinkDelivered(id, "log", "2030-01-01 11:00:01"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Напоминание задачи 1 через URL задачи 2: не удаляется, отметки о доставке остаются
+	if count, err := repository.DeleteReminder(ctx, 2, id); err != nil || count != 0 {
+		t.Fatalf("foreign reminder deleted: %d, %v", count, err)
+	}
+	if delivered, err := repository.IsReminderSinkDelivered(id, "log"); err != nil || !delivered {
+		t.Errorf("foreign reminder lost its deliveries: %v, %v", delivered, err)
+	}
+
+	if count, err := repository.DeleteReminder(ctx, 1, id); err != nil || count != 1 {
+		t.Fatalf("delete reminder: %d, %v", count, err)
+	}
+	if delivered, err := repository.IsReminderSinkDelivered(id, "log"); err != nil || delivered {
+		t.Errorf("deliveries of a deleted reminder remain: %v, %v", delivered, err)
+	}
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"todo/pkg/config"
+)
+
+// SMTPNotifier отправляет уведомление письмом через SMTP-сервер.
+type SMTPNotifier struct {
+	Addr     string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+func SMTPNotifierFromConfig(cfg config.SMTP) (*SMTPNotifier, error) {
+	n := &SMTPNotifier{
+		Addr:     cfg.Addr,
+		From:     cfg.From,
+		To:       cfg.To,
+		Username: cfg.Username,
+		Password: cfg.Password,
+	}
+
+	if n.Addr == "" || n.From == "" || len(n.To) == 0 {
+		return nil, fmt.Errorf("SMTP_ADDR, SMTP_FROM and SMTP_TO are required for smtp notifier")
+	}
+
+	return n, nil
+}
+
+func (n *SMTPNotifier) Name() string {
+	return "smtp"
+}
+
+// Notify отправляет письмо как smtp.SendMail, но соединение открывается с учетом ctx:
+// срок ctx ограничивает весь диалог с сервером, а отмена ctx закрывает соединение.
+func (n *SMTPNotifier) Notify(ctx context.Context, notification Notification) error {
+	host, _, err := net.SplitHostPort(n.Addr)
+	if err != nil {
+		return err
+	}
+
+	// Название задачи задает пользователь: кодирование убирает из заголовка переводы строк
+	// (иначе через них можно дописать свои заголовки) и позволяет писать не только ASCII
+	subject := mime.QEncoding.Encode("UTF-8", fmt.Sprintf("[todo] %s: %s", notification.Kind, notification.Title))
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		n.From, strings.Join(n.To, ", "), subject, notification.Message)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	err = n.send(conn, host, []byte(message))
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// send проводит SMTP-диалог по открытому соединению: STARTTLS, если сервер его поддерживает,
+// и AUTH, если задан Username. Как и smtp.SendMail, без поддержки AUTH на сервере письмо
+// не отправляется.
+func (n *SMTPNotifier) send(conn net.Conn, host string, message []byte) error {
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveSMTP отвечает на один SMTP-диалог без расширений и возвращает принятое письмо.
+func serveSMTP(listener net.Listener) <-chan string {
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 localhost ready")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				received <- data.String()
+				return
+			default:
+				reply("502 unknown command")
+			}
+		}
+	}()
+	return received
+}
+
+func TestSMTPNotify(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := serveSMTP(listener)
+
+	n := &SMTPNotifier{Addr: listener.Addr().String(), From: "todo@example.com", To: []string{"alice@example.com"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, Notification{Kind: "reminder", Title: "release", Message: "Task is due"}); err != nil {
+		t.Fatal(err)
+	}
+	if message := <-received; !strings.Contains(message, "Subject: [todo] reminder: release") || !strings.Contains(message, "Task is due") {
+		t.Errorf("unexpected message: %q", message)
+	}
+}
+
+func TestSMTPNotifyDeadline(t *testing.T) {
+	// Сервер принимает соединение и молчит: без срока ctx Notify ждал бы приветствия бесконечно
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	n := &SMTPNotifier{Addr: listener.Addr().String(), From: "todo@example.com", To: []string{"alice@example.com"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err = n.Notify(ctx, Notification{Kind: "reminder", Title: "release"})
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(started) > 2*time.Second {
+		t.Errorf("Notify returned %v after %s", err, time.Since(started))
+	}
+}
+
+func TestSMTPNotifyHeaderInjection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := serveSMTP(listener)
+
+	n := &SMTPNotifier{Addr: listener.Addr().String(), From: "todo@example.com", To: []string{"alice@example.com"}}
+	title := "release\r\nBcc: mallory@example.com"
+	if err := n.Notify(context.Background(), Notification{Kind: "reminder", Title: title}); err != nil {
+		t.Fatal(err)
+	}
+	message := <-received
+	if strings.Contains(message, "\r\nBcc:") {
+		t.Errorf("title injected a header: %q", message)
+	}
+	if !strings.Contains(message, "Subject: =?UTF-8?q?") {
+		t.Errorf("subject is not encoded: %q", message)
+	}
+}
+
+func TestSMTPNotifyRequiresAuth(t *testing.T) {
+	// Сервер без AUTH: с заданным Username письмо не должно уйти без аутентификации
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := serveSMTP(listener)
+
+	n := &SMTPNotifier{Addr: listener.Addr().String(), From: "todo@example.com", To: []string{"alice@example.com"}, Username: "todo", Password: "secret"}
+	if err := n.Notify(context.Background(), Notification{Kind: "reminder", Title: "release"}); err == nil || !strings.Contains(err.Error(), "AUTH") {
+		t.Fatalf("Notify without AUTH on the server: %v", err)
+	}
+	select {
+	case message := <-received:
+		t.Errorf("message was sent without authentication: %q", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,86 @@
+// Package ratelimit ограничивает частоту запросов по ключу (например, адресу клиента)
+// алгоритмом token bucket. Пределы можно менять на ходу.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval - как часто удаляются корзины клиентов, которые давно не обращались.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter пропускает в среднем rate запросов в секунду на ключ и до burst запросов подряд.
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New создает ограничитель. rate <= 0 отключает ограничение.
+func New(rate float64, burst int) *Limiter {
+	l := &Limiter{buckets: map[string]*bucket{}, now: time.Now}
+	l.SetLimit(rate, burst)
+	return l
+}
+
+// SetLimit меняет пределы. Накопленные запросы клиентов сохраняются, но не больше нового burst.
+func (l *Limiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = max(burst, 1)
+	for _, b := range l.buckets {
+		b.tokens = min(b.tokens, float64(l.burst))
+	}
+}
+
+// Allow расходует запрос ключа. Если запросов не осталось, возвращает false и время,
+// через которое следующий запрос будет пропущен.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / l.rate
+		return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep удаляет заполнившиеся корзины: для клиента они не отличаются от новых.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	refill := time.Duration(float64(l.burst) / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := New(2, 3)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("10.0.0.1"); !ok {
+			t.Fatalf("request %d within burst is rejected", i+1)
+		}
+	}
+	ok, wait := l.Allow("10.0.0.1")
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("request over burst: ok=%v wait=%s", ok, wait)
+	}
+	if ok, _ := l.Allow("10.0.0.2"); !ok {
+		t.Fatal("other client is limited")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.Allow("10.0.0.1"); !ok {
+		t.Fatal("token is not refilled")
+	}
+
+	// Новые пределы действуют сразу, накопленное не превышает новый burst
+	l.SetLimit(1, 1)
+	now = now.Add(time.Hour)
+	if ok, _ := l.Allow("10.0.0.2"); !ok {
+		t.Fatal("refilled client is rejected")
+	}
+	if ok, wait := l.Allow("10.0.0.2"); ok || wait != time.Second {
+		t.Fatalf("new limit is not applied: ok=%v wait=%s", ok, wait)
+	}
+	if len(l.buckets) != 1 {
+		t.Errorf("idle clients are not swept: %d buckets", len(l.buckets))
+	}
+
+	l.SetLimit(0, 1)
+	for i := 0; i < 10; i++ {
+		if ok, _ := l.Allow("10.0.0.2"); !ok {
+			t.Fatal("disabled limiter rejects requests")
+		}
+	}
+}
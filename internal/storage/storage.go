@@ -0,0 +1,216 @@
+// Package storage хранит содержимое вложений в локальном каталоге под именами SHA-256.
+// Одинаковые файлы записываются один раз; счетчики ссылок ведет db.BlobRepo, а файлы
+// без ссылок удаляет Collect.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"todo/internal/db"
+	"todo/internal/logging"
+	"todo/internal/metrics"
+	"todo/internal/tracing"
+	"todo/pkg/config"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+var ErrTooLarge = errors.New("file is too large")
+
+// Store - каталог с содержимым. Файл с хэшем abcd... лежит в ab/abcd..., незавершенные
+// загрузки - в tmp.
+type Store struct {
+	dir   string
+	blobs db.BlobRepo
+	// grace - сколько содержимое без ссылок и незавершенные загрузки ждут удаления
+	grace time.Duration
+	// mu не дает сборщику удалить файл, на который в этот момент появляется ссылка
+	mu  sync.Mutex
+	now func() time.Time
+
+	// Interval - период сборки мусора в Run.
+	Interval time.Duration
+}
+
+func NewStore(dir string, blobs db.BlobRepo, grace time.Duration) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, blobs: blobs, grace: grace, now: time.Now, Interval: time.Hour}, nil
+}
+
+// StoreFromConfig создает хранилище в attachments.dir со сборкой мусора по gc_interval и gc_grace.
+func StoreFromConfig(blobs db.BlobRepo, cfg config.Attachments) (*Store, error) {
+	store, err := NewStore(cfg.Dir, blobs, cfg.GCGrace)
+	if err != nil {
+		return nil, err
+	}
+	store.Interval = cfg.GCInterval
+	return store, nil
+}
+
+// Run периодически удаляет содержимое без ссылок, пока не отменен ctx.
+func (s *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logging.FromContext(ctx).Info("stopping attachment collector")
+			return
+		case <-ticker.C:
+			start := time.Now()
+			_, span := tracing.Start(ctx, "job attachments", tracing.KindInternal)
+			removed, err := s.Collect()
+			metrics.ObserveJob("attachments", start, err)
+			span.SetAttributes("removed", removed)
+			span.SetError(err)
+			span.End()
+			if err != nil {
+				logging.FromContext(ctx).Error("failed to collect attachments", "error", err)
+			}
+			if removed > 0 {
+				logging.FromContext(ctx).Info("removed attachment files", "count", removed)
+			}
+		}
+	}
+}
+
+func (s *Store) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+// Put записывает содержимое r, не читая больше limit байт, и добавляет на него ссылку.
+// Тип содержимого определяется по первым байтам, а не по заголовкам клиента.
+func (s *Store) Put(r io.Reader, limit int64) (*db.Blob, error) {
+	temp, err := os.CreateTemp(filepath.Join(s.dir, "tmp"), "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(temp.Name())
+
+	hash := sha256.New()
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(r, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		temp.Close()
+		return nil, err
+	}
+	sniff = sniff[:n]
+
+	// Лишний байт сверх limit показывает, что файл не поместился
+	reader := io.LimitReader(io.MultiReader(bytes.NewReader(sniff), r), limit+1)
+	size, err := io.Copy(io.MultiWriter(temp, hash), reader)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if size > limit {
+		return nil, ErrTooLarge
+	}
+
+	blob := &db.Blob{Hash: hex.EncodeToString(hash.Sum(nil)), Size: size, ContentType: http.DetectContentType(sniff)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(blob.Hash)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(temp.Name(), path); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := s.blobs.AcquireBlob(blob); err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// Release снимает ссылку, добавленную Put, если вложение так и не было сохранено.
+func (s *Store) Release(hash string) error {
+	return s.blobs.ReleaseBlobs([]string{hash})
+}
+
+func (s *Store) Open(hash string) (*os.File, error) {
+	if len(hash) != sha256.Size*2 {
+		return nil, fs.ErrNotExist
+	}
+	return os.Open(s.path(hash))
+}
+
+// Collect удаляет содержимое, на которое дольше grace нет ссылок, файлы без записи в базе
+// и брошенные загрузки. Возвращает число удаленных файлов.
+func (s *Store) Collect() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.now().Add(-s.grace)
+	removed := 0
+	var errs []error
+
+	orphans, err := s.blobs.GetOrphanBlobs(cutoff.Format(timeLayout))
+	if err != nil {
+		return 0, err
+	}
+	for _, hash := range orphans {
+		deleted, err := s.blobs.DeleteOrphanBlob(hash)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !deleted {
+			continue
+		}
+		if err := os.Remove(s.path(hash)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+
+	err = filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return err
+		}
+
+		if filepath.Base(filepath.Dir(path)) != "tmp" {
+			known, err := s.blobs.HasBlob(entry.Name())
+			if err != nil || known {
+				return err
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return removed, errors.Join(errs...)
+}
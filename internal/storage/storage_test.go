@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"todo/internal/db"
+)
+
+// memoryBlobs - BlobRepo в памяти, released хранит время освобождения.
+type memoryBlobs struct {
+	refs     map[string]int
+	released map[string]string
+}
+
+func newMemoryBlobs() *memoryBlobs {
+	return &memoryBlobs{refs: map[string]int{}, released: map[string]string{}}
+}
+
+func (m *memoryBlobs) AcquireBlob(blob *db.Blob) error {
+	m.refs[blob.Hash]++
+	delete(m.released, blob.Hash)
+	return nil
+}
+
+func (m *memoryBlobs) ReleaseBlobs(hashes []string) error {
+	for _, hash := range hashes {
+		m.refs[hash]--
+		if m.refs[hash] <= 0 {
+			m.released[hash] = time.Now().Format(timeLayout)
+		}
+	}
+	return nil
+}
+
+func (m *memoryBlobs) GetOrphanBlobs(releasedBefore string) ([]string, error) {
+	var hashes []string
+	for hash, at := range m.released {
+		if m.refs[hash] <= 0 && at < releasedBefore {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
+func (m *memoryBlobs) DeleteOrphanBlob(hash string) (bool, error) {
+	if _, ok := m.refs[hash]; !ok || m.refs[hash] > 0 {
+		return false, nil
+	}
+	delete(m.refs, hash)
+	delete(m.released, hash)
+	return true, nil
+}
+
+func (m *memoryBlobs) HasBlob(hash string) (bool, error) {
+	_, ok := m.refs[hash]
+	return ok, nil
+}
+
+func TestStorePutDeduplicates(t *testing.T) {
+	blobs := newMemoryBlobs()
+	store, err := NewStore(t.TempDir(), blobs, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.Put(strings.NewReader("<html><body>hi</body></html>"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Put(strings.NewReader("<html><body>hi</body></html>"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Hash != second.Hash || blobs.refs[first.Hash] != 2 || first.Size != 28 {
+		t.Fatalf("unexpected blobs %+v %+v, refs %d", first, second, blobs.refs[first.Hash])
+	}
+	if !strings.HasPrefix(first.ContentType, "text/html") {
+		t.Errorf("content type = %q", first.ContentType)
+	}
+
+	file, err := store.Open(first.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(file)
+	file.Close()
+	if string(content) != "<html><body>hi</body></html>" {
+		t.Errorf("content = %q", content)
+	}
+
+	if _, err := store.Put(strings.NewReader(strings.Repeat("x", 11)), 10); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("oversized put: got %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(store.dir, "tmp"))
+	if len(entries) != 0 {
+		t.Errorf("temporary files are left: %d", len(entries))
+	}
+}
+
+func TestStoreCollect(t *testing.T) {
+	blobs := newMemoryBlobs()
+	store, err := NewStore(t.TempDir(), blobs, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept, _ := store.Put(strings.NewReader("kept"), 100)
+	orphan, _ := store.Put(strings.NewReader("orphan"), 100)
+	blobs.ReleaseBlobs([]string{orphan.Hash})
+	stray := filepath.Join(store.dir, "tmp", "upload-stray")
+	os.WriteFile(stray, []byte("partial"), 0o644)
+
+	// До истечения grace ничего не удаляется
+	if removed, err := store.Collect(); err != nil || removed != 0 {
+		t.Fatalf("early collect: removed %d, err %v", removed, err)
+	}
+
+	store.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	removed, err := store.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("removed = %d, want 2", removed)
+	}
+	if _, err := os.Stat(store.path(orphan.Hash)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("orphan blob is left: %v", err)
+	}
+	if _, err := os.Stat(stray); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stray upload is left: %v", err)
+	}
+	if _, err := os.Stat(store.path(kept.Hash)); err != nil {
+		t.Errorf("referenced blob was removed: %v", err)
+	}
+}
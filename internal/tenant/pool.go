@@ -0,0 +1,142 @@
+package tenant
+
+import (
+	"container/list"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"todo/internal/db"
+	"todo/pkg/sqlite3"
+)
+
+var ErrInUse = errors.New("workspace database is in use")
+
+// Pool держит открытыми базы последних использованных пространств. Когда открытых баз
+// больше max, закрываются давно не использовавшиеся, с которыми сейчас никто не работает.
+type Pool struct {
+	dir      string
+	max      int
+	workflow *db.Workflow
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	recent  *list.List
+}
+
+type entry struct {
+	slug string
+	conn *sqlite3.Sqlite
+	repo *db.TaskRepository
+	refs int
+	elem *list.Element
+}
+
+func NewPool(dir string, max int, workflow *db.Workflow) *Pool {
+	return &Pool{
+		dir:      dir,
+		max:      max,
+		workflow: workflow,
+		entries:  make(map[string]*entry),
+		recent:   list.New(),
+	}
+}
+
+func (p *Pool) path(slug string) string {
+	return filepath.Join(p.dir, slug+".db")
+}
+
+// Acquire открывает базу пространства (при первом обращении файл создается и мигрирует).
+// release нужно вызвать, когда база больше не нужна.
+func (p *Pool) Acquire(slug string) (*db.TaskRepository, func(), error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e, ok := p.entries[slug]
+	if ok {
+		p.recent.MoveToFront(e.elem)
+	} else {
+		if err := os.MkdirAll(p.dir, 0o755); err != nil {
+			return nil, nil, err
+		}
+		conn, err := sqlite3.NewConnector(p.path(slug))
+		if err != nil {
+			return nil, nil, err
+		}
+		repo, err := db.NewTaskRepository(conn, p.workflow)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		e = &entry{slug: slug, conn: conn, repo: repo}
+		e.elem = p.recent.PushFront(e)
+		p.entries[slug] = e
+	}
+	e.refs++
+	p.evict()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			e.refs--
+			p.evict()
+		})
+	}
+	return e.repo, release, nil
+}
+
+// evict закрывает лишние базы, начиная с давно не использованных. Занятые базы
+// не закрываются, поэтому при пиковой нагрузке открытых баз может быть больше max.
+func (p *Pool) evict() {
+	for elem := p.recent.Back(); elem != nil && len(p.entries) > p.max; {
+		e := elem.Value.(*entry)
+		elem = elem.Prev()
+		if e.refs == 0 {
+			p.close(e)
+		}
+	}
+}
+
+func (p *Pool) close(e *entry) {
+	p.recent.Remove(e.elem)
+	delete(p.entries, e.slug)
+	e.conn.Close()
+}
+
+// Remove закрывает и удаляет файл базы пространства.
+func (p *Pool) Remove(slug string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if e, ok := p.entries[slug]; ok {
+		if e.refs > 0 {
+			return ErrInUse
+		}
+		p.close(e)
+	}
+
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		if err := os.Remove(p.path(slug) + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open возвращает число открытых баз.
+func (p *Pool) Open() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// Close закрывает все базы. Вызывается при остановке сервера.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, e := range p.entries {
+		p.close(e)
+	}
+}
@@ -0,0 +1,73 @@
+package tenant
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"todo/internal/db"
+)
+
+func TestPoolEvictsLeastRecentlyUsed(t *testing.T) {
+	pool := NewPool(t.TempDir(), 2, db.DefaultWorkflow())
+	defer pool.Close()
+
+	acquire := func(slug string) func() {
+		t.Helper()
+		_, release, err := pool.Acquire(slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return release
+	}
+
+	acquire("a")()
+	acquire("b")()
+	acquire("a")()
+	acquire("c")()
+
+	if got := pool.Open(); got != 2 {
+		t.Fatalf("open = %d, want 2", got)
+	}
+	if _, ok := pool.entries["b"]; ok {
+		t.Error("least recently used base b is still open")
+	}
+
+	// Занятая база не закрывается, даже если пул переполнен
+	busy := acquire("a")
+	acquire("d")()
+	acquire("e")()
+	if _, ok := pool.entries["a"]; !ok {
+		t.Error("base a was closed while in use")
+	}
+	busy()
+	busy() // повторный release ничего не делает
+	if got := pool.Open(); got != 2 {
+		t.Errorf("open after release = %d, want 2", got)
+	}
+}
+
+func TestPoolRemove(t *testing.T) {
+	dir := t.TempDir()
+	pool := NewPool(dir, 4, db.DefaultWorkflow())
+	defer pool.Close()
+
+	_, release, err := pool.Acquire("team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Remove("team"); !errors.Is(err, ErrInUse) {
+		t.Fatalf("remove in use: got %v", err)
+	}
+
+	release()
+	if err := pool.Remove("team"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "team.db")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("database file is left: %v", err)
+	}
+	if got := pool.Open(); got != 0 {
+		t.Errorf("open = %d, want 0", got)
+	}
+}
@@ -0,0 +1,216 @@
+// Package tenant определяет рабочее пространство запроса и привязывает к нему хранилище:
+// общую базу с колонкой workspace_id или отдельный файл SQLite пространства.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"todo/internal/auth"
+	"todo/internal/db"
+	"todo/internal/logging"
+	"todo/pkg/config"
+)
+
+// Header - заголовок, которым клиент выбирает пространство.
+const Header = "X-Workspace"
+
+var (
+	ErrSuspended        = errors.New("workspace is suspended")
+	ErrMismatch         = errors.New("workspace does not match token")
+	ErrDefaultWorkspace = errors.New("default workspace cannot be deleted")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ValidSlug проверяет идентификатор пространства. Он же служит поддоменом и именем файла.
+func ValidSlug(slug string) bool {
+	return slugPattern.MatchString(slug)
+}
+
+type Manager struct {
+	repo   *db.TaskRepository
+	config config.Workspaces
+	pool   *Pool
+}
+
+func NewManager(repo *db.TaskRepository, cfg config.Workspaces) *Manager {
+	m := &Manager{repo: repo, config: cfg}
+	if cfg.Mode == config.WorkspaceModeFile {
+		m.pool = NewPool(cfg.Dir, cfg.MaxOpen, repo.Workflow())
+	}
+	return m
+}
+
+// Separate сообщает, что у каждого пространства своя база (режим file).
+func (m *Manager) Separate() bool {
+	return m.pool != nil
+}
+
+// Slug определяет пространство запроса: из токена, затем из заголовка X-Workspace, затем
+// из поддомена WORKSPACE_DOMAIN. Токен, выпущенный для пространства, в другом не действует.
+func (m *Manager) Slug(r *http.Request, principal *auth.Principal) (string, error) {
+	requested := strings.ToLower(strings.TrimSpace(r.Header.Get(Header)))
+	if requested == "" {
+		requested = m.subdomain(r.Host)
+	}
+
+	if principal != nil && principal.Workspace != "" {
+		if requested != "" && requested != principal.Workspace {
+			return "", ErrMismatch
+		}
+		return principal.Workspace, nil
+	}
+	if requested == "" {
+		return db.DefaultWorkspaceSlug, nil
+	}
+	return requested, nil
+}
+
+func (m *Manager) subdomain(host string) string {
+	if m.config.Domain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, ok := strings.CutSuffix(strings.ToLower(host), "."+m.config.Domain)
+	if !ok || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// Resolve находит пространство и проверяет, что principal в нем участвует. Пространство
+// по умолчанию открыто всем, администратор видит все. Для чужих пространств возвращается
+// db.ErrWorkspaceNotFound, чтобы не раскрывать их существование.
+func (m *Manager) Resolve(slug string, principal *auth.Principal) (*db.Workspace, error) {
+	workspace, err := m.repo.GetWorkspaceBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if !principal.IsAdmin() && workspace.ID != db.DefaultWorkspaceID {
+		member, err := m.repo.IsWorkspaceMember(workspace.ID, principal.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !member {
+			return nil, db.ErrWorkspaceNotFound
+		}
+	}
+
+	if workspace.Status == db.WorkspaceSuspended {
+		return nil, ErrSuspended
+	}
+	return workspace, nil
+}
+
+// Bind привязывает ctx к пространству. release нужно вызвать по окончании работы:
+// в режиме file до этого база пространства не будет закрыта. Пространству по умолчанию
+// в режиме file служит основная база, чтобы данные, созданные до включения режима, остались на месте.
+// Логгер в ctx дополняется slug пространства.
+func (m *Manager) Bind(ctx context.Context, workspace *db.Workspace) (context.Context, func(), error) {
+	ctx = logging.With(ctx, "workspace", workspace.Slug)
+	if m.pool == nil {
+		return db.WithWorkspace(ctx, workspace, nil), func() {}, nil
+	}
+	if workspace.ID == db.DefaultWorkspaceID {
+		return db.WithWorkspace(ctx, workspace, m.repo), func() {}, nil
+	}
+
+	repo, release, err := m.pool.Acquire(workspace.Slug)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db.WithWorkspace(ctx, workspace, repo), release, nil
+}
+
+// Each вызывает fn для каждого активного пространства. repo - база пространства,
+// в режиме column это общая база.
+func (m *Manager) Each(ctx context.Context, fn func(ctx context.Context, repo *db.TaskRepository) error) error {
+	workspaces, err := m.repo.GetWorkspaces()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, workspace := range workspaces {
+		if workspace.Status != db.WorkspaceActive {
+			continue
+		}
+		bound, release, err := m.Bind(ctx, workspace)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", workspace.Slug, err))
+			continue
+		}
+		if err := fn(bound, m.repo.ForContext(bound)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", workspace.Slug, err))
+		}
+		release()
+	}
+	return errors.Join(errs...)
+}
+
+// Create создает пространство, а в режиме file - и его базу.
+func (m *Manager) Create(workspace *db.Workspace) (*db.Workspace, error) {
+	workspace, err := m.repo.CreateWorkspace(workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.pool != nil {
+		_, release, err := m.pool.Acquire(workspace.Slug)
+		if err != nil {
+			return nil, err
+		}
+		release()
+	}
+	return workspace, nil
+}
+
+// Delete удаляет пространство вместе со всеми его данными.
+func (m *Manager) Delete(workspace *db.Workspace) error {
+	if workspace.ID == db.DefaultWorkspaceID {
+		return ErrDefaultWorkspace
+	}
+
+	if m.pool != nil {
+		// Ссылки на содержимое вложений ведет основная база: освобождаем их после удаления файла
+		hashes, err := m.attachmentHashes(workspace)
+		if err != nil {
+			return err
+		}
+		if err := m.pool.Remove(workspace.Slug); err != nil {
+			return err
+		}
+		if err := m.repo.ReleaseBlobs(hashes); err != nil {
+			return err
+		}
+	} else if err := m.repo.DeleteWorkspaceData(workspace.ID); err != nil {
+		return err
+	}
+
+	_, err := m.repo.DeleteWorkspace(workspace.ID)
+	return err
+}
+
+func (m *Manager) attachmentHashes(workspace *db.Workspace) ([]string, error) {
+	repo, release, err := m.pool.Acquire(workspace.Slug)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return m.repo.GetAttachmentHashes(db.WithWorkspace(context.Background(), workspace, repo))
+}
+
+// Close закрывает открытые базы пространств.
+func (m *Manager) Close() {
+	if m.pool != nil {
+		m.pool.Close()
+	}
+}
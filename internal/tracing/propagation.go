@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"todo/internal/logging"
+)
+
+// TraceparentHeader - заголовок W3C Trace Context.
+const TraceparentHeader = "traceparent"
+
+const flagSampled = 0x01
+
+// Traceparent форматирует контекст как значение заголовка traceparent версии 00.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent разбирает заголовок traceparent. Поля после флагов, которые может добавить
+// будущая версия формата, пропускаются; версия ff и нулевые идентификаторы недопустимы.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	const length = 55
+	if len(value) < length || (len(value) > length && (value[:2] == "00" || value[length] != '-')) {
+		return SpanContext{}, false
+	}
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return SpanContext{}, false
+	}
+
+	var version, flags [1]byte
+	var sc SpanContext
+	if !decodeHex(version[:], value[0:2]) || version[0] == 0xff ||
+		!decodeHex(sc.TraceID[:], value[3:35]) ||
+		!decodeHex(sc.SpanID[:], value[36:52]) ||
+		!decodeHex(flags[:], value[53:55]) {
+		return SpanContext{}, false
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&flagSampled != 0
+	sc.Remote = true
+	return sc, true
+}
+
+// decodeHex принимает только строчные шестнадцатеричные цифры, как требует спецификация.
+func decodeHex(dst []byte, src string) bool {
+	for i := 0; i < len(src); i++ {
+		if c := src[i]; c >= 'A' && c <= 'F' {
+			return false
+		}
+	}
+	n, err := hex.Decode(dst, []byte(src))
+	return err == nil && n == len(dst)
+}
+
+// Extract кладет в ctx контекст из входящего traceparent, если он корректен.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if sc, ok := ParseTraceparent(header.Get(TraceparentHeader)); ok {
+		return context.WithValue(ctx, remoteKey{}, sc)
+	}
+	return ctx
+}
+
+// Inject передает текущий спан из ctx в исходящий запрос.
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+// Middleware начинает серверный спан запроса, продолжая трассу из traceparent, и добавляет
+// trace_id в логгер запроса. Имя спана уточняется шаблоном маршрута через SetName.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := Start(Extract(r.Context(), r.Header), r.Method, KindServer,
+			"http.request.method", r.Method, "url.path", r.URL.Path)
+		defer span.End()
+
+		ctx = logging.With(ctx, "trace_id", span.Context().TraceID.String())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,456 @@
+// Package tracing - минимальная трассировка в духе OpenTelemetry без внешних зависимостей:
+// спаны с передачей контекста W3C traceparent, выборка и подключаемые экспортеры.
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"todo/pkg/config"
+)
+
+type TraceID [16]byte
+
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+type SpanID [8]byte
+
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+// SpanContext - то, что передается между сервисами: трасса, спан и решение о выборке.
+// Remote отмечает контекст, пришедший в заголовке запроса.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	Remote  bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Kind - вид спана в терминах OTLP.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *Kind) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "server":
+		*k = KindServer
+	case "client":
+		*k = KindClient
+	case "internal":
+		*k = KindInternal
+	default:
+		return fmt.Errorf("unknown span kind %q", text)
+	}
+	return nil
+}
+
+// Sampler решает, записывать ли новую трассу. Дочерние спаны всегда следуют решению
+// родителя, входящий traceparent - если не задан IgnoreRemoteParent.
+type Sampler struct {
+	// Ratio - доля записываемых трасс от 0 до 1.
+	Ratio              float64
+	IgnoreRemoteParent bool
+}
+
+func (s Sampler) sample(parent SpanContext, traceID TraceID) bool {
+	if parent.IsValid() && !(parent.Remote && s.IgnoreRemoteParent) {
+		return parent.Sampled
+	}
+	if s.Ratio >= 1 {
+		return true
+	}
+	if s.Ratio <= 0 {
+		return false
+	}
+	// Решение зависит только от идентификатора трассы, поэтому одинаково во всех сервисах
+	return binary.BigEndian.Uint64(traceID[8:])>>1 < uint64(s.Ratio*(1<<63))
+}
+
+const (
+	serviceName = "lwo-go"
+
+	batchSize     = 256
+	maxQueue      = 4096
+	batchInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second
+)
+
+// Tracer собирает завершенные спаны и отдает их экспортеру пачками в фоне.
+// Без экспортера спаны не записываются, но идентификаторы по-прежнему передаются дальше.
+type Tracer struct {
+	exporter Exporter
+	sampler  Sampler
+
+	mu      sync.Mutex
+	queue   []SpanData
+	dropped int
+
+	exportMu sync.Mutex
+	wake     chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	shutdown sync.Once
+}
+
+func NewTracer(exporter Exporter, sampler Sampler) *Tracer {
+	t := &Tracer{
+		exporter: exporter,
+		sampler:  sampler,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if exporter == nil {
+		close(t.stopped)
+		return t
+	}
+	go t.run()
+	return t
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(NewTracer(nil, Sampler{}))
+}
+
+// Default возвращает трассировщик, которым начинаются новые трассы.
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+// SetDefault заменяет трассировщик по умолчанию. Прежний нужно остановить через Shutdown.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Enabled сообщает, что спаны экспортируются.
+func (t *Tracer) Enabled() bool {
+	return t.exporter != nil
+}
+
+func (t *Tracer) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		case <-t.wake:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		if err := t.Flush(ctx); err != nil {
+			slog.Warn("failed to export spans", "error", err)
+		}
+		cancel()
+	}
+}
+
+func (t *Tracer) enqueue(span SpanData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) >= maxQueue {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, span)
+	if len(t.queue) >= batchSize {
+		select {
+		case t.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush сразу отдает экспортеру накопленные спаны.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	t.exportMu.Lock()
+	defer t.exportMu.Unlock()
+
+	t.mu.Lock()
+	spans, dropped := t.queue, t.dropped
+	t.queue, t.dropped = nil, 0
+	t.mu.Unlock()
+
+	var errs []error
+	if dropped > 0 {
+		errs = append(errs, fmt.Errorf("%d spans dropped: export queue is full", dropped))
+	}
+	for len(spans) > 0 {
+		n := min(len(spans), batchSize)
+		if err := t.exporter.Export(ctx, spans[:n]); err != nil {
+			errs = append(errs, err)
+		}
+		spans = spans[n:]
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown останавливает фоновую отправку, отдает оставшиеся спаны и закрывает экспортер.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	var err error
+	t.shutdown.Do(func() {
+		close(t.done)
+		<-t.stopped
+		err = errors.Join(t.Flush(ctx), t.exporter.Shutdown(ctx))
+	})
+	return err
+}
+
+// Span - операция трассы. Методы незаписываемого спана ничего не делают,
+// так что вызывающему коду не нужно проверять, включена ли трассировка.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	kind   Kind
+	start  time.Time
+
+	mu         sync.Mutex
+	name       string
+	attributes map[string]any
+	err        string
+	ended      bool
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Start начинает спан name - дочерний для спана из ctx или входящего traceparent (см. Extract),
+// иначе новую трассу. args - пары ключ-значение атрибутов, как в log/slog.
+func Start(ctx context.Context, name string, kind Kind, args ...any) (context.Context, *Span) {
+	tracer := Default()
+	parent := SpanContextFromContext(ctx)
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok {
+		tracer = span.tracer
+	}
+
+	sc := SpanContext{TraceID: parent.TraceID, SpanID: newSpanID()}
+	if !parent.IsValid() {
+		sc.TraceID = newTraceID()
+	}
+	sc.Sampled = tracer.sampler.sample(parent, sc.TraceID)
+
+	span := &Span{tracer: tracer, sc: sc, parent: parent.SpanID, kind: kind, start: time.Now(), name: name}
+	span.SetAttributes(args...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext возвращает текущий спан или незаписываемую заглушку.
+func SpanFromContext(ctx context.Context) *Span {
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok {
+		return span
+	}
+	return &Span{}
+}
+
+// SpanContextFromContext возвращает контекст текущего спана, а без него - входящий из Extract.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok {
+		return span.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// Context возвращает идентификаторы спана для передачи дальше.
+func (s *Span) Context() SpanContext {
+	return s.sc
+}
+
+// Recording сообщает, что спан попадет к экспортеру.
+func (s *Span) Recording() bool {
+	return s.tracer != nil && s.tracer.exporter != nil && s.sc.Sampled
+}
+
+// SetName меняет имя спана, например когда шаблон маршрута стал известен внутри запроса.
+func (s *Span) SetName(name string) {
+	if !s.Recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttributes добавляет атрибуты парами ключ-значение.
+func (s *Span) SetAttributes(args ...any) {
+	if !s.Recording() || len(args) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]any, len(args)/2)
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		s.attributes[fmt.Sprint(args[i])] = args[i+1]
+	}
+}
+
+// SetError отмечает спан как неуспешный. nil ничего не меняет.
+func (s *Span) SetError(err error) {
+	if err == nil || !s.Recording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End завершает спан и ставит его в очередь экспорта. Повторные вызовы ничего не делают.
+func (s *Span) End() {
+	if !s.Recording() {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		TraceID:    s.sc.TraceID.String(),
+		SpanID:     s.sc.SpanID.String(),
+		Name:       s.name,
+		Kind:       s.kind,
+		Start:      s.start,
+		End:        end,
+		DurationMS: float64(end.Sub(s.start).Microseconds()) / 1000,
+		Attributes: s.attributes,
+		Status:     StatusOK,
+		Error:      s.err,
+	}
+	s.mu.Unlock()
+
+	if s.parent.IsValid() {
+		data.ParentSpanID = s.parent.String()
+	}
+	if data.Error != "" {
+		data.Status = StatusError
+	}
+	s.tracer.enqueue(data)
+}
+
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// SpanData - завершенный спан в том виде, в котором его получает экспортер.
+type SpanData struct {
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Name         string         `json:"name"`
+	Kind         Kind           `json:"kind"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	DurationMS   float64        `json:"duration_ms"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Status       string         `json:"status"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// FromConfig создает трассировщик по секции tracing. Экспортер none (по умолчанию) отключает
+// отправку, stdout и file пишут JSON-строки, otlp отправляет спаны коллектору. sample_parent=false
+// заставляет игнорировать решение из входящего traceparent.
+func FromConfig(cfg config.Tracing) (*Tracer, error) {
+	sampler := Sampler{Ratio: cfg.SampleRatio, IgnoreRemoteParent: !cfg.SampleParent}
+
+	var exporter Exporter
+	switch cfg.Exporter {
+	case "", "none":
+	case "stdout":
+		exporter = NewWriterExporter(os.Stdout)
+	case "file":
+		file, err := NewFileExporter(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		exporter = file
+	case "otlp":
+		headers, err := parseHeaders(cfg.OTLPHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRACE_OTLP_HEADERS: %w", err)
+		}
+		otlp, err := NewOTLPExporter(cfg.OTLPEndpoint, serviceName, headers)
+		if err != nil {
+			return nil, err
+		}
+		exporter = otlp
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, expected none, stdout, file or otlp", cfg.Exporter)
+	}
+	return NewTracer(exporter, sampler), nil
+}
@@ -0,0 +1,204 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const traceID, spanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	sc, ok := ParseTraceparent("00-" + traceID + "-" + spanID + "-01")
+	if !ok || sc.TraceID.String() != traceID || sc.SpanID.String() != spanID || !sc.Sampled || !sc.Remote {
+		t.Fatalf("unexpected span context: %+v %v", sc, ok)
+	}
+	if got := sc.Traceparent(); got != "00-"+traceID+"-"+spanID+"-01" {
+		t.Errorf("traceparent = %q", got)
+	}
+
+	for _, value := range []string{
+		"",
+		"00-" + traceID + "-" + spanID + "-01-extra",
+		"ff-" + traceID + "-" + spanID + "-01",
+		"00-" + strings.ToUpper(traceID) + "-" + spanID + "-01",
+		"00-00000000000000000000000000000000-" + spanID + "-01",
+		"00-" + traceID + "-0000000000000000-01",
+		"00_" + traceID + "-" + spanID + "-01",
+	} {
+		if _, ok := ParseTraceparent(value); ok {
+			t.Errorf("invalid traceparent %q is accepted", value)
+		}
+	}
+	// Будущие версии могут добавить поля после флагов
+	if sc, ok := ParseTraceparent("01-" + traceID + "-" + spanID + "-00-extra"); !ok || sc.Sampled {
+		t.Errorf("future version is rejected: %+v %v", sc, ok)
+	}
+}
+
+func decodeSpans(t *testing.T, data []byte) []SpanData {
+	t.Helper()
+	var spans []SpanData
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var span SpanData
+		if err := decoder.Decode(&span); err != nil {
+			t.Fatal(err)
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+func TestMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&out), Sampler{Ratio: 1})
+	previous := Default()
+	SetDefault(tracer)
+	t.Cleanup(func() { SetDefault(previous) })
+
+	var outgoing http.Header
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SpanFromContext(r.Context()).SetName("GET /tasks/{id}")
+		ctx, span := Start(r.Context(), "db GetTaskById", KindClient, "db.system", "sqlite")
+		span.SetError(errors.New("no such table"))
+		span.End()
+
+		outgoing = http.Header{}
+		Inject(ctx, outgoing)
+	}))
+
+	req := httptest.NewRequest("GET", "/tasks/7", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := decodeSpans(t, out.Bytes())
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d: %s", len(spans), out.String())
+	}
+	child, server := spans[0], spans[1]
+	if server.Name != "GET /tasks/{id}" || server.Kind != KindServer || server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		server.ParentSpanID != "00f067aa0ba902b7" || server.Status != StatusOK {
+		t.Errorf("unexpected server span: %+v", server)
+	}
+	if child.TraceID != server.TraceID || child.ParentSpanID != server.SpanID || child.Status != StatusError ||
+		child.Error != "no such table" || child.Attributes["db.system"] != "sqlite" {
+		t.Errorf("unexpected child span: %+v", child)
+	}
+	if want := "00-" + child.TraceID + "-" + child.SpanID + "-01"; outgoing.Get(TraceparentHeader) != want {
+		t.Errorf("injected traceparent = %q, want %q", outgoing.Get(TraceparentHeader), want)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	var out bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&out), Sampler{Ratio: 0, IgnoreRemoteParent: false})
+	previous := Default()
+	SetDefault(tracer)
+	t.Cleanup(func() { SetDefault(previous) })
+
+	// Новая трасса не попадает в выборку, но идентификаторы передаются дальше
+	ctx, root := Start(context.Background(), "job overdue", KindInternal)
+	_, child := Start(ctx, "db UpdateOverdueTasks", KindClient)
+	if root.Recording() || child.Recording() || !child.Context().IsValid() || child.Context().TraceID != root.Context().TraceID {
+		t.Fatalf("unexpected sampling: %+v %+v", root.Context(), child.Context())
+	}
+
+	// Решение из входящего traceparent сильнее доли
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, remote := Start(Extract(context.Background(), header), "GET", KindServer)
+	if !remote.Recording() {
+		t.Fatal("sampled remote parent is ignored")
+	}
+
+	tracer.sampler.IgnoreRemoteParent = true
+	if _, span := Start(Extract(context.Background(), header), "GET", KindServer); span.Recording() {
+		t.Fatal("remote parent is followed despite IgnoreRemoteParent")
+	}
+
+	sampled := 0
+	half := Sampler{Ratio: 0.5}
+	for i := 0; i < 1000; i++ {
+		if half.sample(SpanContext{}, newTraceID()) {
+			sampled++
+		}
+	}
+	if sampled < 400 || sampled > 600 {
+		t.Errorf("ratio 0.5 sampled %d of 1000 traces", sampled)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var received otlpRequest
+	var header http.Header
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		header = r.Header
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer collector.Close()
+
+	headers, err := parseHeaders("x-api-key=secret, x-tenant = todo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter, err := NewOTLPExporter(collector.URL, "lwo-go", headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := NewTracer(exporter, Sampler{Ratio: 1})
+	previous := Default()
+	SetDefault(tracer)
+	t.Cleanup(func() { SetDefault(previous) })
+
+	ctx, span := Start(context.Background(), "job webhooks", KindInternal)
+	_, child := Start(ctx, "webhook deliver", KindClient, "webhook.id", 3, "retry", true)
+	child.SetError(errors.New("receiver responded with status 500"))
+	child.End()
+	span.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Get("X-Api-Key") != "secret" || header.Get("X-Tenant") != "todo" {
+		t.Errorf("headers are not sent: %v", header)
+	}
+	if len(received.ResourceSpans) != 1 || len(received.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request: %+v", received)
+	}
+	resource := received.ResourceSpans[0].Resource.Attributes
+	if len(resource) != 1 || resource[0].Key != "service.name" || *resource[0].Value.StringValue != "lwo-go" {
+		t.Errorf("unexpected resource: %+v", resource)
+	}
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	deliver := spans[0]
+	if deliver.Kind != int(KindClient) || deliver.ParentSpanID != spans[1].SpanID || deliver.Status.Code != otlpStatusError ||
+		len(deliver.Attributes) != 2 || *deliver.Attributes[0].Value.BoolValue != true || *deliver.Attributes[1].Value.IntValue != "3" {
+		t.Errorf("unexpected span: %+v", deliver)
+	}
+
+	if _, err := NewOTLPExporter("localhost:4318", "lwo-go", nil); err == nil {
+		t.Error("endpoint without scheme is accepted")
+	}
+	if _, err := parseHeaders("broken"); err == nil {
+		t.Error("header without value is accepted")
+	}
+}
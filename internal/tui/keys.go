@@ -0,0 +1,118 @@
+package tui
+
+import (
+	"unicode/utf8"
+)
+
+// keyCode - клавиша без символа или keyRune для печатного символа.
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyEnter
+	keyEsc
+	keyBackspace
+	keyDelete
+	keyTab
+	keyBacktab
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyPageUp
+	keyPageDown
+	keyCtrlC
+	keyCtrlU
+)
+
+type key struct {
+	code keyCode
+	r    rune
+}
+
+func runeKey(r rune) key {
+	return key{code: keyRune, r: r}
+}
+
+// escapeKeys - последовательности ESC [ ... и ESC O ..., которые отправляют терминалы
+// xterm, VT100 и их потомки.
+var escapeKeys = map[string]keyCode{
+	"[A": keyUp, "[B": keyDown, "[C": keyRight, "[D": keyLeft,
+	"OA": keyUp, "OB": keyDown, "OC": keyRight, "OD": keyLeft,
+	"[H": keyHome, "[F": keyEnd, "OH": keyHome, "OF": keyEnd,
+	"[1~": keyHome, "[7~": keyHome, "[4~": keyEnd, "[8~": keyEnd,
+	"[3~": keyDelete, "[5~": keyPageUp, "[6~": keyPageDown, "[Z": keyBacktab,
+}
+
+// parseKeys разбирает байты, прочитанные из терминала в сыром режиме. Одиночный ESC
+// в конце прочитанного - клавиша Esc: терминал присылает последовательность целиком.
+func parseKeys(data []byte) []key {
+	var keys []key
+	for len(data) > 0 {
+		b := data[0]
+		switch {
+		case b == 0x1b:
+			if len(data) == 1 {
+				keys = append(keys, key{code: keyEsc})
+				data = data[1:]
+				continue
+			}
+			n, code := escapeSequence(data[1:])
+			if n == 0 {
+				keys = append(keys, key{code: keyEsc})
+				data = data[1:]
+				continue
+			}
+			if code != keyRune {
+				keys = append(keys, key{code: code})
+			}
+			data = data[1+n:]
+			continue
+		case b == '\r' || b == '\n':
+			keys = append(keys, key{code: keyEnter})
+		case b == '\t':
+			keys = append(keys, key{code: keyTab})
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, key{code: keyBackspace})
+		case b == 0x03:
+			keys = append(keys, key{code: keyCtrlC})
+		case b == 0x15:
+			keys = append(keys, key{code: keyCtrlU})
+		case b < 0x20:
+			// Остальные управляющие символы не используются
+		default:
+			r, size := utf8.DecodeRune(data)
+			keys = append(keys, runeKey(r))
+			data = data[size:]
+			continue
+		}
+		data = data[1:]
+	}
+	return keys
+}
+
+// escapeSequence возвращает длину последовательности после ESC и клавишу; keyRune -
+// последовательность распознана, но не используется. 0 - это не последовательность.
+func escapeSequence(data []byte) (int, keyCode) {
+	switch data[0] {
+	case 'O':
+		if len(data) < 2 {
+			return 0, keyRune
+		}
+		return 2, escapeKeys[string(data[:2])]
+	case '[':
+		// Параметры - цифры и ';', последовательность заканчивается символом от '@' до '~'
+		for i := 1; i < len(data); i++ {
+			if data[i] >= 0x40 && data[i] <= 0x7e {
+				sequence := string(data[:i+1])
+				if code, ok := escapeKeys[sequence]; ok {
+					return i + 1, code
+				}
+				return i + 1, keyRune
+			}
+		}
+	}
+	return 0, keyRune
+}
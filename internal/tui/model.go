@@ -0,0 +1,476 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"todo/internal/db"
+)
+
+// Store - задачи, с которыми работает интерфейс: локальная база или сервер.
+type Store interface {
+	List(ctx context.Context, projectID int) ([]*db.Task, error)
+	Create(ctx context.Context, input *db.TaskInput) (*db.Task, error)
+	Update(ctx context.Context, id int, input *db.TaskInput) (*db.Task, error)
+	Complete(ctx context.Context, id int) (*db.Task, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// storeTimeout ограничивает одну операцию с задачами: интерфейс ждет ее завершения.
+const storeTimeout = 15 * time.Second
+
+type mode int
+
+const (
+	modeList mode = iota
+	modeSearch
+	modeForm
+	modeConfirm
+	modeHelp
+)
+
+// filter - какие задачи показывать.
+type filter int
+
+const (
+	filterAll filter = iota
+	filterOpen
+	filterOverdue
+	filterDone
+)
+
+var filterNames = []string{"all", "open", "overdue", "done"}
+
+func (f filter) String() string {
+	return filterNames[f]
+}
+
+func (f filter) match(task *db.Task) bool {
+	switch f {
+	case filterOpen:
+		return !task.IsCompleted
+	case filterOverdue:
+		return task.IsOverdue && !task.IsCompleted
+	case filterDone:
+		return task.IsCompleted
+	}
+	return true
+}
+
+// model - состояние интерфейса. Клавиши меняют его через handleKey, а view рисует его
+// целиком; с терминалом model не работает.
+type model struct {
+	ctx   context.Context
+	store Store
+	now   func() time.Time
+
+	tasks []*db.Task
+	// visible - задачи после фильтра и поиска в порядке показа.
+	visible []*db.Task
+	cursor  int
+	offset  int
+	filter  filter
+	search  line
+
+	mode mode
+	form *form
+	// deleting - задача, удаление которой ждет подтверждения. Она запоминается, потому что
+	// обновление списка может сдвинуть курсор.
+	deleting *db.Task
+	message  string
+	failed   bool
+	quit     bool
+
+	width  int
+	height int
+}
+
+func newModel(ctx context.Context, store Store, now func() time.Time) *model {
+	return &model{ctx: ctx, store: store, now: now, width: 80, height: 24}
+}
+
+// selected возвращает задачу под курсором или nil.
+func (m *model) selected() *db.Task {
+	if m.cursor < 0 || m.cursor >= len(m.visible) {
+		return nil
+	}
+	return m.visible[m.cursor]
+}
+
+// reload перечитывает задачи, сохраняя курсор на той же задаче. selectID - задача, на
+// которую нужно перейти (0 - текущая).
+func (m *model) reload(selectID int) {
+	if selectID == 0 {
+		if task := m.selected(); task != nil {
+			selectID = task.ID
+		}
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, storeTimeout)
+	defer cancel()
+	tasks, err := m.store.List(ctx, 0)
+	if err != nil {
+		m.setError(err)
+		return
+	}
+	// Сначала открытые задачи по сроку, выполненные в конце
+	slices.SortStableFunc(tasks, func(a, b *db.Task) int {
+		if a.IsCompleted != b.IsCompleted {
+			if a.IsCompleted {
+				return 1
+			}
+			return -1
+		}
+		if c := strings.Compare(a.DueDate, b.DueDate); c != 0 {
+			return c
+		}
+		return a.ID - b.ID
+	})
+	m.tasks = tasks
+	m.refilter(selectID)
+}
+
+// refilter применяет фильтр и поиск и ставит курсор на задачу selectID, если она видна.
+func (m *model) refilter(selectID int) {
+	query := strings.ToLower(m.search.String())
+	m.visible = m.visible[:0]
+	for _, task := range m.tasks {
+		if !m.filter.match(task) {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(task.Title), query) && !strings.Contains(strings.ToLower(task.Description), query) {
+			continue
+		}
+		m.visible = append(m.visible, task)
+	}
+	if index := slices.IndexFunc(m.visible, func(task *db.Task) bool { return task.ID == selectID }); index >= 0 {
+		m.cursor = index
+	}
+	m.move(0)
+}
+
+// move сдвигает курсор на delta строк и прокручивает список, чтобы курсор был виден.
+func (m *model) move(delta int) {
+	m.cursor = max(min(m.cursor+delta, len(m.visible)-1), 0)
+	rows := m.listHeight()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+	m.offset = max(min(m.offset, len(m.visible)-rows), 0)
+}
+
+// refresh перечитывает задачи, изменившиеся извне, не трогая сообщение пользователю, если
+// перечитать удалось.
+func (m *model) refresh() {
+	message, failed := m.message, m.failed
+	m.reload(0)
+	if !m.failed {
+		m.message, m.failed = message, failed
+	}
+}
+
+func (m *model) setError(err error) {
+	m.message, m.failed = err.Error(), true
+}
+
+func (m *model) setMessage(format string, args ...any) {
+	m.message, m.failed = fmt.Sprintf(format, args...), false
+}
+
+func (m *model) handleKey(k key) {
+	if k.code == keyCtrlC {
+		m.quit = true
+		return
+	}
+	switch m.mode {
+	case modeList:
+		m.message = ""
+		m.listKey(k)
+	case modeSearch:
+		m.searchKey(k)
+	case modeForm:
+		m.formKey(k)
+	case modeConfirm:
+		m.confirmKey(k)
+	case modeHelp:
+		m.mode = modeList
+	}
+}
+
+func (m *model) listKey(k key) {
+	switch k.code {
+	case keyUp:
+		m.move(-1)
+	case keyDown:
+		m.move(1)
+	case keyPageUp:
+		m.move(-m.listHeight())
+	case keyPageDown:
+		m.move(m.listHeight())
+	case keyHome:
+		m.move(-len(m.visible))
+	case keyEnd:
+		m.move(len(m.visible))
+	case keyEnter:
+		m.openForm(m.selected())
+	case keyDelete:
+		m.confirmDelete()
+	case keyEsc:
+		if m.search.String() != "" {
+			m.search.clear()
+			m.refilter(0)
+		}
+	case keyRune:
+		switch k.r {
+		case 'k':
+			m.move(-1)
+		case 'j':
+			m.move(1)
+		case 'g':
+			m.move(-len(m.visible))
+		case 'G':
+			m.move(len(m.visible))
+		case 'a', 'n':
+			m.openForm(nil)
+		case 'e':
+			m.openForm(m.selected())
+		case 'x', ' ':
+			m.complete()
+		case 'd':
+			m.confirmDelete()
+		case '/':
+			m.mode = modeSearch
+		case 'f':
+			m.filter = (m.filter + 1) % filter(len(filterNames))
+			m.refilter(0)
+		case 'r':
+			m.reload(0)
+			if !m.failed {
+				m.setMessage("reloaded %d tasks", len(m.tasks))
+			}
+		case '?':
+			m.mode = modeHelp
+		case 'q':
+			m.quit = true
+		}
+	}
+}
+
+// searchKey редактирует строку поиска; список фильтруется по мере ввода.
+func (m *model) searchKey(k key) {
+	switch k.code {
+	case keyEnter:
+		m.mode = modeList
+	case keyEsc:
+		m.search.clear()
+		m.mode = modeList
+	case keyUp, keyDown:
+		m.mode = modeList
+		m.listKey(k)
+		return
+	default:
+		m.search.edit(k)
+	}
+	m.refilter(0)
+}
+
+func (m *model) complete() {
+	task := m.selected()
+	if task == nil {
+		return
+	}
+	if task.IsCompleted {
+		m.setMessage("task %d is already completed", task.ID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, storeTimeout)
+	defer cancel()
+	if _, err := m.store.Complete(ctx, task.ID); err != nil {
+		m.setError(err)
+		return
+	}
+	m.reload(task.ID)
+	m.setMessage("task %d completed", task.ID)
+}
+
+func (m *model) confirmDelete() {
+	if task := m.selected(); task != nil {
+		m.mode, m.deleting = modeConfirm, task
+	}
+}
+
+func (m *model) confirmKey(k key) {
+	task := m.deleting
+	m.mode, m.deleting = modeList, nil
+	if k.code != keyRune || (k.r != 'y' && k.r != 'Y') {
+		m.setMessage("deletion cancelled")
+		return
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, storeTimeout)
+	defer cancel()
+	if err := m.store.Delete(ctx, task.ID); err != nil {
+		m.setError(err)
+		return
+	}
+	m.reload(0)
+	m.setMessage("task %d deleted", task.ID)
+}
+
+// form - строка создания или изменения задачи: название и срок.
+type form struct {
+	// task - изменяемая задача, nil - новая.
+	task   *db.Task
+	fields [2]line
+	active int
+}
+
+const (
+	fieldTitle = iota
+	fieldDue
+)
+
+var fieldLabels = [...]string{"Title", "Due (YYYY-MM-DD)"}
+
+func (m *model) openForm(task *db.Task) {
+	f := &form{task: task}
+	if task != nil {
+		f.fields[fieldTitle].set(task.Title)
+		f.fields[fieldDue].set(dueDay(task.DueDate))
+	}
+	m.form = f
+	m.mode = modeForm
+}
+
+func (m *model) formKey(k key) {
+	f := m.form
+	switch k.code {
+	case keyEsc:
+		m.mode, m.form = modeList, nil
+	case keyTab, keyDown:
+		f.active = (f.active + 1) % len(f.fields)
+	case keyBacktab, keyUp:
+		f.active = (f.active + len(f.fields) - 1) % len(f.fields)
+	case keyEnter:
+		if f.active < len(f.fields)-1 {
+			f.active++
+			return
+		}
+		m.submit()
+	default:
+		f.fields[f.active].edit(k)
+	}
+}
+
+// submit создает или изменяет задачу. При ошибке форма остается открытой.
+func (m *model) submit() {
+	f := m.form
+	title := strings.TrimSpace(f.fields[fieldTitle].String())
+	due := strings.TrimSpace(f.fields[fieldDue].String())
+	if title == "" {
+		m.setMessage("title is required")
+		m.failed, f.active = true, fieldTitle
+		return
+	}
+	if due != "" {
+		if _, err := time.Parse(time.DateOnly, due); err != nil {
+			m.setMessage("invalid due date %q, expected YYYY-MM-DD", due)
+			m.failed, f.active = true, fieldDue
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, storeTimeout)
+	defer cancel()
+	input := &db.TaskInput{}
+	var task *db.Task
+	var err error
+	if f.task == nil {
+		input.Title = &title
+		if due != "" {
+			input.DueDate = &due
+		}
+		task, err = m.store.Create(ctx, input)
+	} else {
+		if title != f.task.Title {
+			input.Title = &title
+		}
+		// При изменении сервер ждет срок со временем: задача, как и при создании, истекает в конце дня
+		if due != "" && due != dueDay(f.task.DueDate) {
+			dueDate := due + " 23:59:59"
+			input.DueDate = &dueDate
+		}
+		if input.Title == nil && input.DueDate == nil {
+			m.mode, m.form = modeList, nil
+			return
+		}
+		task, err = m.store.Update(ctx, f.task.ID, input)
+	}
+	if err != nil {
+		m.setError(err)
+		return
+	}
+
+	m.mode, m.form = modeList, nil
+	m.reload(task.ID)
+	if f.task == nil {
+		m.setMessage("task %d created", task.ID)
+	} else {
+		m.setMessage("task %d updated", task.ID)
+	}
+}
+
+// dueDay возвращает дату срока без времени.
+func dueDay(dueDate string) string {
+	day, _, _ := strings.Cut(dueDate, " ")
+	return day
+}
+
+// line - редактируемая строка с курсором.
+type line struct {
+	text []rune
+	pos  int
+}
+
+func (l *line) String() string {
+	return string(l.text)
+}
+
+func (l *line) set(value string) {
+	l.text = []rune(value)
+	l.pos = len(l.text)
+}
+
+func (l *line) clear() {
+	l.text, l.pos = nil, 0
+}
+
+func (l *line) edit(k key) {
+	switch k.code {
+	case keyRune:
+		l.text = slices.Insert(l.text, l.pos, k.r)
+		l.pos++
+	case keyBackspace:
+		if l.pos > 0 {
+			l.text = slices.Delete(l.text, l.pos-1, l.pos)
+			l.pos--
+		}
+	case keyDelete:
+		if l.pos < len(l.text) {
+			l.text = slices.Delete(l.text, l.pos, l.pos+1)
+		}
+	case keyLeft:
+		l.pos = max(l.pos-1, 0)
+	case keyRight:
+		l.pos = min(l.pos+1, len(l.text))
+	case keyHome:
+		l.pos = 0
+	case keyEnd:
+		l.pos = len(l.text)
+	case keyCtrlU:
+		l.clear()
+	}
+}
@@ -0,0 +1,147 @@
+// Package tui - интерактивный список задач в терминале: навигация клавишами, создание,
+// изменение, выполнение и удаление задач, фильтр и поиск. Задачи берутся из Store - локальной
+// базы или сервера, список обновляется по событиям сервера или по таймеру.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// sizePoll - как часто проверяется размер терминала. SIGWINCH есть не на всех платформах,
+// а проверка дешевая.
+const sizePoll = 250 * time.Millisecond
+
+// Options - параметры Run.
+type Options struct {
+	Store Store
+	// In - терминал, из которого читаются клавиши; переводится в сырой режим.
+	In *os.File
+	// Out - терминал, на который выводится интерфейс.
+	Out io.Writer
+	// Changes сигнализирует, что задачи изменились и список нужно перечитать. Если канал
+	// nil или закрылся, список перечитывается каждые Poll.
+	Changes <-chan struct{}
+	Poll    time.Duration
+	// Source - откуда задачи (база или адрес сервера), показывается в заголовке.
+	Source string
+	// NoColor выключает цвета, оставляя выделение курсора.
+	NoColor bool
+	// Now - текущее время для отметки сроков; по умолчанию time.Now.
+	Now func() time.Time
+}
+
+// Run показывает интерфейс до выхода пользователя или отмены ctx и восстанавливает терминал.
+func Run(ctx context.Context, opts Options) error {
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
+	fd := int(opts.In.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+	// Альтернативный экран сохраняет содержимое терминала, курсор скрыт
+	io.WriteString(opts.Out, "\x1b[?1049h\x1b[?25l")
+	defer io.WriteString(opts.Out, "\x1b[?25h\x1b[?1049l")
+
+	// Чтение из терминала нельзя прервать, поэтому горутина остается заблокированной до
+	// выхода из программы; буфер не дает ей зависнуть на отправке.
+	keys := make(chan []key, 16)
+	readErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := opts.In.Read(buf)
+			if n > 0 {
+				keys <- parseKeys(buf[:n])
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	m := newModel(ctx, opts.Store, now)
+	m.width, m.height = terminalSize(fd)
+	m.reload(0)
+
+	// Таймер работает всегда, но читается, только когда событий нет
+	changes := opts.Changes
+	var ticker <-chan time.Time
+	if opts.Poll > 0 {
+		t := time.NewTicker(opts.Poll)
+		defer t.Stop()
+		ticker = t.C
+	}
+	live, poll := "live", (<-chan time.Time)(nil)
+	if changes == nil {
+		live, poll = pollLabel(opts.Poll), ticker
+	}
+	size := time.NewTicker(sizePoll)
+	defer size.Stop()
+
+	// Кадр перерисовывается после всего, кроме проверки размера без изменений
+	redraw := true
+	for !m.quit {
+		if redraw {
+			if _, err := opts.Out.Write(m.view(opts.Source, live, opts.NoColor)); err != nil {
+				return err
+			}
+		}
+		redraw = true
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("terminal: %w", err)
+		case batch := <-keys:
+			for _, k := range batch {
+				m.handleKey(k)
+			}
+		case _, ok := <-changes:
+			if ok {
+				m.refresh()
+				break
+			}
+			// Поток событий закончился: дальше список обновляется по таймеру
+			changes, poll = nil, ticker
+			live = pollLabel(opts.Poll)
+		case <-poll:
+			m.refresh()
+		case <-size.C:
+			width, height := terminalSize(fd)
+			redraw = width != m.width || height != m.height
+			m.width, m.height = width, height
+			m.move(0)
+		}
+	}
+	return nil
+}
+
+func pollLabel(poll time.Duration) string {
+	if poll <= 0 {
+		return ""
+	}
+	return "refresh every " + poll.String()
+}
+
+// terminalSize возвращает размер терминала или 80x24, если его не удалось узнать.
+func terminalSize(fd int) (int, int) {
+	width, height, err := term.GetSize(fd)
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
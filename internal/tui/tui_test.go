@@ -0,0 +1,327 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"todo/internal/db"
+)
+
+// memoryStore - задачи в памяти вместо базы или сервера.
+type memoryStore struct {
+	tasks  []*db.Task
+	nextID int
+	// updates - входные данные последних изменений.
+	updates []*db.TaskInput
+}
+
+func newMemoryStore(tasks ...*db.Task) *memoryStore {
+	s := &memoryStore{tasks: tasks, nextID: 1}
+	for _, task := range tasks {
+		s.nextID = max(s.nextID, task.ID+1)
+	}
+	return s
+}
+
+func (s *memoryStore) find(id int) (*db.Task, error) {
+	for _, task := range s.tasks {
+		if task.ID == id {
+			return task, nil
+		}
+	}
+	return nil, errors.New("task not found")
+}
+
+func (s *memoryStore) List(ctx context.Context, projectID int) ([]*db.Task, error) {
+	tasks := make([]*db.Task, len(s.tasks))
+	for i, task := range s.tasks {
+		copied := *task
+		tasks[i] = &copied
+	}
+	return tasks, nil
+}
+
+func (s *memoryStore) Create(ctx context.Context, input *db.TaskInput) (*db.Task, error) {
+	task := &db.Task{ID: s.nextID, Title: *input.Title, Status: "todo"}
+	if input.DueDate != nil {
+		task.DueDate = *input.DueDate + " 23:59:59"
+	}
+	s.nextID++
+	s.tasks = append(s.tasks, task)
+	return task, nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, id int, input *db.TaskInput) (*db.Task, error) {
+	task, err := s.find(id)
+	if err != nil {
+		return nil, err
+	}
+	s.updates = append(s.updates, input)
+	if input.Title != nil {
+		task.Title = *input.Title
+	}
+	if input.DueDate != nil {
+		task.DueDate = *input.DueDate
+	}
+	return task, nil
+}
+
+func (s *memoryStore) Complete(ctx context.Context, id int) (*db.Task, error) {
+	task, err := s.find(id)
+	if err != nil {
+		return nil, err
+	}
+	task.IsCompleted, task.Status = true, "done"
+	return task, nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, id int) error {
+	if _, err := s.find(id); err != nil {
+		return err
+	}
+	s.tasks = slices.DeleteFunc(s.tasks, func(task *db.Task) bool { return task.ID == id })
+	return nil
+}
+
+var testNow = time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+
+func newTestModel(store Store) *model {
+	m := newModel(context.Background(), store, func() time.Time { return testNow })
+	m.reload(0)
+	return m
+}
+
+// typeKeys передает модели клавиши из строки так же, как их прислал бы терминал.
+func typeKeys(m *model, input string) {
+	for _, k := range parseKeys([]byte(input)) {
+		m.handleKey(k)
+	}
+}
+
+func titles(tasks []*db.Task) []string {
+	result := make([]string, len(tasks))
+	for i, task := range tasks {
+		result[i] = task.Title
+	}
+	return result
+}
+
+func sampleStore() *memoryStore {
+	return newMemoryStore(
+		&db.Task{ID: 1, Title: "Write report", DueDate: "2024-03-05 23:59:59", Status: "todo"},
+		&db.Task{ID: 2, Title: "Pay rent", DueDate: "2024-02-28 23:59:59", Status: "todo", IsOverdue: true},
+		&db.Task{ID: 3, Title: "Buy milk", DueDate: "2024-03-01 23:59:59", Status: "done", IsCompleted: true},
+		&db.Task{ID: 4, Title: "Call plumber", DueDate: "2024-03-01 18:00:00", Status: "in_progress", Description: "kitchen sink",
+			CustomFields: map[string]any{PriorityField: "high"}},
+	)
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("a\x1b[A\x1b[B\x1bOC\x1b[3~\x1b[5~\x1b[Z\x1b[1;5C\r\t\x7f\x03\x15я\x1b"))
+	want := []key{
+		runeKey('a'), {code: keyUp}, {code: keyDown}, {code: keyRight}, {code: keyDelete}, {code: keyPageUp},
+		{code: keyBacktab}, {code: keyEnter}, {code: keyTab}, {code: keyBackspace}, {code: keyCtrlC}, {code: keyCtrlU},
+		runeKey('я'), {code: keyEsc},
+	}
+	if !slices.Equal(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	m := newTestModel(sampleStore())
+	if got, want := titles(m.visible), []string{"Pay rent", "Call plumber", "Write report", "Buy milk"}; !slices.Equal(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+
+	typeKeys(m, "jj")
+	if m.selected().ID != 1 {
+		t.Errorf("after jj selected %d, want 1", m.selected().ID)
+	}
+	typeKeys(m, "\x1b[Bjjj")
+	if m.cursor != 3 {
+		t.Errorf("cursor = %d, want the last task", m.cursor)
+	}
+	typeKeys(m, "g")
+	if m.cursor != 0 {
+		t.Errorf("after g cursor = %d", m.cursor)
+	}
+
+	// Список длиннее экрана прокручивается за курсором
+	m.height = chromeRows + 2
+	typeKeys(m, "G")
+	if m.offset != 2 {
+		t.Errorf("offset = %d, want 2", m.offset)
+	}
+	typeKeys(m, "\x1b[5~")
+	if m.cursor != 1 || m.offset != 1 {
+		t.Errorf("after PgUp cursor %d offset %d", m.cursor, m.offset)
+	}
+
+	typeKeys(m, "q")
+	if !m.quit {
+		t.Error("q does not quit")
+	}
+}
+
+func TestFilterAndSearch(t *testing.T) {
+	m := newTestModel(sampleStore())
+
+	typeKeys(m, "f")
+	if got := titles(m.visible); len(got) != 3 || slices.Contains(got, "Buy milk") {
+		t.Errorf("open filter: %v", got)
+	}
+	typeKeys(m, "f")
+	if got := titles(m.visible); !slices.Equal(got, []string{"Pay rent"}) {
+		t.Errorf("overdue filter: %v", got)
+	}
+	typeKeys(m, "f")
+	if got := titles(m.visible); !slices.Equal(got, []string{"Buy milk"}) {
+		t.Errorf("done filter: %v", got)
+	}
+	typeKeys(m, "f")
+
+	// Поиск идет по названию и описанию без учета регистра, список фильтруется по мере ввода
+	typeKeys(m, "/SINK")
+	if m.mode != modeSearch || !slices.Equal(titles(m.visible), []string{"Call plumber"}) {
+		t.Errorf("search: mode %d, %v", m.mode, titles(m.visible))
+	}
+	typeKeys(m, "\x7f\x7f\x7f\x7fr")
+	if got := titles(m.visible); !slices.Equal(got, []string{"Pay rent", "Call plumber", "Write report"}) {
+		t.Errorf("search r: %v", got)
+	}
+	typeKeys(m, "\r")
+	if m.mode != modeList || m.search.String() != "r" {
+		t.Errorf("after Enter mode %d search %q", m.mode, m.search.String())
+	}
+	typeKeys(m, "\x1b")
+	if len(m.visible) != 4 {
+		t.Errorf("Esc does not clear the search: %v", titles(m.visible))
+	}
+}
+
+func TestCreateEditComplete(t *testing.T) {
+	store := sampleStore()
+	m := newTestModel(store)
+
+	typeKeys(m, "a\r")
+	if m.mode != modeForm || m.form.active != fieldDue {
+		t.Fatalf("Enter in the title moves to the due date: mode %d field %d", m.mode, m.form.active)
+	}
+	typeKeys(m, "\r")
+	if !m.failed || m.mode != modeForm || m.form.active != fieldTitle {
+		t.Errorf("empty title is accepted: %q", m.message)
+	}
+	typeKeys(m, "Water plants\t2024-13-01\r")
+	if !m.failed || !strings.Contains(m.message, "invalid due date") {
+		t.Errorf("invalid due date: %q", m.message)
+	}
+	typeKeys(m, "\x15"+"2024-03-02\r")
+	if m.mode != modeList || m.failed {
+		t.Fatalf("create: mode %d message %q", m.mode, m.message)
+	}
+	if task := m.selected(); task == nil || task.ID != 5 || task.Title != "Water plants" || task.DueDate != "2024-03-02 23:59:59" {
+		t.Fatalf("created task is not selected: %+v", task)
+	}
+
+	// Изменяются только измененные поля
+	typeKeys(m, "e\x1b[H\x1b[3~\x1b[3~\x1b[3~\x1b[3~\x1b[3~Feed\r\r")
+	if m.mode != modeList || len(store.updates) != 1 {
+		t.Fatalf("edit: mode %d message %q", m.mode, m.message)
+	}
+	if input := store.updates[0]; input.Title == nil || *input.Title != "Feed plants" || input.DueDate != nil {
+		t.Errorf("update input: %+v", input)
+	}
+	typeKeys(m, "e\r\r")
+	if len(store.updates) != 1 {
+		t.Error("unchanged form sends an update")
+	}
+	typeKeys(m, "e\r\x15"+"2024-03-04\r")
+	if len(store.updates) != 2 || store.updates[1].DueDate == nil || *store.updates[1].DueDate != "2024-03-04 23:59:59" {
+		t.Errorf("due date update: %+v", store.updates)
+	}
+	typeKeys(m, "e\x1b")
+	if m.mode != modeList {
+		t.Error("Esc does not close the form")
+	}
+
+	typeKeys(m, "x")
+	if task := m.selected(); !task.IsCompleted || task.Title != "Feed plants" || m.message != "task 5 completed" {
+		t.Errorf("complete: %+v %q", task, m.message)
+	}
+	typeKeys(m, "x")
+	if !strings.Contains(m.message, "already completed") {
+		t.Errorf("second complete: %q", m.message)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	store := sampleStore()
+	m := newTestModel(store)
+
+	typeKeys(m, "dn")
+	if len(store.tasks) != 4 || m.message != "deletion cancelled" {
+		t.Errorf("cancelled deletion: %d tasks, %q", len(store.tasks), m.message)
+	}
+
+	// Подтверждение удаляет выбранную задачу, даже если список обновился и курсор сдвинулся
+	typeKeys(m, "d")
+	store.tasks = append(store.tasks, &db.Task{ID: 9, Title: "Earlier", DueDate: "2024-01-01 23:59:59", Status: "todo"})
+	m.refresh()
+	typeKeys(m, "y")
+	if _, err := store.find(2); err == nil {
+		t.Errorf("task 2 is not deleted: %v", titles(store.tasks))
+	}
+	if _, err := store.find(9); err != nil || m.message != "task 2 deleted" {
+		t.Errorf("wrong task deleted: %v, %q", titles(store.tasks), m.message)
+	}
+}
+
+func TestView(t *testing.T) {
+	m := newTestModel(sampleStore())
+	m.width, m.height = 60, 10
+
+	frame := string(m.view("tasks.db", "live", false))
+	lines := strings.Split(frame, "\r\n")
+	if len(lines) != m.height {
+		t.Fatalf("frame has %d lines, want %d:\n%s", len(lines), m.height, frame)
+	}
+	for _, want := range []string{"tasks.db", "4 tasks", "\x1b[31m, 1 overdue", "PRIORITY", "\x1b[31mhigh", "\x1b[32m✓ ", "\x1b[33m• "} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("frame has no %q:\n%q", want, frame)
+		}
+	}
+	// Строка курсора выделена целиком
+	if !strings.Contains(lines[2], "\x1b[31;7m! ") {
+		t.Errorf("selected row: %q", lines[2])
+	}
+
+	plain := string(m.view("tasks.db", "", true))
+	if strings.Contains(plain, "\x1b[3") {
+		t.Errorf("NO_COLOR frame has colors: %q", plain)
+	}
+	if !strings.Contains(plain, "\x1b[7m") {
+		t.Error("NO_COLOR frame has no cursor")
+	}
+
+	// Длинные строки обрезаются по ширине
+	m.width = 20
+	for _, row := range strings.Split(string(m.view("tasks.db", "", true)), "\r\n") {
+		text := row
+		for _, code := range []string{"\x1b[H", "\x1b[K", "\x1b[J", "\x1b[7m", "\x1b[0m"} {
+			text = strings.ReplaceAll(text, code, "")
+		}
+		if n := len([]rune(text)); n > m.width {
+			t.Errorf("row is %d wide: %q", n, text)
+		}
+	}
+
+	m.width = 60
+	typeKeys(m, "d")
+	if frame := string(m.view("tasks.db", "", true)); !strings.Contains(frame, `Delete task 2 "Pay rent"?`) {
+		t.Errorf("no confirmation:\n%s", frame)
+	}
+}
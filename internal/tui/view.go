@@ -0,0 +1,324 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"todo/internal/db"
+)
+
+// Стили SGR.
+const (
+	styleNone    = ""
+	styleBold    = "1"
+	styleDim     = "2"
+	styleReverse = "7"
+	styleRed     = "31"
+	styleGreen   = "32"
+	styleYellow  = "33"
+	styleBlue    = "34"
+	styleCyan    = "36"
+)
+
+// dueSoon - срок, начиная с которого открытая задача отмечается как срочная.
+const dueSoon = 24 * time.Hour
+
+// chromeRows - строки вокруг списка: заголовок, названия колонок, строка ввода и подсказка.
+const chromeRows = 4
+
+// PriorityField - пользовательское поле с приоритетом задачи. Значения high, medium и low
+// (и их синонимы) выделяются цветом.
+const PriorityField = "priority"
+
+var priorityStyles = map[string]string{
+	"critical": styleRed, "urgent": styleRed, "high": styleRed,
+	"medium": styleYellow, "normal": styleYellow,
+	"low": styleBlue,
+}
+
+// priority возвращает приоритет задачи из пользовательского поля и его стиль.
+func priority(task *db.Task) (string, string) {
+	value, ok := task.CustomFields[PriorityField]
+	if !ok || value == nil {
+		return "", styleNone
+	}
+	text := fmt.Sprint(value)
+	return text, priorityStyles[strings.ToLower(text)]
+}
+
+func (m *model) listHeight() int {
+	return max(m.height-chromeRows, 1)
+}
+
+// segment - часть строки экрана в одном стиле.
+type segment struct {
+	text  string
+	style string
+}
+
+// screen собирает кадр целиком, чтобы вывести его одной записью без мерцания.
+type screen struct {
+	buf   bytes.Buffer
+	width int
+	lines int
+	// noColor оставляет только инвертирование: им показаны курсор и выделенная строка.
+	noColor bool
+}
+
+// line выводит строку из сегментов, обрезая ее по ширине экрана. fill - стиль, которым
+// строка дополняется до конца (например, выделение курсора).
+func (s *screen) line(fill string, segments ...segment) {
+	// Перевод строки после последней строки прокрутил бы экран, поэтому он ставится перед строкой
+	if s.lines > 0 {
+		s.buf.WriteString("\r\n")
+	}
+	s.lines++
+	left := s.width
+	for _, seg := range segments {
+		if left <= 0 {
+			break
+		}
+		text := []rune(seg.text)
+		if len(text) > left {
+			text = append(text[:max(left-1, 0)], '…')
+		}
+		left -= len(text)
+		style := seg.style
+		if s.noColor && style != styleReverse {
+			style = styleNone
+		}
+		s.styled(string(text), joinStyles(style, fill))
+	}
+	if left > 0 && fill != styleNone {
+		s.styled(strings.Repeat(" ", left), fill)
+	}
+	// Остаток строки от прошлого кадра стирается
+	s.buf.WriteString("\x1b[K")
+}
+
+func (s *screen) styled(text string, style string) {
+	if style == styleNone || text == "" {
+		s.buf.WriteString(text)
+		return
+	}
+	fmt.Fprintf(&s.buf, "\x1b[%sm%s\x1b[0m", style, text)
+}
+
+func joinStyles(styles ...string) string {
+	var parts []string
+	for _, style := range styles {
+		if style != styleNone {
+			parts = append(parts, style)
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// view рисует кадр: курсор в левый верхний угол, строки поверх прошлого кадра и очистка
+// остатка экрана.
+func (m *model) view(source string, live string, noColor bool) []byte {
+	s := &screen{width: m.width, noColor: noColor}
+	s.buf.WriteString("\x1b[H")
+
+	overdue := 0
+	for _, task := range m.tasks {
+		if task.IsOverdue && !task.IsCompleted {
+			overdue++
+		}
+	}
+	header := []segment{{text: "lwo-go", style: styleBold}, {text: " · " + source}, {text: fmt.Sprintf(" · %d tasks", len(m.tasks))}}
+	if overdue > 0 {
+		header = append(header, segment{text: fmt.Sprintf(", %d overdue", overdue), style: styleRed})
+	}
+	header = append(header, segment{text: " · filter: " + m.filter.String()})
+	if search := m.search.String(); search != "" {
+		header = append(header, segment{text: fmt.Sprintf(" · search: %q", search)})
+	}
+	if live != "" {
+		header = append(header, segment{text: " · " + live, style: styleDim})
+	}
+	s.line(styleNone, header...)
+
+	if m.mode == modeHelp {
+		m.viewHelp(s)
+	} else {
+		m.viewList(s)
+	}
+	m.viewPrompt(s)
+	s.line(styleNone, segment{text: m.hints(), style: styleDim})
+	s.buf.WriteString("\x1b[J")
+	return s.buf.Bytes()
+}
+
+func (m *model) viewList(s *screen) {
+	idWidth, statusWidth, priorityWidth := 2, 6, 0
+	for _, task := range m.visible {
+		idWidth = max(idWidth, len(strconv.Itoa(task.ID)))
+		statusWidth = max(statusWidth, len(task.Status))
+		if text, _ := priority(task); text != "" {
+			priorityWidth = max(priorityWidth, len([]rune(text)), len("PRIORITY"))
+		}
+	}
+
+	columns := fmt.Sprintf("  %*s  %-*s  %-10s  ", idWidth, "ID", statusWidth, "STATUS", "DUE")
+	if priorityWidth > 0 {
+		columns += fmt.Sprintf("%-*s  ", priorityWidth, "PRIORITY")
+	}
+	s.line(styleNone, segment{text: columns + "TITLE", style: styleDim})
+
+	rows := m.listHeight()
+	if len(m.visible) == 0 {
+		text := "no tasks, press a to add one"
+		if len(m.tasks) > 0 {
+			text = "no tasks match the filter"
+		}
+		s.line(styleNone, segment{text: "  " + text, style: styleDim})
+		rows--
+	}
+	now := m.now()
+	end := min(m.offset+rows, len(m.visible))
+	for i := m.offset; i < end; i++ {
+		task := m.visible[i]
+		marker, markerStyle, dueStyle := " ", styleNone, styleNone
+		due, err := time.ParseInLocation("2006-01-02 15:04:05", task.DueDate, time.Local)
+		switch {
+		case task.IsCompleted:
+			marker, markerStyle = "✓", styleGreen
+		case task.IsOverdue || err == nil && due.Before(now):
+			marker, markerStyle, dueStyle = "!", styleRed, styleRed
+		case err == nil && due.Sub(now) < dueSoon:
+			marker, markerStyle, dueStyle = "•", styleYellow, styleYellow
+		}
+
+		titleStyle := styleNone
+		if task.IsCompleted {
+			titleStyle = styleDim
+		}
+		row := []segment{
+			{text: marker + " ", style: markerStyle},
+			{text: fmt.Sprintf("%*d  ", idWidth, task.ID)},
+			{text: fmt.Sprintf("%-*s", statusWidth, task.Status), style: styleCyan},
+			{text: "  "},
+			{text: fmt.Sprintf("%-10s", dueDay(task.DueDate)), style: dueStyle},
+			{text: "  "},
+		}
+		if priorityWidth > 0 {
+			text, style := priority(task)
+			row = append(row, segment{text: fmt.Sprintf("%-*s", priorityWidth, text), style: style}, segment{text: "  "})
+		}
+		row = append(row, segment{text: task.Title, style: titleStyle})
+
+		fill := styleNone
+		if i == m.cursor {
+			fill = styleReverse
+		}
+		s.line(fill, row...)
+		rows--
+	}
+	for ; rows > 0; rows-- {
+		s.line(styleNone)
+	}
+}
+
+var helpLines = []string{
+	"↑/k ↓/j        move, PgUp/PgDn page, g/G or Home/End first/last",
+	"a, n           add a task",
+	"e, Enter       edit the title and due date of the selected task",
+	"x, Space       complete the selected task",
+	"d, Delete      delete the selected task (asks for confirmation)",
+	"/              search in titles and descriptions, Esc clears",
+	"f              switch the filter: all, open, overdue, done",
+	"r              reload tasks",
+	"q, Ctrl-C      quit",
+	"",
+	"! overdue   • due within 24 hours   ✓ completed",
+	"Priority comes from the custom field \"" + PriorityField + "\" (high, medium, low).",
+	"",
+	"Press any key to return.",
+}
+
+func (m *model) viewHelp(s *screen) {
+	rows := m.listHeight() + 1
+	for _, text := range helpLines {
+		if rows == 0 {
+			break
+		}
+		s.line(styleNone, segment{text: "  " + text})
+		rows--
+	}
+	for ; rows > 0; rows-- {
+		s.line(styleNone)
+	}
+}
+
+// viewPrompt рисует строку ввода: форму, поиск, подтверждение или сообщение.
+func (m *model) viewPrompt(s *screen) {
+	switch m.mode {
+	case modeForm:
+		action := "New task"
+		if m.form.task != nil {
+			action = fmt.Sprintf("Edit task %d", m.form.task.ID)
+		}
+		segments := []segment{{text: action + ": ", style: styleBold}}
+		for i := range m.form.fields {
+			segments = append(segments, segment{text: fieldLabels[i] + " "})
+			segments = append(segments, m.form.fields[i].segments(i == m.form.active)...)
+			segments = append(segments, segment{text: "  "})
+		}
+		if m.message != "" && m.failed {
+			segments = append(segments, segment{text: m.message, style: styleRed})
+		}
+		s.line(styleNone, segments...)
+	case modeSearch:
+		s.line(styleNone, append([]segment{{text: "/", style: styleBold}}, m.search.segments(true)...)...)
+	case modeConfirm:
+		task := m.deleting
+		s.line(styleNone, segment{text: fmt.Sprintf("Delete task %d %q? [y/N]", task.ID, task.Title), style: styleYellow})
+	default:
+		style := styleGreen
+		if m.failed {
+			style = styleRed
+		}
+		s.line(styleNone, segment{text: m.message, style: style})
+	}
+}
+
+// segments показывает строку с курсором в виде инвертированного символа.
+func (l *line) segments(active bool) []segment {
+	text := string(l.text)
+	if !active {
+		if text == "" {
+			text = "_"
+		}
+		return []segment{{text: text, style: styleDim}}
+	}
+	at := " "
+	if l.pos < len(l.text) {
+		at = string(l.text[l.pos])
+	}
+	after := ""
+	if l.pos < len(l.text) {
+		after = string(l.text[l.pos+1:])
+	}
+	return []segment{
+		{text: string(l.text[:l.pos]), style: styleBold},
+		{text: at, style: styleReverse},
+		{text: after, style: styleBold},
+	}
+}
+
+func (m *model) hints() string {
+	switch m.mode {
+	case modeForm:
+		return "Tab next field  Enter save  Esc cancel"
+	case modeSearch:
+		return "Enter keep the search  Esc clear"
+	case modeConfirm:
+		return "y delete  any other key cancel"
+	case modeHelp:
+		return "any key return"
+	}
+	return "a add  e edit  x done  d delete  / search  f filter  r reload  ? help  q quit"
+}
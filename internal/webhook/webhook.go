@@ -0,0 +1,257 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"todo/internal/db"
+	"todo/internal/events"
+	"todo/internal/logging"
+	"todo/internal/metrics"
+	"todo/internal/tracing"
+	"todo/pkg/config"
+)
+
+const (
+	timeLayout    = "2006-01-02 15:04:05"
+	deliveryBatch = 50
+
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Dispatcher ставит события в очередь доставки в SQLite и отправляет их подписчикам.
+// Очередь переживает перезапуск: недоставленные события будут отправлены после старта.
+type Dispatcher struct {
+	repo db.WebhookRepo
+	// mu защищает настройки доставки: Configure меняет их на ходу
+	mu           sync.RWMutex
+	client       *http.Client
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	wake         chan struct{}
+	now          func() time.Time
+}
+
+func NewDispatcher(repo db.WebhookRepo) *Dispatcher {
+	return &Dispatcher{
+		repo:         repo,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		maxAttempts:  8,
+		backoff:      10 * time.Second,
+		maxBackoff:   time.Hour,
+		pollInterval: 5 * time.Second,
+		wake:         make(chan struct{}, 1),
+		now:          time.Now,
+	}
+}
+
+// DispatcherFromConfig применяет настройки доставки из секции webhooks.
+func DispatcherFromConfig(repo db.WebhookRepo, cfg config.Webhooks) *Dispatcher {
+	d := NewDispatcher(repo)
+	d.Configure(cfg)
+	return d
+}
+
+// Configure меняет настройки доставки. Можно вызывать во время работы Run: новые значения
+// действуют со следующей попытки, новый период опроса - со следующего тика.
+func (d *Dispatcher) Configure(cfg config.Webhooks) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxAttempts = cfg.MaxAttempts
+	d.backoff = cfg.Backoff
+	d.maxBackoff = cfg.MaxBackoff
+	d.pollInterval = cfg.PollInterval
+	// Таймаут клиента нельзя менять, пока он отправляет запросы, поэтому клиент заменяется
+	d.client = &http.Client{Timeout: cfg.Timeout, Transport: d.client.Transport}
+}
+
+// Sign возвращает подпись HMAC-SHA256 от "timestamp.body" в hex.
+// Получатель должен пересчитать ее с тем же секретом и сравнить с заголовком X-Webhook-Signature.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Enqueue ставит событие в очередь для всех активных подписок с подходящим фильтром.
+func (d *Dispatcher) Enqueue(event events.Event) {
+	webhooks, err := d.repo.GetWebhooks()
+	if err != nil {
+		slog.Error("failed to load webhooks", "event", event.Type, "error", err)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to encode webhook payload", "event", event.Type, "error", err)
+		return
+	}
+
+	now := d.now().Format(timeLayout)
+	queued := false
+	for _, webhook := range webhooks {
+		if !webhook.Active || !webhook.Matches(event.Type) {
+			continue
+		}
+		if err := d.repo.EnqueueDelivery(webhook.ID, event.Type, string(payload), now); err != nil {
+			slog.Error("failed to enqueue webhook delivery", "event", event.Type, "webhook_id", webhook.ID, "error", err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run доставляет очередь до отмены контекста: по таймеру и сразу после новых событий.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.mu.RLock()
+	interval := d.pollInterval
+	d.mu.RUnlock()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		d.mu.RLock()
+		if d.pollInterval != interval {
+			interval = d.pollInterval
+			ticker.Reset(interval)
+		}
+		d.mu.RUnlock()
+
+		start := time.Now()
+		tickCtx, span := tracing.Start(ctx, "job webhooks", tracing.KindInternal)
+		delivered, err := d.DeliverPending(tickCtx)
+		metrics.ObserveJob("webhooks", start, err)
+		span.SetAttributes("delivered", delivered)
+		span.SetError(err)
+		span.End()
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to deliver webhooks", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			logging.FromContext(ctx).Info("stopping webhook dispatcher")
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DeliverPending отправляет доставки, время попытки которых наступило, и возвращает число успешных.
+func (d *Dispatcher) DeliverPending(ctx context.Context) (int, error) {
+	deliveries, err := d.repo.GetPendingDeliveries(d.now().Format(timeLayout), deliveryBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+
+		webhook, err := d.repo.GetWebhookById(delivery.WebhookID)
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to load webhook", "webhook_id", delivery.WebhookID, "error", err)
+			continue
+		}
+
+		sendCtx, span := tracing.Start(ctx, "webhook deliver", tracing.KindClient,
+			"webhook.id", webhook.ID, "delivery.id", delivery.ID, "event", delivery.Event)
+		status, err := d.send(sendCtx, webhook, delivery)
+		span.SetAttributes("http.response.status_code", status)
+		span.SetError(err)
+		span.End()
+		if err == nil {
+			delivered++
+			err = d.repo.MarkDeliveryDelivered(delivery.ID, status, d.now().Format(timeLayout))
+		} else {
+			err = d.fail(delivery, status, err)
+		}
+		if err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, webhook *db.Webhook, delivery *db.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+	tracing.Inject(ctx, req.Header)
+
+	d.mu.RLock()
+	client := d.client
+	d.mu.RUnlock()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// fail планирует повтор с экспоненциальной задержкой или переводит доставку в dead.
+func (d *Dispatcher) fail(delivery *db.WebhookDelivery, status int, sendErr error) error {
+	attempts := delivery.Attempts + 1
+	d.mu.RLock()
+	maxAttempts, backoff := d.maxAttempts, d.backoffFor(attempts)
+	d.mu.RUnlock()
+	if attempts >= maxAttempts {
+		slog.Warn("webhook delivery is dead", "delivery_id", delivery.ID, "attempts", attempts, "error", sendErr)
+		return d.repo.MarkDeliveryFailed(delivery.ID, status, sendErr.Error(), "", true)
+	}
+
+	next := d.now().Add(backoff).Format(timeLayout)
+	return d.repo.MarkDeliveryFailed(delivery.ID, status, sendErr.Error(), next, false)
+}
+
+func (d *Dispatcher) backoffFor(attempts int) time.Duration {
+	backoff := d.backoff
+	for i := 1; i < attempts && backoff < d.maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, d.maxBackoff)
+}
@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"todo/internal/db"
+	"todo/internal/events"
+	"todo/pkg/sqlite3"
+)
+
+func newTestRepository(t *testing.T) *db.TaskRepository {
+	conn, err := sqlite3.NewConnector(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repository, err := db.NewTaskRepository(conn, db.DefaultWorkflow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repository
+}
+
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestDeliverSignedEvent(t *testing.T) {
+	repository := newTestRepository(t)
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	hook, err := repository.CreateWebhook(&db.Webhook{URL: server.URL, Events: []string{events.TaskCreated}, Secret: "s3cret", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := NewDispatcher(repository)
+	task := &db.Task{ID: 7, Title: "Task 7"}
+	dispatcher.Enqueue(events.New(events.TaskCreated, task))
+	dispatcher.Enqueue(events.New(events.TaskDeleted, task))
+
+	delivered, err := dispatcher.DeliverPending(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 1 || len(rc.requests) != 1 {
+		t.Fatalf("expected exactly one delivery of the subscribed event, got %d (%d requests)", delivered, len(rc.requests))
+	}
+
+	req, body := rc.requests[0], rc.bodies[0]
+	if got, want := req.Header.Get(SignatureHeader), Sign("s3cret", req.Header.Get(TimestampHeader), body); got != want {
+		t.Errorf("invalid signature: got %s want %s", got, want)
+	}
+	if req.Header.Get(EventHeader) != events.TaskCreated {
+		t.Errorf("unexpected event header: %s", req.Header.Get(EventHeader))
+	}
+
+	var event events.Event
+	if err := json.Unmarshal(body, &event); err != nil || event.TaskID != 7 {
+		t.Errorf("unexpected payload %s: %v", body, err)
+	}
+
+	deliveries, err := repository.GetDeliveries(hook.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].State != db.DeliveryDelivered || deliveries[0].LastStatus != http.StatusOK {
+		t.Errorf("unexpected deliveries: %+v", deliveries)
+	}
+}
+
+func TestRetryWithBackoffAndDeadLetter(t *testing.T) {
+	repository := newTestRepository(t)
+	rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	hook, err := repository.CreateWebhook(&db.Webhook{URL: server.URL, Events: []string{"*"}, Secret: "s", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.Local)
+	dispatcher := NewDispatcher(repository)
+	dispatcher.now = func() time.Time { return now }
+	dispatcher.maxAttempts = 3
+	dispatcher.backoff = time.Minute
+
+	dispatcher.Enqueue(events.New(events.TaskOverdue, &db.Task{ID: 1}))
+
+	// Первая попытка неудачна, следующая запланирована через backoff
+	if _, err := dispatcher.DeliverPending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dispatcher.DeliverPending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.requests) != 1 {
+		t.Fatalf("retry happened before backoff elapsed: %d requests", len(rc.requests))
+	}
+
+	deliveries, _ := repository.GetDeliveries(hook.ID, 10)
+	if want := now.Add(time.Minute).Format(timeLayout); deliveries[0].NextAttemptAt != want {
+		t.Errorf("unexpected next attempt: got %s want %s", deliveries[0].NextAttemptAt, want)
+	}
+
+	now = now.Add(time.Minute)
+	dispatcher.DeliverPending(context.Background())
+	deliveries, _ = repository.GetDeliveries(hook.ID, 10)
+	if want := now.Add(2 * time.Minute).Format(timeLayout); deliveries[0].NextAttemptAt != want {
+		t.Errorf("backoff was not doubled: got %s want %s", deliveries[0].NextAttemptAt, want)
+	}
+
+	now = now.Add(2 * time.Minute)
+	dispatcher.DeliverPending(context.Background())
+	deliveries, _ = repository.GetDeliveries(hook.ID, 10)
+	if deliveries[0].State != db.DeliveryDead || deliveries[0].Attempts != 3 || deliveries[0].LastStatus != http.StatusServiceUnavailable {
+		t.Errorf("expected dead delivery after 3 attempts, got %+v", deliveries[0])
+	}
+
+	now = now.Add(time.Hour)
+	dispatcher.DeliverPending(context.Background())
+	if len(rc.requests) != 3 {
+		t.Errorf("dead delivery was retried: %d requests", len(rc.requests))
+	}
+}
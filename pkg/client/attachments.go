@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+)
+
+func (c *Client) ListAttachments(ctx context.Context, taskID int) ([]*Attachment, error) {
+	var attachments []*Attachment
+	if err := c.call(ctx, http.MethodGet, path("tasks", itoa(taskID), "attachments"), nil, &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// UploadAttachment загружает содержимое r как файл filename. Тело передается потоком,
+// поэтому запрос не повторяется.
+func (c *Client) UploadAttachment(ctx context.Context, taskID int, filename string, r io.Reader) (*Attachment, error) {
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		part, err := form.CreateFormFile("file", filename)
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	req, err := c.newRequest(ctx, http.MethodPost, path("tasks", itoa(taskID), "attachments"), body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := c.do(req)
+	// Если сервер ответил раньше, чем прочитал файл, горутина не должна ждать
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var attachment Attachment
+	if err := decodeResponse(resp, &attachment); err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// AttachmentContent - содержимое вложения. Body нужно закрыть.
+type AttachmentContent struct {
+	Body        io.ReadCloser
+	Filename    string
+	ContentType string
+	// Size - длина Body, -1 - неизвестна.
+	Size int64
+}
+
+// DownloadAttachment открывает содержимое вложения начиная с байта offset
+// (0 - целиком). Докачка идет через заголовок Range.
+func (c *Client) DownloadAttachment(ctx context.Context, taskID int, attachmentID int, offset int64) (*AttachmentContent, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, path("tasks", itoa(taskID), "attachments", itoa(attachmentID)), nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("client: server ignored range request, status %d", resp.StatusCode)
+	}
+
+	content := &AttachmentContent{Body: resp.Body, ContentType: resp.Header.Get("Content-Type"), Size: resp.ContentLength}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		content.Filename = params["filename"]
+	}
+	return content, nil
+}
+
+func (c *Client) DeleteAttachment(ctx context.Context, taskID int, attachmentID int) error {
+	return c.call(ctx, http.MethodDelete, path("tasks", itoa(taskID), "attachments", itoa(attachmentID)), nil, nil)
+}
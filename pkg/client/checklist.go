@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+func (c *Client) GetChecklist(ctx context.Context, taskID int) (*Checklist, error) {
+	return c.checklist(ctx, http.MethodGet, path("tasks", itoa(taskID), "checklist"), nil)
+}
+
+func (c *Client) AddChecklistItem(ctx context.Context, taskID int, input *ChecklistItemInput) (*Checklist, error) {
+	return c.checklist(ctx, http.MethodPost, path("tasks", itoa(taskID), "checklist"), input)
+}
+
+func (c *Client) UpdateChecklistItem(ctx context.Context, taskID int, itemID int, input *ChecklistItemInput) (*Checklist, error) {
+	return c.checklist(ctx, http.MethodPatch, path("tasks", itoa(taskID), "checklist", itoa(itemID)), input)
+}
+
+func (c *Client) DeleteChecklistItem(ctx context.Context, taskID int, itemID int) (*Checklist, error) {
+	return c.checklist(ctx, http.MethodDelete, path("tasks", itoa(taskID), "checklist", itoa(itemID)), nil)
+}
+
+// ReorderChecklist задает порядок пунктов: ids - все пункты чек-листа в новом порядке.
+func (c *Client) ReorderChecklist(ctx context.Context, taskID int, ids []int) (*Checklist, error) {
+	return c.checklist(ctx, http.MethodPut, path("tasks", itoa(taskID), "checklist", "order"), map[string][]int{"ids": ids})
+}
+
+func (c *Client) checklist(ctx context.Context, method string, ref string, in any) (*Checklist, error) {
+	var checklist Checklist
+	if err := c.call(ctx, method, ref, in, &checklist); err != nil {
+		return nil, err
+	}
+	return &checklist, nil
+}
@@ -0,0 +1,326 @@
+// Package client - клиент HTTP API задач lwo-go. Каждый вызов принимает контекст,
+// идемпотентные запросы повторяются с нарастающей паузой, ошибки сервера разбираются
+// в *Error (в том числе из application/problem+json).
+//
+//	c, err := client.New("https://tasks.example.com", client.WithAuth(client.BearerToken(token)))
+//	task, err := c.CreateTask(ctx, &client.TaskInput{Title: client.String("Buy milk"), DueDate: client.String("2030-01-02")})
+//
+// Списки с пагинацией возвращают итератор Seq2; в Go 1.23 и новее его обходят через range:
+//
+//	for comment, err := range c.Comments(ctx, taskID, 50) { ... }
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WorkspaceHeader - заголовок, которым запрос выбирает рабочее пространство.
+const WorkspaceHeader = "X-Workspace"
+
+const (
+	defaultUserAgent = "lwo-go-client"
+	acceptHeader     = "application/json, application/problem+json"
+)
+
+// Authenticator добавляет к запросу данные для аутентификации.
+type Authenticator interface {
+	Authenticate(r *http.Request) error
+}
+
+// AuthenticatorFunc позволяет использовать функцию как Authenticator.
+type AuthenticatorFunc func(r *http.Request) error
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) error {
+	return f(r)
+}
+
+// BearerToken передает токен сессии или JWT в заголовке Authorization.
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) error {
+		r.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// APIKey передает ключ API в заголовке X-API-Key.
+func APIKey(key string) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) error {
+		r.Header.Set("X-API-Key", key)
+		return nil
+	})
+}
+
+// RetryPolicy - повторы идемпотентных запросов (GET, HEAD, PUT, DELETE) при сетевых ошибках,
+// ответах 429 и 502-504. Пауза растет вдвое от MinBackoff до MaxBackoff со случайным
+// разбросом; Retry-After сервера имеет приоритет.
+type RetryPolicy struct {
+	// MaxAttempts - число попыток вместе с первой; 1 отключает повторы.
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+// DefaultRetryPolicy - повторы по умолчанию.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, MinBackoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// backoff возвращает паузу перед попыткой attempt (начиная с 1 для первого повтора).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.MinBackoff << (attempt - 1)
+	if wait <= 0 || wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	// Разброс от половины до полной паузы, чтобы клиенты не повторяли запросы одновременно
+	return wait/2 + rand.N(wait/2+1)
+}
+
+// Client - клиент API. Безопасен для одновременного использования.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	auth       Authenticator
+	workspace  string
+	userAgent  string
+	retry      RetryPolicy
+}
+
+// Option настраивает Client.
+type Option func(*Client)
+
+// WithHTTPClient задает HTTP-клиент, например с таймаутом или своим транспортом.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithAuth задает способ аутентификации.
+func WithAuth(auth Authenticator) Option {
+	return func(c *Client) { c.auth = auth }
+}
+
+// WithWorkspace выбирает рабочее пространство для всех запросов.
+func WithWorkspace(slug string) Option {
+	return func(c *Client) { c.workspace = slug }
+}
+
+// WithRetry задает политику повторов.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// WithUserAgent задает заголовок User-Agent.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New создает клиент для сервера по адресу baseURL, например "https://tasks.example.com".
+func New(baseURL string, options ...Option) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("client: base URL %q must be an absolute http or https URL", baseURL)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+
+	c := &Client{baseURL: parsed, httpClient: http.DefaultClient, userAgent: defaultUserAgent, retry: DefaultRetryPolicy}
+	for _, option := range options {
+		option(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// WithWorkspace возвращает копию клиента, которая работает в пространстве slug.
+func (c *Client) WithWorkspace(slug string) *Client {
+	copied := *c
+	copied.workspace = slug
+	return &copied
+}
+
+// Login получает токен сессии по имени и паролю. Токен передается в WithAuth(BearerToken(token)).
+func (c *Client) Login(ctx context.Context, username string, password string) (string, error) {
+	var response struct {
+		Token string `json:"token"`
+	}
+	err := c.call(ctx, http.MethodPost, "/auth/login", map[string]string{"username": username, "password": password}, &response)
+	return response.Token, err
+}
+
+// call выполняет запрос с телом in в JSON и разбирает ответ в out. in и out могут быть nil.
+func (c *Client) call(ctx context.Context, method string, ref string, in any, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	resp, err := c.send(ctx, method, ref, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, out)
+}
+
+// decodeResponse разбирает успешный ответ в out и дочитывает тело, чтобы соединение
+// вернулось в пул.
+func decodeResponse(resp *http.Response, out any) error {
+	defer io.Copy(io.Discard, resp.Body)
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decode %s response: %w", resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
+// send выполняет запрос, повторяя идемпотентные, и возвращает успешный ответ.
+// Ответ с ошибкой закрывается и возвращается как *Error.
+func (c *Client) send(ctx context.Context, method string, ref string, body []byte) (*http.Response, error) {
+	attempts := 1
+	if idempotent(method) {
+		attempts = c.retry.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := c.newRequest(ctx, method, ref, reader)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := c.do(req)
+		if attempt >= attempts || !retryable(ctx, err) {
+			return resp, err
+		}
+
+		wait := c.retry.backoff(attempt)
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// newRequest создает запрос к пути ref (вместе со строкой запроса) относительно адреса сервера.
+func (c *Client) newRequest(ctx context.Context, method string, ref string, body io.Reader) (*http.Request, error) {
+	target, err := c.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", acceptHeader)
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.workspace != "" {
+		req.Header.Set(WorkspaceHeader, c.workspace)
+	}
+	if c.auth != nil {
+		if err := c.auth.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// resolve дописывает ref к адресу сервера, сохраняя его путь (сервер может быть за префиксом).
+func (c *Client) resolve(ref string) (string, error) {
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	target := *c.baseURL
+	target.Path = c.baseURL.Path + parsed.Path
+	target.RawPath = ""
+	target.RawQuery = parsed.RawQuery
+	return target.String(), nil
+}
+
+// do отправляет запрос один раз. Ответ с кодом 400 и выше превращается в *Error.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, parseError(resp)
+	}
+	return resp, nil
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// retryable сообщает, стоит ли повторить запрос после ошибки err.
+func retryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	// Остальные ошибки - сетевые: соединение не установлено или оборвано
+	return true
+}
+
+// itoa и path собирают пути API из идентификаторов.
+func itoa(id int) string {
+	return strconv.Itoa(id)
+}
+
+func path(segments ...string) string {
+	var builder strings.Builder
+	for _, segment := range segments {
+		builder.WriteByte('/')
+		builder.WriteString(url.PathEscape(segment))
+	}
+	return builder.String()
+}
+
+// String, Int и Bool возвращают указатель на значение для необязательных полей ввода.
+func String(value string) *string { return &value }
+
+func Int(value int) *int { return &value }
+
+func Bool(value bool) *bool { return &value }
@@ -0,0 +1,412 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"todo/internal/app"
+	"todo/pkg/config"
+)
+
+// newServer запускает сервер с настоящими обработчиками. wrap может подменить ответы.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+	t.Setenv("FILEPATH", filepath.Join(t.TempDir(), "tasks.db"))
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("AUTH_ADMIN_USERNAME", "admin")
+	t.Setenv("AUTH_ADMIN_PASSWORD", "password-admin")
+	t.Setenv("AUTH_ALLOW_SIGNUP", "true")
+	t.Setenv("NOTIFIERS", "")
+	t.Setenv("ATTACHMENTS_DIR", filepath.Join(t.TempDir(), "attachments"))
+
+	cfg, err := config.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := app.NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := a.Routes()
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// login возвращает клиент, вошедший под admin.
+func login(t *testing.T, server *httptest.Server, options ...Option) *Client {
+	t.Helper()
+	anonymous, err := New(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := anonymous.Login(context.Background(), "admin", "password-admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(server.URL, append([]Option{WithAuth(BearerToken(token))}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestTasks(t *testing.T) {
+	server := newServer(t, nil)
+	c := login(t, server)
+	ctx := context.Background()
+
+	task, err := c.CreateTask(ctx, &TaskInput{Title: String("Buy milk"), DueDate: String("2099-01-02"), EstimateMinutes: Int(30)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.ID == 0 || task.Status != "todo" || task.DueDate != "2099-01-02 23:59:59" {
+		t.Errorf("created = %+v", task)
+	}
+	task, err = c.UpdateTask(ctx, task.ID, &TaskInput{Title: String("Buy oat milk"), DueDate: String("2099-01-03 12:00:00")})
+	if err != nil || task.Title != "Buy oat milk" {
+		t.Fatalf("updated = %+v, %v", task, err)
+	}
+	if task, err = c.SetTaskStatus(ctx, task.ID, "in_progress"); err != nil || task.Status != "in_progress" {
+		t.Fatalf("status = %+v, %v", task, err)
+	}
+	second, err := c.CreateTask(ctx, &TaskInput{Title: String("Second"), DueDate: String("2099-01-04")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, err = c.CompleteTask(ctx, second.ID); err != nil || !second.IsCompleted {
+		t.Fatalf("completed = %+v, %v", second, err)
+	}
+
+	tasks, err := c.ListTasks(ctx, nil)
+	if err != nil || len(tasks) != 2 {
+		t.Fatalf("tasks = %v, %v", tasks, err)
+	}
+	mine, err := c.MyTasks(ctx, RelationCreated)
+	if err != nil || len(mine) != 2 {
+		t.Errorf("my tasks = %v, %v", mine, err)
+	}
+
+	// Ошибки разбираются из problem+json
+	_, err = c.CreateTask(ctx, &TaskInput{Title: String("Broken"), DueDate: String("soon")})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrBadRequest) || apiErr.Type != "about:blank" || apiErr.Instance != "/tasks" || apiErr.Detail == "" {
+		t.Errorf("validation error = %#v", err)
+	}
+	if err := c.DeleteTask(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTask(ctx, second.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted task: %v", err)
+	}
+	if err := c.DeleteTask(ctx, second.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted twice: %v", err)
+	}
+
+	stranger, err := New(server.URL, WithAuth(BearerToken("wrong")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stranger.ListTasks(ctx, nil); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("wrong token: %v", err)
+	}
+	if _, err := c.WithWorkspace("missing").ListTasks(ctx, nil); err == nil {
+		t.Error("unknown workspace is accepted")
+	}
+}
+
+func TestComments(t *testing.T) {
+	c := login(t, newServer(t, nil))
+	ctx := context.Background()
+
+	task, err := c.CreateTask(ctx, &TaskInput{Title: String("Discuss"), DueDate: String("2099-01-02")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"one", "two", "three", "four", "five"} {
+		if _, err := c.CreateComment(ctx, task.ID, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Итератор переходит по ссылкам Link и останавливается по требованию
+	var bodies []string
+	c.Comments(ctx, task.ID, 2)(func(comment *Comment, err error) bool {
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, comment.Body)
+		return len(bodies) < 4
+	})
+	if strings.Join(bodies, " ") != "one two three four" {
+		t.Errorf("comments = %v", bodies)
+	}
+	all, err := Collect(c.Comments(ctx, task.ID, 2))
+	if err != nil || len(all) != 5 {
+		t.Fatalf("all comments = %v, %v", all, err)
+	}
+
+	updated, err := c.UpdateComment(ctx, task.ID, all[0].ID, "**one**")
+	if err != nil || !strings.Contains(updated.BodyHTML, "<strong>one</strong>") {
+		t.Errorf("updated = %+v, %v", updated, err)
+	}
+	history, err := c.CommentHistory(ctx, task.ID, all[0].ID)
+	if err != nil || len(history) != 1 || history[0].Body != "one" {
+		t.Errorf("history = %v, %v", history, err)
+	}
+	if err := c.DeleteComment(ctx, task.ID, all[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	_, latest, err := c.GetTaskWithComments(ctx, task.ID, 2)
+	if err != nil || len(latest) != 2 || latest[1].Body != "five" {
+		t.Errorf("latest comments = %v, %v", latest, err)
+	}
+	if deleted, err := c.GetComment(ctx, task.ID, all[1].ID); err != nil || !deleted.Deleted {
+		t.Errorf("deleted comment = %+v, %v", deleted, err)
+	}
+	if _, err := Collect(c.WithWorkspace("missing").Comments(ctx, task.ID, 0)); err == nil {
+		t.Error("iterator does not report errors")
+	}
+}
+
+func TestSubresources(t *testing.T) {
+	c := login(t, newServer(t, nil))
+	ctx := context.Background()
+
+	task, err := c.CreateTask(ctx, &TaskInput{Title: String("Release"), DueDate: String("2099-01-02")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checklist, err := c.AddChecklistItem(ctx, task.ID, &ChecklistItemInput{Text: String("build")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checklist, err = c.AddChecklistItem(ctx, task.ID, &ChecklistItemInput{Text: String("tag")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := checklist.Items[0].ID, checklist.Items[1].ID
+	if checklist, err = c.ReorderChecklist(ctx, task.ID, []int{second, first}); err != nil || checklist.Items[0].ID != second {
+		t.Fatalf("reordered = %+v, %v", checklist, err)
+	}
+	if checklist, err = c.UpdateChecklistItem(ctx, task.ID, first, &ChecklistItemInput{Text: String("build"), Done: Bool(true)}); err != nil || checklist.Progress.Done != 1 {
+		t.Fatalf("checked = %+v, %v", checklist, err)
+	}
+	if checklist, err = c.DeleteChecklistItem(ctx, task.ID, second); err != nil || checklist.Progress.Total != 1 {
+		t.Fatalf("deleted item = %+v, %v", checklist, err)
+	}
+
+	reminder, err := c.CreateReminder(ctx, task.ID, &ReminderInput{Before: String("2h")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reminders, err := c.ListReminders(ctx, task.ID); err != nil || len(reminders) != 1 {
+		t.Errorf("reminders = %v, %v", reminders, err)
+	}
+	if err := c.DeleteReminder(ctx, task.ID, reminder.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.ShareTask(ctx, task.ID, &ShareInput{Username: "nobody", Permission: "view"}); err == nil {
+		t.Error("share with an unknown user")
+	}
+	if shares, err := c.ListShares(ctx, task.ID); err != nil || len(shares) != 0 {
+		t.Errorf("shares = %v, %v", shares, err)
+	}
+
+	if timer, err := c.RunningTimer(ctx); err != nil || timer != nil {
+		t.Errorf("timer before start = %+v, %v", timer, err)
+	}
+	if _, err := c.StartTimer(ctx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if timer, err := c.RunningTimer(ctx); err != nil || timer == nil || timer.TaskID != task.ID {
+		t.Errorf("running timer = %+v, %v", timer, err)
+	}
+	if _, err := c.StopTimer(ctx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	worklog, err := c.CreateWorklog(ctx, task.ID, &WorklogInput{Minutes: Int(45), Note: String("review")})
+	if err != nil || worklog.Minutes != 45 {
+		t.Fatalf("worklog = %+v, %v", worklog, err)
+	}
+	if worklogs, err := c.ListWorklogs(ctx, task.ID); err != nil || len(worklogs) != 2 {
+		t.Errorf("worklogs = %v, %v", worklogs, err)
+	}
+	if err := c.DeleteWorklog(ctx, task.ID, worklog.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	attachment, err := c.UploadAttachment(ctx, task.ID, "notes.txt", strings.NewReader("release notes"))
+	if err != nil || attachment.Size != int64(len("release notes")) {
+		t.Fatalf("attachment = %+v, %v", attachment, err)
+	}
+	content, err := c.DownloadAttachment(ctx, task.ID, attachment.ID, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(content.Body)
+	content.Body.Close()
+	if string(data) != "notes" || content.Filename != "notes.txt" {
+		t.Errorf("downloaded %q as %q", data, content.Filename)
+	}
+	if attachments, err := c.ListAttachments(ctx, task.ID); err != nil || len(attachments) != 1 {
+		t.Errorf("attachments = %v, %v", attachments, err)
+	}
+	if err := c.DeleteAttachment(ctx, task.ID, attachment.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	c := login(t, newServer(t, nil))
+	ctx := context.Background()
+
+	report, err := c.Import(ctx, strings.NewReader("external_id,title,due_date\nX-1,Imported,2099-01-02\n"), &ImportOptions{Format: FormatCSV})
+	if err != nil || !report.Committed || report.Created != 1 || report.Rows[0].TaskID == 0 {
+		t.Fatalf("import = %+v, %v", report, err)
+	}
+	task, err := c.GetTask(ctx, report.Rows[0].TaskID)
+	if err != nil || task.ExternalID != "X-1" {
+		t.Fatalf("imported task = %+v, %v", task, err)
+	}
+
+	// При ошибке в записи возвращаются и отчет, и ошибка
+	report, err = c.Import(ctx, strings.NewReader(`{"external_id":"X-1","title":"Renamed"}`+"\n"+`{"due_date":"2099-01-02"}`), &ImportOptions{Format: FormatNDJSON})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || report == nil || report.Failed != 1 || report.Rows[0].Action != "updated" {
+		t.Fatalf("failed import = %+v, %v", report, err)
+	}
+	if report, err = c.Import(ctx, strings.NewReader(`[{"external_id":"X-1","title":"Renamed"}]`), &ImportOptions{DryRun: true}); err != nil || report.Committed || report.Updated != 1 {
+		t.Fatalf("dry run = %+v, %v", report, err)
+	}
+
+	body, err := c.Export(ctx, &ExportOptions{Format: FormatCSV, Columns: []string{"Key=external_id", "Title=title"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if data, err := io.ReadAll(body); err != nil || string(data) != "Key,Title\r\nX-1,Imported\r\n" {
+		t.Errorf("export = %q, %v", data, err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	// Первые два запроса к списку задач получают 503
+	var failures atomic.Int32
+	server := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/tasks" && failures.Add(1) <= 2 {
+				w.Header().Set("Retry-After", "0")
+				http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	retry := RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	c := login(t, server, WithRetry(retry))
+	ctx := context.Background()
+
+	if _, err := c.ListTasks(ctx, nil); err != nil {
+		t.Fatalf("list is not retried: %v", err)
+	}
+	if n := failures.Load(); n != 3 {
+		t.Errorf("attempts = %d, want 3", n)
+	}
+
+	// Создание задачи не идемпотентно и не повторяется
+	failures.Store(0)
+	_, err := c.CreateTask(ctx, &TaskInput{Title: String("Once"), DueDate: String("2099-01-02")})
+	if !errors.Is(err, ErrServer) || failures.Load() != 1 {
+		t.Errorf("create: %v after %d attempts", err, failures.Load())
+	}
+
+	// Отмена контекста прерывает паузу между попытками
+	failures.Store(-100)
+	slow := login(t, server, WithRetry(RetryPolicy{MaxAttempts: 5, MinBackoff: time.Hour, MaxBackoff: time.Hour}))
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	failures.Store(0)
+	if _, err := slow.ListTasks(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("canceled retry: %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	server := newServer(t, nil)
+	c := login(t, server)
+	ctx := context.Background()
+
+	subscription, err := c.Subscribe(ctx, &SubscribeOptions{Types: []string{EventTaskCreated, EventTaskDeleted}, RetryDelay: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Close()
+
+	next := func() *Event {
+		t.Helper()
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				t.Fatalf("subscription ended: %v", subscription.Err())
+			}
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+		return nil
+	}
+
+	task, err := c.CreateTask(ctx, &TaskInput{Title: String("Watched"), DueDate: String("2099-01-02")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := next()
+	if event.Type != EventTaskCreated || event.TaskID != task.ID || event.Task.Title != "Watched" || event.ID == "" {
+		t.Fatalf("event = %+v", event)
+	}
+
+	// После обрыва подписка переподключается и получает пропущенные события
+	server.CloseClientConnections()
+	if err := c.DeleteTask(ctx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(); event.Type != EventTaskDeleted || event.TaskID != task.ID {
+		t.Errorf("event after reconnect = %+v", event)
+	}
+
+	subscription.Close()
+	if _, ok := <-subscription.Events(); ok || subscription.Err() != nil {
+		t.Errorf("closed subscription: %v", subscription.Err())
+	}
+
+	stranger, _ := New(server.URL, WithAuth(BearerToken("wrong")))
+	if _, err := stranger.Subscribe(ctx, nil); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("wrong token: %v", err)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	for header, want := range map[string]string{
+		`</tasks/1/comments?after=2&limit=2>; rel="next"`: "/tasks/1/comments?after=2&limit=2",
+		`</a>; rel="prev", </b>; rel=next`:                "/b",
+		`</a>; rel="prev"`:                                "",
+		`broken; rel="next"`:                              "",
+	} {
+		if got := nextLink([]string{header}); got != want {
+			t.Errorf("nextLink(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
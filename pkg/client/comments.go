@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Comments обходит комментарии задачи от старых к новым, запрашивая их страницами по limit
+// (0 - размер страницы сервера по умолчанию).
+func (c *Client) Comments(ctx context.Context, taskID int, limit int) Seq2[*Comment, error] {
+	ref := path("tasks", itoa(taskID), "comments")
+	if limit > 0 {
+		ref += "?" + url.Values{"limit": {strconv.Itoa(limit)}}.Encode()
+	}
+	return paginate[*Comment](ctx, c, ref)
+}
+
+func (c *Client) GetComment(ctx context.Context, taskID int, commentID int) (*Comment, error) {
+	var comment Comment
+	if err := c.call(ctx, http.MethodGet, path("tasks", itoa(taskID), "comments", itoa(commentID)), nil, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// CreateComment добавляет комментарий. body - текст в Markdown, @username упоминает пользователя.
+func (c *Client) CreateComment(ctx context.Context, taskID int, body string) (*Comment, error) {
+	var comment Comment
+	input := map[string]string{"body": body}
+	if err := c.call(ctx, http.MethodPost, path("tasks", itoa(taskID), "comments"), input, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (c *Client) UpdateComment(ctx context.Context, taskID int, commentID int, body string) (*Comment, error) {
+	var comment Comment
+	input := map[string]string{"body": body}
+	if err := c.call(ctx, http.MethodPut, path("tasks", itoa(taskID), "comments", itoa(commentID)), input, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (c *Client) DeleteComment(ctx context.Context, taskID int, commentID int) error {
+	return c.call(ctx, http.MethodDelete, path("tasks", itoa(taskID), "comments", itoa(commentID)), nil, nil)
+}
+
+// CommentHistory возвращает прежние версии комментария.
+func (c *Client) CommentHistory(ctx context.Context, taskID int, commentID int) ([]*CommentRevision, error) {
+	var revisions []*CommentRevision
+	if err := c.call(ctx, http.MethodGet, path("tasks", itoa(taskID), "comments", itoa(commentID), "history"), nil, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
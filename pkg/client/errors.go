@@ -0,0 +1,115 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ошибки по коду ответа. Проверяются через errors.Is:
+//
+//	if errors.Is(err, client.ErrNotFound) { ... }
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrTooLarge     = errors.New("request entity too large")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+)
+
+// maxErrorBody - сколько байт тела ошибки читается для описания.
+const maxErrorBody = 64 << 10
+
+// Error - ответ сервера с кодом 400 и выше. Поля Type, Title, Detail и Instance
+// соответствуют application/problem+json (RFC 9457); у текстового ответа Detail - его текст.
+type Error struct {
+	StatusCode int
+	Type       string
+	Title      string
+	Detail     string
+	Instance   string
+	// RetryAfter - пауза из заголовка Retry-After, 0 - заголовка нет.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	title := e.Title
+	if title == "" {
+		title = http.StatusText(e.StatusCode)
+	}
+	if e.Detail == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, title)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, title, e.Detail)
+}
+
+// Is сопоставляет ошибку с ErrNotFound и другими ошибками по коду ответа.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrTooLarge:
+		return e.StatusCode == http.StatusRequestEntityTooLarge
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// parseError читает ответ с ошибкой: problem+json, если сервер его прислал, иначе текст.
+func parseError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil {
+		return apiErr
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" {
+		var problem struct {
+			Type     string `json:"type"`
+			Title    string `json:"title"`
+			Detail   string `json:"detail"`
+			Instance string `json:"instance"`
+		}
+		if json.Unmarshal(body, &problem) == nil {
+			apiErr.Type, apiErr.Title, apiErr.Detail, apiErr.Instance = problem.Type, problem.Title, problem.Detail, problem.Instance
+			return apiErr
+		}
+	}
+	apiErr.Detail = strings.TrimSpace(string(body))
+	return apiErr
+}
+
+// retryAfter разбирает Retry-After в секундах или в виде даты HTTP.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
@@ -0,0 +1,245 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRetryDelay - пауза перед переподключением, пока сервер не прислал свою в поле retry.
+const defaultRetryDelay = 3 * time.Second
+
+// SubscribeOptions - фильтр и начальная позиция потока событий.
+type SubscribeOptions struct {
+	// Types - типы событий (EventTaskCreated и другие), пусто - все.
+	Types []string
+	// TaskIDs - задачи, о которых нужны события, пусто - все доступные.
+	TaskIDs []int
+	// ProjectIDs - проекты, о задачах которых нужны события, пусто - все.
+	ProjectIDs []int
+	// LastEventID продолжает поток после события с этим ID.
+	LastEventID string
+	// RetryDelay - пауза перед переподключением; 0 - как просит сервер.
+	RetryDelay time.Duration
+}
+
+func (o *SubscribeOptions) query() string {
+	query := url.Values{}
+	if len(o.Types) > 0 {
+		query.Set("type", strings.Join(o.Types, ","))
+	}
+	if len(o.TaskIDs) > 0 {
+		query.Set("task_id", joinIDs(o.TaskIDs))
+	}
+	if len(o.ProjectIDs) > 0 {
+		query.Set("project_id", joinIDs(o.ProjectIDs))
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Subscription - подписка на поток событий /events. При обрыве соединения подписка
+// переподключается и продолжает поток с последнего полученного события. Если сервер
+// не сохранил пропущенные события, приходит событие EventReset.
+type Subscription struct {
+	events chan *Event
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	lastID string
+	err    error
+}
+
+// Subscribe подключается к потоку событий. Ошибка подключения (например, неверный токен)
+// возвращается сразу. HTTP-клиент не должен ограничивать время ответа (http.Client.Timeout),
+// иначе поток будет обрываться. opts может быть nil.
+func (c *Client) Subscribe(ctx context.Context, opts *SubscribeOptions) (*Subscription, error) {
+	if opts == nil {
+		opts = &SubscribeOptions{}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription{events: make(chan *Event), cancel: cancel, done: make(chan struct{}), lastID: opts.LastEventID}
+
+	resp, err := c.connect(ctx, opts, s.lastID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	go s.run(ctx, c, opts, resp)
+	return s, nil
+}
+
+// Events возвращает канал событий. Канал закрывается, когда подписка заканчивается.
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// LastEventID возвращает ID последнего полученного события, чтобы продолжить поток
+// в другой подписке.
+func (s *Subscription) LastEventID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastID
+}
+
+// Err возвращает причину окончания подписки: ошибку сервера, после которой переподключаться
+// бессмысленно. После Close или отмены контекста - nil.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close заканчивает подписку и ждет, пока закроется соединение.
+func (s *Subscription) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func (c *Client) connect(ctx context.Context, opts *SubscribeOptions, lastID string) (*http.Response, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/events"+opts.query(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream, application/problem+json")
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("client: unexpected event stream content type %q", resp.Header.Get("Content-Type"))
+	}
+	return resp, nil
+}
+
+func (s *Subscription) run(ctx context.Context, c *Client, opts *SubscribeOptions, resp *http.Response) {
+	defer close(s.done)
+	defer close(s.events)
+
+	delay := defaultRetryDelay
+	if opts.RetryDelay > 0 {
+		delay = opts.RetryDelay
+	}
+	for {
+		serverDelay := s.read(ctx, resp.Body)
+		resp.Body.Close()
+		if serverDelay > 0 && opts.RetryDelay == 0 {
+			delay = serverDelay
+		}
+
+		for {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			var err error
+			resp, err = c.connect(ctx, opts, s.LastEventID())
+			if err == nil {
+				break
+			}
+			if !retryable(ctx, err) {
+				if ctx.Err() == nil {
+					s.mu.Lock()
+					s.err = err
+					s.mu.Unlock()
+				}
+				return
+			}
+		}
+	}
+}
+
+// read разбирает поток text/event-stream до обрыва соединения и возвращает паузу
+// переподключения из поля retry (0 - сервер ее не присылал).
+func (s *Subscription) read(ctx context.Context, body io.Reader) time.Duration {
+	var retry time.Duration
+	var id, eventType string
+	var data strings.Builder
+	hasID := false
+
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return retry
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			// Пустая строка завершает событие
+			if hasID {
+				s.mu.Lock()
+				s.lastID = id
+				s.mu.Unlock()
+			}
+			if data.Len() > 0 || eventType != "" {
+				event := &Event{}
+				if json.Unmarshal([]byte(data.String()), event) == nil {
+					event.ID = id
+					if eventType != "" {
+						event.Type = eventType
+					}
+					select {
+					case s.events <- event:
+					case <-ctx.Done():
+						return retry
+					}
+				}
+			}
+			id, eventType, hasID = "", "", false
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// Комментарий, например heartbeat
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id, hasID = value, true
+		case "event":
+			eventType = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
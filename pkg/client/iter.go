@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Seq2 - итератор в форме iter.Seq2 из Go 1.23: его можно обойти через range, а в более
+// ранних версиях - вызвать с функцией yield. Итераторы API выдают пары (значение, ошибка);
+// после ошибки обход заканчивается.
+type Seq2[K, V any] func(yield func(K, V) bool)
+
+// Collect обходит seq и возвращает все значения или первую ошибку.
+func Collect[T any](seq Seq2[T, error]) ([]T, error) {
+	var items []T
+	var failed error
+	seq(func(item T, err error) bool {
+		if err != nil {
+			failed = err
+			return false
+		}
+		items = append(items, item)
+		return true
+	})
+	return items, failed
+}
+
+// paginate обходит список, начиная с ref, и переходит по ссылкам Link: rel="next",
+// пока сервер их присылает. Следующая страница запрашивается, только когда текущая прочитана.
+func paginate[T any](ctx context.Context, c *Client, ref string) Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for ref != "" {
+			resp, err := c.send(ctx, http.MethodGet, ref, nil)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			var page []T
+			err = decodeResponse(resp, &page)
+			resp.Body.Close()
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			ref = nextLink(resp.Header.Values("Link"))
+			for _, item := range page {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// nextLink возвращает ссылку rel="next" из заголовков Link.
+func nextLink(headers []string) string {
+	for _, header := range headers {
+		for _, link := range strings.Split(header, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(name, "rel") && strings.Trim(value, `"`) == "next" {
+					return target[1 : len(target)-1]
+				}
+			}
+		}
+	}
+	return ""
+}
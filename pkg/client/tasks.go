@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ListOptions - фильтр списка задач.
+type ListOptions struct {
+	ProjectID int
+	// Fields фильтрует по значениям пользовательских полей проекта: ключ поля - значение.
+	// Требует ProjectID.
+	Fields map[string]string
+	// Sort - сортировка по пользовательскому полю: "cf.<key>" или "-cf.<key>".
+	Sort string
+}
+
+func (o *ListOptions) query() string {
+	if o == nil {
+		return ""
+	}
+	query := url.Values{}
+	if o.ProjectID != 0 {
+		query.Set("project_id", strconv.Itoa(o.ProjectID))
+	}
+	for key, value := range o.Fields {
+		query.Set("cf."+key, value)
+	}
+	if o.Sort != "" {
+		query.Set("sort", o.Sort)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+// Tasks обходит задачи, видимые пользователю. opts может быть nil.
+func (c *Client) Tasks(ctx context.Context, opts *ListOptions) Seq2[*Task, error] {
+	return paginate[*Task](ctx, c, "/tasks"+opts.query())
+}
+
+// ListTasks возвращает все задачи, видимые пользователю. opts может быть nil.
+func (c *Client) ListTasks(ctx context.Context, opts *ListOptions) ([]*Task, error) {
+	return Collect(c.Tasks(ctx, opts))
+}
+
+func (c *Client) GetTask(ctx context.Context, id int) (*Task, error) {
+	var task Task
+	if err := c.call(ctx, http.MethodGet, path("tasks", itoa(id)), nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetTaskWithComments возвращает задачу вместе с последними limit комментариями
+// (0 - сколько отдает сервер по умолчанию).
+func (c *Client) GetTaskWithComments(ctx context.Context, id int, limit int) (*Task, []*Comment, error) {
+	query := url.Values{"include": {"comments"}}
+	if limit > 0 {
+		query.Set("comments_limit", strconv.Itoa(limit))
+	}
+	var response struct {
+		Task
+		Comments []*Comment `json:"comments"`
+	}
+	if err := c.call(ctx, http.MethodGet, path("tasks", itoa(id))+"?"+query.Encode(), nil, &response); err != nil {
+		return nil, nil, err
+	}
+	return &response.Task, response.Comments, nil
+}
+
+func (c *Client) CreateTask(ctx context.Context, input *TaskInput) (*Task, error) {
+	var task Task
+	if err := c.call(ctx, http.MethodPost, "/tasks", input, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *Client) UpdateTask(ctx context.Context, id int, input *TaskInput) (*Task, error) {
+	var task Task
+	if err := c.call(ctx, http.MethodPut, path("tasks", itoa(id)), input, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *Client) DeleteTask(ctx context.Context, id int) error {
+	return c.call(ctx, http.MethodDelete, path("tasks", itoa(id)), nil, nil)
+}
+
+// CompleteTask переводит задачу в завершающий статус.
+func (c *Client) CompleteTask(ctx context.Context, id int) (*Task, error) {
+	var task Task
+	if err := c.call(ctx, http.MethodPatch, path("tasks", itoa(id), "complete"), nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// SetTaskStatus переводит задачу в статус status по правилам workflow сервера.
+func (c *Client) SetTaskStatus(ctx context.Context, id int, status string) (*Task, error) {
+	var task Task
+	input := map[string]string{"status": status}
+	if err := c.call(ctx, http.MethodPatch, path("tasks", itoa(id), "status"), input, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// Отношения пользователя к задаче для MyTasks.
+const (
+	RelationAssigned = "assigned"
+	RelationWatching = "watching"
+	RelationCreated  = "created"
+)
+
+// MyTasks возвращает задачи, где текущий пользователь исполнитель, наблюдатель или автор.
+// Без relations - все три списка вместе.
+func (c *Client) MyTasks(ctx context.Context, relations ...string) ([]*Task, error) {
+	ref := "/me/tasks"
+	if len(relations) > 0 {
+		ref += "?" + url.Values{"filter": {strings.Join(relations, ",")}}.Encode()
+	}
+	var tasks []*Task
+	if err := c.call(ctx, http.MethodGet, ref, nil, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (c *Client) ListReminders(ctx context.Context, taskID int) ([]*Reminder, error) {
+	var reminders []*Reminder
+	if err := c.call(ctx, http.MethodGet, path("tasks", itoa(taskID), "reminders"), nil, &reminders); err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+func (c *Client) CreateReminder(ctx context.Context, taskID int, input *ReminderInput) (*Reminder, error) {
+	var reminder Reminder
+	if err := c.call(ctx, http.MethodPost, path("tasks", itoa(taskID), "reminders"), input, &reminder); err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+func (c *Client) DeleteReminder(ctx context.Context, taskID int, reminderID int) error {
+	return c.call(ctx, http.MethodDelete, path("tasks", itoa(taskID), "reminders", itoa(reminderID)), nil, nil)
+}
+
+func (c *Client) ListShares(ctx context.Context, taskID int) ([]*Share, error) {
+	var shares []*Share
+	if err := c.call(ctx, http.MethodGet, path("tasks", itoa(taskID), "shares"), nil, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// ShareTask выдает пользователю доступ к задаче. Повторный вызов меняет право.
+func (c *Client) ShareTask(ctx context.Context, taskID int, input *ShareInput) (*Share, error) {
+	var share Share
+	if err := c.call(ctx, http.MethodPost, path("tasks", itoa(taskID), "shares"), input, &share); err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (c *Client) UnshareTask(ctx context.Context, taskID int, userID int) error {
+	return c.call(ctx, http.MethodDelete, path("tasks", itoa(taskID), "shares", itoa(userID)), nil, nil)
+}
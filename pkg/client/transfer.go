@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Форматы выгрузки и загрузки задач.
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+var formatContentTypes = map[string]string{
+	FormatJSON:   "application/json",
+	FormatNDJSON: "application/x-ndjson",
+	FormatCSV:    "text/csv",
+}
+
+// ExportOptions - формат и фильтр выгрузки.
+type ExportOptions struct {
+	// Format - FormatJSON (по умолчанию), FormatNDJSON или FormatCSV.
+	Format string
+	// ProjectID выгружает только задачи проекта, 0 - все доступные.
+	ProjectID int
+	// Columns - колонки CSV в виде заголовок=поле или поле; пусто - настройка сервера.
+	Columns []string
+}
+
+// ImportOptions - формат и режим загрузки.
+type ImportOptions struct {
+	// Format - FormatJSON (массив или объект на строке, по умолчанию), FormatNDJSON или FormatCSV.
+	Format string
+	// DryRun проверяет записи, ничего не сохраняя.
+	DryRun bool
+	// Columns - колонки CSV, как в ExportOptions.
+	Columns []string
+}
+
+// ImportReport - итог загрузки по записям.
+type ImportReport struct {
+	DryRun    bool         `json:"dry_run"`
+	Committed bool         `json:"committed"`
+	Total     int          `json:"total"`
+	Created   int          `json:"created"`
+	Updated   int          `json:"updated"`
+	Failed    int          `json:"failed"`
+	Rows      []*ImportRow `json:"rows"`
+}
+
+// ImportRow - итог записи: Action - created, updated или failed.
+type ImportRow struct {
+	Row        int    `json:"row"`
+	Action     string `json:"action"`
+	TaskID     int    `json:"task_id,omitempty"`
+	ExternalID string `json:"external_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Export открывает выгрузку доступных задач. Сервер отдает задачи потоком, поэтому
+// выгрузку лучше читать из Body, не накапливая в памяти; Body нужно закрыть.
+func (c *Client) Export(ctx context.Context, opts *ExportOptions) (io.ReadCloser, error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+	query := url.Values{}
+	if opts.Format != "" {
+		query.Set("format", opts.Format)
+	}
+	if opts.ProjectID != 0 {
+		query.Set("project_id", strconv.Itoa(opts.ProjectID))
+	}
+	if len(opts.Columns) > 0 {
+		query.Set("columns", strings.Join(opts.Columns, ","))
+	}
+	ref := "/export"
+	if len(query) > 0 {
+		ref += "?" + query.Encode()
+	}
+	resp, err := c.send(ctx, http.MethodGet, ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Import загружает задачи из r в одной транзакции. Тело передается потоком, поэтому
+// запрос не повторяется. Если хотя бы одна запись не прошла проверку, сервер ничего не
+// сохраняет и отвечает 422: тогда возвращаются и отчет, и *Error.
+func (c *Client) Import(ctx context.Context, r io.Reader, opts *ImportOptions) (*ImportReport, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	format := opts.Format
+	if format == "" {
+		format = FormatJSON
+	}
+	query := url.Values{"format": {format}}
+	if opts.DryRun {
+		query.Set("dry_run", "true")
+	}
+	if len(opts.Columns) > 0 {
+		query.Set("columns", strings.Join(opts.Columns, ","))
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/import?"+query.Encode(), r)
+	if err != nil {
+		return nil, err
+	}
+	if contentType, ok := formatContentTypes[format]; ok {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.do(req)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity {
+		var report ImportReport
+		if json.Unmarshal([]byte(apiErr.Detail), &report) == nil {
+			return &report, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var report ImportReport
+	if err := decodeResponse(resp, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
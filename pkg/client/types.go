@@ -0,0 +1,185 @@
+package client
+
+import "time"
+
+// Task - задача в ответах API. Даты - строки "2006-01-02 15:04:05" в часовом поясе сервера.
+type Task struct {
+	ID                int                `json:"id"`
+	Title             string             `json:"title"`
+	Description       string             `json:"description,omitempty"`
+	DueDate           string             `json:"due_date"`
+	Status            string             `json:"status"`
+	IsCompleted       bool               `json:"is_completed"`
+	IsOverdue         bool               `json:"is_overdue"`
+	CreatedAt         string             `json:"created_at"`
+	OwnerID           int                `json:"owner_id,omitempty"`
+	ProjectID         int                `json:"project_id,omitempty"`
+	EstimateMinutes   int                `json:"estimate_minutes,omitempty"`
+	Assignees         []int              `json:"assignees,omitempty"`
+	Watchers          []int              `json:"watchers,omitempty"`
+	Checklist         []*ChecklistItem   `json:"checklist,omitempty"`
+	ChecklistProgress *ChecklistProgress `json:"checklist_progress,omitempty"`
+	CustomFields      map[string]any     `json:"custom_fields,omitempty"`
+	ExternalID        string             `json:"external_id,omitempty"`
+}
+
+// TaskInput - поля задачи при создании и изменении. При создании обязателен Title, а DueDate -
+// дата YYYY-MM-DD (задача истекает в конце дня) или дата со временем YYYY-MM-DD HH:MM:SS;
+// при изменении DueDate задается полностью: YYYY-MM-DD HH:MM:SS.
+type TaskInput struct {
+	Title           *string `json:"title"`
+	Description     *string `json:"description,omitempty"`
+	DueDate         *string `json:"due_date,omitempty"`
+	ProjectID       *int    `json:"project_id,omitempty"`
+	Assignees       *[]int  `json:"assignees,omitempty"`
+	Watchers        *[]int  `json:"watchers,omitempty"`
+	EstimateMinutes *int    `json:"estimate_minutes,omitempty"`
+	// CustomFields задает значения пользовательских полей; nil в значении удаляет его.
+	CustomFields map[string]any `json:"custom_fields,omitempty"`
+	// ExternalID - идентификатор задачи во внешней системе; пустая строка его снимает.
+	ExternalID *string `json:"external_id,omitempty"`
+}
+
+// Reminder - напоминание о задаче.
+type Reminder struct {
+	ID          int    `json:"id"`
+	TaskID      int    `json:"task_id"`
+	RemindAt    string `json:"remind_at,omitempty"`
+	Before      string `json:"before,omitempty"`
+	FireAt      string `json:"fire_at"`
+	State       string `json:"state"`
+	Attempts    int    `json:"attempts"`
+	LastError   string `json:"last_error,omitempty"`
+	DeliveredAt string `json:"delivered_at,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ReminderInput задает время напоминания: At - момент, Before - интервал до срока (например, "2h").
+type ReminderInput struct {
+	At     *string `json:"at,omitempty"`
+	Before *string `json:"before,omitempty"`
+}
+
+// Share - доступ пользователя к задаче.
+type Share struct {
+	UserID     int    `json:"user_id"`
+	Username   string `json:"username"`
+	Permission string `json:"permission"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// ShareInput выдает доступ по UserID или Username. Permission - view или edit.
+type ShareInput struct {
+	UserID     int    `json:"user_id,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Permission string `json:"permission"`
+}
+
+// Comment - комментарий к задаче. BodyHTML - тело в Markdown, переведенное в HTML.
+type Comment struct {
+	ID        int    `json:"id"`
+	TaskID    int    `json:"task_id"`
+	AuthorID  int    `json:"author_id"`
+	Author    string `json:"author,omitempty"`
+	Body      string `json:"body"`
+	BodyHTML  string `json:"body_html"`
+	Mentions  []int  `json:"mentions,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+	Deleted   bool   `json:"deleted,omitempty"`
+}
+
+// CommentRevision - прежняя версия комментария.
+type CommentRevision struct {
+	Body     string `json:"body"`
+	EditedAt string `json:"edited_at"`
+}
+
+// Attachment - вложение задачи.
+type Attachment struct {
+	ID          int    `json:"id"`
+	TaskID      int    `json:"task_id"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	SHA256      string `json:"sha256"`
+	UploadedBy  int    `json:"uploaded_by"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type ChecklistItem struct {
+	ID       int    `json:"id"`
+	Text     string `json:"text"`
+	Done     bool   `json:"done"`
+	Position int    `json:"position"`
+}
+
+type ChecklistItemInput struct {
+	Text     *string `json:"text"`
+	Done     *bool   `json:"done,omitempty"`
+	Position *int    `json:"position,omitempty"`
+}
+
+type ChecklistProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Checklist - чек-лист задачи после изменения. TaskStatus - статус задачи: отметка всех
+// пунктов может перевести ее в другой статус.
+type Checklist struct {
+	Items      []*ChecklistItem   `json:"items"`
+	Progress   *ChecklistProgress `json:"checklist_progress"`
+	TaskStatus string             `json:"task_status"`
+}
+
+// Worklog - запись о затраченном времени. У запущенного таймера EndedAt пустое.
+type Worklog struct {
+	ID          int    `json:"id"`
+	TaskID      int    `json:"task_id"`
+	UserID      int    `json:"user_id"`
+	StartedAt   string `json:"started_at"`
+	EndedAt     string `json:"ended_at,omitempty"`
+	Seconds     int    `json:"seconds"`
+	Minutes     int    `json:"minutes"`
+	Note        string `json:"note,omitempty"`
+	Source      string `json:"source"`
+	AutoStopped bool   `json:"auto_stopped,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type WorklogInput struct {
+	Minutes   *int    `json:"minutes"`
+	StartedAt *string `json:"started_at,omitempty"`
+	Note      *string `json:"note,omitempty"`
+}
+
+// Типы событий потока /events.
+const (
+	EventTaskCreated    = "task.created"
+	EventTaskUpdated    = "task.updated"
+	EventTaskCompleted  = "task.completed"
+	EventTaskDeleted    = "task.deleted"
+	EventTaskOverdue    = "task.overdue"
+	EventTaskReassigned = "task.reassigned"
+	EventCommentCreated = "comment.created"
+	EventCommentUpdated = "comment.updated"
+	EventCommentDeleted = "comment.deleted"
+	EventConfigChanged  = "config.changed"
+	// EventReset приходит, когда сервер не сохранил все пропущенные события: состояние
+	// нужно перечитать целиком.
+	EventReset = "reset"
+)
+
+// Event - событие из потока /events. ID передается серверу при переподключении.
+type Event struct {
+	ID                string    `json:"-"`
+	Type              string    `json:"event"`
+	TaskID            int       `json:"task_id"`
+	Task              *Task     `json:"task,omitempty"`
+	Comment           *Comment  `json:"comment,omitempty"`
+	PreviousAssignees []int     `json:"previous_assignees,omitempty"`
+	Mentioned         []int     `json:"mentioned,omitempty"`
+	Workspace         string    `json:"workspace,omitempty"`
+	OccurredAt        time.Time `json:"occurred_at"`
+}
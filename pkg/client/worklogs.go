@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+func (c *Client) ListWorklogs(ctx context.Context, taskID int) ([]*Worklog, error) {
+	var worklogs []*Worklog
+	if err := c.call(ctx, http.MethodGet, path("tasks", itoa(taskID), "worklogs"), nil, &worklogs); err != nil {
+		return nil, err
+	}
+	return worklogs, nil
+}
+
+// CreateWorklog записывает время вручную.
+func (c *Client) CreateWorklog(ctx context.Context, taskID int, input *WorklogInput) (*Worklog, error) {
+	return c.worklog(ctx, http.MethodPost, path("tasks", itoa(taskID), "worklogs"), input)
+}
+
+func (c *Client) DeleteWorklog(ctx context.Context, taskID int, worklogID int) error {
+	return c.call(ctx, http.MethodDelete, path("tasks", itoa(taskID), "worklogs", itoa(worklogID)), nil, nil)
+}
+
+// StartTimer запускает таймер по задаче. Запущенный таймер по другой задаче останавливается.
+func (c *Client) StartTimer(ctx context.Context, taskID int) (*Worklog, error) {
+	return c.worklog(ctx, http.MethodPost, path("tasks", itoa(taskID), "timer", "start"), nil)
+}
+
+// StopTimer останавливает таймер и возвращает получившуюся запись о времени.
+func (c *Client) StopTimer(ctx context.Context, taskID int) (*Worklog, error) {
+	return c.worklog(ctx, http.MethodPost, path("tasks", itoa(taskID), "timer", "stop"), nil)
+}
+
+// RunningTimer возвращает запущенный таймер текущего пользователя или nil, если таймер не идет.
+func (c *Client) RunningTimer(ctx context.Context) (*Worklog, error) {
+	var timer *Worklog
+	if err := c.call(ctx, http.MethodGet, "/me/timer", nil, &timer); err != nil {
+		return nil, err
+	}
+	return timer, nil
+}
+
+func (c *Client) worklog(ctx context.Context, method string, ref string, in any) (*Worklog, error) {
+	var worklog Worklog
+	if err := c.call(ctx, method, ref, in, &worklog); err != nil {
+		return nil, err
+	}
+	return &worklog, nil
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -20,16 +22,21 @@ func Searchup(dir string, filename string) string {
 	return Searchup(path.Dir(dir), filename)
 }
 
-func Load(path string) error {
-	directory, err := os.Getwd()
-	if err != nil {
-		return err
+// LoadEnv загружает переменные из файла filename, не перезаписывая уже заданные.
+// Имя без каталога ищется в текущем каталоге и выше. Если файла нет, ошибка
+// соответствует fs.ErrNotExist.
+func LoadEnv(filename string) error {
+	filepath := filename
+	if !strings.ContainsRune(filename, os.PathSeparator) {
+		directory, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		filepath = Searchup(directory, filename)
 	}
 
-	filepath := Searchup(directory, ".env")
-
 	if filepath == "" {
-		return fmt.Errorf("could not find dot env file")
+		return fmt.Errorf("could not find env file %s: %w", filename, fs.ErrNotExist)
 	}
 
 	return godotenv.Load(filepath)
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader(fs)
+	if err := fs.Parse(append([]string{"--env-file", ""}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	return loader.Load()
+}
+
+func TestLayering(t *testing.T) {
+	files := map[string]string{
+		"lwo.yaml": `
+server:
+  address: "0.0.0.0:9000"  # из файла
+  write_timeout: 30s
+jobs:
+  interval: 5m
+notify:
+  notifiers:
+    - log
+    - file
+attachments:
+  max_size: 10M
+`,
+		"lwo.toml": `
+jobs.interval = "5m"
+
+[server]
+address = "0.0.0.0:9000"
+write_timeout = "30s"
+
+[notify]
+notifiers = ["log", "file"]
+
+[attachments]
+max_size = "10M"
+`,
+		"lwo.json": `{
+  "server": {"address": "0.0.0.0:9000", "write_timeout": "30s"},
+  "jobs": {"interval": "5m"},
+  "notify": {"notifiers": ["log", "file"]},
+  "attachments": {"max_size": "10M"}
+}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := writeFile(t, name, content)
+			t.Setenv("SERVER_WRITE_TIMEOUT", "45s")
+			t.Setenv("LOG_LEVEL", "debug")
+
+			cfg, err := load(t, "--config", path, "--log.level", "warn", "--tasks.checklist_auto_complete")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Address != "0.0.0.0:9000" || cfg.Jobs.Interval != 5*time.Minute || cfg.Attachments.MaxSize != 10<<20 {
+				t.Errorf("file values are not applied: %+v %+v %+v", cfg.Server, cfg.Jobs, cfg.Attachments)
+			}
+			if strings.Join(cfg.Notify.Notifiers, ",") != "log,file" {
+				t.Errorf("notifiers = %v", cfg.Notify.Notifiers)
+			}
+			if cfg.Server.WriteTimeout != 45*time.Second {
+				t.Errorf("env does not override file: write_timeout = %s", cfg.Server.WriteTimeout)
+			}
+			if cfg.Log.Level != "warn" || !cfg.Tasks.ChecklistAutoComplete {
+				t.Errorf("flags do not override env: %+v %+v", cfg.Log, cfg.Tasks)
+			}
+			if cfg.Server.IdleTimeout != 2*time.Minute || cfg.Database.Path != "tasks.db" {
+				t.Errorf("defaults are lost: %+v %+v", cfg.Server, cfg.Database)
+			}
+		})
+	}
+}
+
+func TestValidation(t *testing.T) {
+	path := writeFile(t, "lwo.yaml", "server:\n  adress: localhost:8080\n")
+	if _, err := load(t, "--config", path); err == nil || !strings.Contains(err.Error(), `unknown setting "server.adress"`) {
+		t.Errorf("unknown key: %v", err)
+	}
+
+	t.Setenv("JOBS_INTERVAL", "soon")
+	if _, err := load(t); err == nil || !strings.Contains(err.Error(), "JOBS_INTERVAL: expected a duration") {
+		t.Errorf("invalid env value: %v", err)
+	}
+	t.Setenv("JOBS_INTERVAL", "")
+
+	t.Setenv("AUTH_ADMIN_USERNAME", "admin")
+	_, err := load(t, "--server.shutdown_timeout", "0s", "--server.shutdown_delay", "-1s", "--log.format", "xml")
+	if err == nil {
+		t.Fatal("invalid configuration is accepted")
+	}
+	for _, want := range []string{
+		`invalid server.shutdown_timeout (SERVER_SHUTDOWN_TIMEOUT = "0s"): must be positive`,
+		`invalid server.shutdown_delay (SERVER_SHUTDOWN_DELAY = "-1s"): must not be negative`,
+		`invalid log.format (LOG_FORMAT = "xml"): unknown value "xml", expected text, json`,
+		`invalid auth.admin_password (AUTH_ADMIN_PASSWORD = ""): is required with auth.admin_username`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	path := writeFile(t, "custom.env", "LWO_TEST_VALUE=from-file\n")
+	if err := LoadEnv(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Unsetenv("LWO_TEST_VALUE") })
+	if got := os.Getenv("LWO_TEST_VALUE"); got != "from-file" {
+		t.Errorf("LWO_TEST_VALUE = %q", got)
+	}
+
+	if err := LoadEnv(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Error("missing env file is ignored")
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	cfg := Defaults()
+	cfg.Auth.AdminUsername = "admin"
+	cfg.Auth.AdminPassword = "hunter2"
+	cfg.Notify.SMTP.To = []string{"ops@example.com"}
+
+	var out bytes.Buffer
+	if err := cfg.WriteYAML(&out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "hunter2") || !strings.Contains(out.String(), `  admin_password: "[redacted]"`) {
+		t.Errorf("secret is printed:\n%s", out.String())
+	}
+
+	// Вывод --print-config можно использовать как файл настроек, кроме скрытых секретов
+	path := writeFile(t, "printed.yaml", out.String())
+	printed := Defaults()
+	if err := printed.applyFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if printed.Auth.AdminUsername != "admin" || printed.Notify.SMTP.To[0] != "ops@example.com" ||
+		printed.Attachments.MaxSize != cfg.Attachments.MaxSize || printed.JWT.TTL != cfg.JWT.TTL {
+		t.Errorf("printed configuration does not round-trip:\n%s", out.String())
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// IsSecret сообщает, что значение переменной нельзя показывать. URL уведомлений
+// часто содержит токен (например, входящие вебхуки чатов), а заголовки экспорта трасс -
+// ключ коллектора, поэтому они тоже скрываются. _KEY считается только целым словом:
+// JWT_KEYS_DIR и JWT_KEYS_RELOAD_INTERVAL - не секреты.
+func IsSecret(name string) bool {
+	for _, marker := range []string{"PASSWORD", "SECRET", "TOKEN", "WEBHOOK_URL", "HEADERS"} {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return strings.HasSuffix(name, "_KEY") || strings.Contains(name, "_KEY_")
+}
+
+// Redacted возвращает действующие значения настроек по именам переменных окружения,
+// скрывая непустые секреты.
+func (c *Config) Redacted() map[string]string {
+	values := map[string]string{}
+	for _, s := range c.settings() {
+		values[s.env] = s.redacted()
+	}
+	return values
+}
+
+func (s setting) redacted() string {
+	value := s.format()
+	if IsSecret(s.env) && value != "" {
+		return redacted
+	}
+	return value
+}
+
+// WriteYAML выводит настройки в формате файла настроек, скрывая секреты.
+func (c *Config) WriteYAML(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	var section []string
+	for _, s := range c.settings() {
+		path := strings.Split(s.key, ".")
+		parents, name := path[:len(path)-1], path[len(path)-1]
+
+		// Открываем секции, которых не было у предыдущей настройки
+		common := 0
+		for common < len(parents) && common < len(section) && parents[common] == section[common] {
+			common++
+		}
+		for i := common; i < len(parents); i++ {
+			buf.WriteString(strings.Repeat("  ", i) + parents[i] + ":\n")
+		}
+		section = parents
+
+		buf.WriteString(strings.Repeat("  ", len(parents)) + name + ": " + yamlValue(s) + "\n")
+	}
+	return buf.Flush()
+}
+
+func yamlValue(s setting) string {
+	if list, ok := s.value.Interface().([]string); ok && !IsSecret(s.env) {
+		quoted := make([]string, len(list))
+		for i, item := range list {
+			quoted[i] = strconv.Quote(item)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	switch s.value.Interface().(type) {
+	case bool, int, float64:
+		return s.format()
+	}
+	return strconv.Quote(s.redacted())
+}
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Разбор файлов настроек. Настройки - это секции со скалярами и списками скаляров, поэтому
+// YAML и TOML поддерживаются в этом объеме: без якорей, многострочных строк и таблиц массивов.
+
+func parseJSON(data []byte) (map[string]any, error) {
+	var values map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return values, nil
+}
+
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func parseYAML(data []byte) (map[string]any, error) {
+	p := &yamlParser{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(stripComment(line), " \r")
+		text := strings.TrimLeft(line, " ")
+		if text == "" || text == "---" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(line) - len(text), text: text})
+	}
+	if len(p.lines) == 0 {
+		return map[string]any{}, nil
+	}
+
+	values, err := p.parseMap(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].number)
+	}
+	return values, nil
+}
+
+func (p *yamlParser) parseMap(indent int) (map[string]any, error) {
+	values := map[string]any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+		}
+		key, rest, ok := strings.Cut(line.text, ":")
+		if !ok || isListItem(line.text) {
+			return nil, fmt.Errorf("line %d: expected key: value", line.number)
+		}
+		key = unquote(strings.TrimSpace(key))
+		rest = strings.TrimSpace(rest)
+		p.pos++
+
+		if rest != "" {
+			value, err := parseScalarOrList(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line.number, err)
+			}
+			values[key] = value
+			continue
+		}
+
+		// Пустое значение открывает вложенную секцию или список; список может стоять на уровне ключа
+		if p.pos >= len(p.lines) {
+			values[key] = nil
+			continue
+		}
+		next := p.lines[p.pos]
+		if next.indent < indent || next.indent == indent && !isListItem(next.text) {
+			values[key] = nil
+			continue
+		}
+		var err error
+		if isListItem(next.text) {
+			values[key], err = p.parseList(next.indent)
+		} else {
+			values[key], err = p.parseMap(next.indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (p *yamlParser) parseList(indent int) ([]any, error) {
+	var items []any
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isListItem(line.text) {
+			break
+		}
+		items = append(items, unquote(strings.TrimSpace(strings.TrimPrefix(line.text, "-"))))
+		p.pos++
+	}
+	return items, nil
+}
+
+func isListItem(text string) bool {
+	return strings.HasPrefix(text, "- ") || text == "-"
+}
+
+func parseTOML(data []byte) (map[string]any, error) {
+	values := map[string]any{}
+	section := values
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table header %q", i+1, line)
+			}
+			var err error
+			if section, err = tomlTable(values, strings.Trim(line, "[]")); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			continue
+		}
+
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		value, err := parseScalarOrList(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		// Ключ с точками (server.address = ...) задает значение во вложенной таблице
+		path := strings.Split(strings.TrimSpace(key), ".")
+		table, err := tomlTable(section, strings.Join(path[:len(path)-1], "."))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		table[unquote(strings.TrimSpace(path[len(path)-1]))] = value
+	}
+	return values, nil
+}
+
+// tomlTable возвращает вложенную таблицу по пути через точку, создавая недостающие.
+func tomlTable(root map[string]any, path string) (map[string]any, error) {
+	table := root
+	if path == "" {
+		return table, nil
+	}
+	for _, name := range strings.Split(path, ".") {
+		name = unquote(strings.TrimSpace(name))
+		next, ok := table[name]
+		if !ok {
+			child := map[string]any{}
+			table[name] = child
+			table = child
+			continue
+		}
+		child, ok := next.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a table", name)
+		}
+		table = child
+	}
+	return table, nil
+}
+
+// parseScalarOrList разбирает значение или список в квадратных скобках. Кавычки снимаются,
+// остальное остается строкой и приводится к типу поля при применении.
+func parseScalarOrList(value string) (any, error) {
+	if !strings.HasPrefix(value, "[") {
+		return unquote(value), nil
+	}
+	if !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("unterminated list %q", value)
+	}
+
+	items := []any{}
+	inner := strings.TrimSpace(value[1 : len(value)-1])
+	if inner == "" {
+		return items, nil
+	}
+	for _, item := range splitList(inner) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, unquote(item))
+		}
+	}
+	return items, nil
+}
+
+// splitList делит элементы списка по запятым вне кавычек.
+func splitList(value string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && tokenStart(value, i):
+			quote = c
+		case c == ',':
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, value[start:])
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 {
+		switch {
+		case value[0] == '"' && value[len(value)-1] == '"':
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				return unquoted
+			}
+		case value[0] == '\'' && value[len(value)-1] == '\'':
+			return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+	}
+	return value
+}
+
+// stripComment отрезает комментарий # вне кавычек. В YAML комментарий начинается
+// с начала строки или после пробела, поэтому # внутри значения (a#b) остается.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && tokenStart(line, i):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// tokenStart сообщает, что кавычка в позиции i открывает значение, а не стоит внутри слова.
+func tokenStart(value string, i int) bool {
+	return i == 0 || strings.IndexByte(" \t:=[,", value[i-1]) >= 0
+}
@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+// JWT - настройки проверки и выпуска JWT. Пустой KeysDir отключает JWT.
+type JWT struct {
+	KeysDir        string        `key:"keys_dir" env:"JWT_KEYS_DIR" help:"directory with JWT keys, empty disables JWT"`
+	SigningKey     string        `key:"signing_key" env:"JWT_SIGNING_KEY" help:"key ID used to issue tokens"`
+	Issuer         string        `key:"issuer" env:"JWT_ISSUER" help:"expected and issued iss claim"`
+	Audience       string        `key:"audience" env:"JWT_AUDIENCE" help:"expected and issued aud claim"`
+	ClockSkew      time.Duration `key:"clock_skew" env:"JWT_CLOCK_SKEW" help:"allowed clock difference"`
+	TTL            time.Duration `key:"ttl" env:"JWT_TTL" help:"lifetime of issued tokens"`
+	ReloadInterval time.Duration `key:"reload_interval" env:"JWT_KEYS_RELOAD_INTERVAL" help:"period of key directory reload"`
+}
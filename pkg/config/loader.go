@@ -0,0 +1,356 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Size - размер в байтах. В настройках записывается числом или числом с K, M, G.
+type Size int64
+
+func ParseSize(value string) (Size, error) {
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(value, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("expected non-negative size, got %q", value)
+	}
+	return Size(parsed * multiplier), nil
+}
+
+func (s Size) String() string {
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}} {
+		if s != 0 && int64(s)%unit.size == 0 {
+			return strconv.FormatInt(int64(s)/unit.size, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(int64(s), 10)
+}
+
+// setting - одна настройка Config: путь в файле, переменная окружения и поле структуры.
+// reload - значение можно применить без перезапуска.
+type setting struct {
+	key    string
+	env    string
+	help   string
+	reload bool
+	value  reflect.Value
+}
+
+// settings перечисляет настройки в порядке объявления полей.
+func (c *Config) settings() []setting {
+	var result []setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key := prefix + field.Tag.Get("key")
+			if field.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			result = append(result, setting{
+				key:    key,
+				env:    field.Tag.Get("env"),
+				help:   field.Tag.Get("help"),
+				reload: field.Tag.Get("reload") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return result
+}
+
+// lookup находит настройку по ключу.
+func (c *Config) lookup(key string) (setting, bool) {
+	for _, s := range c.settings() {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// Keys - переменные окружения, которые читает сервис.
+func Keys() []string {
+	var keys []string
+	for _, s := range Defaults().settings() {
+		keys = append(keys, s.env)
+	}
+	return keys
+}
+
+// set разбирает строковое значение по типу поля. Списки пишутся через запятую.
+func (s setting) set(value string) error {
+	switch target := s.value.Addr().Interface().(type) {
+	case *string:
+		*target = value
+	case *bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", value)
+		}
+		*target = parsed
+	case *int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", value)
+		}
+		*target = parsed
+	case *float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("expected a number, got %q", value)
+		}
+		*target = parsed
+	case *time.Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("expected a duration such as 30s or 5m, got %q", value)
+		}
+		*target = parsed
+	case *Size:
+		parsed, err := ParseSize(value)
+		if err != nil {
+			return err
+		}
+		*target = parsed
+	case *[]string:
+		*target = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*target = append(*target, item)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("config: unsupported type of %s", s.key))
+	}
+	return nil
+}
+
+// setAny принимает значение из файла: скаляр или список скаляров.
+func (s setting) setAny(value any) error {
+	list, ok := value.([]any)
+	if !ok {
+		return s.set(scalarString(value))
+	}
+	if _, ok := s.value.Addr().Interface().(*[]string); !ok {
+		return fmt.Errorf("expected a single value, got a list")
+	}
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		if _, nested := item.([]any); nested {
+			return fmt.Errorf("nested lists are not supported")
+		}
+		items = append(items, scalarString(item))
+	}
+	return s.set(strings.Join(items, ","))
+}
+
+func scalarString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// format возвращает значение в том виде, в котором его принимает set.
+func (s setting) format() string {
+	switch v := s.value.Interface().(type) {
+	case []string:
+		return strings.Join(v, ",")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Loader собирает Config из источников по возрастанию приоритета: значения по умолчанию,
+// файл настроек (YAML, TOML или JSON по расширению), переменные окружения вместе с .env
+// и флаги командной строки.
+type Loader struct {
+	// File - файл настроек: --config или CONFIG_FILE. Пустой - без файла.
+	File string
+	// EnvFile - файл с переменными окружения: --env-file, по умолчанию .env. Если путь
+	// не содержит каталога, файл ищется в текущем каталоге и выше; отсутствие не ошибка.
+	EnvFile string
+	// PrintConfig - выставлен флаг --print-config.
+	PrintConfig bool
+
+	flags map[string]string
+	order []string
+}
+
+// NewLoader регистрирует в fs флаги загрузки и по флагу на каждую настройку.
+// Значения флагов применяются в Load поверх остальных источников.
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{EnvFile: ".env", flags: map[string]string{}}
+	fs.StringVar(&l.File, "config", os.Getenv("CONFIG_FILE"), "configuration file (YAML, TOML or JSON), env CONFIG_FILE")
+	fs.StringVar(&l.EnvFile, "env-file", l.EnvFile, "file with environment variables")
+	fs.BoolVar(&l.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+
+	for _, s := range Defaults().settings() {
+		key := s.key
+		usage := fmt.Sprintf("%s (env %s, default %s)", s.help, s.env, s.format())
+		record := func(value string) error {
+			if _, seen := l.flags[key]; !seen {
+				l.order = append(l.order, key)
+			}
+			l.flags[key] = value
+			return nil
+		}
+		if s.value.Kind() == reflect.Bool {
+			fs.BoolFunc(key, usage, func(value string) error { return record(value) })
+		} else {
+			fs.Func(key, usage, record)
+		}
+	}
+	return l
+}
+
+// Load читает все источники и проверяет результат.
+func (l *Loader) Load() (*Config, error) {
+	if l.EnvFile != "" {
+		err := LoadEnv(l.EnvFile)
+		if err != nil && !(errors.Is(err, fs.ErrNotExist) && l.EnvFile == ".env") {
+			return nil, err
+		}
+	}
+
+	cfg := Defaults()
+	if l.File != "" {
+		if err := cfg.applyFile(l.File); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, key := range l.order {
+		s, _ := cfg.lookup(key)
+		if err := s.set(l.flags[key]); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", key, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FromEnv собирает настройки только из значений по умолчанию и окружения, без файлов и флагов.
+func FromEnv() (*Config, error) {
+	return (&Loader{}).Load()
+}
+
+// applyEnv применяет непустые переменные окружения.
+func (c *Config) applyEnv() error {
+	var errs []error
+	for _, s := range c.settings() {
+		value := os.Getenv(s.env)
+		if value == "" {
+			continue
+		}
+		if err := s.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyFile применяет файл настроек. Неизвестные ключи считаются ошибкой, чтобы опечатка
+// не превращалась молча в значение по умолчанию.
+func (c *Config) applyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		values, err = parseJSON(data)
+	case ".yaml", ".yml":
+		values, err = parseYAML(data)
+	case ".toml":
+		values, err = parseTOML(data)
+	default:
+		return fmt.Errorf("%s: unsupported config format %q, expected .yaml, .toml or .json", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	flat := flatten(values, "")
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var errs []error
+	for _, key := range keys {
+		value := flat[key]
+		s, ok := c.lookup(key)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", path, key))
+			continue
+		}
+		if err := s.setAny(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flatten превращает вложенные секции в ключи через точку.
+func flatten(values map[string]any, prefix string) map[string]any {
+	flat := map[string]any{}
+	for key, value := range values {
+		if section, ok := value.(map[string]any); ok {
+			for nested, v := range flatten(section, prefix+key+".") {
+				flat[nested] = v
+			}
+			continue
+		}
+		flat[prefix+key] = value
+	}
+	return flat
+}
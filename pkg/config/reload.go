@@ -0,0 +1,38 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+)
+
+// Clone возвращает копию настроек, не разделяющую списки с исходными.
+func (c *Config) Clone() *Config {
+	clone := *c
+	for _, s := range clone.settings() {
+		if list, ok := s.value.Interface().([]string); ok {
+			s.value.Set(reflect.ValueOf(slices.Clone(list)))
+		}
+	}
+	return &clone
+}
+
+// Update сравнивает действующие настройки с заново прочитанными next. Изменения настроек
+// с тегом reload переносятся в результат (applied), остальные остаются прежними
+// и возвращаются в restart - они вступят в силу только после перезапуска.
+func (c *Config) Update(next *Config) (updated *Config, applied []string, restart []string) {
+	updated = c.Clone()
+	incoming := next.Clone()
+	current := updated.settings()
+	for i, s := range incoming.settings() {
+		if reflect.DeepEqual(current[i].value.Interface(), s.value.Interface()) {
+			continue
+		}
+		if !s.reload {
+			restart = append(restart, s.key)
+			continue
+		}
+		current[i].value.Set(s.value)
+		applied = append(applied, s.key)
+	}
+	return updated, applied, restart
+}
@@ -0,0 +1,204 @@
+package config
+
+import "time"
+
+// Config - все настройки сервиса. Теги описывают каждую настройку: key - имя в файле настроек
+// и флаге (через точку с секцией, например --server.address), env - переменная окружения,
+// help - описание для --help, reload - настройка применяется без перезапуска (см. Update).
+// Порядок источников см. в Loader.
+type Config struct {
+	Server      Server      `key:"server"`
+	CORS        CORS        `key:"cors"`
+	RateLimit   RateLimit   `key:"rate_limit"`
+	Database    Database    `key:"database"`
+	Workflow    Workflow    `key:"workflow"`
+	Workspaces  Workspaces  `key:"workspaces"`
+	Log         Log         `key:"log"`
+	Tracing     Tracing     `key:"tracing"`
+	Metrics     Metrics     `key:"metrics"`
+	Auth        Auth        `key:"auth"`
+	JWT         JWT         `key:"jwt"`
+	Jobs        Jobs        `key:"jobs"`
+	Notify      Notify      `key:"notify"`
+	Reminders   Reminders   `key:"reminders"`
+	Webhooks    Webhooks    `key:"webhooks"`
+	Attachments Attachments `key:"attachments"`
+	Tasks       Tasks       `key:"tasks"`
+	Transfer    Transfer    `key:"transfer"`
+	CLI         CLI         `key:"cli"`
+}
+
+// Server - адрес и таймауты HTTP-сервера. Нулевой таймаут отключает ограничение: по умолчанию
+// так для чтения тела и записи ответа, иначе обрывались бы загрузки вложений и потоки событий.
+type Server struct {
+	Address           string        `key:"address" env:"SERVER_ADDRESS" help:"address to listen on"`
+	ReadTimeout       time.Duration `key:"read_timeout" env:"SERVER_READ_TIMEOUT" help:"maximum time to read a request including the body"`
+	ReadHeaderTimeout time.Duration `key:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" help:"maximum time to read request headers"`
+	WriteTimeout      time.Duration `key:"write_timeout" env:"SERVER_WRITE_TIMEOUT" help:"maximum time to write a response"`
+	IdleTimeout       time.Duration `key:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" help:"how long keep-alive connections stay idle"`
+	ShutdownDelay     time.Duration `key:"shutdown_delay" env:"SERVER_SHUTDOWN_DELAY" help:"how long /readyz reports not ready before the listener closes on shutdown"`
+	ShutdownTimeout   time.Duration `key:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" help:"how long to wait for active requests on shutdown"`
+	TLSCert           string        `key:"tls_cert" env:"TLS_CERT_FILE" help:"PEM certificate file, enables HTTPS" reload:"true"`
+	TLSKey            string        `key:"tls_key" env:"TLS_KEY_FILE" help:"PEM private key file of the certificate" reload:"true"`
+}
+
+// CORS - источники, которым браузер разрешает обращаться к API. * разрешает любой.
+type CORS struct {
+	Origins []string `key:"origins" env:"CORS_ORIGINS" help:"comma-separated allowed origins, empty disables CORS" reload:"true"`
+}
+
+// RateLimit - ограничение частоты запросов с одного адреса, 0 отключает ограничение.
+type RateLimit struct {
+	RPS   float64 `key:"rps" env:"RATE_LIMIT_RPS" help:"requests per second from one client address, 0 disables" reload:"true"`
+	Burst int     `key:"burst" env:"RATE_LIMIT_BURST" help:"requests allowed above the rate at once" reload:"true"`
+}
+
+// Database - файл SQLite и пул соединений.
+type Database struct {
+	Path         string        `key:"path" env:"FILEPATH" help:"SQLite database file"`
+	MaxOpenConns int           `key:"max_open_conns" env:"DB_MAX_OPEN_CONNS" help:"maximum open connections, 0 means unlimited"`
+	BusyTimeout  time.Duration `key:"busy_timeout" env:"DB_BUSY_TIMEOUT" help:"how long to wait for a locked database"`
+}
+
+// Workflow - статусы задач и переходы между ними (см. db.ParseWorkflow).
+type Workflow struct {
+	Transitions string `key:"transitions" env:"WORKFLOW_TRANSITIONS" help:"allowed transitions as from:to1,to2;from2:to3"`
+	Initial     string `key:"initial" env:"WORKFLOW_INITIAL" help:"status of new tasks"`
+	Done        string `key:"done" env:"WORKFLOW_DONE" help:"status set by completing a task"`
+	Closed      string `key:"closed" env:"WORKFLOW_CLOSED" help:"comma-separated statuses that are never overdue"`
+}
+
+type Log struct {
+	Format string `key:"format" env:"LOG_FORMAT" help:"log format: text or json"`
+	Level  string `key:"level" env:"LOG_LEVEL" help:"log level: debug, info, warn or error" reload:"true"`
+}
+
+type Tracing struct {
+	Exporter     string  `key:"exporter" env:"TRACE_EXPORTER" help:"span exporter: none, stdout, file or otlp"`
+	File         string  `key:"file" env:"TRACE_FILE" help:"JSON lines file for the file exporter"`
+	OTLPEndpoint string  `key:"otlp_endpoint" env:"TRACE_OTLP_ENDPOINT" help:"OTLP/HTTP collector address"`
+	OTLPHeaders  string  `key:"otlp_headers" env:"TRACE_OTLP_HEADERS" help:"extra OTLP headers as name=value,..."`
+	SampleRatio  float64 `key:"sample_ratio" env:"TRACE_SAMPLE_RATIO" help:"share of new traces to record, from 0 to 1"`
+	SampleParent bool    `key:"sample_parent" env:"TRACE_SAMPLE_PARENT" help:"follow the sampling decision of incoming traceparent"`
+}
+
+type Metrics struct {
+	Token string `key:"token" env:"METRICS_TOKEN" help:"bearer token required for GET /metrics"`
+}
+
+type Auth struct {
+	AdminUsername string        `key:"admin_username" env:"AUTH_ADMIN_USERNAME" help:"administrator created on startup"`
+	AdminPassword string        `key:"admin_password" env:"AUTH_ADMIN_PASSWORD" help:"password of the startup administrator"`
+	AllowSignup   bool          `key:"allow_signup" env:"AUTH_ALLOW_SIGNUP" help:"allow self-registration"`
+	SessionTTL    time.Duration `key:"session_ttl" env:"AUTH_SESSION_TTL" help:"session lifetime"`
+}
+
+// Jobs - период проверки просроченных задач, напоминаний и забытых таймеров.
+type Jobs struct {
+	Interval time.Duration `key:"interval" env:"JOBS_INTERVAL" help:"period of overdue, reminder and timer jobs" reload:"true"`
+}
+
+type Notify struct {
+	Notifiers  []string `key:"notifiers" env:"NOTIFIERS" help:"notification channels: log, webhook, smtp, file"`
+	Dir        string   `key:"dir" env:"NOTIFY_DIR" help:"directory of the file notifier"`
+	WebhookURL string   `key:"webhook_url" env:"NOTIFY_WEBHOOK_URL" help:"URL of the webhook notifier"`
+	SMTP       SMTP     `key:"smtp"`
+}
+
+type SMTP struct {
+	Addr     string   `key:"addr" env:"SMTP_ADDR" help:"SMTP server host:port"`
+	From     string   `key:"from" env:"SMTP_FROM" help:"sender address"`
+	To       []string `key:"to" env:"SMTP_TO" help:"comma-separated recipients"`
+	Username string   `key:"username" env:"SMTP_USERNAME" help:"SMTP user"`
+	Password string   `key:"password" env:"SMTP_PASSWORD" help:"SMTP password"`
+}
+
+type Reminders struct {
+	CatchUp       string        `key:"catchup" env:"REMINDER_CATCHUP" help:"missed reminders on startup: all, latest or skip"`
+	CatchUpWindow time.Duration `key:"catchup_window" env:"REMINDER_CATCHUP_WINDOW" help:"ignore reminders missed longer ago, 0 means no limit"`
+	MaxAttempts   int           `key:"max_attempts" env:"REMINDER_MAX_ATTEMPTS" help:"delivery attempts per channel"`
+}
+
+type Webhooks struct {
+	MaxAttempts  int           `key:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" help:"delivery attempts before a delivery is dead" reload:"true"`
+	Backoff      time.Duration `key:"backoff" env:"WEBHOOK_BACKOFF" help:"delay before the first retry" reload:"true"`
+	MaxBackoff   time.Duration `key:"max_backoff" env:"WEBHOOK_MAX_BACKOFF" help:"maximum delay between retries" reload:"true"`
+	PollInterval time.Duration `key:"poll_interval" env:"WEBHOOK_POLL_INTERVAL" help:"period of the delivery queue check" reload:"true"`
+	Timeout      time.Duration `key:"timeout" env:"WEBHOOK_TIMEOUT" help:"receiver response timeout" reload:"true"`
+}
+
+type Attachments struct {
+	Dir        string        `key:"dir" env:"ATTACHMENTS_DIR" help:"attachment content directory"`
+	MaxSize    Size          `key:"max_size" env:"ATTACHMENTS_MAX_SIZE" help:"maximum file size, e.g. 25M"`
+	Quota      Size          `key:"quota" env:"ATTACHMENTS_QUOTA" help:"default workspace quota, 0 means unlimited"`
+	GCInterval time.Duration `key:"gc_interval" env:"ATTACHMENTS_GC_INTERVAL" help:"period of unreferenced content cleanup"`
+	GCGrace    time.Duration `key:"gc_grace" env:"ATTACHMENTS_GC_GRACE" help:"how long unreferenced content is kept"`
+}
+
+type Tasks struct {
+	ChecklistAutoComplete bool `key:"checklist_auto_complete" env:"CHECKLIST_AUTO_COMPLETE" help:"complete a task when its checklist is done"`
+	TimerAutoStopHours    int  `key:"timer_auto_stop_hours" env:"TIMER_AUTO_STOP_HOURS" help:"stop timers running longer, 0 disables"`
+}
+
+// Transfer - выгрузка /export и загрузка /import. CSVColumns - колонки CSV в виде
+// заголовок=поле или просто поле (заголовок совпадает с полем); пусто - все поля задачи.
+type Transfer struct {
+	CSVColumns    []string `key:"csv_columns" env:"TRANSFER_CSV_COLUMNS" help:"comma-separated CSV columns as header=field, empty means all task fields"`
+	MaxImportSize Size     `key:"max_import_size" env:"TRANSFER_MAX_IMPORT_SIZE" help:"maximum request body of /import, e.g. 10M"`
+}
+
+// CLI - настройки команд lwo-go для работы с задачами. Пустой URL - команды открывают
+// database.path напрямую, иначе обращаются к серверу.
+type CLI struct {
+	URL       string `key:"url" env:"LWO_URL" help:"server URL for CLI commands, empty means the local database"`
+	Token     string `key:"token" env:"LWO_TOKEN" help:"bearer token or API key for the server"`
+	Workspace string `key:"workspace" env:"LWO_WORKSPACE" help:"workspace slug for CLI commands"`
+}
+
+// Defaults возвращает настройки по умолчанию.
+func Defaults() *Config {
+	return &Config{
+		Server: Server{
+			Address:           "localhost:8080",
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownDelay:     3 * time.Second,
+			ShutdownTimeout:   5 * time.Second,
+		},
+		RateLimit:  RateLimit{Burst: 20},
+		Database:   Database{Path: "tasks.db", BusyTimeout: 5 * time.Second},
+		Workspaces: Workspaces{Mode: WorkspaceModeColumn, Dir: "workspaces", MaxOpen: 16},
+		Log:        Log{Format: "text", Level: "info"},
+		Tracing: Tracing{
+			Exporter:     "none",
+			File:         "traces.jsonl",
+			OTLPEndpoint: "http://localhost:4318",
+			SampleRatio:  1,
+			SampleParent: true,
+		},
+		Auth: Auth{SessionTTL: 24 * time.Hour},
+		JWT:  JWT{ClockSkew: time.Minute, TTL: time.Hour, ReloadInterval: 30 * time.Second},
+		Jobs: Jobs{Interval: time.Minute},
+		Notify: Notify{
+			Notifiers: []string{"log"},
+			Dir:       "notifications",
+		},
+		Reminders: Reminders{CatchUp: "all", MaxAttempts: 5},
+		Webhooks: Webhooks{
+			MaxAttempts:  8,
+			Backoff:      10 * time.Second,
+			MaxBackoff:   time.Hour,
+			PollInterval: 5 * time.Second,
+			Timeout:      10 * time.Second,
+		},
+		Attachments: Attachments{
+			Dir:        "attachments",
+			MaxSize:    25 << 20,
+			Quota:      1 << 30,
+			GCInterval: time.Hour,
+			GCGrace:    time.Hour,
+		},
+		Tasks:    Tasks{TimerAutoStopHours: 12},
+		Transfer: Transfer{MaxImportSize: 10 << 20},
+	}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Validate проверяет настройки целиком и возвращает все найденные ошибки сразу.
+// В сообщении указаны ключ настройки и переменная окружения, чтобы было понятно, что исправлять.
+func (c *Config) Validate() error {
+	v := &validator{config: c}
+
+	v.check(c.Server.Address != "", "server.address", "must not be empty")
+	v.nonNegative("server.read_timeout", c.Server.ReadTimeout)
+	v.nonNegative("server.read_header_timeout", c.Server.ReadHeaderTimeout)
+	v.nonNegative("server.write_timeout", c.Server.WriteTimeout)
+	v.nonNegative("server.idle_timeout", c.Server.IdleTimeout)
+	v.nonNegative("server.shutdown_delay", c.Server.ShutdownDelay)
+	v.positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	v.check((c.Server.TLSCert == "") == (c.Server.TLSKey == ""), "server.tls_key", "server.tls_cert and server.tls_key must be set together")
+
+	v.check(c.RateLimit.RPS >= 0, "rate_limit.rps", "must not be negative")
+	v.check(c.RateLimit.Burst >= 1, "rate_limit.burst", "must be at least 1")
+
+	v.check(c.Database.Path != "", "database.path", "must not be empty")
+	v.check(c.Database.MaxOpenConns >= 0, "database.max_open_conns", "must not be negative")
+	v.nonNegative("database.busy_timeout", c.Database.BusyTimeout)
+
+	v.oneOf("workspaces.mode", c.Workspaces.Mode, WorkspaceModeColumn, WorkspaceModeFile)
+	v.check(c.Workspaces.MaxOpen >= 1, "workspaces.max_open", "must be at least 1")
+	c.Workspaces.Domain = strings.ToLower(c.Workspaces.Domain)
+
+	v.oneOf("log.format", c.Log.Format, "text", "json")
+	v.oneOf("log.level", strings.ToLower(c.Log.Level), "debug", "info", "warn", "error")
+
+	v.oneOf("tracing.exporter", c.Tracing.Exporter, "none", "stdout", "file", "otlp")
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be from 0 to 1")
+
+	v.positive("auth.session_ttl", c.Auth.SessionTTL)
+	v.check(c.Auth.AdminUsername == "" || c.Auth.AdminPassword != "", "auth.admin_password", "is required with auth.admin_username")
+
+	v.check(c.JWT.SigningKey == "" || c.JWT.KeysDir != "", "jwt.signing_key", "requires jwt.keys_dir")
+	v.nonNegative("jwt.clock_skew", c.JWT.ClockSkew)
+	v.positive("jwt.ttl", c.JWT.TTL)
+	v.positive("jwt.reload_interval", c.JWT.ReloadInterval)
+
+	v.positive("jobs.interval", c.Jobs.Interval)
+
+	for _, name := range c.Notify.Notifiers {
+		v.oneOf("notify.notifiers", name, "log", "webhook", "smtp", "file")
+		switch name {
+		case "webhook":
+			v.check(c.Notify.WebhookURL != "", "notify.webhook_url", "is required for the webhook notifier")
+		case "smtp":
+			smtp := c.Notify.SMTP
+			v.check(smtp.Addr != "" && smtp.From != "" && len(smtp.To) > 0, "notify.smtp",
+				"addr, from and to are required for the smtp notifier")
+		}
+	}
+
+	v.oneOf("reminders.catchup", c.Reminders.CatchUp, "all", "latest", "skip")
+	v.nonNegative("reminders.catchup_window", c.Reminders.CatchUpWindow)
+	v.check(c.Reminders.MaxAttempts >= 1, "reminders.max_attempts", "must be at least 1")
+
+	v.check(c.Webhooks.MaxAttempts >= 1, "webhooks.max_attempts", "must be at least 1")
+	v.positive("webhooks.backoff", c.Webhooks.Backoff)
+	v.positive("webhooks.max_backoff", c.Webhooks.MaxBackoff)
+	v.positive("webhooks.poll_interval", c.Webhooks.PollInterval)
+	v.positive("webhooks.timeout", c.Webhooks.Timeout)
+
+	v.check(c.Attachments.Dir != "", "attachments.dir", "must not be empty")
+	v.check(c.Attachments.MaxSize > 0, "attachments.max_size", "must be positive")
+	v.positive("attachments.gc_interval", c.Attachments.GCInterval)
+	v.positive("attachments.gc_grace", c.Attachments.GCGrace)
+	v.check(c.Transfer.MaxImportSize > 0, "transfer.max_import_size", "must be positive")
+
+	v.check(c.Tasks.TimerAutoStopHours >= 0, "tasks.timer_auto_stop_hours", "must not be negative")
+
+	if c.CLI.URL != "" {
+		target, err := url.Parse(c.CLI.URL)
+		v.check(err == nil && (target.Scheme == "http" || target.Scheme == "https") && target.Host != "", "cli.url", "expected an http or https URL")
+	}
+
+	return errors.Join(v.errs...)
+}
+
+type validator struct {
+	config *Config
+	errs   []error
+}
+
+func (v *validator) check(ok bool, key string, message string) {
+	if ok {
+		return
+	}
+	name := key
+	if s, found := v.config.lookup(key); found {
+		name = fmt.Sprintf("%s (%s = %q)", key, s.env, s.redacted())
+	}
+	v.errs = append(v.errs, fmt.Errorf("invalid %s: %s", name, message))
+}
+
+func (v *validator) positive(key string, value time.Duration) {
+	v.check(value > 0, key, "must be positive")
+}
+
+func (v *validator) nonNegative(key string, value time.Duration) {
+	v.check(value >= 0, key, "must not be negative")
+}
+
+func (v *validator) oneOf(key string, value string, allowed ...string) {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	v.check(false, key, fmt.Sprintf("unknown value %q, expected %s", value, strings.Join(allowed, ", ")))
+}
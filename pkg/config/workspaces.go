@@ -0,0 +1,17 @@
+package config
+
+const (
+	WorkspaceModeColumn = "column"
+	WorkspaceModeFile   = "file"
+)
+
+// Workspaces - настройки изоляции рабочих пространств. В режиме column данные всех
+// пространств лежат в одной базе с колонкой workspace_id, в режиме file у каждого
+// пространства свой файл SQLite в Dir, открытыми держатся не больше MaxOpen файлов.
+// Domain - базовый домен для поддоменов пространств.
+type Workspaces struct {
+	Mode    string `key:"mode" env:"WORKSPACE_MODE" help:"workspace isolation: column or file"`
+	Dir     string `key:"dir" env:"WORKSPACE_DIR" help:"directory of workspace databases in file mode"`
+	MaxOpen int    `key:"max_open" env:"WORKSPACE_MAX_OPEN" help:"maximum open workspace databases"`
+	Domain  string `key:"domain" env:"WORKSPACE_DOMAIN" help:"base domain for workspace subdomains"`
+}
@@ -0,0 +1,249 @@
+// Package jwt - проверка и выпуск JSON Web Token (RFC 7519) с алгоритмами HS256, RS256 и EdDSA.
+// Ключи загружаются из каталога и перечитываются при изменении файлов, публичные ключи
+// отдаются в формате JWKS (RFC 7517).
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	ErrMalformed    = errors.New("jwt: malformed token")
+	ErrUnknownKey   = errors.New("jwt: unknown signing key")
+	ErrSignature    = errors.New("jwt: invalid signature")
+	ErrExpired      = errors.New("jwt: token is expired")
+	ErrNotYetValid  = errors.New("jwt: token is not valid yet")
+	ErrIssuer       = errors.New("jwt: unexpected issuer")
+	ErrAudience     = errors.New("jwt: unexpected audience")
+	ErrNoSigningKey = errors.New("jwt: signing key is not configured")
+)
+
+// Audience - claim aud, который по RFC может быть строкой или массивом строк.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// Claims - зарегистрированные claims и те, что использует сервис: role, scope
+// (права через пробел) и preferred_username.
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+	Username  string   `json:"preferred_username,omitempty"`
+	Role      string   `json:"role,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	Workspace string   `json:"workspace,omitempty"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// Key - ключ подписи. У HS256 есть только секрет, у RS256 и EdDSA - публичный ключ
+// и, если ключ свой, приватный.
+type Key struct {
+	ID      string
+	Alg     string
+	secret  []byte
+	public  crypto.PublicKey
+	private crypto.Signer
+}
+
+// CanSign сообщает, может ли сервер подписывать этим ключом.
+func (k *Key) CanSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+func (k *Key) sign(input []byte) ([]byte, error) {
+	switch k.Alg {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case RS256:
+		digest := sha256.Sum256(input)
+		return k.private.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case EdDSA:
+		return k.private.Sign(rand.Reader, input, crypto.Hash(0))
+	}
+	return nil, fmt.Errorf("jwt: unsupported algorithm %q", k.Alg)
+}
+
+func (k *Key) verify(input []byte, signature []byte) bool {
+	switch k.Alg {
+	case HS256:
+		expected, _ := k.sign(input)
+		return subtle.ConstantTimeCompare(expected, signature) == 1
+	case RS256:
+		digest := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(k.public.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case EdDSA:
+		return ed25519.Verify(k.public.(ed25519.PublicKey), input, signature)
+	}
+	return false
+}
+
+var encoding = base64.RawURLEncoding
+
+// Sign подписывает claims ключом набора, выбранным для выпуска токенов.
+func (s *KeySet) Sign(claims *Claims) (string, error) {
+	key := s.SigningKey()
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+
+	head, err := json.Marshal(header{Alg: key.Alg, Typ: "JWT", Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	input := encoding.EncodeToString(head) + "." + encoding.EncodeToString(body)
+	signature, err := key.sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+
+	return input + "." + encoding.EncodeToString(signature), nil
+}
+
+// Validator проверяет подпись и claims токена. Пустые Issuer и Audience не проверяются,
+// exp обязателен. Skew - допустимое расхождение часов с выпустившим токен сервисом.
+type Validator struct {
+	Keys     *KeySet
+	Issuer   string
+	Audience string
+	Skew     time.Duration
+	Now      func() time.Time
+}
+
+// LooksLikeJWT отличает JWT от других bearer-токенов по структуре из трех частей.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func (v *Validator) Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var head header
+	if err := decodePart(parts[0], &head); err != nil {
+		return nil, err
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	// Алгоритм берется из ключа, а не из токена: заголовок только выбирает ключ,
+	// поэтому токен не может подменить RS256 на HS256 с публичным ключом в роли секрета
+	keys := v.Keys.candidates(head.Kid, head.Alg)
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+
+	input := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if key.verify(input, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrSignature
+	}
+
+	var claims Claims
+	if err := decodePart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if err := v.validate(&claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (v *Validator) validate(claims *Claims) error {
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	current := now()
+
+	if claims.ExpiresAt == 0 || current.Add(-v.Skew).Unix() >= claims.ExpiresAt {
+		return ErrExpired
+	}
+	if claims.NotBefore != 0 && current.Add(v.Skew).Unix() < claims.NotBefore {
+		return ErrNotYetValid
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return ErrIssuer
+	}
+	if v.Audience != "" && !slices.Contains(claims.Audience, v.Audience) {
+		return ErrAudience
+	}
+	return nil
+}
+
+func decodePart(part string, target any) error {
+	data, err := encoding.DecodeString(part)
+	if err != nil {
+		return ErrMalformed
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minSecretLength = 32
+	minRSABits      = 2048
+)
+
+// KeySet - набор ключей из каталога. Файл <kid>.pem содержит приватный или публичный
+// RSA/Ed25519 ключ, файл <kid>.secret - секрет HS256. Остальные файлы игнорируются.
+// Чужие публичные ключи можно положить рядом, чтобы принимать токены других сервисов.
+type KeySet struct {
+	dir        string
+	signingKID string
+
+	mu    sync.RWMutex
+	keys  map[string]*Key
+	stamp string
+}
+
+// LoadDir читает ключи из dir. signingKID выбирает ключ для выпуска токенов,
+// пустое значение означает, что сервер токены не выпускает.
+func LoadDir(dir string, signingKID string) (*KeySet, error) {
+	s := &KeySet{dir: dir, signingKID: signingKID}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewKeySet собирает набор из готовых ключей, без каталога.
+func NewKeySet(signingKID string, keys ...*Key) *KeySet {
+	s := &KeySet{signingKID: signingKID, keys: make(map[string]*Key)}
+	for _, key := range keys {
+		s.keys[key.ID] = key
+	}
+	return s
+}
+
+// Reload перечитывает каталог, если файлы изменились. При ошибке остается прежний набор,
+// поэтому недописанный при ротации файл не ломает проверку токенов.
+func (s *KeySet) Reload() (bool, error) {
+	if s.dir == "" {
+		return false, nil
+	}
+
+	stamp, err := dirStamp(s.dir)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	unchanged := stamp == s.stamp
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	keys, err := loadKeys(s.dir)
+	if err != nil {
+		return false, err
+	}
+	if s.signingKID != "" {
+		if key, ok := keys[s.signingKID]; !ok || !key.CanSign() {
+			return false, fmt.Errorf("jwt: signing key %q not found or has no private part", s.signingKID)
+		}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.stamp = stamp
+	s.mu.Unlock()
+	return true, nil
+}
+
+// Watch перечитывает каталог каждые interval до отмены ctx.
+func (s *KeySet) Watch(ctx context.Context, interval time.Duration, onReload func(changed bool, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := s.Reload()
+			if onReload != nil {
+				onReload(changed, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *KeySet) SigningKey() *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.signingKID == "" {
+		return nil
+	}
+	return s.keys[s.signingKID]
+}
+
+// Keys возвращает загруженные ключи, упорядоченные по идентификатору.
+func (s *KeySet) Keys() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+func (s *KeySet) KeyIDs() []string {
+	var ids []string
+	for _, key := range s.Keys() {
+		ids = append(ids, key.ID)
+	}
+	return ids
+}
+
+func (s *KeySet) candidates(kid string, alg string) []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid != "" {
+		if key, ok := s.keys[kid]; ok && key.Alg == alg {
+			return []*Key{key}
+		}
+		return nil
+	}
+
+	var keys []*Key
+	for _, key := range s.keys {
+		if key.Alg == alg {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// JWK - публичный ключ в формате RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает публичные части собственных асимметричных ключей. Во время ротации
+// сюда попадают и старые ключи, пока их файлы лежат в каталоге, - так уже выданные
+// токены продолжают проверяться. Секреты HS256 и чужие публичные ключи не публикуются.
+func (s *KeySet) JWKS() JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys {
+		if key.private == nil {
+			continue
+		}
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Alg}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encoding.EncodeToString(public.N.Bytes())
+			jwk.E = encoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = encoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+func dirStamp(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+func loadKeys(dir string) (map[string]*Key, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*Key)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".pem" && ext != ".secret") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(entry.Name(), ext)
+		var key *Key
+		if ext == ".secret" {
+			key, err = NewHMACKey(kid, []byte(strings.TrimSpace(string(data))))
+		} else {
+			key, err = ParsePEM(kid, data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		keys[kid] = key
+	}
+
+	return keys, nil
+}
+
+func NewHMACKey(kid string, secret []byte) (*Key, error) {
+	if len(secret) < minSecretLength {
+		return nil, fmt.Errorf("jwt: HS256 secret must be at least %d bytes", minSecretLength)
+	}
+	return &Key{ID: kid, Alg: HS256, secret: secret}, nil
+}
+
+// NewSigningKey оборачивает приватный RSA или Ed25519 ключ.
+func NewSigningKey(kid string, private any) (*Key, error) {
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("jwt: RSA key must be at least %d bits", minRSABits)
+		}
+		return &Key{ID: kid, Alg: RS256, public: &private.PublicKey, private: private}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: kid, Alg: EdDSA, public: private.Public(), private: private}, nil
+	}
+	return nil, fmt.Errorf("jwt: unsupported private key type %T", private)
+}
+
+// NewVerifyKey оборачивает публичный RSA или Ed25519 ключ.
+func NewVerifyKey(kid string, public any) (*Key, error) {
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("jwt: RSA key must be at least %d bits", minRSABits)
+		}
+		return &Key{ID: kid, Alg: RS256, public: public}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: kid, Alg: EdDSA, public: public}, nil
+	}
+	return nil, fmt.Errorf("jwt: unsupported public key type %T", public)
+}
+
+// ParsePEM разбирает PKCS#8/PKCS#1 приватный или PKIX/PKCS#1 публичный ключ.
+func ParsePEM(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(kid, private)
+	case "RSA PRIVATE KEY":
+		private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(kid, private)
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewVerifyKey(kid, public)
+	case "RSA PUBLIC KEY":
+		public, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewVerifyKey(kid, public)
+	}
+	return nil, fmt.Errorf("jwt: unsupported PEM block %q", block.Type)
+}
@@ -0,0 +1,149 @@
+// Package markdown переводит в HTML безопасное подмножество Markdown для комментариев:
+// абзацы, заголовки, списки, цитаты, блоки и фрагменты кода, жирный и курсив, ссылки http(s)
+// и mailto. Весь исходный HTML экранируется.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	listPattern    = regexp.MustCompile(`^\s*[-*]\s+(.*)$`)
+	boldPattern    = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	italicPattern  = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	linkPattern    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s*]+)\)`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@(\w[\w.-]*)`)
+)
+
+// Render возвращает HTML для src.
+func Render(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	var out strings.Builder
+	var paragraph, quote []string
+	inList := false
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + strings.Join(paragraph, "<br>\n") + "</p>\n")
+			paragraph = nil
+		}
+		if len(quote) > 0 {
+			out.WriteString("<blockquote><p>" + strings.Join(quote, "<br>\n") + "</p></blockquote>\n")
+			quote = nil
+		}
+		if inList {
+			out.WriteString("</ul>\n")
+			inList = false
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			flush()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, html.EscapeString(lines[i]))
+			}
+			out.WriteString("<pre><code>" + strings.Join(code, "\n") + "</code></pre>\n")
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flush()
+		case headingPattern.MatchString(trimmed):
+			flush()
+			match := headingPattern.FindStringSubmatch(trimmed)
+			level := strconv.Itoa(len(match[1]))
+			out.WriteString("<h" + level + ">" + inline(match[2]) + "</h" + level + ">\n")
+		case listPattern.MatchString(line):
+			if !inList {
+				flush()
+				out.WriteString("<ul>\n")
+				inList = true
+			}
+			out.WriteString("<li>" + inline(listPattern.FindStringSubmatch(line)[1]) + "</li>\n")
+		case strings.HasPrefix(trimmed, ">"):
+			if len(quote) == 0 {
+				flush()
+			}
+			quote = append(quote, inline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))))
+		default:
+			if inList || len(quote) > 0 {
+				flush()
+			}
+			paragraph = append(paragraph, inline(trimmed))
+		}
+	}
+	flush()
+
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// inline обрабатывает разметку внутри строки. Фрагменты в `...` выводятся как есть.
+func inline(text string) string {
+	parts := strings.Split(text, "`")
+	var out strings.Builder
+	for i, part := range parts {
+		switch {
+		case i%2 == 1 && i < len(parts)-1:
+			out.WriteString("<code>" + html.EscapeString(part) + "</code>")
+		case i%2 == 1:
+			// Непарная обратная кавычка остается текстом
+			out.WriteString("`" + emphasis(part))
+		default:
+			out.WriteString(emphasis(part))
+		}
+	}
+	return out.String()
+}
+
+func emphasis(text string) string {
+	text = html.EscapeString(text)
+	text = boldPattern.ReplaceAllString(text, "<strong>$1</strong>")
+	text = italicPattern.ReplaceAllString(text, "<em>$1</em>")
+	return linkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := linkPattern.FindStringSubmatch(match)
+		url := strings.ToLower(html.UnescapeString(parts[2]))
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "mailto:") {
+			return match
+		}
+		return `<a href="` + parts[2] + `" rel="nofollow noopener">` + parts[1] + "</a>"
+	})
+}
+
+// Mentions возвращает имена из упоминаний @name вне кода, без повторов, в порядке появления.
+func Mentions(src string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	inCode := false
+	for _, line := range strings.Split(src, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+
+		parts := strings.Split(line, "`")
+		for i := 0; i < len(parts); i += 2 {
+			for _, match := range mentionPattern.FindAllStringSubmatch(parts[i], -1) {
+				name := strings.TrimRight(match[1], ".-")
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return names
+}
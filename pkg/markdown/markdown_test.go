@@ -0,0 +1,39 @@
+package markdown
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"hello\nworld", "<p>hello<br>\nworld</p>"},
+		{"**bold** and *em*", "<p><strong>bold</strong> and <em>em</em></p>"},
+		{"<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{"[docs](https://example.com/a?b=1&c=2)", `<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener">docs</a></p>`},
+		{"[x](javascript:alert(1))", "<p>[x](javascript:alert(1))</p>"},
+		{`[x](https://e.com/"onclick=")`, `<p><a href="https://e.com/&#34;onclick=&#34;" rel="nofollow noopener">x</a></p>`},
+		{"use `a**b**`", "<p>use <code>a**b**</code></p>"},
+		{"## Title", "<h2>Title</h2>"},
+		{"- one\n- two\n\nafter", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<p>after</p>"},
+		{"> quoted", "<blockquote><p>quoted</p></blockquote>"},
+		{"```\n<b>x</b>\n```", "<pre><code>&lt;b&gt;x&lt;/b&gt;</code></pre>"},
+	}
+
+	for _, test := range tests {
+		if got := Render(test.src); got != test.want {
+			t.Errorf("Render(%q):\n got %q\nwant %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	src := "@alice please ask @bob.smith. Not mail@example.com, not `@code`\n```\n@block\n```\n@alice again"
+	want := []string{"alice", "bob.smith"}
+	if got := Mentions(src); !slices.Equal(got, want) {
+		t.Errorf("Mentions: got %v, want %v", got, want)
+	}
+}
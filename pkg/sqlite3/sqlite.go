@@ -1,22 +1,43 @@
 package sqlite3
 
 import (
+	"context"
 	"database/sql"
-	"os"
+	"strconv"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type Sqlite struct {
 	conn *sql.DB
+	path string
 }
 
-func ConnectorInit() (*Sqlite, error) {
-	filepath := os.Getenv("FILEPATH")
+// Options - настройки пула: MaxOpenConns 0 снимает ограничение, BusyTimeout задает,
+// сколько ждать заблокированную другим соединением базу.
+type Options struct {
+	MaxOpenConns int
+	BusyTimeout  time.Duration
+}
 
-	connector, err := newSqliteConnector(filepath)
+// Open открывает базу filepath с настройками options.
+func Open(filepath string, options Options) (*Sqlite, error) {
+	connector := &Sqlite{}
+	dsn := filepath
+	if options.BusyTimeout > 0 {
+		dsn += "?_busy_timeout=" + strconv.FormatInt(options.BusyTimeout.Milliseconds(), 10)
+	}
+	if _, err := connector.open(dsn, filepath); err != nil {
+		return nil, err
+	}
+	connector.conn.SetMaxOpenConns(options.MaxOpenConns)
+	return connector, nil
+}
 
-	return connector, err
+// NewConnector открывает базу по явно указанному пути, например во временном каталоге тестов.
+func NewConnector(filepath string) (*Sqlite, error) {
+	return newSqliteConnector(filepath)
 }
 
 func newSqliteConnector(filepath string) (*Sqlite, error) {
@@ -29,15 +50,21 @@ func newSqliteConnector(filepath string) (*Sqlite, error) {
 }
 
 func (p *Sqlite) setConn(filepath string) (*Sqlite, error) {
+	return p.open(filepath, filepath)
+}
+
+// open подключается по dsn - пути с параметрами драйвера; path запоминается для Path.
+func (p *Sqlite) open(dsn string, filepath string) (*Sqlite, error) {
 	if p.conn != nil {
 		return p, nil
 	}
 
-	dbConn, err := sql.Open("sqlite3", filepath)
+	dbConn, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, err
 	}
 	p.conn = dbConn
+	p.path = filepath
 
 	if err := p.conn.Ping(); err != nil {
 		return nil, err
@@ -57,3 +84,26 @@ func (p *Sqlite) QueryRow(query string, args ...any) *sql.Row {
 func (p *Sqlite) Exec(query string, args ...any) (sql.Result, error) {
 	return p.conn.Exec(query, args...)
 }
+
+// BeginTx начинает транзакцию на одном из соединений пула.
+func (p *Sqlite) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return p.conn.BeginTx(ctx, opts)
+}
+
+// Path возвращает путь, с которым открыта база.
+func (p *Sqlite) Path() string {
+	return p.path
+}
+
+func (p *Sqlite) Ping() error {
+	return p.conn.Ping()
+}
+
+// Stats возвращает статистику пула соединений database/sql.
+func (p *Sqlite) Stats() sql.DBStats {
+	return p.conn.Stats()
+}
+
+func (p *Sqlite) Close() error {
+	return p.conn.Close()
+}
@@ -0,0 +1,285 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pair возвращает серверное и клиентское соединения поверх TCP на localhost. Сырые кадры
+// пишутся прямо в client.conn, чтобы проверить разбор того, что Conn сам не отправит.
+func pair(t *testing.T) (*Conn, *Conn) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := listener.Accept()
+		accepted <- conn
+	}()
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConn := <-accepted
+	if serverConn == nil {
+		t.Fatal("accept failed")
+	}
+
+	server := newConn(serverConn, bufio.NewReader(serverConn), false)
+	client := newConn(clientConn, bufio.NewReader(clientConn), true)
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	server.SetReadDeadline(deadline)
+	client.SetReadDeadline(deadline)
+	return server, client
+}
+
+// frame собирает кадр; masked маскирует его, как клиент.
+func frame(fin bool, opcode int, payload []byte, masked bool) []byte {
+	first := byte(opcode)
+	if fin {
+		first |= 0x80
+	}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	data := []byte{first}
+	switch {
+	case len(payload) < 126:
+		data = append(data, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		data = append(data, maskBit|126)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(payload)))
+	default:
+		data = append(data, maskBit|127)
+		data = binary.BigEndian.AppendUint64(data, uint64(len(payload)))
+	}
+	if !masked {
+		return append(data, payload...)
+	}
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	data = append(data, mask[:]...)
+	for i, b := range payload {
+		data = append(data, b^mask[i%4])
+	}
+	return data
+}
+
+func write(t *testing.T, conn *Conn, frames ...[]byte) {
+	t.Helper()
+	for _, data := range frames {
+		if _, err := conn.conn.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// expectClose проверяет, что собеседник закрыл соединение с кодом code.
+func expectClose(t *testing.T, conn *Conn, code int) {
+	t.Helper()
+	_, _, err := conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != code {
+		t.Errorf("got %v, want close %d", err, code)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			opcode, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(opcode, append([]byte("echo: "), data...), time.Now().Add(time.Second))
+		}
+	}))
+	defer server.Close()
+
+	conn, err := Dial(strings.Replace(server.URL, "http://", "ws://", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	large := strings.Repeat("x", 70000)
+	for _, message := range []string{"hello", large} {
+		if err := conn.WriteMessage(TextMessage, []byte(message), time.Now().Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		opcode, data, err := conn.ReadMessage()
+		if err != nil || opcode != TextMessage || string(data) != "echo: "+message {
+			t.Fatalf("echo of %d bytes: %d %d bytes %v", len(message), opcode, len(data), err)
+		}
+	}
+	conn.WriteClose(CloseNormal, "")
+	expectClose(t, conn, CloseNormal)
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired || resp.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("plain GET: %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestMasking(t *testing.T) {
+	server, client := pair(t)
+
+	// Клиент маскирует кадры, сервер - нет
+	if err := client.WriteMessage(BinaryMessage, []byte{1, 2, 3}, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	raw := make([]byte, 2+4+3)
+	if _, err := io.ReadFull(server.br, raw); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		raw[6+i] ^= raw[2+i%4]
+	}
+	if raw[1] != 0x80|3 || string(raw[6:]) != "\x01\x02\x03" {
+		t.Errorf("client frame: %x", raw)
+	}
+
+	// Немаскированный кадр от клиента - нарушение протокола
+	write(t, client, frame(true, TextMessage, []byte("plain"), false))
+	if _, _, err := server.ReadMessage(); !errors.Is(err, ErrProtocol) {
+		t.Errorf("unmasked frame: %v", err)
+	}
+	expectClose(t, client, CloseProtocolError)
+}
+
+func TestMaskedFromServer(t *testing.T) {
+	server, client := pair(t)
+	write(t, server, frame(true, TextMessage, []byte("masked"), true))
+	if _, _, err := client.ReadMessage(); !errors.Is(err, ErrProtocol) {
+		t.Errorf("masked frame from server: %v", err)
+	}
+}
+
+func TestFragmentation(t *testing.T) {
+	server, client := pair(t)
+
+	// Управляющий кадр может прийти между фрагментами; ping получает pong
+	write(t, client,
+		frame(false, TextMessage, []byte("Hel"), true),
+		frame(true, PingMessage, []byte("p"), true),
+		frame(false, ContinuationMessage, []byte("lo, "), true),
+		frame(true, ContinuationMessage, []byte("world"), true),
+	)
+	opcode, data, err := server.ReadMessage()
+	if err != nil || opcode != TextMessage || string(data) != "Hello, world" {
+		t.Fatalf("fragmented message: %d %q %v", opcode, data, err)
+	}
+	pong := make(chan string, 1)
+	client.SetPongHandler(func(data []byte) { pong <- string(data) })
+	write(t, server, frame(true, TextMessage, []byte("done"), false))
+	if _, data, err := client.ReadMessage(); err != nil || string(data) != "done" {
+		t.Fatalf("after pong: %q %v", data, err)
+	}
+	if got := <-pong; got != "p" {
+		t.Errorf("pong payload %q", got)
+	}
+
+	// Продолжение без начала и новое сообщение до конца прошлого - ошибки протокола
+	write(t, client, frame(true, ContinuationMessage, []byte("x"), true))
+	if _, _, err := server.ReadMessage(); !errors.Is(err, ErrProtocol) {
+		t.Errorf("continuation without start: %v", err)
+	}
+
+	server, client = pair(t)
+	write(t, client, frame(false, TextMessage, []byte("a"), true), frame(true, TextMessage, []byte("b"), true))
+	if _, _, err := server.ReadMessage(); !errors.Is(err, ErrProtocol) {
+		t.Errorf("interleaved message: %v", err)
+	}
+
+	server, client = pair(t)
+	write(t, client, frame(false, PingMessage, nil, true))
+	if _, _, err := server.ReadMessage(); !errors.Is(err, ErrProtocol) {
+		t.Errorf("fragmented ping: %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	server, client := pair(t)
+
+	if err := client.WriteClose(CloseGoingAway, "bye"); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := server.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Text != "bye" {
+		t.Fatalf("server got %v", err)
+	}
+	// Сервер отвечает кадром закрытия, после него запись невозможна
+	expectClose(t, client, CloseGoingAway)
+	if err := server.WriteMessage(TextMessage, []byte("late"), time.Now().Add(time.Second)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("write after close: %v", err)
+	}
+	if err := server.WriteClose(CloseNormal, ""); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+
+	// Кадр закрытия без кода
+	server, client = pair(t)
+	write(t, client, frame(true, CloseMessage, nil, true))
+	if _, _, err := server.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != CloseNoStatus {
+		t.Errorf("empty close: %v", err)
+	}
+}
+
+func TestOversizedFrames(t *testing.T) {
+	server, client := pair(t)
+	server.SetReadLimit(10)
+	write(t, client, frame(true, TextMessage, []byte("0123456789a"), true))
+	if _, _, err := server.ReadMessage(); !errors.Is(err, ErrMessageTooBig) {
+		t.Errorf("large frame: %v", err)
+	}
+	expectClose(t, client, CloseMessageTooBig)
+
+	// Предел считается по всему сообщению, а не по кадру
+	server, client = pair(t)
+	server.SetReadLimit(10)
+	write(t, client, frame(false, TextMessage, []byte("012345"), true), frame(true, ContinuationMessage, []byte("6789a"), true))
+	if _, _, err := server.ReadMessage(); !errors.Is(err, ErrMessageTooBig) {
+		t.Errorf("large message: %v", err)
+	}
+
+	// Длина с установленным старшим битом не должна уронить сервер
+	server, client = pair(t)
+	header := binary.BigEndian.AppendUint64([]byte{0x80 | TextMessage, 0x80 | 127}, 1<<63|5)
+	write(t, client, append(header, 0, 0, 0, 0))
+	if _, _, err := server.ReadMessage(); !errors.Is(err, ErrProtocol) {
+		t.Errorf("negative length: %v", err)
+	}
+	expectClose(t, client, CloseProtocolError)
+
+	// Управляющие кадры не длиннее 125 байт
+	server, client = pair(t)
+	write(t, client, frame(true, PingMessage, make([]byte, 126), true))
+	if _, _, err := server.ReadMessage(); !errors.Is(err, ErrProtocol) {
+		t.Errorf("long ping: %v", err)
+	}
+}